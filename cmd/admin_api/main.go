@@ -21,6 +21,7 @@ import (
 	"github.com/moonmoon1919/go-api-reference/internal/config"
 	"github.com/moonmoon1919/go-api-reference/internal/healthservice"
 	"github.com/moonmoon1919/go-api-reference/internal/middleware"
+	"github.com/moonmoon1919/go-api-reference/internal/requests"
 	"github.com/moonmoon1919/go-api-reference/internal/server"
 	"github.com/moonmoon1919/go-api-reference/internal/store"
 	"github.com/moonmoon1919/go-api-reference/pkg/events"
@@ -31,14 +32,17 @@ var (
 	userMiddleware          = middleware.InsertRequestingUser
 	errorHandlingMiddleware = middleware.ErrorHandlingMiddleware
 	loggingMiddleware       = middleware.LoggingMiddleware
+	requestIdMiddleware     = middleware.RequestIdMiddleware
 
 	// Pool validator middleware
 	auditLogReadPermissions  = middleware.PermissionValidationMiddleware(middleware.NewHas("admin::auditlog::read"))
 	exampleReadPermissions   = middleware.PermissionValidationMiddleware(middleware.NewHas("admin::example::read"))
+	exampleUpdatePermissions = middleware.PermissionValidationMiddleware(middleware.NewHas("admin::example::update"))
 	exampleDeletePermissions = middleware.PermissionValidationMiddleware(middleware.NewHas("admin::example::delete"))
 	userReadPermissions      = middleware.PermissionValidationMiddleware(middleware.NewHas("admin::user::read"))
 	userCreatePermissions    = middleware.PermissionValidationMiddleware(middleware.NewHas("admin::user::create"))
 	userDeletePermissions    = middleware.PermissionValidationMiddleware(middleware.NewHas("admin::user::delete"))
+	userUpdatePermissions    = middleware.PermissionValidationMiddleware(middleware.NewHas("admin::user::update"))
 
 	// Logging
 	logger     *slog.Logger
@@ -47,30 +51,40 @@ var (
 
 // END MOVE
 type routerControllers struct {
-	admin *adminservice.Controller
+	admin  *adminservice.Controller
+	health *healthservice.HealthController
 }
 
 func buildRoutes(controllers routerControllers, profiling bool) *http.ServeMux {
 	router := http.NewServeMux()
 
-	hc := healthservice.HealthController{}
-	router.HandleFunc("GET /health", hc.Get)
+	router.HandleFunc("GET /health", controllers.health.Get)
+
+	handle := adminservice.Handle
 
 	// Example routes
 	adminRouter := http.NewServeMux()
-	adminRouter.Handle("GET /users/{id}/examples", userMiddleware(exampleReadPermissions(controllers.admin.GetExamplesForUser)))
-	adminRouter.Handle("GET /examples/{id}", userMiddleware(exampleReadPermissions(controllers.admin.GetExample)))
-	adminRouter.Handle("DELETE /examples/{id}", userMiddleware(exampleDeletePermissions(controllers.admin.DeleteExample)))
+	adminRouter.Handle("GET /users/{id}/examples", userMiddleware(exampleReadPermissions(handle(controllers.admin.GetExamplesForUser))))
+	adminRouter.Handle("GET /examples/{id}", userMiddleware(exampleReadPermissions(handle(controllers.admin.GetExample))))
+	adminRouter.Handle("DELETE /examples/{id}", userMiddleware(exampleDeletePermissions(handle(controllers.admin.DeleteExample))))
+	adminRouter.Handle("GET /examples/{id}/revisions", userMiddleware(exampleReadPermissions(handle(controllers.admin.ListRevisions))))
+	adminRouter.Handle("GET /examples/{id}/revisions/{rev}", userMiddleware(exampleReadPermissions(handle(controllers.admin.GetRevision))))
+	adminRouter.Handle("POST /examples/{id}/revisions/{rev}/revert", userMiddleware(exampleUpdatePermissions(handle(controllers.admin.RevertExample))))
+	adminRouter.Handle("GET /trash", userMiddleware(exampleReadPermissions(handle(controllers.admin.ListTrash))))
+	adminRouter.Handle("DELETE /trash/{id}", userMiddleware(exampleDeletePermissions(handle(controllers.admin.PurgeExample))))
 
 	// User routes
-	adminRouter.Handle("POST /users", userMiddleware(userCreatePermissions(controllers.admin.AddUser)))
-	adminRouter.Handle("GET /users", userMiddleware(userReadPermissions(controllers.admin.ListUsers)))
-	adminRouter.Handle("GET /users/{id}", userMiddleware(userReadPermissions(controllers.admin.GetUser)))
-	adminRouter.Handle("DELETE /users/{id}", userMiddleware(userDeletePermissions(controllers.admin.DeleteUser)))
+	adminRouter.Handle("POST /users", userMiddleware(userCreatePermissions(handle(controllers.admin.AddUser))))
+	adminRouter.Handle("GET /users", userMiddleware(userReadPermissions(handle(controllers.admin.ListUsers))))
+	adminRouter.Handle("GET /users/{id}", userMiddleware(userReadPermissions(handle(controllers.admin.GetUser))))
+	adminRouter.Handle("DELETE /users/{id}", userMiddleware(userDeletePermissions(handle(controllers.admin.DeleteUser))))
+	adminRouter.Handle("PUT /users/{id}/quota", userMiddleware(userUpdatePermissions(handle(controllers.admin.SetQuota))))
+	adminRouter.Handle("DELETE /users/{id}/quota", userMiddleware(userUpdatePermissions(handle(controllers.admin.ResetQuota))))
+	adminRouter.Handle("GET /usage", userMiddleware(userReadPermissions(handle(controllers.admin.ListUsage))))
 
 	// Audit log routes
-	adminRouter.Handle("GET /examples/{id}/events", userMiddleware(auditLogReadPermissions(controllers.admin.GetEventsForItem)))
-	adminRouter.Handle("GET /users/{id}/events", userMiddleware(auditLogReadPermissions(controllers.admin.GetEventsForUser)))
+	adminRouter.Handle("GET /examples/{id}/events", userMiddleware(auditLogReadPermissions(handle(controllers.admin.GetEventsForItem))))
+	adminRouter.Handle("GET /users/{id}/events", userMiddleware(auditLogReadPermissions(handle(controllers.admin.GetEventsForUser))))
 
 	router.Handle("/admin/", http.StripPrefix("/admin", adminRouter))
 
@@ -81,11 +95,12 @@ func buildRoutes(controllers routerControllers, profiling bool) *http.ServeMux {
 Factory function for creating a new server with all middleware and handlers
 */
 func NewServer(config server.Config, controllers routerControllers) *http.Server {
-	router := buildRoutes(controllers, len(config.Profiling.Must()) > 0)
+	router := buildRoutes(controllers, config.Profiling)
+	compressionMiddleware := middleware.CompressionMiddleware(config.CompressionMinSize)
 
 	return &http.Server{
-		Handler:      errorHandlingMiddleware(loggingMiddleware(router)),
-		Addr:         config.Port.Must(),
+		Handler:      requestIdMiddleware(compressionMiddleware(errorHandlingMiddleware(loggingMiddleware(router)))),
+		Addr:         config.Port,
 		ReadTimeout:  config.Timeouts.Read,
 		WriteTimeout: config.Timeouts.Write,
 		IdleTimeout:  config.Timeouts.Idle,
@@ -102,42 +117,84 @@ type appConfig struct {
 Main entry point for the application
 */
 func main() {
-	// MARK: Config
-	cfg := appConfig{
-		server: server.Config{
-			Port: config.NewFirst(
-				config.NewEnvironmentSource("PORT"),
-				config.NewDefaultValueSource(":8081"),
-			),
-			Profiling: config.NewFirst(
-				config.NewEnvironmentSource("PROFILING_ENABLED"),
-				config.NewDefaultValueSource(""),
-			),
-			Timeouts: server.Timeouts{
-				Read:  10 * time.Second,
-				Write: 10 * time.Second,
-				Idle:  30 * time.Second,
-			},
-		},
-		database: store.Config{
-			Host:     config.NewEnvironmentSource("DB_HOST"),
-			User:     config.NewEnvironmentSource("DB_USER"),
-			Password: config.NewEnvironmentSource("DB_PASS"),
-			Database: config.NewEnvironmentSource("DB_NAME"),
-			Schema: config.NewFirst(
-				config.NewEnvironmentSource("DB_SCHEMA"),
-				config.NewDefaultValueSource("schemas"),
-			),
-		},
-		cache: cache.Config{
-			Host: config.NewEnvironmentSource("CACHE_HOST"),
+	// MARK: Logging
+	// The level can change at runtime through LOG_LEVEL
+	logLevel := new(slog.LevelVar)
+	logger = slog.New(slog.NewJSONHandler(
+		os.Stdout,
+		&slog.HandlerOptions{
+			Level: logLevel,
 		},
+	))
+	slog.SetDefault(logger)
+
+	// MARK: Config
+	// Resolve everything up front so all config problems are reported at once
+	lookup := config.NewFileLookup(
+		config.NewFirst(
+			config.NewEnvironmentSource("SECRETS_DIR"),
+			config.NewDefaultValueSource("/run/secrets"),
+		).Must(),
+		config.NewFirst(
+			config.NewEnvironmentSource("ENV_FILE"),
+			config.NewDefaultValueSource(".env"),
+		).Must(),
+	)
+
+	// Values from a config server come after the local sources, when one is configured
+	var remote *config.HttpClient
+	if configServerUrl, err := lookup("CONFIG_SERVER_URL").Get(); err == nil {
+		remote = config.NewHttpClient(
+			configServerUrl,
+			config.WithBearerToken(lookup("CONFIG_SERVER_TOKEN", config.NewDefaultValueSource("")).Must()),
+			config.WithTimeout(2*time.Second),
+			config.WithRetries(3, 100*time.Millisecond),
+			config.WithTTL(5*time.Minute),
+			config.WithBatch(),
+		)
+		lookup = lookup.Then(remote.Source)
+	}
+
+	loadConfig := func(loader *config.Loader, lookup config.Lookup) appConfig {
+		return appConfig{
+			server:   server.LoadConfig(loader, lookup, ":8081"),
+			database: store.LoadConfig(loader, lookup),
+			cache:    cache.LoadConfig(loader, lookup),
+		}
+	}
+
+	// Every key the server is asked for in one request, keys it doesn't have are fetched one by one
+	if remote != nil {
+		keys := config.Keys(func(loader *config.Loader, lookup config.Lookup) {
+			loadConfig(loader, lookup)
+			lookup("LOG_LEVEL")
+		})
+
+		if err := remote.Prefetch(logContext, keys...); err != nil {
+			slog.LogAttrs(logContext, slog.LevelWarn, server.ConfigPrefetchFailedMsg, slog.String(server.LogKeyError, err.Error()))
+		}
+	}
+
+	loader := config.NewLoader()
+	cfg := loadConfig(loader, lookup)
+
+	// Config that can change without a restart, re-read on SIGHUP and every CONFIG_RELOAD_INTERVAL
+	reloader := config.NewReloader(map[string]config.Configurator{
+		"LOG_LEVEL": lookup("LOG_LEVEL", config.NewDefaultValueSource("INFO")),
+	})
+	server.WatchLogLevel(reloader, "LOG_LEVEL", logLevel)
+
+	if err := errors.Join(loader.Err(), reloader.Reload()); err != nil {
+		slog.LogAttrs(logContext, slog.LevelError, server.InvalidConfigMsg, slog.String(server.LogKeyError, err.Error()))
+		os.Exit(1)
 	}
 
+	slog.LogAttrs(logContext, slog.LevelInfo, server.EffectiveConfigMsg, loader.Attrs()...)
+
 	// MARK: Repository
 	dbCache, err := valkeyaside.NewClient(valkeyaside.ClientOption{
 		ClientOption: valkey.ClientOption{
-			InitAddress: []string{cfg.cache.Host.Must()},
+			InitAddress: []string{cfg.cache.Host},
 			SelectDB:    1,
 		},
 	})
@@ -162,7 +219,7 @@ func main() {
 
 	// MARK: Cache
 	cacheClient, err := valkey.NewClient(valkey.ClientOption{
-		InitAddress: []string{cfg.cache.Host.Must()},
+		InitAddress: []string{cfg.cache.Host},
 		SelectDB:    2,
 	})
 
@@ -170,7 +227,11 @@ func main() {
 		panic(err)
 	}
 
-	cache := cache.NewValkeyCache(cacheClient)
+	// The cache only holds ETags, keep serving requests without it when valkey is down
+	cache := cache.NewCircuitBreaker(
+		cache.NewValkeyCache(cacheClient),
+		cache.DefaultBreakerConfig(),
+	)
 
 	// MARK: Event bus
 	auditlogrepo := auditservice.NewSQLRepository(dbpool)
@@ -193,31 +254,35 @@ func main() {
 		ExampleStore: exampleRepo,
 		AuditStore:   auditRepo,
 		Bus:          &eventBus,
+		Quota:        cfg.server.Quota,
 	}
 
 	// MARK: Controllers
 	controllers := routerControllers{
-		admin: &adminservice.Controller{Service: service, Cache: cache},
+		admin:  &adminservice.Controller{Service: service, Cache: cache, Cursors: requests.NewCursorCodec(cfg.server.CursorSecret)},
+		health: &healthservice.HealthController{Checks: []healthservice.Check{cache}},
 	}
 
-	// MARK: Logging
-	logger = slog.New(slog.NewJSONHandler(
-		os.Stdout,
-		&slog.HandlerOptions{
-			Level: slog.LevelInfo,
-		},
-	))
-	slog.SetDefault(logger)
-
 	// MARK: Signals
 	processShutdownChannel := make(chan os.Signal, server.ProcessChannelsBufferSize)
 	serverShutdownChannel := make(chan struct{}, server.ProcessChannelsBufferSize)
 	queueShutdownChan := make(chan struct{}, server.ProcessChannelsBufferSize)
 	signal.Notify(processShutdownChannel, os.Interrupt, syscall.SIGTERM, syscall.SIGINT)
 
+	reloadChannel := make(chan os.Signal, server.ProcessChannelsBufferSize)
+	reloadShutdownChannel := make(chan struct{})
+	signal.Notify(reloadChannel, syscall.SIGHUP)
+
 	defer close(processShutdownChannel)
 	defer close(serverShutdownChannel)
 	defer close(queueShutdownChan)
+	defer close(reloadShutdownChannel)
+
+	go reloader.Watch(reloadShutdownChannel, reloadChannel, cfg.server.ReloadInterval)
+
+	if remote != nil {
+		go remote.Refresh(reloadShutdownChannel, time.Minute)
+	}
 
 	go eventBus.Listen(queueShutdownChan)
 
@@ -22,9 +22,12 @@ import (
 	"github.com/moonmoon1919/go-api-reference/internal/exampleservice"
 	"github.com/moonmoon1919/go-api-reference/internal/healthservice"
 	"github.com/moonmoon1919/go-api-reference/internal/middleware"
+	"github.com/moonmoon1919/go-api-reference/internal/ratelimit"
+	"github.com/moonmoon1919/go-api-reference/internal/requests"
 	"github.com/moonmoon1919/go-api-reference/internal/server"
 	"github.com/moonmoon1919/go-api-reference/internal/store"
 	"github.com/moonmoon1919/go-api-reference/pkg/events"
+	"github.com/moonmoon1919/go-api-reference/pkg/example"
 )
 
 var (
@@ -32,11 +35,14 @@ var (
 	userMiddleware          = middleware.InsertRequestingUser
 	errorHandlingMiddleware = middleware.ErrorHandlingMiddleware
 	loggingMiddleware       = middleware.LoggingMiddleware
+	requestIdMiddleware     = middleware.RequestIdMiddleware
 
 	// Pool validator middleware
 	exampleReadPermissions   = middleware.PermissionValidationMiddleware(middleware.NewHas("example::read"))
 	exampleCreatePermissions = middleware.PermissionValidationMiddleware(middleware.NewHas("example::create"))
 	exampleDeletePermissions = middleware.PermissionValidationMiddleware(middleware.NewHas("example::delete"))
+	// A batch can create, update and delete
+	exampleBatchPermissions = middleware.PermissionValidationMiddleware(middleware.NewHasAll([]string{"example::create", "example::delete"}))
 
 	// Logging
 	logger     *slog.Logger
@@ -45,11 +51,15 @@ var (
 
 type routerControllers struct {
 	example *exampleservice.Controller
+	health  *healthservice.HealthController
+	// Unsafe routes replay their response for a repeated Idempotency-Key
+	idempotent func(http.HandlerFunc) http.HandlerFunc
+	// Every example route is rate limited per client
+	limited func(http.HandlerFunc) http.HandlerFunc
 }
 
 func buildRoutes(controllers routerControllers, profiling bool) *http.ServeMux {
 	router := http.NewServeMux()
-	hc := healthservice.HealthController{}
 
 	if profiling {
 		router.HandleFunc("/debug/pprof", pprof.Index)
@@ -60,14 +70,27 @@ func buildRoutes(controllers routerControllers, profiling bool) *http.ServeMux {
 		router.HandleFunc("/debug/pprof/heap", pprof.Handler("heap").ServeHTTP)
 	}
 
-	router.HandleFunc("GET /health", hc.Get)
+	router.HandleFunc("GET /health", controllers.health.Get)
 
 	// Example service
-	router.Handle("POST /examples", userMiddleware(exampleCreatePermissions(controllers.example.Create)))
-	router.Handle("GET /examples", userMiddleware(controllers.example.List))
-	router.Handle("GET /examples/{id}", userMiddleware(exampleReadPermissions(controllers.example.Get)))
-	router.Handle("PATCH /examples/{id}", userMiddleware(exampleCreatePermissions(controllers.example.Patch)))
-	router.Handle("DELETE /examples/{id}", userMiddleware(exampleDeletePermissions(controllers.example.Delete)))
+	idempotent := controllers.idempotent
+	limited := controllers.limited
+	handle := exampleservice.Handle
+
+	router.Handle("POST /examples", userMiddleware(limited(exampleCreatePermissions(idempotent(handle(controllers.example.Create))))))
+	router.Handle("POST /examples/batch", userMiddleware(limited(exampleBatchPermissions(idempotent(handle(controllers.example.Batch))))))
+	router.Handle("GET /examples", userMiddleware(limited(handle(controllers.example.List))))
+	router.Handle("GET /examples/{id}", userMiddleware(limited(exampleReadPermissions(handle(controllers.example.Get)))))
+	router.Handle("PUT /examples/{id}", userMiddleware(limited(exampleCreatePermissions(handle(controllers.example.Put)))))
+	router.Handle("PATCH /examples/{id}", userMiddleware(limited(exampleCreatePermissions(idempotent(handle(controllers.example.Patch))))))
+	router.Handle("DELETE /examples/{id}", userMiddleware(limited(exampleDeletePermissions(idempotent(handle(controllers.example.Delete))))))
+	router.Handle("POST /examples/{id}/restore", userMiddleware(limited(exampleDeletePermissions(idempotent(handle(controllers.example.Restore))))))
+	router.Handle("GET /examples/{id}/revisions", userMiddleware(limited(exampleReadPermissions(handle(controllers.example.ListRevisions)))))
+	router.Handle("GET /examples/{id}/revisions/{rev}", userMiddleware(limited(exampleReadPermissions(handle(controllers.example.GetRevision)))))
+	router.Handle("POST /examples/{id}/revisions/{rev}/revert", userMiddleware(limited(exampleCreatePermissions(idempotent(handle(controllers.example.Revert))))))
+
+	// Account routes
+	router.Handle("GET /me/usage", userMiddleware(limited(handle(controllers.example.Usage))))
 
 	return router
 }
@@ -82,11 +105,12 @@ type serverTimeouts struct {
 Factory function for creating a new server with all middleware and handlers
 */
 func NewServer(config server.Config, controllers routerControllers) *http.Server {
-	router := buildRoutes(controllers, len(config.Profiling.Must()) > 0)
+	router := buildRoutes(controllers, config.Profiling)
+	compressionMiddleware := middleware.CompressionMiddleware(config.CompressionMinSize)
 
 	return &http.Server{
-		Handler:      errorHandlingMiddleware(loggingMiddleware(router)),
-		Addr:         config.Port.Must(),
+		Handler:      requestIdMiddleware(compressionMiddleware(errorHandlingMiddleware(loggingMiddleware(router)))),
+		Addr:         config.Port,
 		ReadTimeout:  config.Timeouts.Read,
 		WriteTimeout: config.Timeouts.Write,
 		IdleTimeout:  config.Timeouts.Idle,
@@ -97,48 +121,102 @@ type appConfig struct {
 	server   server.Config
 	database store.Config
 	cache    cache.Config
+	limits   ratelimit.Config
+	// Words examples can't contain, on top of the built-in message rules
+	forbiddenWords []string
 }
 
 /*
 Main entry point for the application
 */
 func main() {
-	// MARK: Config
-	cfg := appConfig{
-		server: server.Config{
-			Port: config.NewFirst(
-				config.NewEnvironmentSource("PORT"),
-				config.NewDefaultValueSource(":8080"),
-			),
-			Profiling: config.NewFirst(
-				config.NewEnvironmentSource("PROFILING_ENABLED"),
-				config.NewDefaultValueSource(""),
-			),
-			Timeouts: server.Timeouts{
-				Read:  10 * time.Second,
-				Write: 10 * time.Second,
-				Idle:  30 * time.Second,
-			},
-		},
-		database: store.Config{
-			Host:     config.NewEnvironmentSource("DB_HOST"),
-			User:     config.NewEnvironmentSource("DB_USER"),
-			Password: config.NewEnvironmentSource("DB_PASS"),
-			Database: config.NewEnvironmentSource("DB_NAME"),
-			Schema: config.NewFirst(
-				config.NewEnvironmentSource("DB_SCHEMA"),
-				config.NewDefaultValueSource("schemas"),
-			),
-		},
-		cache: cache.Config{
-			Host: config.NewEnvironmentSource("CACHE_HOST"),
+	// MARK: Logging
+	// The level can change at runtime through LOG_LEVEL
+	logLevel := new(slog.LevelVar)
+	logger = slog.New(slog.NewJSONHandler(
+		os.Stdout,
+		&slog.HandlerOptions{
+			Level: logLevel,
 		},
+	))
+	slog.SetDefault(logger)
+
+	// MARK: Config
+	// Resolve everything up front so all config problems are reported at once
+	lookup := config.NewFileLookup(
+		config.NewFirst(
+			config.NewEnvironmentSource("SECRETS_DIR"),
+			config.NewDefaultValueSource("/run/secrets"),
+		).Must(),
+		config.NewFirst(
+			config.NewEnvironmentSource("ENV_FILE"),
+			config.NewDefaultValueSource(".env"),
+		).Must(),
+	)
+
+	// Values from a config server come after the local sources, when one is configured
+	var remote *config.HttpClient
+	if configServerUrl, err := lookup("CONFIG_SERVER_URL").Get(); err == nil {
+		remote = config.NewHttpClient(
+			configServerUrl,
+			config.WithBearerToken(lookup("CONFIG_SERVER_TOKEN", config.NewDefaultValueSource("")).Must()),
+			config.WithTimeout(2*time.Second),
+			config.WithRetries(3, 100*time.Millisecond),
+			config.WithTTL(5*time.Minute),
+			config.WithBatch(),
+		)
+		lookup = lookup.Then(remote.Source)
+	}
+
+	loadConfig := func(loader *config.Loader, lookup config.Lookup) appConfig {
+		cfg := appConfig{
+			server:   server.LoadConfig(loader, lookup, ":8080"),
+			database: store.LoadConfig(loader, lookup),
+			cache:    cache.LoadConfig(loader, lookup),
+			limits:   ratelimit.LoadConfig(loader, lookup),
+		}
+		config.Bind(loader, "EXAMPLE_FORBIDDEN_WORDS", config.StringList(lookup("EXAMPLE_FORBIDDEN_WORDS", config.NewDefaultValueSource(""))), &cfg.forbiddenWords)
+
+		return cfg
+	}
+
+	// Every key the server is asked for in one request, keys it doesn't have are fetched one by one
+	if remote != nil {
+		keys := config.Keys(func(loader *config.Loader, lookup config.Lookup) {
+			loadConfig(loader, lookup)
+			lookup("LOG_LEVEL")
+		})
+
+		if err := remote.Prefetch(logContext, keys...); err != nil {
+			slog.LogAttrs(logContext, slog.LevelWarn, server.ConfigPrefetchFailedMsg, slog.String(server.LogKeyError, err.Error()))
+		}
+	}
+
+	loader := config.NewLoader()
+	cfg := loadConfig(loader, lookup)
+
+	// Config that can change without a restart, re-read on SIGHUP and every CONFIG_RELOAD_INTERVAL
+	reloader := config.NewReloader(map[string]config.Configurator{
+		"LOG_LEVEL": lookup("LOG_LEVEL", config.NewDefaultValueSource("INFO")),
+	})
+	server.WatchLogLevel(reloader, "LOG_LEVEL", logLevel)
+
+	if err := errors.Join(loader.Err(), reloader.Reload()); err != nil {
+		slog.LogAttrs(logContext, slog.LevelError, server.InvalidConfigMsg, slog.String(server.LogKeyError, err.Error()))
+		os.Exit(1)
 	}
 
+	slog.LogAttrs(logContext, slog.LevelInfo, server.EffectiveConfigMsg, loader.Attrs()...)
+
+	// MARK: Domain rules
+	forbidden := example.ForbiddenWords(cfg.forbiddenWords...)
+	example.MessageRules = append(example.MessageRules, forbidden)
+	example.TitleRules = append(example.TitleRules, forbidden)
+
 	// MARK: Repository
 	dbCache, err := valkeyaside.NewClient(valkeyaside.ClientOption{
 		ClientOption: valkey.ClientOption{
-			InitAddress: []string{cfg.cache.Host.Must()},
+			InitAddress: []string{cfg.cache.Host},
 			SelectDB:    1,
 		},
 	})
@@ -161,7 +239,7 @@ func main() {
 
 	// MARK: Cache
 	cacheClient, err := valkey.NewClient(valkey.ClientOption{
-		InitAddress: []string{cfg.cache.Host.Must()},
+		InitAddress: []string{cfg.cache.Host},
 		SelectDB:    2,
 	})
 
@@ -169,7 +247,22 @@ func main() {
 		panic(err)
 	}
 
-	cache := cache.NewValkeyCache(cacheClient)
+	// Responses to retried writes, kept next to the ETags under their own prefix
+	// A request can't run longer than the write timeout, so that's as long as a key is reserved for
+	idempotency := middleware.IdempotencyMiddleware(cache.NewValkeyReserver(cacheClient), cfg.server.IdempotencyTTL, cfg.server.Timeouts.Write)
+
+	// Replicas share limits through valkey, the memory backend counts per replica
+	var limiter ratelimit.Limiter = ratelimit.NewInMemoryLimiter()
+	if cfg.limits.Backend == ratelimit.ValkeyBackend {
+		limiter = ratelimit.NewValkeyLimiter(cacheClient)
+	}
+	limited := middleware.RateLimitMiddleware(limiter, cfg.limits.Policy)
+
+	// The cache only holds ETags, keep serving requests without it when valkey is down
+	cache := cache.NewCircuitBreaker(
+		cache.NewValkeyCache(cacheClient),
+		cache.DefaultBreakerConfig(),
+	)
 
 	// MARK: Event bus
 	auditlogrepo := auditservice.NewSQLRepository(dbpool)
@@ -187,31 +280,36 @@ func main() {
 	eventBus := bus.New(bus.Subscribers{subscriber})
 
 	// MARK: Service
-	service := exampleservice.Service{Store: repo, Bus: &eventBus}
+	service := exampleservice.Service{Store: repo, Bus: &eventBus, Quota: cfg.server.Quota}
 
 	// MARK: Controllers
 	controllers := routerControllers{
-		example: &exampleservice.Controller{Service: service, Cache: cache},
+		example:    &exampleservice.Controller{Service: service, Cache: cache, Cursors: requests.NewCursorCodec(cfg.server.CursorSecret)},
+		health:     &healthservice.HealthController{Checks: []healthservice.Check{cache}},
+		idempotent: idempotency,
+		limited:    limited,
 	}
 
-	// MARK: Logging
-	logger = slog.New(slog.NewJSONHandler(
-		os.Stdout,
-		&slog.HandlerOptions{
-			Level: slog.LevelInfo,
-		},
-	))
-	slog.SetDefault(logger)
-
 	// MARK: Signals
 	processShutdownChannel := make(chan os.Signal, server.ProcessChannelsBufferSize)
 	serverShutdownChannel := make(chan struct{}, server.ProcessChannelsBufferSize)
 	queueShutdownChan := make(chan struct{}, server.ProcessChannelsBufferSize)
 	signal.Notify(processShutdownChannel, os.Interrupt, syscall.SIGTERM, syscall.SIGINT)
 
+	reloadChannel := make(chan os.Signal, server.ProcessChannelsBufferSize)
+	reloadShutdownChannel := make(chan struct{})
+	signal.Notify(reloadChannel, syscall.SIGHUP)
+
 	defer close(processShutdownChannel)
 	defer close(serverShutdownChannel)
 	defer close(queueShutdownChan)
+	defer close(reloadShutdownChannel)
+
+	go reloader.Watch(reloadShutdownChannel, reloadChannel, cfg.server.ReloadInterval)
+
+	if remote != nil {
+		go remote.Refresh(reloadShutdownChannel, time.Minute)
+	}
 
 	go eventBus.Listen(queueShutdownChan)
 
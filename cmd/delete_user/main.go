@@ -62,17 +62,13 @@ func main() {
 		return
 	}
 
+	loader := config.NewLoader()
 	cfg := appConfig{
-		database: store.Config{
-			Host:     config.NewEnvironmentSource("DB_HOST"),
-			User:     config.NewEnvironmentSource("DB_USER"),
-			Password: config.NewEnvironmentSource("DB_PASS"),
-			Database: config.NewEnvironmentSource("DB_NAME"),
-			Schema: config.NewFirst(
-				config.NewEnvironmentSource("DB_SCHEMA"),
-				config.NewDefaultValueSource("schemas"),
-			),
-		},
+		database: store.LoadConfig(loader, config.EnvironmentLookup),
+	}
+
+	if err := loader.Err(); err != nil {
+		panic(err)
 	}
 
 	// MARK: Repository
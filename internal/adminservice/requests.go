@@ -2,9 +2,10 @@ package adminservice
 
 import (
 	"encoding/json"
-	"errors"
 	"log/slog"
-	"strings"
+
+	"github.com/moonmoon1919/go-api-reference/internal/requests"
+	"github.com/moonmoon1919/go-api-reference/pkg/users"
 )
 
 type CreateUserRequest struct {
@@ -21,7 +22,7 @@ func (r *CreateUserRequest) UnmarshalJSON(data []byte) error {
 
 	if err := json.Unmarshal(data, aux); err != nil {
 		slog.Error("UNMARSHAL_CREATE_USER_REQUEST_ERROR", "error", err)
-		return errors.New("INVALID_REQUEST_BODY")
+		return requests.InvalidBodyError()
 	}
 
 	missingRequiredFields := []string{}
@@ -31,8 +32,50 @@ func (r *CreateUserRequest) UnmarshalJSON(data []byte) error {
 	}
 
 	if len(missingRequiredFields) > 0 {
-		return errors.New("MISSING_REQUIRED_FIELDS: " + strings.Join(missingRequiredFields, ", "))
+		return requests.MissingFieldsError(missingRequiredFields...)
+	}
+
+	return nil
+}
+
+/*
+Both limits are required, -1 is unlimited and 0 freezes the user's writes
+*/
+type SetQuotaRequest struct {
+	Examples *int `json:"examples"`
+	Bytes    *int `json:"bytes"`
+}
+
+func (r *SetQuotaRequest) UnmarshalJSON(data []byte) error {
+	type Aux SetQuotaRequest
+	aux := &struct {
+		*Aux
+	}{
+		Aux: (*Aux)(r),
+	}
+
+	if err := json.Unmarshal(data, aux); err != nil {
+		slog.Error("UNMARSHAL_SET_QUOTA_REQUEST_ERROR", "error", err)
+		return requests.InvalidBodyError()
+	}
+
+	missingRequiredFields := []string{}
+
+	if aux.Examples == nil {
+		missingRequiredFields = append(missingRequiredFields, "examples")
+	}
+
+	if aux.Bytes == nil {
+		missingRequiredFields = append(missingRequiredFields, "bytes")
+	}
+
+	if len(missingRequiredFields) > 0 {
+		return requests.MissingFieldsError(missingRequiredFields...)
 	}
 
 	return nil
 }
+
+func (r SetQuotaRequest) Quota() users.Quota {
+	return users.Quota{Examples: *r.Examples, Bytes: *r.Bytes}
+}
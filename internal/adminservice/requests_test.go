@@ -43,3 +43,49 @@ func TestCreateUserRequestUnmarshalJson(t *testing.T) {
 		})
 	}
 }
+
+func TestSetQuotaRequestUnmarshalJson(t *testing.T) {
+	tests := []struct {
+		name       string
+		json       string
+		errMessage string
+	}{
+		{
+			name:       "PassingCase-ValidRequest",
+			json:       `{"examples": 100, "bytes": 0}`,
+			errMessage: "",
+		},
+		{
+			name:       "FailingCase-MissingBytes",
+			json:       `{"examples": 100}`,
+			errMessage: "MISSING_REQUIRED_FIELDS: bytes",
+		},
+		{
+			name:       "FailingCase-MissingBoth",
+			json:       `{}`,
+			errMessage: "MISSING_REQUIRED_FIELDS: examples, bytes",
+		},
+		{
+			name:       "FailingCase-NotANumber",
+			json:       `{"examples": "lots", "bytes": 0}`,
+			errMessage: "INVALID_REQUEST_BODY",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var request SetQuotaRequest
+
+			err := json.Unmarshal([]byte(tc.json), &request)
+
+			var errMsg string
+			if err != nil {
+				errMsg = err.Error()
+			}
+
+			if errMsg != tc.errMessage {
+				t.Errorf("expected error message %s, got %s", tc.errMessage, errMsg)
+			}
+		})
+	}
+}
@@ -1,6 +1,11 @@
 package adminservice
 
 import (
+	"time"
+
+	"github.com/moonmoon1919/go-api-reference/internal/requests"
+	"github.com/moonmoon1919/go-api-reference/internal/responses"
+	"github.com/moonmoon1919/go-api-reference/internal/store"
 	"github.com/moonmoon1919/go-api-reference/pkg/events"
 	"github.com/moonmoon1919/go-api-reference/pkg/example"
 	"github.com/moonmoon1919/go-api-reference/pkg/users"
@@ -16,50 +21,115 @@ func NewGetUserResponseFromUser(user *users.User) GetUserResponse {
 	}
 }
 
-type ListUserResponse struct {
-	Users []GetUserResponse `json:"users"`
+type ListUserResponse = responses.Paged[GetUserResponse]
+
+func NewListUsersResponseFromPage(page store.Page[users.User], p store.Pagination, cursors requests.CursorCodec) ListUserResponse {
+	return responses.NewPaged(page, p, func(u users.User) GetUserResponse { return NewGetUserResponseFromUser(&u) }).
+		WithCursors(cursors.Token(page.Next), cursors.Token(page.Prev))
 }
 
-func NewListUsersResponseFromUsers(users *[]users.User) ListUserResponse {
-	items := make([]GetUserResponse, len(*users))
+/*
+A limit of -1 is unlimited
+*/
+type QuotaResponse struct {
+	Examples int  `json:"examples"`
+	Bytes    int  `json:"bytes"`
+	Default  bool `json:"default"`
+}
 
-	for idx, i := range *users {
-		items[idx] = NewGetUserResponseFromUser(&i)
-	}
+type UsageResponse struct {
+	UserId   string        `json:"user_id"`
+	Examples int           `json:"examples"`
+	Bytes    int           `json:"bytes"`
+	Quota    QuotaResponse `json:"quota"`
+}
 
-	return ListUserResponse{
-		Users: items,
+func NewUsageResponseFromUsage(u UserUsage) UsageResponse {
+	return UsageResponse{
+		UserId:   u.UserId,
+		Examples: u.Usage.Examples,
+		Bytes:    u.Usage.Bytes,
+		Quota:    QuotaResponse{Examples: u.Quota.Examples, Bytes: u.Quota.Bytes, Default: u.Default},
 	}
 }
 
+type ListUsageResponse = responses.Paged[UsageResponse]
+
+func NewListUsageResponseFromPage(page store.Page[UserUsage], p store.Pagination, cursors requests.CursorCodec) ListUsageResponse {
+	return responses.NewPaged(page, p, NewUsageResponseFromUsage).
+		WithCursors(cursors.Token(page.Next), cursors.Token(page.Prev))
+}
+
+/*
+Admins only see an example's metadata, never its title or message
+*/
 type GetExampleResponse struct {
-	Id     string `json:"id"`
-	UserId string `json:"message"`
+	Id        string     `json:"id"`
+	UserId    string     `json:"user_id"`
+	Tags      []string   `json:"tags"`
+	Status    string     `json:"status"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	DeletedBy string     `json:"deleted_by,omitempty"`
 }
 
 func NewGetExampleResponseFromExample(e example.Example) GetExampleResponse {
+	tags := e.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+
 	return GetExampleResponse{
-		Id:     e.Id,
-		UserId: e.UserId,
+		Id:        e.Id,
+		UserId:    e.UserId,
+		Tags:      tags,
+		Status:    string(e.Status),
+		CreatedAt: e.CreatedAt,
+		UpdatedAt: e.UpdatedAt,
+		DeletedAt: e.DeletedAt,
+		DeletedBy: e.DeletedBy,
 	}
 }
 
-type ListExampleResponse struct {
-	Items []GetExampleResponse `json:"items"`
+type ListExampleResponse = responses.Paged[GetExampleResponse]
+
+func NewListExampleResponseFromPage(page store.Page[example.Example], p store.Pagination, cursors requests.CursorCodec) ListExampleResponse {
+	return responses.NewPaged(page, p, NewGetExampleResponseFromExample).
+		WithCursors(cursors.Token(page.Next), cursors.Token(page.Prev))
 }
 
-func NewListExampleResponseFromExamples(e []example.Example) ListExampleResponse {
-	items := make([]GetExampleResponse, len(e))
+/*
+Revisions without their title and message, which admins can't see
+*/
+type RevisionResponse struct {
+	Revision  int       `json:"revision"`
+	Tags      []string  `json:"tags"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+}
 
-	for idx, i := range e {
-		items[idx] = NewGetExampleResponseFromExample(i)
+func NewRevisionResponseFromRevision(r example.Revision) RevisionResponse {
+	tags := r.Tags
+	if tags == nil {
+		tags = []string{}
 	}
 
-	return ListExampleResponse{
-		Items: items,
+	return RevisionResponse{
+		Revision:  r.Revision,
+		Tags:      tags,
+		Status:    string(r.Status),
+		CreatedAt: r.CreatedAt,
 	}
 }
 
+type ListRevisionResponse = responses.Paged[RevisionResponse]
+
+func NewListRevisionResponseFromPage(page store.Page[example.Revision], p store.Pagination, cursors requests.CursorCodec) ListRevisionResponse {
+	return responses.NewPaged(page, p, NewRevisionResponseFromRevision).
+		WithCursors(cursors.Token(page.Next), cursors.Token(page.Prev))
+}
+
 type EventResponse struct {
 	Name      example.ExampleEvent `json:"name"`
 	UserId    string               `json:"user_id"`
@@ -78,18 +148,11 @@ func NewEventResponseFromEvent(e *events.Event) EventResponse {
 	}
 }
 
-type ListEventResponse struct {
-	Events []EventResponse `json:"events"`
-}
-
-func NewListEventsFromEventsList(e *[]events.Event) ListEventResponse {
-	events := make([]EventResponse, len(*e))
+/*
+Events are paged with page only, so there are no cursors
+*/
+type ListEventResponse = responses.Paged[EventResponse]
 
-	for idx, i := range *e {
-		events[idx] = NewEventResponseFromEvent(&i)
-	}
-
-	return ListEventResponse{
-		Events: events,
-	}
+func NewListEventsFromPage(page store.Page[events.Event], p store.Pagination) ListEventResponse {
+	return responses.NewPaged(page, p, func(e events.Event) EventResponse { return NewEventResponseFromEvent(&e) })
 }
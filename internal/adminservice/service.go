@@ -6,6 +6,7 @@ import (
 	"log/slog"
 
 	"github.com/moonmoon1919/go-api-reference/internal/bus"
+	"github.com/moonmoon1919/go-api-reference/internal/store"
 	"github.com/moonmoon1919/go-api-reference/pkg/events"
 	"github.com/moonmoon1919/go-api-reference/pkg/example"
 	"github.com/moonmoon1919/go-api-reference/pkg/users"
@@ -19,13 +20,22 @@ const (
 	getExampleMsg       = "ADMIN_SERVICE_GET_EXAMPLE"
 	listExamplesMsg     = "ADMIN_SERVICE_LIST_EXAMPLE_FOR_USER"
 	deleteExampleMsg    = "ADMIN_SERVICE_DELETE_EXAMPLE"
+	listTrashMsg        = "ADMIN_SERVICE_LIST_TRASH"
+	purgeExampleMsg     = "ADMIN_SERVICE_PURGE_EXAMPLE"
+	listRevisionsMsg    = "ADMIN_SERVICE_LIST_REVISIONS"
+	getRevisionMsg      = "ADMIN_SERVICE_GET_REVISION"
+	revertExampleMsg    = "ADMIN_SERVICE_REVERT_EXAMPLE"
 	getEventsForItemMsg = "ADMIN_SERVICE_GET_EVENTS_FOR_ITEM"
 	listByEventUserMsg  = "ADMIN_SERVICE_LIST_BY_EVENT_FOR_USER"
 	listEventsByUserMsg = "ADMIN_SERVICE_LIST_EVENTS_FOR_USER"
+	setQuotaMsg         = "ADMIN_SERVICE_SET_QUOTA"
+	resetQuotaMsg       = "ADMIN_SERVICE_RESET_QUOTA"
+	listUsageMsg        = "ADMIN_SERVICE_LIST_USAGE"
 
 	// Errors
 	storeErrorMsg = "STORE_ERROR"
 	logKeyId      = "ID"
+	logKeyRev     = "REVISION"
 	logKeyErr     = "ERR"
 )
 
@@ -33,6 +43,7 @@ var limitToLargeError = errors.New("maximum limit is 50")
 var invalidPageError = errors.New("page must be greater than 0")
 var userServiceNotFound = errors.New("user not found")
 var exampleServiceNotFound = errors.New("example not found")
+var revisionServiceNotFound = errors.New("revision not found")
 var storeError = errors.New("store error")
 
 type Service struct {
@@ -40,6 +51,23 @@ type Service struct {
 	ExampleStore ExampleStorer
 	AuditStore   AuditStorer
 	Bus          bus.Busser
+	// For users without a quota of their own
+	Quota users.Quota
+}
+
+/*
+Maximum limit we support is 50, page is only used when there is no cursor
+*/
+func validatePagination(p store.Pagination) error {
+	if p.Limit > 50 {
+		return limitToLargeError
+	}
+
+	if p.Cursor == nil && p.Page < 1 {
+		return invalidPageError
+	}
+
+	return nil
 }
 
 // MARK: Users
@@ -94,22 +122,18 @@ func (s Service) GetUser(ctx context.Context, id string) (users.User, error) {
 	return user, nil
 }
 
-func (s Service) ListUsers(ctx context.Context, limit, page int) ([]users.User, error) {
+func (s Service) ListUsers(ctx context.Context, p store.Pagination) (store.Page[users.User], error) {
 	slog.LogAttrs(
 		ctx,
 		slog.LevelInfo,
 		listUserMsg,
 	)
 
-	if limit > 50 {
-		return nil, limitToLargeError
+	if err := validatePagination(p); err != nil {
+		return store.Page[users.User]{}, err
 	}
 
-	if page < 1 {
-		return nil, invalidPageError
-	}
-
-	retrievedUsers, err := s.UserStore.List(ctx, limit, page)
+	retrievedUsers, err := s.UserStore.List(ctx, p)
 	if err != nil {
 		slog.LogAttrs(
 			ctx,
@@ -117,7 +141,7 @@ func (s Service) ListUsers(ctx context.Context, limit, page int) ([]users.User,
 			storeErrorMsg,
 			slog.String(logKeyErr, err.Error()),
 		)
-		return make([]users.User, 0), nil
+		return store.Page[users.User]{Items: make([]users.User, 0)}, nil
 	}
 
 	return retrievedUsers, nil
@@ -156,6 +180,97 @@ func (s Service) DeleteUser(ctx context.Context, id string) error {
 	return nil
 }
 
+// MARK: Quotas
+/*
+Replaces the default quota for a user, examples they already have are kept
+even when they no longer fit
+*/
+func (s Service) SetQuota(ctx context.Context, id string, quota users.Quota) error {
+	slog.LogAttrs(
+		ctx,
+		slog.LevelInfo,
+		setQuotaMsg,
+		slog.String(logKeyId, id),
+	)
+
+	if err := quota.Validate(); err != nil {
+		return err
+	}
+
+	return s.quotaError(ctx, id, s.UserStore.SetQuota(ctx, id, quota))
+}
+
+/*
+Puts a user back on the default quota
+*/
+func (s Service) ResetQuota(ctx context.Context, id string) error {
+	slog.LogAttrs(
+		ctx,
+		slog.LevelInfo,
+		resetQuotaMsg,
+		slog.String(logKeyId, id),
+	)
+
+	return s.quotaError(ctx, id, s.UserStore.DeleteQuota(ctx, id))
+}
+
+func (s Service) quotaError(ctx context.Context, id string, err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, userNotFoundError):
+		slog.LogAttrs(
+			ctx,
+			slog.LevelInfo,
+			notFoundMsg,
+			slog.String(logKeyId, id),
+		)
+		return userServiceNotFound
+	default:
+		slog.LogAttrs(
+			ctx,
+			slog.LevelError,
+			storeErrorMsg,
+			slog.String(logKeyErr, err.Error()),
+		)
+		return storeError
+	}
+}
+
+/*
+Every user's usage with the quota it counts against, in user id order
+*/
+func (s Service) ListUsage(ctx context.Context, p store.Pagination) (store.Page[UserUsage], error) {
+	slog.LogAttrs(
+		ctx,
+		slog.LevelInfo,
+		listUsageMsg,
+	)
+
+	if err := validatePagination(p); err != nil {
+		return store.Page[UserUsage]{}, err
+	}
+
+	page, err := s.UserStore.ListUsage(ctx, p)
+	if err != nil {
+		slog.LogAttrs(
+			ctx,
+			slog.LevelError,
+			storeErrorMsg,
+			slog.String(logKeyErr, err.Error()),
+		)
+		return store.Page[UserUsage]{}, storeError
+	}
+
+	for i := range page.Items {
+		if page.Items[i].Default {
+			page.Items[i].Quota = s.Quota
+		}
+	}
+
+	return page, nil
+}
+
 // MARK: Examples
 func (s Service) GetExample(ctx context.Context, id string) (example.Example, error) {
 	slog.LogAttrs(
@@ -192,7 +307,7 @@ func (s Service) GetExample(ctx context.Context, id string) (example.Example, er
 	return item, nil
 }
 
-func (s Service) GetExamplesForUser(ctx context.Context, id string, limit, page int) ([]example.Example, error) {
+func (s Service) GetExamplesForUser(ctx context.Context, id string, p store.Pagination) (store.Page[example.Example], error) {
 	slog.LogAttrs(
 		ctx,
 		slog.LevelInfo,
@@ -200,15 +315,11 @@ func (s Service) GetExamplesForUser(ctx context.Context, id string, limit, page
 		slog.String(logKeyId, id),
 	)
 
-	if limit > 50 {
-		return nil, limitToLargeError
-	}
-
-	if page < 1 {
-		return nil, invalidPageError
+	if err := validatePagination(p); err != nil {
+		return store.Page[example.Example]{}, err
 	}
 
-	items, err := s.ExampleStore.GetForUser(ctx, id, limit, page)
+	items, err := s.ExampleStore.GetForUser(ctx, id, p)
 	if err != nil {
 		slog.LogAttrs(
 			ctx,
@@ -216,7 +327,7 @@ func (s Service) GetExamplesForUser(ctx context.Context, id string, limit, page
 			storeErrorMsg,
 			slog.String(logKeyErr, err.Error()),
 		)
-		return nil, err
+		return store.Page[example.Example]{}, err
 	}
 
 	return items, nil
@@ -230,7 +341,7 @@ func (s Service) DeleteExample(ctx context.Context, userId, id string) error {
 		slog.String(logKeyId, id),
 	)
 
-	err := s.ExampleStore.Delete(ctx, id)
+	err := s.ExampleStore.Delete(ctx, id, userId)
 	if err != nil {
 		switch {
 		case errors.Is(err, exampleNotFoundError):
@@ -262,8 +373,195 @@ func (s Service) DeleteExample(ctx context.Context, userId, id string) error {
 	return nil
 }
 
+// MARK: Trash
+func (s Service) ListTrash(ctx context.Context, p store.Pagination) (store.Page[example.Example], error) {
+	slog.LogAttrs(
+		ctx,
+		slog.LevelInfo,
+		listTrashMsg,
+	)
+
+	if err := validatePagination(p); err != nil {
+		return store.Page[example.Example]{}, err
+	}
+
+	items, err := s.ExampleStore.ListTrash(ctx, p)
+	if err != nil {
+		slog.LogAttrs(
+			ctx,
+			slog.LevelError,
+			storeErrorMsg,
+			slog.String(logKeyErr, err.Error()),
+		)
+		return store.Page[example.Example]{}, err
+	}
+
+	return items, nil
+}
+
+/*
+Permanently removes a trashed example, examples that aren't in the
+trash are not found
+*/
+func (s Service) PurgeExample(ctx context.Context, userId, id string) error {
+	slog.LogAttrs(
+		ctx,
+		slog.LevelInfo,
+		purgeExampleMsg,
+		slog.String(logKeyId, id),
+	)
+
+	err := s.ExampleStore.Purge(ctx, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, exampleNotFoundError):
+			slog.LogAttrs(
+				ctx,
+				slog.LevelInfo,
+				notFoundMsg,
+				slog.String(logKeyId, id),
+			)
+
+			return exampleServiceNotFound
+		default:
+			slog.LogAttrs(
+				ctx,
+				slog.LevelError,
+				storeErrorMsg,
+				slog.String(logKeyErr, err.Error()),
+			)
+			return err
+		}
+	}
+
+	s.Bus.Notify(events.NewEvent(
+		userId,
+		example.ExamplePurged{Id: id},
+	))
+
+	return nil
+}
+
+// MARK: Revisions
+/*
+Revisions of any user's example, the example itself must not be in the trash
+*/
+func (s Service) ListRevisions(ctx context.Context, id string, p store.Pagination) (store.Page[example.Revision], error) {
+	slog.LogAttrs(
+		ctx,
+		slog.LevelInfo,
+		listRevisionsMsg,
+		slog.String(logKeyId, id),
+	)
+
+	if err := validatePagination(p); err != nil {
+		return store.Page[example.Revision]{}, err
+	}
+
+	if _, err := s.GetExample(ctx, id); err != nil {
+		return store.Page[example.Revision]{}, err
+	}
+
+	items, err := s.ExampleStore.ListRevisions(ctx, id, p)
+	if err != nil {
+		slog.LogAttrs(
+			ctx,
+			slog.LevelError,
+			storeErrorMsg,
+			slog.String(logKeyErr, err.Error()),
+		)
+		return store.Page[example.Revision]{}, err
+	}
+
+	return items, nil
+}
+
+func (s Service) GetRevision(ctx context.Context, id string, revision int) (example.Revision, error) {
+	slog.LogAttrs(
+		ctx,
+		slog.LevelInfo,
+		getRevisionMsg,
+		slog.String(logKeyId, id),
+		slog.Int(logKeyRev, revision),
+	)
+
+	if _, err := s.GetExample(ctx, id); err != nil {
+		return example.Revision{}, err
+	}
+
+	item, err := s.ExampleStore.GetRevision(ctx, id, revision)
+	if err != nil {
+		switch {
+		case errors.Is(err, revisionNotFoundError):
+			slog.LogAttrs(
+				ctx,
+				slog.LevelInfo,
+				notFoundMsg,
+				slog.String(logKeyId, id),
+				slog.Int(logKeyRev, revision),
+			)
+
+			return example.Revision{}, revisionServiceNotFound
+		default:
+			slog.LogAttrs(
+				ctx,
+				slog.LevelError,
+				storeErrorMsg,
+				slog.String(logKeyErr, err.Error()),
+			)
+			return example.Revision{}, err
+		}
+	}
+
+	return item, nil
+}
+
+/*
+Reverts any user's example, the event is recorded against the administrator
+*/
+func (s Service) RevertExample(ctx context.Context, userId, id string, revision int) (example.Example, error) {
+	slog.LogAttrs(
+		ctx,
+		slog.LevelInfo,
+		revertExampleMsg,
+		slog.String(logKeyId, id),
+		slog.Int(logKeyRev, revision),
+	)
+
+	item, err := s.ExampleStore.Revert(ctx, id, revision)
+	if err != nil {
+		switch {
+		case errors.Is(err, exampleNotFoundError), errors.Is(err, revisionNotFoundError):
+			slog.LogAttrs(
+				ctx,
+				slog.LevelInfo,
+				notFoundMsg,
+				slog.String(logKeyId, id),
+				slog.Int(logKeyRev, revision),
+			)
+
+			return example.Nil(), exampleServiceNotFound
+		default:
+			slog.LogAttrs(
+				ctx,
+				slog.LevelError,
+				storeErrorMsg,
+				slog.String(logKeyErr, err.Error()),
+			)
+			return example.Nil(), err
+		}
+	}
+
+	s.Bus.Notify(events.NewEvent(
+		userId,
+		example.ExampleUpdated{Id: id},
+	))
+
+	return item, nil
+}
+
 // MARK: Audit
-func (s Service) GetEventsForItem(ctx context.Context, itemId string, limit, page int) ([]events.Event, error) {
+func (s Service) GetEventsForItem(ctx context.Context, itemId string, p store.Pagination) (store.Page[events.Event], error) {
 	slog.LogAttrs(
 		ctx,
 		slog.LevelInfo,
@@ -271,14 +569,11 @@ func (s Service) GetEventsForItem(ctx context.Context, itemId string, limit, pag
 		slog.String(logKeyId, itemId),
 	)
 
-	if limit > 50 {
-		return nil, limitToLargeError
+	if err := validatePagination(p); err != nil {
+		return store.Page[events.Event]{}, err
 	}
 
-	if page < 1 {
-		return nil, invalidPageError
-	}
-	data, err := s.AuditStore.GetEventsForItem(ctx, itemId, limit, page)
+	data, err := s.AuditStore.GetEventsForItem(ctx, itemId, p)
 	if err != nil {
 		slog.LogAttrs(
 			ctx,
@@ -286,13 +581,13 @@ func (s Service) GetEventsForItem(ctx context.Context, itemId string, limit, pag
 			storeErrorMsg,
 			slog.String(logKeyErr, err.Error()),
 		)
-		return nil, storeError
+		return store.Page[events.Event]{}, storeError
 	}
 
 	return data, nil
 }
 
-func (s Service) GetEventsForUser(ctx context.Context, userId string, limit, page int) ([]events.Event, error) {
+func (s Service) GetEventsForUser(ctx context.Context, userId string, p store.Pagination) (store.Page[events.Event], error) {
 	slog.LogAttrs(
 		ctx,
 		slog.LevelInfo,
@@ -300,15 +595,11 @@ func (s Service) GetEventsForUser(ctx context.Context, userId string, limit, pag
 		slog.String(logKeyId, userId),
 	)
 
-	if limit > 50 {
-		return nil, limitToLargeError
-	}
-
-	if page < 1 {
-		return nil, invalidPageError
+	if err := validatePagination(p); err != nil {
+		return store.Page[events.Event]{}, err
 	}
 
-	data, err := s.AuditStore.GetEventsForUser(ctx, userId, limit, page)
+	data, err := s.AuditStore.GetEventsForUser(ctx, userId, p)
 	if err != nil {
 		slog.LogAttrs(
 			ctx,
@@ -316,13 +607,13 @@ func (s Service) GetEventsForUser(ctx context.Context, userId string, limit, pag
 			storeErrorMsg,
 			slog.String(logKeyErr, err.Error()),
 		)
-		return nil, storeError
+		return store.Page[events.Event]{}, storeError
 	}
 
 	return data, nil
 }
 
-func (s Service) GetByEventAndUser(ctx context.Context, userId, eventName string, limit, page int) ([]events.Event, error) {
+func (s Service) GetByEventAndUser(ctx context.Context, userId, eventName string, p store.Pagination) (store.Page[events.Event], error) {
 	slog.LogAttrs(
 		ctx,
 		slog.LevelInfo,
@@ -331,15 +622,11 @@ func (s Service) GetByEventAndUser(ctx context.Context, userId, eventName string
 		slog.String("eventName", eventName),
 	)
 
-	if limit > 50 {
-		return nil, limitToLargeError
-	}
-
-	if page < 1 {
-		return nil, invalidPageError
+	if err := validatePagination(p); err != nil {
+		return store.Page[events.Event]{}, err
 	}
 
-	data, err := s.AuditStore.GetByEventAndUser(ctx, userId, eventName, limit, page)
+	data, err := s.AuditStore.GetByEventAndUser(ctx, userId, eventName, p)
 	if err != nil {
 		slog.LogAttrs(
 			ctx,
@@ -347,7 +634,7 @@ func (s Service) GetByEventAndUser(ctx context.Context, userId, eventName string
 			storeErrorMsg,
 			slog.String(logKeyErr, err.Error()),
 		)
-		return nil, storeError
+		return store.Page[events.Event]{}, storeError
 	}
 
 	return data, nil
@@ -7,8 +7,10 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/moonmoon1919/go-api-reference/internal/bus"
+	"github.com/moonmoon1919/go-api-reference/internal/store"
 	"github.com/moonmoon1919/go-api-reference/pkg/events"
 	"github.com/moonmoon1919/go-api-reference/pkg/example"
+	"github.com/moonmoon1919/go-api-reference/pkg/users"
 )
 
 // MARK: Users
@@ -147,7 +149,7 @@ func TestListUsers(t *testing.T) {
 				}
 			}
 
-			retrievedItems, err := service.ListUsers(context.TODO(), tc.limit, tc.page)
+			retrievedItems, err := service.ListUsers(context.TODO(), store.Pagination{Limit: tc.limit, Page: tc.page})
 
 			var errMessage string
 			if err != nil {
@@ -158,8 +160,8 @@ func TestListUsers(t *testing.T) {
 				t.Errorf("Got unexpected error %s, expecting %s", errMessage, tc.errMessage)
 			}
 
-			if len(retrievedItems) != tc.numItems {
-				t.Errorf("Found %d items, expected %d", len(retrievedItems), tc.numItems)
+			if len(retrievedItems.Items) != tc.numItems {
+				t.Errorf("Found %d items, expected %d", len(retrievedItems.Items), tc.numItems)
 			}
 		})
 	}
@@ -214,6 +216,115 @@ func TestDeleteUser(t *testing.T) {
 	}
 }
 
+// MARK: Quotas
+func TestSetQuota(t *testing.T) {
+	tests := []struct {
+		name       string
+		create     bool
+		quota      users.Quota
+		errMessage string
+	}{
+		{
+			name:   "PassingCase",
+			create: true,
+			quota:  users.Quota{Examples: 10, Bytes: users.Unlimited},
+		},
+		{
+			name:   "PassingCase-Zero",
+			create: true,
+			quota:  users.Quota{Examples: 0, Bytes: 0},
+		},
+		{
+			name:       "FailingCase-Negative",
+			create:     true,
+			quota:      users.Quota{Examples: -2, Bytes: users.Unlimited},
+			errMessage: "quota limits must be 0 or greater, or -1 for unlimited",
+		},
+		{
+			name:       "FailingCase-NotFound",
+			quota:      users.Quota{Examples: 10, Bytes: users.Unlimited},
+			errMessage: "user not found",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			userStore := newInMemoryUserStore()
+			service := Service{UserStore: userStore, ExampleStore: newInMemoryExampleStore()}
+			id := uuid.NewString()
+
+			if tc.create {
+				service.AddUser(context.TODO(), id)
+			}
+
+			// When
+			err := service.SetQuota(context.TODO(), id, tc.quota)
+
+			// Then
+			var errMessage string
+			if err != nil {
+				errMessage = err.Error()
+			}
+
+			if errMessage != tc.errMessage {
+				t.Errorf("expected error message %s, got %s", tc.errMessage, errMessage)
+			}
+
+			quota, ok := userStore.quotas[id]
+			if ok != (tc.errMessage == "") || (ok && quota != tc.quota) {
+				t.Errorf("expected the quota to be stored only on success, got %+v", quota)
+			}
+		})
+	}
+}
+
+func TestListUsage(t *testing.T) {
+	// Given
+	userStore := newInMemoryUserStore()
+	service := Service{UserStore: userStore, ExampleStore: newInMemoryExampleStore(), Quota: users.Quota{Examples: 100, Bytes: 1000}}
+
+	own, other := uuid.NewString(), uuid.NewString()
+	service.AddUser(context.TODO(), own)
+	service.AddUser(context.TODO(), other)
+	service.SetQuota(context.TODO(), own, users.Quota{Examples: 5, Bytes: 50})
+	userStore.usage[own] = users.Usage{Examples: 2, Bytes: 20}
+
+	// When
+	page, err := service.ListUsage(context.TODO(), store.Pagination{Limit: 10, Page: 1})
+
+	// Then
+	if err != nil {
+		t.Errorf("unexpected error %s", err.Error())
+	}
+
+	expected := map[string]UserUsage{
+		own:   {UserId: own, Usage: users.Usage{Examples: 2, Bytes: 20}, Quota: users.Quota{Examples: 5, Bytes: 50}},
+		other: {UserId: other, Quota: service.Quota, Default: true},
+	}
+
+	if len(page.Items) != len(expected) {
+		t.Fatalf("expected %d users, got %d", len(expected), len(page.Items))
+	}
+
+	for _, item := range page.Items {
+		if item != expected[item.UserId] {
+			t.Errorf("expected %+v, got %+v", expected[item.UserId], item)
+		}
+	}
+
+	// When
+	service.ResetQuota(context.TODO(), own)
+	page, _ = service.ListUsage(context.TODO(), store.Pagination{Limit: 10, Page: 1})
+
+	// Then
+	for _, item := range page.Items {
+		if !item.Default || item.Quota != service.Quota {
+			t.Errorf("expected every user to be back on the default quota, got %+v", item)
+		}
+	}
+}
+
 // MARK: Examples
 func TestGetExample(t *testing.T) {
 	tests := []struct {
@@ -336,7 +447,7 @@ func TestGetExamplesForUser(t *testing.T) {
 				}
 			}
 
-			retrievedItems, err := service.GetExamplesForUser(context.TODO(), tc.userId, tc.limit, tc.page)
+			retrievedItems, err := service.GetExamplesForUser(context.TODO(), tc.userId, store.Pagination{Limit: tc.limit, Page: tc.page})
 
 			var errMessage string
 			if err != nil {
@@ -347,8 +458,8 @@ func TestGetExamplesForUser(t *testing.T) {
 				t.Errorf("Got unexpected error %s, expected %s", errMessage, tc.errMessage)
 			}
 
-			if len(retrievedItems) != tc.numItems {
-				t.Errorf("Found %d items, expected %d", len(retrievedItems), tc.numItems)
+			if len(retrievedItems.Items) != tc.numItems {
+				t.Errorf("Found %d items, expected %d", len(retrievedItems.Items), tc.numItems)
 			}
 		})
 	}
@@ -410,6 +521,72 @@ func TestDeleteExample(t *testing.T) {
 	}
 }
 
+// MARK: Trash
+func TestListTrash(t *testing.T) {
+	tests := []struct {
+		name          string
+		trashed       int
+		kept          int
+		expectedItems int
+	}{
+		{
+			name:          "PassingCase-OnlyTrashed",
+			trashed:       2,
+			kept:          3,
+			expectedItems: 2,
+		},
+		{
+			name:          "PassingCase-EmptyTrash",
+			trashed:       0,
+			kept:          1,
+			expectedItems: 0,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			exampleStore := newInMemoryExampleStore()
+			service := Service{ExampleStore: exampleStore, Bus: bus.NewFake()}
+			userId := uuid.NewString()
+
+			for i := 0; i < tc.trashed+tc.kept; i++ {
+				item, err := exampleStore.add(context.TODO(), example.Example{UserId: userId})
+				if err != nil {
+					t.Errorf("unexpected error while adding item %s", err.Error())
+				}
+
+				if i < tc.trashed {
+					service.DeleteExample(context.TODO(), userId, item.Id)
+				}
+			}
+
+			// When
+			page, err := service.ListTrash(context.TODO(), store.Pagination{Limit: 10, Page: 1})
+
+			// Then
+			if err != nil {
+				t.Errorf("unexpected error %s", err.Error())
+			}
+
+			if len(page.Items) != tc.expectedItems {
+				t.Errorf("expected %d items, got %d", tc.expectedItems, len(page.Items))
+			}
+
+			for _, item := range page.Items {
+				if item.DeletedBy != userId {
+					t.Errorf("expected item to be deleted by %s, got %s", userId, item.DeletedBy)
+				}
+			}
+
+			forUser, _ := service.GetExamplesForUser(context.TODO(), userId, store.Pagination{Limit: 10, Page: 1})
+			if len(forUser.Items) != tc.kept {
+				t.Errorf("expected %d items for user, got %d", tc.kept, len(forUser.Items))
+			}
+		})
+	}
+}
+
 // MARK: Audit
 func TestGetEventsForItem(t *testing.T) {
 	tests := []struct {
@@ -479,7 +656,7 @@ func TestGetEventsForItem(t *testing.T) {
 			}
 
 			// When
-			retrievedItems, err := svc.GetEventsForItem(context.TODO(), tc.itemId, tc.limit, tc.page)
+			retrievedItems, err := svc.GetEventsForItem(context.TODO(), tc.itemId, store.Pagination{Limit: tc.limit, Page: tc.page})
 
 			// Then
 			var errMessage string
@@ -491,8 +668,8 @@ func TestGetEventsForItem(t *testing.T) {
 				t.Errorf("Expected error %s, got %s", tc.errMessage, errMessage)
 			}
 
-			if len(retrievedItems) != tc.numEvents {
-				t.Errorf("Found %d items, expected %d", len(retrievedItems), tc.numEvents)
+			if len(retrievedItems.Items) != tc.numEvents {
+				t.Errorf("Found %d items, expected %d", len(retrievedItems.Items), tc.numEvents)
 			}
 		})
 	}
@@ -561,7 +738,7 @@ func TestGetEventsForUser(t *testing.T) {
 			}
 
 			// When
-			retrievedItems, err := svc.GetEventsForUser(context.TODO(), tc.userId, tc.limit, tc.page)
+			retrievedItems, err := svc.GetEventsForUser(context.TODO(), tc.userId, store.Pagination{Limit: tc.limit, Page: tc.page})
 
 			// Then
 			var errMessage string
@@ -573,8 +750,8 @@ func TestGetEventsForUser(t *testing.T) {
 				t.Errorf("Expected error %s, got %s", tc.errMessage, errMessage)
 			}
 
-			if len(retrievedItems) != tc.numEvents {
-				t.Errorf("Found %d items, expected %d", len(retrievedItems), tc.numEvents)
+			if len(retrievedItems.Items) != tc.numEvents {
+				t.Errorf("Found %d items, expected %d", len(retrievedItems.Items), tc.numEvents)
 			}
 		})
 	}
@@ -645,7 +822,7 @@ func TestGetByEventAndUser(t *testing.T) {
 			}
 
 			// When
-			retrievedItems, err := svc.GetByEventAndUser(context.TODO(), tc.userId, string(tc.eventType), tc.limit, tc.page)
+			retrievedItems, err := svc.GetByEventAndUser(context.TODO(), tc.userId, string(tc.eventType), store.Pagination{Limit: tc.limit, Page: tc.page})
 
 			// Then
 			var errMessage string
@@ -657,8 +834,8 @@ func TestGetByEventAndUser(t *testing.T) {
 				t.Errorf("Expected error %s, got %s", tc.errMessage, errMessage)
 			}
 
-			if len(retrievedItems) != tc.numEvents {
-				t.Errorf("Found %d items, expected %d", len(retrievedItems), tc.numEvents)
+			if len(retrievedItems.Items) != tc.numEvents {
+				t.Errorf("Found %d items, expected %d", len(retrievedItems.Items), tc.numEvents)
 			}
 		})
 	}
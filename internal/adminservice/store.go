@@ -6,10 +6,15 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/moonmoon1919/go-api-reference/internal/store"
 	"github.com/moonmoon1919/go-api-reference/pkg/events"
 	"github.com/moonmoon1919/go-api-reference/pkg/example"
 	"github.com/moonmoon1919/go-api-reference/pkg/users"
@@ -20,26 +25,55 @@ const (
 	notFoundMsg = "NOT_FOUND"
 	userKey     = "USER"
 	errKey      = "ERR"
+
+	// Postgres error code for a missing referenced row
+	foreignKeyViolation = "23503"
 )
 
 var userNotFoundError = errors.New("user not found")
 var exampleNotFoundError = errors.New("example not found")
+var revisionNotFoundError = errors.New("example revision not found")
 
 // MARK: Users
 type UserStorer interface {
 	Add(ctx context.Context, item users.User) error
 	Get(ctx context.Context, id string) (users.User, error)
-	List(ctx context.Context, limit, page int) ([]users.User, error)
+	// Users are listed in id order
+	List(ctx context.Context, p store.Pagination) (store.Page[users.User], error)
 	Delete(ctx context.Context, id string) error
+	// Gives a user a quota of their own in place of the default
+	SetQuota(ctx context.Context, id string, quota users.Quota) error
+	// Puts a user back on the default quota
+	DeleteQuota(ctx context.Context, id string) error
+	// Every user's usage, listed in user id order
+	ListUsage(ctx context.Context, p store.Pagination) (store.Page[UserUsage], error)
+}
+
+/*
+A user's usage and the quota it counts against
+
+Default is set when the user doesn't have a quota of their own, stores leave
+Quota empty then
+*/
+type UserUsage struct {
+	UserId  string
+	Usage   users.Usage
+	Quota   users.Quota
+	Default bool
 }
 
 type userMemoryStore struct {
-	items map[string]users.User
+	items  map[string]users.User
+	quotas map[string]users.Quota
+	// Kept up to date by the example service's store in the database
+	usage map[string]users.Usage
 }
 
 func newInMemoryUserStore() *userMemoryStore {
 	return &userMemoryStore{
-		items: make(map[string]users.User),
+		items:  make(map[string]users.User),
+		quotas: make(map[string]users.Quota),
+		usage:  make(map[string]users.Usage),
 	}
 }
 
@@ -57,14 +91,18 @@ func (u *userMemoryStore) Get(ctx context.Context, id string) (users.User, error
 	}
 }
 
-func (u *userMemoryStore) List(ctx context.Context, _, _ int) ([]users.User, error) {
+func userIdKey(u users.User) string {
+	return u.Id
+}
+
+func (u *userMemoryStore) List(ctx context.Context, p store.Pagination) (store.Page[users.User], error) {
 	var results []users.User
 
 	for _, usr := range u.items {
 		results = append(results, usr)
 	}
 
-	return results, nil
+	return store.PageSlice(results, userIdKey, p), nil
 }
 
 func (u *userMemoryStore) Delete(ctx context.Context, id string) error {
@@ -73,10 +111,47 @@ func (u *userMemoryStore) Delete(ctx context.Context, id string) error {
 	}
 
 	delete(u.items, id)
+	delete(u.quotas, id)
+	delete(u.usage, id)
 
 	return nil
 }
 
+func (u *userMemoryStore) SetQuota(ctx context.Context, id string, quota users.Quota) error {
+	if _, ok := u.items[id]; !ok {
+		return userNotFoundError
+	}
+
+	u.quotas[id] = quota
+
+	return nil
+}
+
+func (u *userMemoryStore) DeleteQuota(ctx context.Context, id string) error {
+	if _, ok := u.items[id]; !ok {
+		return userNotFoundError
+	}
+
+	delete(u.quotas, id)
+
+	return nil
+}
+
+func usageKey(u UserUsage) string {
+	return u.UserId
+}
+
+func (u *userMemoryStore) ListUsage(ctx context.Context, p store.Pagination) (store.Page[UserUsage], error) {
+	var results []UserUsage
+
+	for id := range u.items {
+		quota, ok := u.quotas[id]
+		results = append(results, UserUsage{UserId: id, Usage: u.usage[id], Quota: quota, Default: !ok})
+	}
+
+	return store.PageSlice(results, usageKey, p), nil
+}
+
 // SQL
 type userSQLRepository struct {
 	pool *pgxpool.Pool
@@ -114,10 +189,13 @@ func (u *userSQLRepository) Get(ctx context.Context, id string) (users.User, err
 	return user, nil
 }
 
-func (u *userSQLRepository) List(ctx context.Context, limit, page int) ([]users.User, error) {
-	offset := (page - 1) * limit
+func (u *userSQLRepository) List(ctx context.Context, p store.Pagination) (store.Page[users.User], error) {
+	clause, args := p.Clause("id", 1)
 
-	res, err := u.pool.Query(ctx, "SELECT * FROM users ORDER BY id LIMIT $1 OFFSET $2", limit, offset)
+	res, err := u.pool.Query(ctx, "SELECT id FROM users WHERE TRUE"+clause, args...)
+	if err != nil {
+		return store.Page[users.User]{}, err
+	}
 
 	var results []users.User
 	for res.Next() {
@@ -128,10 +206,101 @@ func (u *userSQLRepository) List(ctx context.Context, limit, page int) ([]users.
 
 	resErr := res.Err()
 	if resErr != nil {
-		return nil, err
+		return store.Page[users.User]{}, resErr
 	}
 
-	return results, err
+	page := store.NewPage(results, userIdKey, p)
+
+	page.Total, err = store.CountRows(ctx, u.pool, p.Count, "users", "")
+	if err != nil {
+		return store.Page[users.User]{}, err
+	}
+
+	return page, nil
+}
+
+func (u *userSQLRepository) SetQuota(ctx context.Context, id string, quota users.Quota) error {
+	_, err := u.pool.Exec(
+		ctx,
+		"INSERT INTO quotas (uid, examples, bytes) VALUES ($1, $2, $3) ON CONFLICT (uid) DO UPDATE SET examples=EXCLUDED.examples, bytes=EXCLUDED.bytes",
+		id,
+		quota.Examples,
+		quota.Bytes,
+	)
+
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolation {
+			return userNotFoundError
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func (u *userSQLRepository) DeleteQuota(ctx context.Context, id string) error {
+	tag, err := u.pool.Exec(ctx, "DELETE FROM quotas WHERE uid=$1", id)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() > 0 {
+		return nil
+	}
+
+	// Already on the default quota, as long as the user exists
+	_, err = u.Get(ctx, id)
+
+	return err
+}
+
+/*
+Usage comes from example_usage, which the database keeps up to date as
+examples are written, users without examples have none
+*/
+func (u *userSQLRepository) ListUsage(ctx context.Context, p store.Pagination) (store.Page[UserUsage], error) {
+	clause, args := p.Clause("users.id", 1)
+
+	res, err := u.pool.Query(
+		ctx,
+		"SELECT users.id, COALESCE(u.examples, 0), COALESCE(u.bytes, 0), q.examples, q.bytes FROM users "+
+			"LEFT JOIN example_usage u ON u.uid = users.id LEFT JOIN quotas q ON q.uid = users.id WHERE TRUE"+clause,
+		args...,
+	)
+	if err != nil {
+		return store.Page[UserUsage]{}, err
+	}
+
+	var results []UserUsage
+	for res.Next() {
+		var row UserUsage
+		var examples, bytes *int
+
+		res.Scan(&row.UserId, &row.Usage.Examples, &row.Usage.Bytes, &examples, &bytes)
+
+		row.Default = examples == nil || bytes == nil
+		if !row.Default {
+			row.Quota = users.Quota{Examples: *examples, Bytes: *bytes}
+		}
+
+		results = append(results, row)
+	}
+
+	resErr := res.Err()
+	if resErr != nil {
+		return store.Page[UserUsage]{}, resErr
+	}
+
+	page := store.NewPage(results, usageKey, p)
+
+	page.Total, err = store.CountRows(ctx, u.pool, p.Count, "users", "")
+	if err != nil {
+		return store.Page[UserUsage]{}, err
+	}
+
+	return page, nil
 }
 
 /*
@@ -155,20 +324,34 @@ func (u *userSQLRepository) Delete(ctx context.Context, id string) error {
 
 // MARK: Examples
 type ExampleStorer interface {
+	// Trashed examples are never returned by Get or GetForUser
 	Get(ctx context.Context, id string) (example.Example, error)
-	GetForUser(ctx context.Context, userId string, limit, page int) ([]example.Example, error)
-	Delete(ctx context.Context, id string) error
+	// Examples are listed in id order
+	GetForUser(ctx context.Context, userId string, p store.Pagination) (store.Page[example.Example], error)
+	// Moves an example to the trash
+	Delete(ctx context.Context, id, deletedBy string) error
+	// Trashed examples across all users, listed in id order
+	ListTrash(ctx context.Context, p store.Pagination) (store.Page[example.Example], error)
+	// Permanently removes a trashed example
+	Purge(ctx context.Context, id string) error
+	// Revisions are listed oldest first
+	ListRevisions(ctx context.Context, id string, p store.Pagination) (store.Page[example.Revision], error)
+	GetRevision(ctx context.Context, id string, revision int) (example.Revision, error)
+	// Puts back the content of a revision, writing a new revision
+	Revert(ctx context.Context, id string, revision int) (example.Example, error)
 }
 
 type exampleMemoryStore struct {
 	items       map[string]example.Example
 	byUserIndex map[string][]example.Example
+	revisions   map[string][]example.Revision
 }
 
 func newInMemoryExampleStore() *exampleMemoryStore {
 	return &exampleMemoryStore{
 		items:       make(map[string]example.Example),
 		byUserIndex: make(map[string][]example.Example),
+		revisions:   make(map[string][]example.Revision),
 	}
 }
 
@@ -176,8 +359,10 @@ func newInMemoryExampleStore() *exampleMemoryStore {
 func (e *exampleMemoryStore) add(ctx context.Context, item example.Example) (example.Example, error) {
 	// Pretend to be a DB
 	item.Id = uuid.NewString()
+	item.Version = 1
 
 	e.items[item.Id] = item
+	e.revisions[item.Id] = []example.Revision{example.NewRevision(item)}
 
 	_, ok := e.byUserIndex[item.UserId]
 
@@ -190,26 +375,80 @@ func (e *exampleMemoryStore) add(ctx context.Context, item example.Example) (exa
 	return item, nil
 }
 
+/*
+Keeps the user index in sync with items
+*/
+func (e *exampleMemoryStore) put(item example.Example) {
+	e.items[item.Id] = item
+
+	for i, x := range e.byUserIndex[item.UserId] {
+		if x.Id == item.Id {
+			e.byUserIndex[item.UserId][i] = item
+		}
+	}
+}
+
 func (e *exampleMemoryStore) Get(ctx context.Context, id string) (example.Example, error) {
-	if item, ok := e.items[id]; !ok {
+	if item, ok := e.items[id]; !ok || item.Deleted() {
 		return example.Nil(), exampleNotFoundError
 	} else {
 		return item, nil
 	}
 }
 
-func (e *exampleMemoryStore) GetForUser(ctx context.Context, userId string, _, _ int) ([]example.Example, error) {
-	items := e.byUserIndex[userId]
+func exampleKey(e example.Example) string {
+	return e.Id
+}
+
+func (e *exampleMemoryStore) GetForUser(ctx context.Context, userId string, p store.Pagination) (store.Page[example.Example], error) {
+	var items []example.Example
+	for _, item := range e.byUserIndex[userId] {
+		if !item.Deleted() {
+			items = append(items, item)
+		}
+	}
 
-	return items, nil
+	return store.PageSlice(items, exampleKey, p), nil
 }
 
-func (e *exampleMemoryStore) Delete(ctx context.Context, id string) error {
-	if _, ok := e.items[id]; !ok {
+func (e *exampleMemoryStore) Delete(ctx context.Context, id, deletedBy string) error {
+	item, ok := e.items[id]
+	if !ok || item.Deleted() {
 		return exampleNotFoundError
 	}
 
-	item := e.items[id]
+	now := time.Now().UTC()
+	item.DeletedAt = &now
+	item.DeletedBy = deletedBy
+	item.Version++
+	item.UpdatedAt = now
+
+	e.put(item)
+	e.revisions[id] = append(e.revisions[id], example.NewRevision(item))
+
+	return nil
+}
+
+func (e *exampleMemoryStore) ListTrash(ctx context.Context, p store.Pagination) (store.Page[example.Example], error) {
+	var items []example.Example
+	for _, item := range e.items {
+		if item.Deleted() {
+			items = append(items, item)
+		}
+	}
+
+	slices.SortFunc(items, func(a, b example.Example) int {
+		return strings.Compare(a.Id, b.Id)
+	})
+
+	return store.PageSlice(items, exampleKey, p), nil
+}
+
+func (e *exampleMemoryStore) Purge(ctx context.Context, id string) error {
+	item, ok := e.items[id]
+	if !ok || !item.Deleted() {
+		return exampleNotFoundError
+	}
 
 	// Delete from user index
 	b := e.byUserIndex[item.UserId][:0]
@@ -221,10 +460,55 @@ func (e *exampleMemoryStore) Delete(ctx context.Context, id string) error {
 	e.byUserIndex[item.UserId] = b
 
 	delete(e.items, id)
+	delete(e.revisions, id)
 
 	return nil
 }
 
+/*
+Zero padded so revisions sort by number
+*/
+func revisionKey(r example.Revision) string {
+	return fmt.Sprintf("%010d", r.Revision)
+}
+
+func (e *exampleMemoryStore) ListRevisions(ctx context.Context, id string, p store.Pagination) (store.Page[example.Revision], error) {
+	return store.PageSlice(e.revisions[id], revisionKey, p), nil
+}
+
+func (e *exampleMemoryStore) GetRevision(ctx context.Context, id string, revision int) (example.Revision, error) {
+	for _, r := range e.revisions[id] {
+		if r.Revision == revision {
+			return r, nil
+		}
+	}
+
+	return example.Revision{}, revisionNotFoundError
+}
+
+func (e *exampleMemoryStore) Revert(ctx context.Context, id string, revision int) (example.Example, error) {
+	item, err := e.Get(ctx, id)
+	if err != nil {
+		return example.Nil(), err
+	}
+
+	r, err := e.GetRevision(ctx, id, revision)
+	if err != nil {
+		return example.Nil(), err
+	}
+
+	item.Title = r.Title
+	item.Message = r.Message
+	item.Tags = r.Tags
+	item.Version++
+	item.UpdatedAt = time.Now().UTC()
+
+	e.put(item)
+	e.revisions[id] = append(e.revisions[id], example.NewRevision(item))
+
+	return item, nil
+}
+
 func serializer(val *example.Example) (string, error) {
 	b, err := json.Marshal(val)
 	return string(b), err
@@ -239,6 +523,20 @@ func deserializer(s string) (*example.Example, error) {
 	return &result, nil
 }
 
+// Everything but the title and message, which admins can't see
+const exampleColumns = "id, uid, tags, status, created_at, updated_at, deleted_at, COALESCE(deleted_by::text, '')"
+
+func scanExample(row pgx.Row, e *example.Example) error {
+	return row.Scan(&e.Id, &e.UserId, &e.Tags, &e.Status, &e.CreatedAt, &e.UpdatedAt, &e.DeletedAt, &e.DeletedBy)
+}
+
+// Revisions without their title and message, for the same reason
+const revisionColumns = "example_id, revision, tags, status, created_at"
+
+func scanRevision(row pgx.Row, r *example.Revision) error {
+	return row.Scan(&r.ExampleId, &r.Revision, &r.Tags, &r.Status, &r.CreatedAt)
+}
+
 type exampleSQLRepository struct {
 	pool        *pgxpool.Pool
 	cacheClient valkeyaside.CacheAsideClient
@@ -258,7 +556,7 @@ Admins cannot see message content of "example" objects.
 */
 func (e *exampleSQLRepository) Get(ctx context.Context, id string) (example.Example, error) {
 	var result example.Example
-	err := e.pool.QueryRow(ctx, "SELECT id, uid FROM examples WHERE id=$1", id).Scan(&result.Id, &result.UserId)
+	err := scanExample(e.pool.QueryRow(ctx, "SELECT "+exampleColumns+" FROM examples WHERE id=$1 AND deleted_at IS NULL", id), &result)
 
 	if err != nil {
 		switch {
@@ -272,37 +570,92 @@ func (e *exampleSQLRepository) Get(ctx context.Context, id string) (example.Exam
 	return result, nil
 }
 
-func (e *exampleSQLRepository) GetForUser(ctx context.Context, userId string, limit, page int) ([]example.Example, error) {
-	offset := (page - 1) * limit
+/*
+Lists the examples matching where, the arguments for where come first
+*/
+func (e *exampleSQLRepository) list(ctx context.Context, where string, p store.Pagination, args ...any) (store.Page[example.Example], error) {
+	clause, pageArgs := p.Clause("id", len(args)+1)
 
-	res, err := e.pool.Query(ctx, "SELECT id, uid FROM examples WHERE uid=$1 ORDER BY id LIMIT $2 OFFSET $3", userId, limit, offset)
+	res, err := e.pool.Query(ctx, "SELECT "+exampleColumns+" FROM examples WHERE "+where+clause, append(args, pageArgs...)...)
 	if err != nil {
-		return make([]example.Example, 0), err
+		return store.Page[example.Example]{Items: make([]example.Example, 0)}, err
 	}
 
 	var results []example.Example
 	for res.Next() {
 		var e example.Example
-		res.Scan(&e.Id, &e.UserId)
+		scanExample(res, &e)
 		results = append(results, e)
 	}
 
 	resErr := res.Err()
 	if resErr != nil {
-		return make([]example.Example, 0), resErr
+		return store.Page[example.Example]{Items: make([]example.Example, 0)}, resErr
 	}
 
-	return results, nil
+	page := store.NewPage(results, exampleKey, p)
+
+	page.Total, err = store.CountRows(ctx, e.pool, p.Count, "examples", where, args...)
+	if err != nil {
+		return store.Page[example.Example]{Items: make([]example.Example, 0)}, err
+	}
+
+	return page, nil
+}
+
+func (e *exampleSQLRepository) GetForUser(ctx context.Context, userId string, p store.Pagination) (store.Page[example.Example], error) {
+	return e.list(ctx, "uid=$1 AND deleted_at IS NULL", p, userId)
+}
+
+func (e *exampleSQLRepository) ListTrash(ctx context.Context, p store.Pagination) (store.Page[example.Example], error) {
+	return e.list(ctx, "deleted_at IS NOT NULL", p)
 }
 
 /*
-Deletes an example on a users behalf
+Moves an example to the trash on a users behalf
+
+The new version is recorded as a revision in the same statement, like a
+revert. Removes from cache so we don't serve stale cache records to users
+*/
+func (e *exampleSQLRepository) Delete(ctx context.Context, id, deletedBy string) error {
+	var i string
+	err := e.pool.QueryRow(
+		ctx,
+		"WITH written AS ("+
+			"UPDATE examples SET deleted_at=now(), deleted_by=$2, version=version+1, updated_at=now() WHERE id=$1 AND deleted_at IS NULL "+
+			"RETURNING *), "+
+			"revision AS (INSERT INTO example_revisions (example_id, revision, title, message, tags, status, created_at) "+
+			"SELECT id, version, title, message, tags, status, updated_at FROM written) "+
+			"SELECT id FROM written",
+		id,
+		deletedBy,
+	).Scan(&i)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return exampleNotFoundError
+		default:
+			return err
+		}
+	}
+
+	// Delete from cache
+	err = e.cacheClient.Del(ctx, id)
+
+	if err != nil {
+		return nil
+	}
+
+	return nil
+}
 
-Removes from cache so we don't serve stale cache records to users
+/*
+Permanently removes an example, only trashed examples can be purged
 */
-func (e *exampleSQLRepository) Delete(ctx context.Context, id string) error {
+func (e *exampleSQLRepository) Purge(ctx context.Context, id string) error {
 	var i string
-	err := e.pool.QueryRow(ctx, "DELETE FROM examples WHERE id=$1 RETURNING id", id).Scan(&i)
+	err := e.pool.QueryRow(ctx, "DELETE FROM examples WHERE id=$1 AND deleted_at IS NOT NULL RETURNING id", id).Scan(&i)
 
 	if err != nil {
 		switch {
@@ -323,11 +676,100 @@ func (e *exampleSQLRepository) Delete(ctx context.Context, id string) error {
 	return nil
 }
 
+/*
+Lists the revisions of an example, oldest first
+*/
+func (e *exampleSQLRepository) ListRevisions(ctx context.Context, id string, p store.Pagination) (store.Page[example.Revision], error) {
+	clause, args := p.Clause("revision", 2)
+
+	res, err := e.pool.Query(ctx, "SELECT "+revisionColumns+" FROM example_revisions WHERE example_id=$1"+clause, append([]any{id}, args...)...)
+	if err != nil {
+		return store.Page[example.Revision]{}, err
+	}
+
+	var results []example.Revision
+	for res.Next() {
+		var r example.Revision
+		scanRevision(res, &r)
+		results = append(results, r)
+	}
+
+	resErr := res.Err()
+	if resErr != nil {
+		return store.Page[example.Revision]{}, resErr
+	}
+
+	page := store.NewPage(results, revisionKey, p)
+
+	page.Total, err = store.CountRows(ctx, e.pool, p.Count, "example_revisions", "example_id=$1", id)
+	if err != nil {
+		return store.Page[example.Revision]{}, err
+	}
+
+	return page, nil
+}
+
+func (e *exampleSQLRepository) GetRevision(ctx context.Context, id string, revision int) (example.Revision, error) {
+	var result example.Revision
+	err := scanRevision(e.pool.QueryRow(ctx, "SELECT "+revisionColumns+" FROM example_revisions WHERE example_id=$1 AND revision=$2", id, revision), &result)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return example.Revision{}, revisionNotFoundError
+		default:
+			return example.Revision{}, err
+		}
+	}
+
+	return result, nil
+}
+
+/*
+Puts back the content of a revision on a users behalf
+
+The content is copied inside the database so admins never read it, the new
+version is recorded as a revision in the same statement
+*/
+func (e *exampleSQLRepository) Revert(ctx context.Context, id string, revision int) (example.Example, error) {
+	var result example.Example
+	err := scanExample(e.pool.QueryRow(
+		ctx,
+		"WITH written AS ("+
+			"UPDATE examples e SET title=r.title, message=r.message, tags=r.tags, version=e.version+1, updated_at=now() "+
+			"FROM example_revisions r WHERE e.id=$1 AND e.deleted_at IS NULL AND r.example_id=e.id AND r.revision=$2 "+
+			"RETURNING e.*), "+
+			"revision AS (INSERT INTO example_revisions (example_id, revision, title, message, tags, status, created_at) "+
+			"SELECT id, version, title, message, tags, status, updated_at FROM written) "+
+			"SELECT "+exampleColumns+" FROM written",
+		id,
+		revision,
+	), &result)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return example.Nil(), exampleNotFoundError
+		default:
+			return example.Nil(), err
+		}
+	}
+
+	// Delete from cache, a stale entry expires with its TTL
+	e.cacheClient.Del(ctx, id)
+
+	return result, nil
+}
+
 // MARK: Audit
+/*
+Events are listed oldest first and paged with page only, timestamps aren't
+unique so they can't be used as a cursor
+*/
 type AuditStorer interface {
-	GetEventsForItem(ctx context.Context, itemId string, limit, page int) ([]events.Event, error)
-	GetEventsForUser(ctx context.Context, userId string, limit, page int) ([]events.Event, error)
-	GetByEventAndUser(ctx context.Context, userId, eventName string, limit, page int) ([]events.Event, error)
+	GetEventsForItem(ctx context.Context, itemId string, p store.Pagination) (store.Page[events.Event], error)
+	GetEventsForUser(ctx context.Context, userId string, p store.Pagination) (store.Page[events.Event], error)
+	GetByEventAndUser(ctx context.Context, userId, eventName string, p store.Pagination) (store.Page[events.Event], error)
 }
 
 type auditLogMemoryStore struct {
@@ -374,24 +816,31 @@ func (a *auditLogMemoryStore) add(e events.Event) error {
 	return nil
 }
 
-func (a *auditLogMemoryStore) GetEventsForItem(ctx context.Context, itemId string, limit, page int) ([]events.Event, error) {
+/*
+Zero padded so events sort by timestamp
+*/
+func eventKey(e events.Event) string {
+	return fmt.Sprintf("%020d", e.Timestamp)
+}
+
+func (a *auditLogMemoryStore) GetEventsForItem(ctx context.Context, itemId string, p store.Pagination) (store.Page[events.Event], error) {
 	items := a.byItemIdx[itemId]
 
-	return items, nil
+	return store.PageSlice(items, eventKey, p), nil
 }
 
-func (a *auditLogMemoryStore) GetEventsForUser(ctx context.Context, userId string, limit, page int) ([]events.Event, error) {
+func (a *auditLogMemoryStore) GetEventsForUser(ctx context.Context, userId string, p store.Pagination) (store.Page[events.Event], error) {
 	items := a.byUserIndex[userId]
 
-	return items, nil
+	return store.PageSlice(items, eventKey, p), nil
 }
 
-func (a *auditLogMemoryStore) GetByEventAndUser(ctx context.Context, userId, eventName string, limit, page int) ([]events.Event, error) {
+func (a *auditLogMemoryStore) GetByEventAndUser(ctx context.Context, userId, eventName string, p store.Pagination) (store.Page[events.Event], error) {
 	compositeKey := a.generateCompositeKey(userId, eventName)
 
 	items := a.byEventAndUserIndex[compositeKey]
 
-	return items, nil
+	return store.PageSlice(items, eventKey, p), nil
 }
 
 type auditLogSQLRepository struct {
@@ -453,6 +902,20 @@ func (i intermediateEvent) ToEvent(ctx context.Context) (events.Event, error) {
 	case example.ExampleDeletedEvent:
 		var ev example.ExampleDeleted
 
+		if err := json.Unmarshal(eventDataStr, &ev); err != nil {
+			return events.Nil(), err
+		}
+		e.Data = ev
+	case example.ExampleRestoredEvent:
+		var ev example.ExampleRestored
+
+		if err := json.Unmarshal(eventDataStr, &ev); err != nil {
+			return events.Nil(), err
+		}
+		e.Data = ev
+	case example.ExamplePurgedEvent:
+		var ev example.ExamplePurged
+
 		if err := json.Unmarshal(eventDataStr, &ev); err != nil {
 			return events.Nil(), err
 		}
@@ -502,54 +965,46 @@ func (a *auditLogSQLRepository) loadResults(ctx context.Context, rows pgx.Rows)
 	return results, nil
 }
 
-func (a *auditLogSQLRepository) GetEventsForItem(ctx context.Context, itemId string, limit, page int) ([]events.Event, error) {
-	offset := (page - 1) * limit
+/*
+Lists the events matching where, the arguments for where come first
+*/
+func (a *auditLogSQLRepository) list(ctx context.Context, where string, p store.Pagination, args ...any) (store.Page[events.Event], error) {
+	// Events are only paged with page, see AuditStorer
+	p.Cursor = nil
+	clause, pageArgs := p.Clause("timestamp", len(args)+1)
 
 	rows, err := a.pool.Query(
 		ctx,
-		"SELECT eventname, uid, entityid, timestamp, event FROM auditlog WHERE entityid=$1 ORDER BY timestamp LIMIT $2 OFFSET $3",
-		itemId,
-		limit,
-		offset,
+		"SELECT eventname, uid, entityid, timestamp, event FROM auditlog WHERE "+where+clause,
+		append(args, pageArgs...)...,
 	)
 	if err != nil {
-		return nil, err
+		return store.Page[events.Event]{}, err
 	}
 
-	return a.loadResults(ctx, rows)
-}
+	results, err := a.loadResults(ctx, rows)
+	if err != nil {
+		return store.Page[events.Event]{}, err
+	}
 
-func (a *auditLogSQLRepository) GetEventsForUser(ctx context.Context, userId string, limit, page int) ([]events.Event, error) {
-	offset := (page - 1) * limit
+	page := store.NewPage(results, eventKey, p)
 
-	rows, err := a.pool.Query(
-		ctx,
-		"SELECT eventname, uid, entityid, timestamp, event FROM auditlog WHERE uid=$1 ORDER BY timestamp LIMIT $2 OFFSET $3",
-		userId,
-		limit,
-		offset,
-	)
+	page.Total, err = store.CountRows(ctx, a.pool, p.Count, "auditlog", where, args...)
 	if err != nil {
-		return nil, err
+		return store.Page[events.Event]{}, err
 	}
 
-	return a.loadResults(ctx, rows)
+	return page, nil
 }
 
-func (a *auditLogSQLRepository) GetByEventAndUser(ctx context.Context, userId, eventName string, limit, page int) ([]events.Event, error) {
-	offset := (page - 1) * limit
+func (a *auditLogSQLRepository) GetEventsForItem(ctx context.Context, itemId string, p store.Pagination) (store.Page[events.Event], error) {
+	return a.list(ctx, "entityid=$1", p, itemId)
+}
 
-	rows, err := a.pool.Query(
-		ctx,
-		"SELECT eventname, uid, entityid, timestamp, event FROM auditlog WHERE uid=$1 AND eventName=$2 ORDER BY timestamp LIMIT $3 OFFSET $4",
-		userId,
-		eventName,
-		limit,
-		offset,
-	)
-	if err != nil {
-		return nil, err
-	}
+func (a *auditLogSQLRepository) GetEventsForUser(ctx context.Context, userId string, p store.Pagination) (store.Page[events.Event], error) {
+	return a.list(ctx, "uid=$1", p, userId)
+}
 
-	return a.loadResults(ctx, rows)
+func (a *auditLogSQLRepository) GetByEventAndUser(ctx context.Context, userId, eventName string, p store.Pagination) (store.Page[events.Event], error) {
+	return a.list(ctx, "uid=$1 AND eventName=$2", p, userId, eventName)
 }
@@ -27,27 +27,18 @@ type testConfig struct {
 }
 
 func buildConfig() testConfig {
+	loader := config.NewLoader()
+
 	return testConfig{
-		database: store.Config{
-			Host:     config.NewEnvironmentSource("DB_HOST"),
-			User:     config.NewEnvironmentSource("DB_USER"),
-			Password: config.NewEnvironmentSource("DB_PASS"),
-			Database: config.NewEnvironmentSource("DB_NAME"),
-			Schema: config.NewFirst(
-				config.NewEnvironmentSource("DB_SCHEMA"),
-				config.NewDefaultValueSource("schemas"),
-			),
-		},
-		cache: cache.Config{
-			Host: config.NewEnvironmentSource("CACHE_HOST"),
-		},
+		database: store.LoadConfig(loader, config.EnvironmentLookup),
+		cache:    cache.LoadConfig(loader, config.EnvironmentLookup),
 	}
 }
 
 func buildClients(cfg testConfig) (*pgxpool.Pool, valkeyaside.CacheAsideClient, error) {
 	dbCache, err := valkeyaside.NewClient(valkeyaside.ClientOption{
 		ClientOption: valkey.ClientOption{
-			InitAddress: []string{cfg.cache.Host.Must()},
+			InitAddress: []string{cfg.cache.Host},
 			SelectDB:    1,
 		},
 	})
@@ -216,7 +207,7 @@ func TestIntegrationAdminUserList(t *testing.T) {
 			}
 
 			// When
-			resp, err := repository.List(context.TODO(), tc.numUsers, 1)
+			resp, err := repository.List(context.TODO(), store.Pagination{Limit: tc.numUsers, Page: 1})
 
 			var errorMessage string
 			if err != nil {
@@ -228,12 +219,12 @@ func TestIntegrationAdminUserList(t *testing.T) {
 				t.Errorf("Expected error message %s, got %s", tc.errorMessage, errorMessage)
 			}
 
-			if len(resp) != tc.numUsers {
-				t.Errorf("Expected to find %d users, got %d", tc.numUsers, len(resp))
+			if len(resp.Items) != tc.numUsers {
+				t.Errorf("Expected to find %d users, got %d", tc.numUsers, len(resp.Items))
 			}
 
 			// Clean up
-			for _, user := range resp {
+			for _, user := range resp.Items {
 				pool.Exec(context.TODO(), "DELETE FROM users where id=$1", user.Id)
 			}
 		})
@@ -297,6 +288,80 @@ func TestIntegrationAdminUserDelete(t *testing.T) {
 	}
 }
 
+func TestIntegrationAdminUserQuota(t *testing.T) {
+	if testType != "INTEGRATION" {
+		t.Skip()
+	}
+
+	cfg := buildConfig()
+	pool, _, err := buildClients(cfg)
+	if err != nil {
+		t.Errorf("Unexpected error building clients %s", err.Error())
+	}
+	defer pool.Close()
+
+	repository := NewUserSQLRepository(pool)
+
+	// Finds a user in the report, other tests may have left users behind
+	find := func(id string) (UserUsage, bool) {
+		p := store.Pagination{Limit: 50, Page: 1}
+		for {
+			page, err := repository.ListUsage(context.TODO(), p)
+			if err != nil {
+				t.Fatalf("Unexpected error listing usage %s", err.Error())
+			}
+
+			for _, item := range page.Items {
+				if item.UserId == id {
+					return item, true
+				}
+			}
+
+			if !page.HasMore() {
+				return UserUsage{}, false
+			}
+
+			p.Cursor = page.Next
+		}
+	}
+
+	// Given
+	userId := uuid.NewString()
+	repository.Add(context.TODO(), users.NewUserWithId(userId))
+	pool.Exec(context.TODO(), "INSERT INTO examples (message, tags, uid) VALUES ('hello', '{go}', $1)", userId)
+
+	// When
+	err = repository.SetQuota(context.TODO(), userId, users.Quota{Examples: 5, Bytes: 500})
+
+	// Then
+	if err != nil {
+		t.Errorf("Unexpected error setting quota %s", err.Error())
+	}
+
+	expected := UserUsage{UserId: userId, Usage: users.Usage{Examples: 1, Bytes: 7}, Quota: users.Quota{Examples: 5, Bytes: 500}}
+	if item, ok := find(userId); !ok || item != expected {
+		t.Errorf("Expected %+v, got %+v", expected, item)
+	}
+
+	// When
+	err = repository.DeleteQuota(context.TODO(), userId)
+
+	// Then
+	if err != nil {
+		t.Errorf("Unexpected error deleting quota %s", err.Error())
+	}
+
+	if item, ok := find(userId); !ok || !item.Default {
+		t.Errorf("Expected the user to be on the default quota, got %+v", item)
+	}
+
+	if err := repository.SetQuota(context.TODO(), uuid.NewString(), users.Quota{}); err == nil || err.Error() != "user not found" {
+		t.Errorf("Expected user not found for a missing user, got %v", err)
+	}
+
+	pool.Exec(context.TODO(), "DELETE FROM users where id=$1", userId)
+}
+
 // MARK: Examples
 func TestIntegrationAdminExampleGet(t *testing.T) {
 	if testType != "INTEGRATION" {
@@ -414,7 +479,7 @@ func TestIntegrationAdminExampleGetForUser(t *testing.T) {
 			}
 
 			// When
-			resp, err := repository.GetForUser(context.TODO(), tc.userId, tc.numItems, 1)
+			resp, err := repository.GetForUser(context.TODO(), tc.userId, store.Pagination{Limit: tc.numItems, Page: 1})
 
 			var errorMessage string
 			if err != nil {
@@ -426,11 +491,11 @@ func TestIntegrationAdminExampleGetForUser(t *testing.T) {
 				t.Errorf("Expected error message %s, got %s", tc.errorMessage, errorMessage)
 			}
 
-			if len(resp) != tc.numItems {
-				t.Errorf("Expected %d items, found %d", tc.numItems, len(resp))
+			if len(resp.Items) != tc.numItems {
+				t.Errorf("Expected %d items, found %d", tc.numItems, len(resp.Items))
 			}
 
-			for _, item := range resp {
+			for _, item := range resp.Items {
 				if item.UserId != tc.userId {
 					t.Errorf("Expected all items to have user id %s, item %s has user %s", tc.userId, item.Id, item.UserId)
 				}
@@ -483,7 +548,7 @@ func TestIntegrationAdminExampleDelete(t *testing.T) {
 			}
 
 			// When
-			err = repository.Delete(context.TODO(), id)
+			err = repository.Delete(context.TODO(), id, tc.userId)
 
 			var errorMessage string
 			if err != nil {
@@ -500,6 +565,15 @@ func TestIntegrationAdminExampleDelete(t *testing.T) {
 				t.Errorf("Expected getting a deleted item to error, found no error")
 			}
 
+			// Trashed items can be purged exactly once
+			if err = repository.Purge(context.TODO(), id); err != nil {
+				t.Errorf("Unexpected error purging a trashed item: %s", err.Error())
+			}
+
+			if err = repository.Purge(context.TODO(), id); err == nil {
+				t.Errorf("Expected purging a purged item to error, found no error")
+			}
+
 			// Cleanup - triggers cascading delete
 			pool.Exec(context.TODO(), "DELETE FROM users where id=$1", tc.userId)
 		})
@@ -556,19 +630,19 @@ func TestIntegrationAdminAuditGetEventsForItem(t *testing.T) {
 			}
 
 			// When
-			resp, err := repository.GetEventsForItem(context.TODO(), tc.entityId, 10, 1)
+			resp, err := repository.GetEventsForItem(context.TODO(), tc.entityId, store.Pagination{Limit: 10, Page: 1})
 
 			if err != nil {
 				t.Errorf("Received unexpected error %s", err.Error())
 			}
 
 			// Then
-			if len(resp) != tc.numEvents {
-				t.Errorf("Expected to find %d items, found %d", tc.numEvents, len(resp))
+			if len(resp.Items) != tc.numEvents {
+				t.Errorf("Expected to find %d items, found %d", tc.numEvents, len(resp.Items))
 			}
 
 			// Cleanup
-			for _, event := range resp {
+			for _, event := range resp.Items {
 				pool.Exec(context.TODO(), "DELETE FROM auditlog WHERE eventname=$1 uid=$2 event=$3 timestamp=$4",
 					event.Name,
 					event.UserId,
@@ -641,19 +715,19 @@ func TestIntegrationAdminAuditGetEventsForUser(t *testing.T) {
 			}
 
 			// When
-			resp, err := repository.GetEventsForUser(context.TODO(), tc.userId, 10, 1)
+			resp, err := repository.GetEventsForUser(context.TODO(), tc.userId, store.Pagination{Limit: 10, Page: 1})
 
 			if err != nil {
 				t.Errorf("Received unexpected error %s", err.Error())
 			}
 
 			// Then
-			if len(resp) != len(tc.eventsList) {
-				t.Errorf("Expected to find %d items, found %d", len(tc.eventsList), len(resp))
+			if len(resp.Items) != len(tc.eventsList) {
+				t.Errorf("Expected to find %d items, found %d", len(tc.eventsList), len(resp.Items))
 			}
 
 			// Cleanup
-			for _, event := range resp {
+			for _, event := range resp.Items {
 				pool.Exec(context.TODO(), "DELETE FROM auditlog WHERE eventname=$1 uid=$2 event=$3 timestamp=$4",
 					event.Name,
 					event.UserId,
@@ -736,7 +810,7 @@ func TestIntegrationAdminAuditGetByEventAndUser(t *testing.T) {
 			}
 
 			// When
-			resp, err := repository.GetByEventAndUser(context.TODO(), tc.userId, string(tc.eventName), 10, 1)
+			resp, err := repository.GetByEventAndUser(context.TODO(), tc.userId, string(tc.eventName), store.Pagination{Limit: 10, Page: 1})
 
 			if err != nil {
 				t.Errorf("Received unexpected error %s", err.Error())
@@ -751,12 +825,12 @@ func TestIntegrationAdminAuditGetByEventAndUser(t *testing.T) {
 				}
 			}
 
-			if len(resp) != len(filteredList) {
-				t.Errorf("Expected to find %d items, found %d", len(filteredList), len(resp))
+			if len(resp.Items) != len(filteredList) {
+				t.Errorf("Expected to find %d items, found %d", len(filteredList), len(resp.Items))
 			}
 
 			// Cleanup
-			for _, event := range resp {
+			for _, event := range resp.Items {
 				pool.Exec(context.TODO(), "DELETE FROM auditlog WHERE eventname=$1 uid=$2 event=$3 timestamp=$4",
 					event.Name,
 					event.UserId,
@@ -1,541 +1,441 @@
 package adminservice
 
 import (
-	"encoding/json"
 	"errors"
-	"log/slog"
 	"net/http"
+	"strconv"
 
 	"github.com/moonmoon1919/go-api-reference/internal/cache"
 	"github.com/moonmoon1919/go-api-reference/internal/middleware"
 	"github.com/moonmoon1919/go-api-reference/internal/requests"
 	"github.com/moonmoon1919/go-api-reference/internal/responses"
+	"github.com/moonmoon1919/go-api-reference/internal/store"
 	"github.com/moonmoon1919/go-api-reference/pkg/events"
+	"github.com/moonmoon1919/go-api-reference/pkg/users"
 )
 
 const (
-	msgNotFoundError     = "NOT_FOUND"
-	msgJsonMarshallError = "JSON_MARSHAL_ERROR"
-	msgServiceError      = "SERVICE_ERROR"
-	msgControllerError   = "CONTROLLER_ERROR"
-	keyError             = "ERROR"
-	errInvalidLimit      = "LIMIT_MUST_BE_INTEGER"
-	errLimitOutOfRange   = "LIMIT_OUT_OF_RANGE"
-	errInvalidPage       = "PAGE_MUST_BE_INTEGER"
-	errPageOutOfRange    = "PAGE_OUT_OF_RANGE"
-	errMissingUser       = "USER_MISSING"
-	pathValId            = "id"
+	errInvalidLimit    = "LIMIT_MUST_BE_INTEGER"
+	errLimitOutOfRange = "LIMIT_OUT_OF_RANGE"
+	errInvalidPage     = "PAGE_MUST_BE_INTEGER"
+	errPageOutOfRange  = "PAGE_OUT_OF_RANGE"
+	errInvalidCursor   = "CURSOR_INVALID"
+	errInvalidTotal    = "TOTAL_MUST_BE_EXACT_OR_ESTIMATED"
+	errLimitTooLarge   = "LIMIT_TOO_LARGE"
+	errCursorWithPage  = "CURSOR_WITH_PAGE"
+	errInvalidRevision = "REVISION_MUST_BE_INTEGER"
+	errNegativeQuota   = "QUOTA_MUST_NOT_BE_NEGATIVE"
+	pathValId          = "id"
+	pathValRevision    = "rev"
 )
 
+var missingUserError = errors.New("requesting user is missing from the context")
+var invalidRevisionError = errors.New("revision must be a positive integer")
+
 type Controller struct {
 	Service Service
 	Cache   cache.Cacher
+	Cursors requests.CursorCodec
 }
 
-// MARK: User
-func (c Controller) AddUser(w http.ResponseWriter, r *http.Request) {
-	var request CreateUserRequest
-	if err := requests.LoadRequestBody(w, r, &request); err != nil {
-		return
+/*
+The errors a client can cause, from the service and from reading the request
+*/
+var problems = responses.NewRegistry().
+	RegisterFunc(requests.BodyProblem).
+	Register(userServiceNotFound, http.StatusNotFound, responses.NOT_FOUND).
+	Register(exampleServiceNotFound, http.StatusNotFound, responses.NOT_FOUND).
+	Register(revisionServiceNotFound, http.StatusNotFound, responses.NOT_FOUND).
+	Register(users.NegativeQuotaError, http.StatusBadRequest, errNegativeQuota).
+	Register(limitToLargeError, http.StatusBadRequest, errLimitTooLarge).
+	Register(invalidPageError, http.StatusBadRequest, errPageOutOfRange).
+	Register(invalidRevisionError, http.StatusBadRequest, errInvalidRevision).
+	Register(requests.InvalidLimitError, http.StatusBadRequest, errInvalidLimit).
+	Register(requests.LimitTooLowError, http.StatusBadRequest, errLimitOutOfRange).
+	Register(requests.InvalidPageError, http.StatusBadRequest, errInvalidPage).
+	Register(requests.PageTooLowError, http.StatusBadRequest, errPageOutOfRange).
+	Register(requests.InvalidTotalError, http.StatusBadRequest, errInvalidTotal).
+	Register(requests.CursorWithPageError, http.StatusBadRequest, errCursorWithPage).
+	Register(requests.InvalidCursorError, http.StatusBadRequest, errInvalidCursor)
+
+/*
+Adapts a controller method to an http.HandlerFunc, errors are written as problems
+*/
+func Handle(h responses.Handler) http.HandlerFunc {
+	return problems.Handle(h)
+}
+
+func requestingUser(r *http.Request) (middleware.RequestingUser, error) {
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		return middleware.RequestingUser{}, missingUserError
+	}
+
+	return user, nil
+}
+
+/*
+Events are paged with page only, see AuditStorer
+*/
+func eventPagination(r *http.Request) (store.Pagination, error) {
+	limit, page, err := requests.GetPaginationParameters(r)
+	if err != nil {
+		return store.Pagination{}, err
 	}
 
-	err := c.Service.AddUser(r.Context(), request.UserId)
+	count, err := requests.GetCountMode(r)
 	if err != nil {
-		slog.LogAttrs(
-			r.Context(),
-			slog.LevelError,
-			msgServiceError,
-			slog.String(keyError, err.Error()),
-		)
+		return store.Pagination{}, err
+	}
 
-		responses.WriteInternalServerErrorResponse(w)
-		return
+	return store.Pagination{Limit: limit, Page: page, Count: count}, nil
+}
+
+/*
+Headers for a list response, with Link headers when there are cursors
+*/
+func listHeaders(r *http.Request, next, prev string) responses.Headers {
+	headers := responses.Headers{responses.NoCachePrivate()}
+
+	if links, ok := responses.PageLinks(r.URL, next, prev); ok {
+		headers = append(headers, links)
 	}
 
-	responses.WriteCreatedResponse(w, nil, &responses.Headers{
-		responses.NoCachePrivate(),
-	})
+	return headers
 }
 
-func (c Controller) GetUser(w http.ResponseWriter, r *http.Request) {
+// MARK: User
+func (c Controller) AddUser(r *http.Request) (responses.Response, error) {
+	var request CreateUserRequest
+	if err := requests.DecodeBody(r, &request); err != nil {
+		return responses.Response{}, err
+	}
+
+	if err := c.Service.AddUser(r.Context(), request.UserId); err != nil {
+		return responses.Response{}, err
+	}
+
+	return responses.Created(nil, responses.NoCachePrivate()), nil
+}
+
+func (c Controller) GetUser(r *http.Request) (responses.Response, error) {
 	id, err := requests.LoadPathValue(r, pathValId)
 	if err != nil {
-		responses.WriteInternalServerErrorResponse(w)
-		return
+		return responses.Response{}, err
 	}
 
 	data, err := c.Service.GetUser(r.Context(), id)
 	if err != nil {
-		switch {
-		case errors.Is(err, userServiceNotFound):
-			// Not found is not necessarily an error from our perspective
-			// So we log it as info
-			slog.LogAttrs(
-				r.Context(),
-				slog.LevelInfo,
-				notFoundMsg,
-				slog.Any(logKeyId, id),
-			)
-			responses.WriteNotFoundResponse(w)
-			return
-		default:
-			slog.LogAttrs(
-				r.Context(),
-				slog.LevelError,
-				msgServiceError,
-				slog.Any(keyError, err),
-			)
-			responses.WriteInternalServerErrorResponse(w)
-			return
-		}
-	}
-
-	resp := NewGetUserResponseFromUser(&data)
-	respBytes, err := json.Marshal(resp)
-
-	if err != nil {
-		slog.LogAttrs(
-			r.Context(),
-			slog.LevelError,
-			msgJsonMarshallError,
-			slog.String(keyError, err.Error()),
-		)
-
-		responses.WriteInternalServerErrorResponse(w)
-		return
-	}
-
-	// Content-Digest
-	digest := responses.CalculateContentDigest(&respBytes)
-
-	responses.WriteSuccessResponse(
-		w,
-		&respBytes,
-		&responses.Headers{
-			responses.NoCachePrivate(),
-			responses.ContentDigest(digest, responses.SHA256),
-		},
-	)
-
-	return
+		return responses.Response{}, err
+	}
+
+	return responses.OK(NewGetUserResponseFromUser(&data), responses.NoCachePrivate()), nil
 }
 
-func (c Controller) ListUsers(w http.ResponseWriter, r *http.Request) {
-	limit, page, err := requests.GetPaginationParameters(r)
+func (c Controller) ListUsers(r *http.Request) (responses.Response, error) {
+	pagination, err := requests.GetCursorPaginationParameters(r, c.Cursors)
 	if err != nil {
-		responses.WriteBadRequestResponse(w, err.Error())
-		return
+		return responses.Response{}, err
 	}
 
-	data, err := c.Service.ListUsers(r.Context(), limit, page)
+	data, err := c.Service.ListUsers(r.Context(), pagination)
 	if err != nil {
-		switch {
-		// Dont log client errors
-		case errors.Is(err, limitToLargeError):
-			responses.WriteBadRequestResponse(w, err.Error())
-			return
-		case errors.Is(err, invalidPageError):
-			responses.WriteBadRequestResponse(w, err.Error())
-			return
-		default:
-			slog.LogAttrs(
-				r.Context(),
-				slog.LevelError,
-				msgServiceError,
-				slog.String(keyError, err.Error()),
-			)
+		return responses.Response{}, err
+	}
+
+	resp := NewListUsersResponseFromPage(data, pagination, c.Cursors)
+
+	return responses.OK(resp, listHeaders(r, resp.NextCursor, resp.PrevCursor)...), nil
+}
 
-			responses.WriteInternalServerErrorResponse(w)
-			return
-		}
+func (c Controller) DeleteUser(r *http.Request) (responses.Response, error) {
+	id, err := requests.LoadPathValue(r, pathValId)
+	if err != nil {
+		return responses.Response{}, err
 	}
 
-	resp := NewListUsersResponseFromUsers(&data)
-	respBytes, err := json.Marshal(resp)
+	if err := c.Service.DeleteUser(r.Context(), id); err != nil {
+		return responses.Response{}, err
+	}
+
+	return responses.NoContent(responses.NoCachePrivate()), nil
+}
+
+// MARK: Quota
+func (c Controller) SetQuota(r *http.Request) (responses.Response, error) {
+	id, err := requests.LoadPathValue(r, pathValId)
 	if err != nil {
-		slog.LogAttrs(
-			r.Context(),
-			slog.LevelError,
-			msgJsonMarshallError,
-			slog.String(keyError, err.Error()),
-		)
+		return responses.Response{}, err
+	}
 
-		responses.WriteInternalServerErrorResponse(w)
+	var request SetQuotaRequest
+	if err := requests.DecodeBody(r, &request); err != nil {
+		return responses.Response{}, err
 	}
 
-	digest := responses.CalculateContentDigest(&respBytes)
+	if err := c.Service.SetQuota(r.Context(), id, request.Quota()); err != nil {
+		return responses.Response{}, err
+	}
 
-	responses.WriteSuccessResponse(
-		w,
-		&respBytes,
-		&responses.Headers{
-			responses.NoCachePrivate(),
-			responses.ContentDigest(digest, responses.SHA256),
-		},
-	)
+	return responses.NoContent(responses.NoCachePrivate()), nil
 }
 
-func (c Controller) DeleteUser(w http.ResponseWriter, r *http.Request) {
-	if id, err := requests.LoadPathValue(r, pathValId); err != nil {
-		responses.WriteInternalServerErrorResponse(w)
-		return
-	} else {
-		err := c.Service.DeleteUser(r.Context(), id)
-
-		if err != nil {
-			switch {
-			case errors.Is(err, userServiceNotFound):
-				// Log client errors as info
-				slog.LogAttrs(
-					r.Context(),
-					slog.LevelInfo,
-					msgNotFoundError,
-					slog.String(keyError, err.Error()),
-				)
-
-				responses.WriteNotFoundResponse(w)
-				return
-			default:
-				slog.LogAttrs(
-					r.Context(),
-					slog.LevelInfo,
-					msgServiceError,
-					slog.String(keyError, err.Error()),
-				)
-
-				responses.WriteInternalServerErrorResponse(w)
-				return
-			}
-		}
-	}
-
-	responses.WriteNoContentResponse(w, &responses.Headers{
-		responses.NoCachePrivate(),
-	})
+func (c Controller) ResetQuota(r *http.Request) (responses.Response, error) {
+	id, err := requests.LoadPathValue(r, pathValId)
+	if err != nil {
+		return responses.Response{}, err
+	}
+
+	if err := c.Service.ResetQuota(r.Context(), id); err != nil {
+		return responses.Response{}, err
+	}
+
+	return responses.NoContent(responses.NoCachePrivate()), nil
+}
+
+/*
+The usage report, every user with what they're storing and their quota
+*/
+func (c Controller) ListUsage(r *http.Request) (responses.Response, error) {
+	pagination, err := requests.GetCursorPaginationParameters(r, c.Cursors)
+	if err != nil {
+		return responses.Response{}, err
+	}
+
+	data, err := c.Service.ListUsage(r.Context(), pagination)
+	if err != nil {
+		return responses.Response{}, err
+	}
+
+	resp := NewListUsageResponseFromPage(data, pagination, c.Cursors)
+
+	return responses.OK(resp, listHeaders(r, resp.NextCursor, resp.PrevCursor)...), nil
 }
 
 // MARK: Example
-func (c Controller) GetExample(w http.ResponseWriter, r *http.Request) {
+func (c Controller) GetExample(r *http.Request) (responses.Response, error) {
 	id, err := requests.LoadPathValue(r, pathValId)
 	if err != nil {
-		slog.LogAttrs(
-			r.Context(),
-			slog.LevelError,
-			msgControllerError,
-			slog.String(errKey, err.Error()),
-		)
-
-		responses.WriteInternalServerErrorResponse(w)
-		return
+		return responses.Response{}, err
 	}
 
 	data, err := c.Service.GetExample(r.Context(), id)
 	if err != nil {
-		switch {
-		case errors.Is(err, exampleServiceNotFound):
-			// Log client errors as info
-			slog.LogAttrs(
-				r.Context(),
-				slog.LevelInfo,
-				msgNotFoundError,
-				slog.String(keyError, err.Error()),
-			)
-
-			responses.WriteNotFoundResponse(w)
-			return
-		default:
-			slog.LogAttrs(
-				r.Context(),
-				slog.LevelInfo,
-				msgServiceError,
-				slog.String(keyError, err.Error()),
-			)
-
-			responses.WriteInternalServerErrorResponse(w)
-			return
-		}
-	}
-
-	resp := NewGetExampleResponseFromExample(data)
-	respBytes, err := json.Marshal(resp)
-	if err != nil {
-		slog.LogAttrs(
-			r.Context(),
-			slog.LevelError,
-			msgJsonMarshallError,
-			slog.String(keyError, err.Error()),
-		)
-
-		responses.WriteInternalServerErrorResponse(w)
-		return
-	}
-
-	digest := responses.CalculateContentDigest(&respBytes)
-
-	responses.WriteSuccessResponse(
-		w,
-		&respBytes,
-		&responses.Headers{
-			responses.NoCachePrivate(),
-			responses.ContentDigest(digest, responses.SHA256),
-		},
-	)
+		return responses.Response{}, err
+	}
+
+	return responses.OK(NewGetExampleResponseFromExample(data), responses.NoCachePrivate()), nil
 }
 
-func (c Controller) GetExamplesForUser(w http.ResponseWriter, r *http.Request) {
+func (c Controller) GetExamplesForUser(r *http.Request) (responses.Response, error) {
 	userId, err := requests.LoadPathValue(r, pathValId)
 	if err != nil {
-		slog.LogAttrs(
-			r.Context(),
-			slog.LevelError,
-			msgControllerError,
-			slog.String(errKey, err.Error()),
-		)
-
-		responses.WriteInternalServerErrorResponse(w)
-		return
+		return responses.Response{}, err
 	}
 
-	limit, page, err := requests.GetPaginationParameters(r)
+	pagination, err := requests.GetCursorPaginationParameters(r, c.Cursors)
 	if err != nil {
-		responses.WriteBadRequestResponse(w, err.Error())
-		return
+		return responses.Response{}, err
 	}
 
-	data, err := c.Service.GetExamplesForUser(r.Context(), userId, limit, page)
+	data, err := c.Service.GetExamplesForUser(r.Context(), userId, pagination)
 	if err != nil {
-		switch {
-		case errors.Is(err, limitToLargeError):
-			responses.WriteBadRequestResponse(w, err.Error())
-			return
-		case errors.Is(err, invalidPageError):
-			responses.WriteBadRequestResponse(w, err.Error())
-			return
-		default:
-			slog.LogAttrs(
-				r.Context(),
-				slog.LevelError,
-				msgServiceError,
-				slog.String(keyError, err.Error()),
-			)
+		return responses.Response{}, err
+	}
+
+	resp := NewListExampleResponseFromPage(data, pagination, c.Cursors)
+
+	return responses.OK(resp, listHeaders(r, resp.NextCursor, resp.PrevCursor)...), nil
+}
 
-			responses.WriteInternalServerErrorResponse(w)
-			return
-		}
+func (c Controller) DeleteExample(r *http.Request) (responses.Response, error) {
+	id, err := requests.LoadPathValue(r, pathValId)
+	if err != nil {
+		return responses.Response{}, err
 	}
 
-	resp := NewListExampleResponseFromExamples(data)
-	respByes, err := json.Marshal(resp)
+	user, err := requestingUser(r)
 	if err != nil {
-		slog.LogAttrs(
-			r.Context(),
-			slog.LevelError,
-			msgJsonMarshallError,
-			slog.String(keyError, err.Error()),
-		)
+		return responses.Response{}, err
+	}
 
-		responses.WriteInternalServerErrorResponse(w)
-		return
+	if err := c.Service.DeleteExample(r.Context(), user.Id, id); err != nil {
+		return responses.Response{}, err
 	}
 
-	digest := responses.CalculateContentDigest(&respByes)
+	// Clear the cache
+	c.Cache.Delete(r.Context(), id)
+
+	return responses.NoContent(responses.NoCachePrivate()), nil
+}
+
+// MARK: Audit
+func (c Controller) GetEventsForItem(r *http.Request) (responses.Response, error) {
+	id, err := requests.LoadPathValue(r, pathValId)
+	if err != nil {
+		return responses.Response{}, err
+	}
 
-	responses.WriteSuccessResponse(
-		w,
-		&respByes,
-		&responses.Headers{
-			responses.NoCachePrivate(),
-			responses.ContentDigest(digest, responses.SHA256),
-		},
-	)
+	pagination, err := eventPagination(r)
+	if err != nil {
+		return responses.Response{}, err
+	}
+
+	data, err := c.Service.GetEventsForItem(r.Context(), id, pagination)
+	if err != nil {
+		return responses.Response{}, err
+	}
 
-	return
+	return responses.OK(NewListEventsFromPage(data, pagination), responses.NoCachePrivate()), nil
 }
 
-func (c Controller) DeleteExample(w http.ResponseWriter, r *http.Request) {
+func (c Controller) GetEventsForUser(r *http.Request) (responses.Response, error) {
 	id, err := requests.LoadPathValue(r, pathValId)
 	if err != nil {
-		slog.LogAttrs(
-			r.Context(),
-			slog.LevelError,
-			msgControllerError,
-			slog.String(errKey, err.Error()),
-		)
+		return responses.Response{}, err
+	}
 
-		responses.WriteInternalServerErrorResponse(w)
-		return
+	pagination, err := eventPagination(r)
+	if err != nil {
+		return responses.Response{}, err
 	}
 
-	user, ok := middleware.UserFromContext(r.Context())
-	if !ok {
-		slog.LogAttrs(
-			r.Context(),
-			slog.LevelError,
-			msgServiceError,
-			slog.String(keyError, errMissingUser),
-		)
-		responses.WriteInternalServerErrorResponse(w)
-		return
-	}
-
-	err = c.Service.DeleteExample(r.Context(), user.Id, id)
-	if err != nil {
-		switch {
-		case errors.Is(err, exampleServiceNotFound):
-			// Log client errors as info
-			slog.LogAttrs(
-				r.Context(),
-				slog.LevelInfo,
-				msgNotFoundError,
-				slog.String(keyError, err.Error()),
-			)
-
-			responses.WriteNotFoundResponse(w)
-			return
-		default:
-			slog.LogAttrs(
-				r.Context(),
-				slog.LevelError,
-				msgServiceError,
-				slog.String(keyError, err.Error()),
-			)
-
-			responses.WriteInternalServerErrorResponse(w)
-			return
-		}
+	// Check if we're filtering by event name
+	eventName := r.URL.Query().Get("eventName")
+
+	var data store.Page[events.Event]
+	if eventName == "" {
+		data, err = c.Service.GetEventsForUser(r.Context(), id, pagination)
+	} else {
+		data, err = c.Service.GetByEventAndUser(r.Context(), id, eventName, pagination)
 	}
 
-	// Clear the cache
-	c.Cache.Delete(r.Context(), id)
+	if err != nil {
+		return responses.Response{}, err
+	}
+
+	return responses.OK(NewListEventsFromPage(data, pagination), responses.NoCachePrivate()), nil
+}
+
+// MARK: Trash
+func (c Controller) ListTrash(r *http.Request) (responses.Response, error) {
+	pagination, err := requests.GetCursorPaginationParameters(r, c.Cursors)
+	if err != nil {
+		return responses.Response{}, err
+	}
+
+	data, err := c.Service.ListTrash(r.Context(), pagination)
+	if err != nil {
+		return responses.Response{}, err
+	}
 
-	responses.WriteNoContentResponse(w, &responses.Headers{
-		responses.NoCachePrivate(),
-	})
+	resp := NewListExampleResponseFromPage(data, pagination, c.Cursors)
 
-	return
+	return responses.OK(resp, listHeaders(r, resp.NextCursor, resp.PrevCursor)...), nil
 }
 
-// MARK: Audit
-func (c Controller) GetEventsForItem(w http.ResponseWriter, r *http.Request) {
+func (c Controller) PurgeExample(r *http.Request) (responses.Response, error) {
 	id, err := requests.LoadPathValue(r, pathValId)
 	if err != nil {
-		slog.LogAttrs(
-			r.Context(),
-			slog.LevelError,
-			msgControllerError,
-			slog.String(logKeyErr, err.Error()),
-		)
-
-		responses.WriteInternalServerErrorResponse(w)
-		return
+		return responses.Response{}, err
 	}
 
-	limit, page, err := requests.GetPaginationParameters(r)
+	user, err := requestingUser(r)
 	if err != nil {
-		responses.WriteBadRequestResponse(w, err.Error())
-		return
+		return responses.Response{}, err
+	}
+
+	if err := c.Service.PurgeExample(r.Context(), user.Id, id); err != nil {
+		return responses.Response{}, err
 	}
 
-	data, err := c.Service.GetEventsForItem(r.Context(), id, limit, page)
+	// Clear the cache
+	c.Cache.Delete(r.Context(), id)
+
+	return responses.NoContent(responses.NoCachePrivate()), nil
+}
+
+// MARK: Revisions
+/*
+Reads the revision path value, it must be a revision number
+*/
+func revisionPathValue(r *http.Request) (int, error) {
+	value, err := requests.LoadPathValue(r, pathValRevision)
 	if err != nil {
-		slog.LogAttrs(
-			r.Context(),
-			slog.LevelError,
-			msgServiceError,
-			slog.String(keyError, err.Error()),
-		)
+		return 0, err
+	}
+
+	revision, err := strconv.Atoi(value)
+	if err != nil || revision < 1 {
+		return 0, invalidRevisionError
+	}
 
-		responses.WriteInternalServerErrorResponse(w)
-		return
+	return revision, nil
+}
+
+func (c Controller) ListRevisions(r *http.Request) (responses.Response, error) {
+	id, err := requests.LoadPathValue(r, pathValId)
+	if err != nil {
+		return responses.Response{}, err
 	}
 
-	// Marshal response
-	resp := NewListEventsFromEventsList(&data)
-	respBytes, err := json.Marshal(resp)
+	pagination, err := requests.GetCursorPaginationParameters(r, c.Cursors)
 	if err != nil {
-		slog.LogAttrs(
-			r.Context(),
-			slog.LevelError,
-			msgJsonMarshallError,
-			slog.String(logKeyErr, err.Error()),
-		)
+		return responses.Response{}, err
+	}
 
-		responses.WriteInternalServerErrorResponse(w)
-		return
+	data, err := c.Service.ListRevisions(r.Context(), id, pagination)
+	if err != nil {
+		return responses.Response{}, err
 	}
 
-	// Generate content digest
-	digest := responses.CalculateContentDigest(&respBytes)
+	resp := NewListRevisionResponseFromPage(data, pagination, c.Cursors)
 
-	// Write response
-	responses.WriteSuccessResponse(w, &respBytes, &responses.Headers{
-		responses.NoCachePrivate(),
-		responses.ContentDigest(digest, responses.SHA256),
-	})
+	return responses.OK(resp, listHeaders(r, resp.NextCursor, resp.PrevCursor)...), nil
 }
 
-func (c Controller) GetEventsForUser(w http.ResponseWriter, r *http.Request) {
+func (c Controller) GetRevision(r *http.Request) (responses.Response, error) {
 	id, err := requests.LoadPathValue(r, pathValId)
 	if err != nil {
-		slog.LogAttrs(
-			r.Context(),
-			slog.LevelError,
-			msgControllerError,
-			slog.String(logKeyErr, err.Error()),
-		)
-
-		responses.WriteInternalServerErrorResponse(w)
-		return
+		return responses.Response{}, err
 	}
 
-	limit, page, err := requests.GetPaginationParameters(r)
+	revision, err := revisionPathValue(r)
 	if err != nil {
-		responses.WriteBadRequestResponse(w, err.Error())
-		return
+		return responses.Response{}, err
 	}
 
-	// Check if we're filtering by event name
-	eventName := r.URL.Query().Get("eventName")
+	data, err := c.Service.GetRevision(r.Context(), id, revision)
+	if err != nil {
+		return responses.Response{}, err
+	}
 
-	var data []events.Event
-	var svcErr error
+	return responses.OK(NewRevisionResponseFromRevision(data), responses.NoCachePrivate()), nil
+}
 
-	if eventName == "" {
-		data, svcErr = c.Service.GetEventsForUser(r.Context(), id, limit, page)
-	} else {
-		data, svcErr = c.Service.GetByEventAndUser(r.Context(), id, eventName, limit, page)
+func (c Controller) RevertExample(r *http.Request) (responses.Response, error) {
+	id, err := requests.LoadPathValue(r, pathValId)
+	if err != nil {
+		return responses.Response{}, err
 	}
 
-	if svcErr != nil {
-		slog.LogAttrs(
-			r.Context(),
-			slog.LevelError,
-			msgServiceError,
-			slog.String(keyError, err.Error()),
-		)
+	revision, err := revisionPathValue(r)
+	if err != nil {
+		return responses.Response{}, err
+	}
 
-		responses.WriteInternalServerErrorResponse(w)
-		return
+	user, err := requestingUser(r)
+	if err != nil {
+		return responses.Response{}, err
 	}
 
-	// Marshal response
-	resp := NewListEventsFromEventsList(&data)
-	respBytes, err := json.Marshal(resp)
+	data, err := c.Service.RevertExample(r.Context(), user.Id, id, revision)
 	if err != nil {
-		slog.LogAttrs(
-			r.Context(),
-			slog.LevelError,
-			msgJsonMarshallError,
-			slog.String(logKeyErr, err.Error()),
-		)
+		return responses.Response{}, err
 	}
 
-	// Generate content digest
-	digest := responses.CalculateContentDigest(&respBytes)
+	// Clear the cache, the owner's ETag moved on
+	c.Cache.Delete(r.Context(), id)
 
-	// Write response
-	responses.WriteSuccessResponse(w, &respBytes, &responses.Headers{
-		responses.NoCachePrivate(),
-		responses.ContentDigest(digest, responses.SHA256),
-	})
+	return responses.OK(NewGetExampleResponseFromExample(data), responses.NoCachePrivate()), nil
 }
@@ -3,7 +3,9 @@ package adminservice
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
@@ -14,6 +16,9 @@ import (
 	"github.com/moonmoon1919/go-api-reference/internal/bus"
 	"github.com/moonmoon1919/go-api-reference/internal/cache"
 	"github.com/moonmoon1919/go-api-reference/internal/middleware"
+	"github.com/moonmoon1919/go-api-reference/internal/requests"
+	"github.com/moonmoon1919/go-api-reference/internal/responses"
+	"github.com/moonmoon1919/go-api-reference/internal/store"
 	"github.com/moonmoon1919/go-api-reference/pkg/example"
 	"github.com/moonmoon1919/go-api-reference/pkg/users"
 )
@@ -54,7 +59,7 @@ func TestControllerAddUser(t *testing.T) {
 			request := httptest.NewRequest(http.MethodPut, "/admin/users", strings.NewReader(string(body)))
 
 			// When
-			controller.AddUser(tc.responseWriter, request)
+			Handle(controller.AddUser)(tc.responseWriter, request)
 
 			// Then
 			if tc.responseWriter.Code != tc.expectedStatus {
@@ -113,7 +118,7 @@ func TestControllerGetUser(t *testing.T) {
 			req.SetPathValue("id", id)
 
 			// When
-			controller.GetUser(tc.responseWriter, req)
+			Handle(controller.GetUser)(tc.responseWriter, req)
 
 			// Then
 			if tc.responseWriter.Code != tc.expectedStatus {
@@ -138,6 +143,9 @@ func TestControllerListUsers(t *testing.T) {
 		request        *http.Request
 		expectedStatus int
 		numItems       int
+		expectedItems  int
+		expectedMore   bool
+		expectedTotal  *responses.Total
 	}{
 		{
 			name:           "PassingCase-50-items",
@@ -145,6 +153,17 @@ func TestControllerListUsers(t *testing.T) {
 			request:        httptest.NewRequest(http.MethodGet, "/admin/users?limit=50&page=1", nil),
 			expectedStatus: http.StatusOK,
 			numItems:       50,
+			expectedItems:  50,
+		},
+		{
+			name:           "PassingCase-HasMoreWithTotal",
+			responseWriter: httptest.NewRecorder(),
+			request:        httptest.NewRequest(http.MethodGet, "/admin/users?limit=2&page=1&total=estimated", nil),
+			expectedStatus: http.StatusOK,
+			numItems:       3,
+			expectedItems:  2,
+			expectedMore:   true,
+			expectedTotal:  &responses.Total{Count: 3},
 		},
 		{
 			name:           "PassingCase-0-items",
@@ -167,6 +186,13 @@ func TestControllerListUsers(t *testing.T) {
 			expectedStatus: http.StatusBadRequest,
 			numItems:       0,
 		},
+		{
+			name:           "FailingCase-InvalidTotal",
+			responseWriter: httptest.NewRecorder(),
+			request:        httptest.NewRequest(http.MethodGet, "/admin/users?limit=50&total=maybe", nil),
+			expectedStatus: http.StatusBadRequest,
+			numItems:       0,
+		},
 	}
 
 	for _, tc := range tests {
@@ -186,7 +212,7 @@ func TestControllerListUsers(t *testing.T) {
 			}
 
 			// When
-			controller.ListUsers(tc.responseWriter, tc.request)
+			Handle(controller.ListUsers)(tc.responseWriter, tc.request)
 
 			// Then
 			if tc.responseWriter.Code != tc.expectedStatus {
@@ -198,8 +224,16 @@ func TestControllerListUsers(t *testing.T) {
 				var actual ListUserResponse
 				json.Unmarshal(tc.responseWriter.Body.Bytes(), &actual)
 
-				if len(actual.Users) != tc.numItems {
-					t.Errorf("expected %d users, found %d", tc.numItems, len(actual.Users))
+				if len(actual.Items) != tc.expectedItems {
+					t.Errorf("expected %d users, found %d", tc.expectedItems, len(actual.Items))
+				}
+
+				if actual.HasMore != tc.expectedMore {
+					t.Errorf("expected has_more %t, got %t", tc.expectedMore, actual.HasMore)
+				}
+
+				if !reflect.DeepEqual(actual.Total, tc.expectedTotal) {
+					t.Errorf("expected total %+v, got %+v", tc.expectedTotal, actual.Total)
 				}
 			}
 
@@ -244,7 +278,7 @@ func TestControllerDeleteUser(t *testing.T) {
 			initialResponseWriter := httptest.NewRecorder()
 			initialRequest := httptest.NewRequest(http.MethodPut, "/admin/users", strings.NewReader(string(body)))
 
-			controller.AddUser(initialResponseWriter, initialRequest)
+			Handle(controller.AddUser)(initialResponseWriter, initialRequest)
 
 			if initialResponseWriter.Code != http.StatusCreated {
 				t.Errorf("expected status %d, got %d when putting initial data", http.StatusCreated, initialResponseWriter.Code)
@@ -254,7 +288,7 @@ func TestControllerDeleteUser(t *testing.T) {
 			req.SetPathValue("id", tc.userId)
 
 			// When
-			controller.DeleteUser(tc.responseWriter, req)
+			Handle(controller.DeleteUser)(tc.responseWriter, req)
 
 			// Then
 			if tc.validate {
@@ -268,6 +302,114 @@ func TestControllerDeleteUser(t *testing.T) {
 	}
 }
 
+// MARK: QUOTA
+func TestControllerSetQuota(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		create         bool
+		expectedStatus int
+	}{
+		{
+			name:           "PassingCase",
+			body:           `{"examples": 10, "bytes": 1024}`,
+			create:         true,
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:           "PassingCase-Unlimited",
+			body:           `{"examples": -1, "bytes": -1}`,
+			create:         true,
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:           "PassingCase-Zero",
+			body:           `{"examples": 0, "bytes": 0}`,
+			create:         true,
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:           "FailingCase-Negative",
+			body:           `{"examples": -2, "bytes": 1024}`,
+			create:         true,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "FailingCase-MissingField",
+			body:           `{"examples": 10}`,
+			create:         true,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "FailingCase-NotFound",
+			body:           `{"examples": 10, "bytes": 1024}`,
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			us := newInMemoryUserStore()
+			controller := Controller{Service: Service{UserStore: us, ExampleStore: newInMemoryExampleStore()}}
+			userId := uuid.NewString()
+
+			if tc.create {
+				us.Add(context.TODO(), users.NewUserWithId(userId))
+			}
+
+			req := httptest.NewRequest(http.MethodPut, fmt.Sprintf("/admin/users/%s/quota", userId), strings.NewReader(tc.body))
+			req.SetPathValue("id", userId)
+			w := httptest.NewRecorder()
+
+			// When
+			Handle(controller.SetQuota)(w, req)
+
+			// Then
+			if w.Code != tc.expectedStatus {
+				t.Errorf("expected status %d, got %d", tc.expectedStatus, w.Code)
+			}
+
+			// The quota is only replaced again by ResetQuota
+			if w.Code == http.StatusNoContent {
+				reset := httptest.NewRecorder()
+				Handle(controller.ResetQuota)(reset, req)
+
+				if _, ok := us.quotas[userId]; ok || reset.Code != http.StatusNoContent {
+					t.Errorf("expected the quota to be reset, got %d", reset.Code)
+				}
+			}
+		})
+	}
+}
+
+func TestControllerListUsage(t *testing.T) {
+	// Given
+	us := newInMemoryUserStore()
+	controller := Controller{
+		Service: Service{UserStore: us, ExampleStore: newInMemoryExampleStore(), Quota: users.Quota{Examples: 100}},
+		Cursors: requests.NewCursorCodec("secret"),
+	}
+
+	userId := uuid.NewString()
+	us.Add(context.TODO(), users.NewUserWithId(userId))
+	us.usage[userId] = users.Usage{Examples: 3, Bytes: 30}
+
+	w := httptest.NewRecorder()
+
+	// When
+	Handle(controller.ListUsage)(w, httptest.NewRequest(http.MethodGet, "/admin/usage", nil))
+
+	// Then
+	var resp ListUsageResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+
+	expected := UsageResponse{UserId: userId, Examples: 3, Bytes: 30, Quota: QuotaResponse{Examples: 100, Default: true}}
+	if w.Code != http.StatusOK || len(resp.Items) != 1 || resp.Items[0] != expected {
+		t.Errorf("expected %+v, got %d %+v", expected, w.Code, resp.Items)
+	}
+}
+
 // MARK: GET EXAMPLE
 func TestControllerGetExample(t *testing.T) {
 	tests := []struct {
@@ -304,11 +446,13 @@ func TestControllerGetExample(t *testing.T) {
 			var id string = "1234"
 
 			if tc.expectedStatus != http.StatusNotFound {
-				d, _ := es.add(context.TODO(), example.Example{UserId: tc.userId, Id: uuid.NewString()})
+				d, _ := es.add(context.TODO(), example.Example{UserId: tc.userId, Id: uuid.NewString(), Tags: []string{"intro"}, Status: example.Published})
 				id = d.Id
 				expected = GetExampleResponse{
 					Id:     d.Id,
 					UserId: tc.userId,
+					Tags:   []string{"intro"},
+					Status: "published",
 				}
 			}
 
@@ -316,7 +460,7 @@ func TestControllerGetExample(t *testing.T) {
 			req.SetPathValue("id", id)
 
 			// When
-			controller.GetExample(tc.responseWriter, req)
+			Handle(controller.GetExample)(tc.responseWriter, req)
 
 			// Then
 			if tc.responseWriter.Code != tc.expectedStatus {
@@ -343,6 +487,7 @@ func TestControllerGetExamplesForUser(t *testing.T) {
 		userId         string
 		page           int
 		limit          int
+		cursor         string
 	}{
 		{
 			name:           "PassingCase-SomeItems",
@@ -380,6 +525,16 @@ func TestControllerGetExamplesForUser(t *testing.T) {
 			limit:          50,
 			page:           0,
 		},
+		{
+			name:           "FailingCase-InvalidCursor",
+			responseWriter: httptest.NewRecorder(),
+			userId:         uuid.NewString(),
+			expectedStatus: http.StatusBadRequest,
+			numItems:       0,
+			limit:          50,
+			page:           1,
+			cursor:         "not-a-cursor",
+		},
 	}
 
 	cache := cache.NewInMemoryCache()
@@ -387,11 +542,16 @@ func TestControllerGetExamplesForUser(t *testing.T) {
 	us := newInMemoryUserStore()
 	as := newInMemoryAuditLogStore()
 	service := Service{ExampleStore: es, UserStore: us, AuditStore: as}
-	controller := Controller{Service: service, Cache: cache}
+	controller := Controller{Service: service, Cache: cache, Cursors: requests.NewCursorCodec("secret")}
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/admin/users/%s/examples?page=%d&limit=%d", tc.userId, tc.page, tc.limit), nil)
+			target := fmt.Sprintf("/admin/users/%s/examples?page=%d&limit=%d", tc.userId, tc.page, tc.limit)
+			if tc.cursor != "" {
+				target = fmt.Sprintf("/admin/users/%s/examples?limit=%d&cursor=%s", tc.userId, tc.limit, tc.cursor)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, target, nil)
 			req.SetPathValue("id", tc.userId)
 
 			// Given
@@ -400,7 +560,7 @@ func TestControllerGetExamplesForUser(t *testing.T) {
 			}
 
 			// When
-			controller.GetExamplesForUser(tc.responseWriter, req)
+			Handle(controller.GetExamplesForUser)(tc.responseWriter, req)
 
 			// Then
 			if tc.responseWriter.Code != tc.expectedStatus {
@@ -477,7 +637,7 @@ func TestControllerDeleteExample(t *testing.T) {
 			r := request.WithContext(ctx)
 
 			// When
-			controller.DeleteExample(tc.responseWriter, r)
+			Handle(controller.DeleteExample)(tc.responseWriter, r)
 
 			// Then
 			if tc.responseWriter.Code != tc.expectedStatus {
@@ -502,6 +662,100 @@ func TestControllerDeleteExample(t *testing.T) {
 	}
 }
 
+// MARK: PURGE EXAMPLE
+func TestControllerPurgeExample(t *testing.T) {
+	tests := []struct {
+		name           string
+		responseWriter *httptest.ResponseRecorder
+		expectedStatus int
+		create         bool
+		trash          bool
+	}{
+		{
+			name:           "PassingCase",
+			responseWriter: httptest.NewRecorder(),
+			expectedStatus: http.StatusNoContent,
+			create:         true,
+			trash:          true,
+		},
+		{
+			name:           "NotTrashedCase",
+			responseWriter: httptest.NewRecorder(),
+			expectedStatus: http.StatusNotFound,
+			create:         true,
+			trash:          false,
+		},
+		{
+			name:           "NotFoundCase",
+			responseWriter: httptest.NewRecorder(),
+			expectedStatus: http.StatusNotFound,
+			create:         false,
+			trash:          false,
+		},
+	}
+
+	b := bus.NewFake()
+	cache := cache.NewInMemoryCache()
+	es := newInMemoryExampleStore()
+	us := newInMemoryUserStore()
+	as := newInMemoryAuditLogStore()
+	service := Service{ExampleStore: es, UserStore: us, AuditStore: as, Bus: b}
+	controller := Controller{Service: service, Cache: cache}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			var id string = "1234"
+
+			if tc.create {
+				ex, err := es.add(context.TODO(), example.Example{UserId: uuid.NewString()})
+				if err != nil {
+					t.Errorf("unexpected error adding example, %d", err)
+				}
+
+				id = ex.Id
+			}
+
+			if tc.trash {
+				if err := es.Delete(context.TODO(), id, "123"); err != nil {
+					t.Errorf("unexpected error trashing example, %s", err.Error())
+				}
+			}
+
+			request := httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/admin/trash/%s", id), nil)
+			request.SetPathValue("id", id)
+
+			ctx := middleware.ContextWithUser(request.Context(), middleware.RequestingUser{
+				Id:          "123",
+				Role:        middleware.AdministratorRole,
+				Permissions: middleware.NewPermissionSet([]string{"admin::example::delete"}),
+			})
+			r := request.WithContext(ctx)
+
+			// When
+			Handle(controller.PurgeExample)(tc.responseWriter, r)
+
+			// Then
+			if tc.responseWriter.Code != tc.expectedStatus {
+				t.Errorf("expected status code to be %d, got %d", tc.expectedStatus, tc.responseWriter.Code)
+			}
+
+			if tc.create && !tc.trash {
+				// Examples outside the trash are untouched
+				if _, err := es.Get(context.TODO(), id); err != nil {
+					t.Errorf("expected item to still exist, got %s", err.Error())
+				}
+			}
+
+			if tc.trash {
+				if _, ok := es.items[id]; ok {
+					t.Errorf("expected item to be purged, item found")
+				}
+			}
+		})
+	}
+}
+
 // MARK: GET EVENTS FOR ITEM
 func TestControllerGetEventsForItem(t *testing.T) {
 	tests := []struct {
@@ -524,18 +778,205 @@ func TestControllerGetEventsForItem(t *testing.T) {
 // MARK: GET EVENTS FOR USER
 func TestControllerGetEventsForUser(t *testing.T) {
 	tests := []struct {
-		name string
+		name           string
+		query          string
+		expectedStatus int
+		expectedCode   string
 	}{
-		{},
+		{
+			name:           "PassingCase",
+			query:          "",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "PassingCase-EventName",
+			query:          "?eventName=example.created",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "FailingCase-LimitTooLarge",
+			query:          "?limit=51",
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   errLimitTooLarge,
+		},
+		{
+			name:           "FailingCase-LimitTooLarge-EventName",
+			query:          "?eventName=example.created&limit=51",
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   errLimitTooLarge,
+		},
+	}
+
+	service := Service{ExampleStore: newInMemoryExampleStore(), UserStore: newInMemoryUserStore(), AuditStore: newInMemoryAuditLogStore()}
+	controller := Controller{Service: service, Cache: cache.NewInMemoryCache()}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			id := uuid.NewString()
+			request := httptest.NewRequest(http.MethodGet, "/admin/users/"+id+"/events"+tc.query, nil)
+			request.SetPathValue(pathValId, id)
+			w := httptest.NewRecorder()
+
+			// When
+			Handle(controller.GetEventsForUser)(w, request)
+
+			// Then
+			if w.Code != tc.expectedStatus {
+				t.Errorf("expected status code to be %d, got %d", tc.expectedStatus, w.Code)
+			}
+
+			if tc.expectedCode == "" {
+				return
+			}
+
+			var problem responses.Problem
+			json.Unmarshal(w.Body.Bytes(), &problem)
+
+			if problem.Code != tc.expectedCode {
+				t.Errorf("expected code %s, got %s", tc.expectedCode, problem.Code)
+			}
+		})
+	}
+}
+
+// MARK: PROBLEMS
+func TestProblems(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		expectedStatus int
+		expectedCode   string
+		expectedLevel  slog.Level
+	}{
+		{name: "UserNotFound", err: userServiceNotFound, expectedStatus: http.StatusNotFound, expectedCode: responses.NOT_FOUND, expectedLevel: slog.LevelInfo},
+		{name: "ExampleNotFound", err: exampleServiceNotFound, expectedStatus: http.StatusNotFound, expectedCode: responses.NOT_FOUND, expectedLevel: slog.LevelInfo},
+		{name: "RevisionNotFound", err: revisionServiceNotFound, expectedStatus: http.StatusNotFound, expectedCode: responses.NOT_FOUND, expectedLevel: slog.LevelInfo},
+		{name: "NegativeQuota", err: users.NegativeQuotaError, expectedStatus: http.StatusBadRequest, expectedCode: errNegativeQuota, expectedLevel: slog.LevelInfo},
+		{name: "LimitTooLarge", err: limitToLargeError, expectedStatus: http.StatusBadRequest, expectedCode: errLimitTooLarge, expectedLevel: slog.LevelInfo},
+		{name: "PageOutOfRange", err: invalidPageError, expectedStatus: http.StatusBadRequest, expectedCode: errPageOutOfRange, expectedLevel: slog.LevelInfo},
+		{name: "InvalidRevision", err: invalidRevisionError, expectedStatus: http.StatusBadRequest, expectedCode: errInvalidRevision, expectedLevel: slog.LevelInfo},
+		{name: "InvalidLimit", err: requests.InvalidLimitError, expectedStatus: http.StatusBadRequest, expectedCode: errInvalidLimit, expectedLevel: slog.LevelInfo},
+		{name: "LimitTooLow", err: requests.LimitTooLowError, expectedStatus: http.StatusBadRequest, expectedCode: errLimitOutOfRange, expectedLevel: slog.LevelInfo},
+		{name: "InvalidPage", err: requests.InvalidPageError, expectedStatus: http.StatusBadRequest, expectedCode: errInvalidPage, expectedLevel: slog.LevelInfo},
+		{name: "PageTooLow", err: requests.PageTooLowError, expectedStatus: http.StatusBadRequest, expectedCode: errPageOutOfRange, expectedLevel: slog.LevelInfo},
+		{name: "InvalidTotal", err: requests.InvalidTotalError, expectedStatus: http.StatusBadRequest, expectedCode: errInvalidTotal, expectedLevel: slog.LevelInfo},
+		{name: "CursorWithPage", err: requests.CursorWithPageError, expectedStatus: http.StatusBadRequest, expectedCode: errCursorWithPage, expectedLevel: slog.LevelInfo},
+		{name: "InvalidCursor", err: requests.InvalidCursorError, expectedStatus: http.StatusBadRequest, expectedCode: errInvalidCursor, expectedLevel: slog.LevelInfo},
+		{name: "InvalidBody", err: requests.DecodeError{Err: requests.InvalidBodyError()}, expectedStatus: http.StatusBadRequest, expectedCode: "INVALID_REQUEST_BODY", expectedLevel: slog.LevelInfo},
+		{name: "Wrapped", err: fmt.Errorf("%w: %s", userServiceNotFound, "id"), expectedStatus: http.StatusNotFound, expectedCode: responses.NOT_FOUND, expectedLevel: slog.LevelInfo},
+		{name: "MissingUser", err: missingUserError, expectedStatus: http.StatusInternalServerError, expectedCode: responses.INTERNAL_SERVER_ERROR, expectedLevel: slog.LevelError},
+		{name: "Unregistered", err: errors.New("connection refused"), expectedStatus: http.StatusInternalServerError, expectedCode: responses.INTERNAL_SERVER_ERROR, expectedLevel: slog.LevelError},
 	}
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
 			// Given
+			err := tc.err
 
 			// When
+			problem, level := problems.Classify(err)
 
 			// Then
+			if problem.Status != tc.expectedStatus || problem.Code != tc.expectedCode {
+				t.Errorf("expected %d %s, got %d %s", tc.expectedStatus, tc.expectedCode, problem.Status, problem.Code)
+			}
+
+			if level != tc.expectedLevel {
+				t.Errorf("expected level %s, got %s", tc.expectedLevel, level)
+			}
+		})
+	}
+}
+
+// MARK: REVERT EXAMPLE
+func TestControllerRevertExample(t *testing.T) {
+	tests := []struct {
+		name           string
+		revision       string
+		trash          bool
+		expectedStatus int
+	}{
+		{
+			name:           "PassingCase",
+			revision:       "1",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "RevisionNotFoundCase",
+			revision:       "9",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "TrashedCase",
+			revision:       "1",
+			trash:          true,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "InvalidRevisionCase",
+			revision:       "latest",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	b := bus.NewFake()
+	cache := cache.NewInMemoryCache()
+	es := newInMemoryExampleStore()
+	service := Service{ExampleStore: es, Bus: b}
+	controller := Controller{Service: service, Cache: cache}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			ex, err := es.add(context.TODO(), example.Example{UserId: uuid.NewString(), Message: "first"})
+			if err != nil {
+				t.Errorf("unexpected error adding example, %s", err.Error())
+			}
+
+			// The owner changes the message after the first revision
+			ex.Message = "second"
+			es.put(ex)
+
+			if tc.trash {
+				es.Delete(context.TODO(), ex.Id, "123")
+			}
+
+			request := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/admin/examples/%s/revisions/%s/revert", ex.Id, tc.revision), nil)
+			request.SetPathValue("id", ex.Id)
+			request.SetPathValue("rev", tc.revision)
+
+			ctx := middleware.ContextWithUser(request.Context(), middleware.RequestingUser{
+				Id:          "123",
+				Role:        middleware.AdministratorRole,
+				Permissions: middleware.NewPermissionSet([]string{"admin::example::update"}),
+			})
+			w := httptest.NewRecorder()
+
+			// When
+			Handle(controller.RevertExample)(w, request.WithContext(ctx))
+
+			// Then
+			if w.Code != tc.expectedStatus {
+				t.Errorf("expected status code to be %d, got %d", tc.expectedStatus, w.Code)
+			}
+
+			if tc.expectedStatus == http.StatusOK {
+				item, _ := es.Get(context.TODO(), ex.Id)
+				if item.Message != "first" {
+					t.Errorf("expected message to be reverted to first, got %s", item.Message)
+				}
+
+				// Admins never see the content they revert
+				if strings.Contains(w.Body.String(), "first") {
+					t.Errorf("expected the response to leave out the message, got %s", w.Body.String())
+				}
+
+				revisions, _ := es.ListRevisions(context.TODO(), ex.Id, store.Pagination{Limit: 10, Page: 1})
+				if len(revisions.Items) != 2 {
+					t.Errorf("expected the revert to write a revision, got %d revisions", len(revisions.Items))
+				}
+			}
 		})
 	}
 }
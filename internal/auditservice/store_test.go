@@ -20,17 +20,10 @@ type testConfig struct {
 }
 
 func buildConfig() testConfig {
+	loader := config.NewLoader()
+
 	return testConfig{
-		database: store.Config{
-			Host:     config.NewEnvironmentSource("DB_HOST"),
-			User:     config.NewEnvironmentSource("DB_USER"),
-			Password: config.NewEnvironmentSource("DB_PASS"),
-			Database: config.NewEnvironmentSource("DB_NAME"),
-			Schema: config.NewFirst(
-				config.NewEnvironmentSource("DB_SCHEMA"),
-				config.NewDefaultValueSource("schemas"),
-			),
-		},
+		database: store.LoadConfig(loader, config.EnvironmentLookup),
 	}
 }
 
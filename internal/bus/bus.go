@@ -16,18 +16,23 @@ type Subscribers []Subscriber
 type Busser interface {
 	Listen(done <-chan struct{})
 	Notify(event events.Event)
+	// Hands a batch of events to the subscribers in one go
+	NotifyAll(batch []events.Event)
 	CloseAndDrain(ctx context.Context)
 }
 
+/*
+Events travel in batches, a single event is a batch of one
+*/
 type Bus struct {
-	ch          chan events.Event
+	ch          chan []events.Event
 	subscribers Subscribers
 	wg          sync.WaitGroup
 }
 
 func New(subscribers Subscribers) Bus {
 	return Bus{
-		ch:          make(chan events.Event),
+		ch:          make(chan []events.Event),
 		subscribers: subscribers,
 		wg:          sync.WaitGroup{},
 	}
@@ -38,31 +43,34 @@ func (b *Bus) Listen(done <-chan struct{}) {
 		select {
 		case <-done:
 			return
-		case v := <-b.ch:
-			for _, subscriber := range b.subscribers {
-				b.wg.Add(1)
-				go func(v events.Event, sub Subscriber) {
-					defer b.wg.Done()
-					sub(v)
-				}(v, subscriber)
-			}
+		case batch := <-b.ch:
+			b.dispatch(batch)
 		}
 	}
 }
 
+/*
+Each subscriber gets the batch on its own goroutine and works through it in order
+*/
+func (b *Bus) dispatch(batch []events.Event) {
+	for _, subscriber := range b.subscribers {
+		b.wg.Add(1)
+		go func(batch []events.Event, sub Subscriber) {
+			defer b.wg.Done()
+			for _, v := range batch {
+				sub(v)
+			}
+		}(batch, subscriber)
+	}
+}
+
 func (b *Bus) CloseAndDrain(ctx context.Context) {
 	// Drain the work queue by closing it and processing all remaining items
 	close(b.ch)
 	slog.LogAttrs(ctx, slog.LevelInfo, QUEUE_CLOSED)
 
-	for v := range b.ch {
-		for _, subscriber := range b.subscribers {
-			b.wg.Add(1)
-			go func(v events.Event, sub Subscriber) {
-				defer b.wg.Done()
-				sub(v)
-			}(v, subscriber)
-		}
+	for batch := range b.ch {
+		b.dispatch(batch)
 	}
 
 	// Wait for all in flight work to finish
@@ -80,5 +88,13 @@ func (b *Bus) CloseAndDrain(ctx context.Context) {
 }
 
 func (b *Bus) Notify(event events.Event) {
-	b.ch <- event
+	b.ch <- []events.Event{event}
+}
+
+func (b *Bus) NotifyAll(batch []events.Event) {
+	if len(batch) == 0 {
+		return
+	}
+
+	b.ch <- batch
 }
@@ -23,6 +23,10 @@ func (b *FakeBus) Notify(event events.Event) {
 	b.Messages = append(b.Messages, event)
 }
 
+func (b *FakeBus) NotifyAll(batch []events.Event) {
+	b.Messages = append(b.Messages, batch...)
+}
+
 func (b *FakeBus) CloseAndDrain(ctx context.Context) {
 	fmt.Printf("Not implemented")
 }
@@ -0,0 +1,257 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var CircuitOpenError = errors.New("cache circuit is open")
+
+// MARK: State
+type State int
+
+const (
+	Closed State = iota
+	Open
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// MARK: Config
+type BreakerConfig struct {
+	// Name reported by the health check
+	Name string
+	// Maximum time a single cache operation may take before it counts as a failure
+	Timeout time.Duration
+	// Number of consecutive failures that opens the circuit
+	FailureThreshold int
+	// How long the circuit stays open before letting a probe through
+	OpenDuration time.Duration
+	// Number of successful probes required to close the circuit again
+	HalfOpenSuccesses int
+}
+
+func DefaultBreakerConfig() BreakerConfig {
+	return BreakerConfig{
+		Name:              "cache",
+		Timeout:           250 * time.Millisecond,
+		FailureThreshold:  5,
+		OpenDuration:      10 * time.Second,
+		HalfOpenSuccesses: 2,
+	}
+}
+
+/*
+The lookuper interface lets the breaker tell a cache miss apart from a cache failure.
+
+Cacher.Get only reports whether a value was found, which is all callers need,
+but the breaker needs to know when the backing store is unreachable.
+*/
+type lookuper interface {
+	lookup(ctx context.Context, key string) (string, bool, error)
+}
+
+// MARK: Breaker
+/*
+A Cacher that stops calling the wrapped cache once it starts failing.
+
+While the circuit is open writes fail fast with CircuitOpenError and reads are
+treated as a miss. After OpenDuration a single probe is let through at a time,
+the circuit closes again after HalfOpenSuccesses successful probes.
+*/
+type CircuitBreaker struct {
+	cache  Cacher
+	config BreakerConfig
+	now    func() time.Time
+
+	mu        sync.Mutex
+	state     State
+	failures  int
+	successes int
+	probing   bool
+	openedAt  time.Time
+}
+
+func NewCircuitBreaker(cache Cacher, config BreakerConfig) *CircuitBreaker {
+	return &CircuitBreaker{
+		cache:  cache,
+		config: config,
+		now:    time.Now,
+		state:  Closed,
+	}
+}
+
+func (b *CircuitBreaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.state
+}
+
+func (b *CircuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case Open:
+		if b.now().Sub(b.openedAt) < b.config.OpenDuration {
+			return false
+		}
+
+		b.state = HalfOpen
+		b.successes = 0
+		b.probing = true
+		return true
+	case HalfOpen:
+		// Only one probe at a time, everyone else is short circuited
+		if b.probing {
+			return false
+		}
+
+		b.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+func (b *CircuitBreaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err == nil {
+		switch b.state {
+		case HalfOpen:
+			b.probing = false
+			b.successes++
+
+			if b.successes >= b.config.HalfOpenSuccesses {
+				b.state = Closed
+				b.failures = 0
+			}
+		default:
+			b.failures = 0
+		}
+
+		return
+	}
+
+	switch b.state {
+	case HalfOpen:
+		b.trip()
+	default:
+		b.failures++
+
+		if b.failures >= b.config.FailureThreshold {
+			b.trip()
+		}
+	}
+}
+
+func (b *CircuitBreaker) trip() {
+	b.state = Open
+	b.openedAt = b.now()
+	b.failures = 0
+	b.successes = 0
+	b.probing = false
+}
+
+/*
+Records the outcome of an operation
+
+A cancelled caller is not the cache's fault, so it is not held against it
+*/
+func (b *CircuitBreaker) done(parent context.Context, err error) {
+	if err != nil && parent.Err() != nil {
+		b.release()
+		return
+	}
+
+	b.record(err)
+}
+
+func (b *CircuitBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
+
+func (b *CircuitBreaker) Set(ctx context.Context, key, val string) error {
+	if !b.allow() {
+		return CircuitOpenError
+	}
+
+	opCtx, cancel := context.WithTimeout(ctx, b.config.Timeout)
+	defer cancel()
+
+	err := b.cache.Set(opCtx, key, val)
+	b.done(ctx, err)
+
+	return err
+}
+
+func (b *CircuitBreaker) Get(ctx context.Context, key string) (string, bool) {
+	if !b.allow() {
+		return EMPTY_STRING, false
+	}
+
+	opCtx, cancel := context.WithTimeout(ctx, b.config.Timeout)
+	defer cancel()
+
+	l, ok := b.cache.(lookuper)
+	if !ok {
+		v, found := b.cache.Get(opCtx, key)
+		b.done(ctx, nil)
+
+		return v, found
+	}
+
+	v, found, err := l.lookup(opCtx, key)
+	b.done(ctx, err)
+
+	if err != nil {
+		return EMPTY_STRING, false
+	}
+
+	return v, found
+}
+
+func (b *CircuitBreaker) Delete(ctx context.Context, key string) error {
+	if !b.allow() {
+		return CircuitOpenError
+	}
+
+	opCtx, cancel := context.WithTimeout(ctx, b.config.Timeout)
+	defer cancel()
+
+	err := b.cache.Delete(opCtx, key)
+	b.done(ctx, err)
+
+	return err
+}
+
+// MARK: Health
+func (b *CircuitBreaker) Name() string {
+	return b.config.Name
+}
+
+func (b *CircuitBreaker) Status() (string, bool) {
+	state := b.State()
+
+	return state.String(), state == Closed
+}
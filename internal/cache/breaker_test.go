@@ -0,0 +1,176 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+var unavailableError = errors.New("cache unavailable")
+
+/*
+A cache that fails while down is true
+*/
+type flakyCache struct {
+	down  bool
+	calls int
+}
+
+func (f *flakyCache) Set(ctx context.Context, key, val string) error {
+	f.calls++
+
+	if f.down {
+		return unavailableError
+	}
+
+	return nil
+}
+
+func (f *flakyCache) Get(ctx context.Context, key string) (string, bool) {
+	v, ok, _ := f.lookup(ctx, key)
+
+	return v, ok
+}
+
+func (f *flakyCache) lookup(ctx context.Context, key string) (string, bool, error) {
+	f.calls++
+
+	if f.down {
+		return EMPTY_STRING, false, unavailableError
+	}
+
+	return "etag", true, nil
+}
+
+func (f *flakyCache) Delete(ctx context.Context, key string) error {
+	f.calls++
+
+	if f.down {
+		return unavailableError
+	}
+
+	return nil
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	tests := []struct {
+		name          string
+		failures      int
+		waitForProbe  bool
+		recover       bool
+		probes        int
+		expectedState State
+	}{
+		{
+			name:          "PassingCase-StaysClosedBelowThreshold",
+			failures:      2,
+			expectedState: Closed,
+		},
+		{
+			name:          "PassingCase-OpensAtThreshold",
+			failures:      3,
+			expectedState: Open,
+		},
+		{
+			name:          "PassingCase-FailedProbeReopens",
+			failures:      3,
+			waitForProbe:  true,
+			recover:       false,
+			probes:        1,
+			expectedState: Open,
+		},
+		{
+			name:          "PassingCase-HalfOpenAfterOneProbe",
+			failures:      3,
+			waitForProbe:  true,
+			recover:       true,
+			probes:        1,
+			expectedState: HalfOpen,
+		},
+		{
+			name:          "PassingCase-ClosesAfterSuccessfulProbes",
+			failures:      3,
+			waitForProbe:  true,
+			recover:       true,
+			probes:        2,
+			expectedState: Closed,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			now := time.Now()
+			backing := &flakyCache{down: true}
+			breaker := NewCircuitBreaker(backing, BreakerConfig{
+				Name:              "cache",
+				Timeout:           time.Second,
+				FailureThreshold:  3,
+				OpenDuration:      time.Minute,
+				HalfOpenSuccesses: 2,
+			})
+			breaker.now = func() time.Time { return now }
+
+			// When
+			for range tc.failures {
+				breaker.Set(context.TODO(), "key", "value")
+			}
+
+			if tc.waitForProbe {
+				now = now.Add(2 * time.Minute)
+				backing.down = !tc.recover
+
+				for range tc.probes {
+					breaker.Set(context.TODO(), "key", "value")
+				}
+			}
+
+			// Then
+			if state := breaker.State(); state != tc.expectedState {
+				t.Errorf("expected state %s, got %s", tc.expectedState, state)
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerOpen(t *testing.T) {
+	// Given
+	backing := &flakyCache{down: true}
+	breaker := NewCircuitBreaker(backing, BreakerConfig{
+		Name:              "cache",
+		Timeout:           time.Second,
+		FailureThreshold:  1,
+		OpenDuration:      time.Minute,
+		HalfOpenSuccesses: 1,
+	})
+
+	breaker.Set(context.TODO(), "key", "value")
+	calls := backing.calls
+
+	// When
+	setErr := breaker.Set(context.TODO(), "key", "value")
+	_, found := breaker.Get(context.TODO(), "key")
+	deleteErr := breaker.Delete(context.TODO(), "key")
+
+	// Then
+	if !errors.Is(setErr, CircuitOpenError) {
+		t.Errorf("expected set to fail with %s, got %v", CircuitOpenError, setErr)
+	}
+
+	if found {
+		t.Errorf("expected get to be a miss while the circuit is open")
+	}
+
+	if !errors.Is(deleteErr, CircuitOpenError) {
+		t.Errorf("expected delete to fail with %s, got %v", CircuitOpenError, deleteErr)
+	}
+
+	if backing.calls != calls {
+		t.Errorf("expected no calls to the cache while open, got %d", backing.calls-calls)
+	}
+
+	if status, healthy := breaker.Status(); healthy || status != "open" {
+		t.Errorf("expected unhealthy open status, got %s", status)
+	}
+}
@@ -3,5 +3,16 @@ package cache
 import "github.com/moonmoon1919/go-api-reference/internal/config"
 
 type Config struct {
-	Host config.Configurator
+	Host string
+}
+
+/*
+Resolves the cache config, problems are collected by the loader
+*/
+func LoadConfig(l *config.Loader, lookup config.Lookup) Config {
+	var c Config
+
+	config.Bind(l, "CACHE_HOST", lookup("CACHE_HOST"), &c.Host)
+
+	return c
 }
@@ -1,21 +1,19 @@
 package cache
 
-import "context"
+import (
+	"context"
+	"sync"
+	"time"
+)
 
 type InMemoryCache struct {
 	items map[string]string
 }
 
-func (m *InMemoryCache) Set(ctx context.Context, key string, val *[]byte) (string, error) {
-	genVal, err := generateCacheValue(val)
+func (m *InMemoryCache) Set(ctx context.Context, key, val string) error {
+	m.items[key] = val
 
-	if err != nil {
-		return "", err
-	}
-
-	m.items[key] = genVal
-
-	return genVal, nil
+	return nil
 }
 
 func (m *InMemoryCache) Get(ctx context.Context, key string) (string, bool) {
@@ -24,6 +22,12 @@ func (m *InMemoryCache) Get(ctx context.Context, key string) (string, bool) {
 	return val, ok
 }
 
+func (m *InMemoryCache) lookup(ctx context.Context, key string) (string, bool, error) {
+	val, ok := m.Get(ctx, key)
+
+	return val, ok, nil
+}
+
 func (m *InMemoryCache) Delete(ctx context.Context, key string) error {
 	delete(m.items, key)
 
@@ -35,3 +39,56 @@ func NewInMemoryCache() *InMemoryCache {
 		items: make(map[string]string),
 	}
 }
+
+// MARK: Reserver
+type reservation struct {
+	val     string
+	expires time.Time
+}
+
+/*
+Reservations are checked and set under one lock, so only one caller wins a key
+*/
+type InMemoryReserver struct {
+	mu    sync.Mutex
+	items map[string]reservation
+	now   func() time.Time
+}
+
+func (m *InMemoryReserver) Reserve(ctx context.Context, key, val string, ttl time.Duration) (string, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if existing, ok := m.items[key]; ok && m.now().Before(existing.expires) {
+		return existing.val, false, nil
+	}
+
+	m.items[key] = reservation{val: val, expires: m.now().Add(ttl)}
+
+	return val, true, nil
+}
+
+func (m *InMemoryReserver) Set(ctx context.Context, key, val string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.items[key] = reservation{val: val, expires: m.now().Add(ttl)}
+
+	return nil
+}
+
+func (m *InMemoryReserver) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.items, key)
+
+	return nil
+}
+
+func NewInMemoryReserver() *InMemoryReserver {
+	return &InMemoryReserver{
+		items: make(map[string]reservation),
+		now:   time.Now,
+	}
+}
@@ -2,42 +2,61 @@ package cache
 
 import (
 	"context"
+	"time"
 
 	"github.com/valkey-io/valkey-go"
 )
 
 const EMPTY_STRING string = ""
 
+// Matches the TTL of the read through cache in the stores
+const defaultTTL = time.Minute
+
 type ValkeyCache struct {
 	client valkey.Client
+	ttl    time.Duration
 }
 
-func (v *ValkeyCache) Set(ctx context.Context, key string, val *[]byte) (string, error) {
-	genVal, err := generateCacheValue(val)
+/*
+Stores a value with a TTL
+
+Entries expire so a value left behind by a failed write (e.g., while the
+circuit breaker is open) is only served for a bounded amount of time
+*/
+func (v *ValkeyCache) Set(ctx context.Context, key, val string) error {
+	err := v.client.Do(
+		ctx,
+		v.client.B().Set().Key(key).Value(val).Ex(v.ttl).Build()).Error()
 
 	if err != nil {
-		return EMPTY_STRING, err
+		return err
 	}
 
-	err = v.client.Do(
-		ctx,
-		v.client.B().Set().Key(key).Value(genVal).Build()).Error()
+	return nil
+}
+
+func (v *ValkeyCache) Get(ctx context.Context, key string) (string, bool) {
+	val, ok, err := v.lookup(ctx, key)
 
 	if err != nil {
-		return EMPTY_STRING, err
+		return EMPTY_STRING, false
 	}
 
-	return genVal, nil
+	return val, ok
 }
 
-func (v *ValkeyCache) Get(ctx context.Context, key string) (string, bool) {
+func (v *ValkeyCache) lookup(ctx context.Context, key string) (string, bool, error) {
 	val, err := v.client.Do(ctx, v.client.B().Get().Key(key).Build()).ToString()
 
+	if valkey.IsValkeyNil(err) {
+		return EMPTY_STRING, false, nil
+	}
+
 	if err != nil {
-		return EMPTY_STRING, false
+		return EMPTY_STRING, false, err
 	}
 
-	return val, true
+	return val, true, nil
 }
 
 func (v *ValkeyCache) Delete(ctx context.Context, key string) error {
@@ -53,5 +72,45 @@ func (v *ValkeyCache) Delete(ctx context.Context, key string) error {
 func NewValkeyCache(client valkey.Client) *ValkeyCache {
 	return &ValkeyCache{
 		client: client,
+		ttl:    defaultTTL,
 	}
 }
+
+// MARK: Reserver
+type ValkeyReserver struct {
+	client valkey.Client
+}
+
+/*
+SET NX GET checks and sets in one command, the reply is the value that was
+already there or nil when ours was stored
+*/
+func (v *ValkeyReserver) Reserve(ctx context.Context, key, val string, ttl time.Duration) (string, bool, error) {
+	existing, err := v.client.Do(
+		ctx,
+		v.client.B().Set().Key(key).Value(val).Nx().Get().Ex(ttl).Build()).ToString()
+
+	if valkey.IsValkeyNil(err) {
+		return val, true, nil
+	}
+
+	if err != nil {
+		return EMPTY_STRING, false, err
+	}
+
+	return existing, false, nil
+}
+
+func (v *ValkeyReserver) Set(ctx context.Context, key, val string, ttl time.Duration) error {
+	return v.client.Do(
+		ctx,
+		v.client.B().Set().Key(key).Value(val).Ex(ttl).Build()).Error()
+}
+
+func (v *ValkeyReserver) Delete(ctx context.Context, key string) error {
+	return v.client.Do(ctx, v.client.B().Del().Key(key).Build()).Error()
+}
+
+func NewValkeyReserver(client valkey.Client) *ValkeyReserver {
+	return &ValkeyReserver{client: client}
+}
@@ -1,10 +1,8 @@
 package config
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
-	"net/http"
 	"os"
 )
 
@@ -20,6 +18,12 @@ type CustomSource[T any] struct {
 	f func() (T, error)
 }
 
+func NewCustomSource[T any](f func() (T, error)) CustomSource[T] {
+	return CustomSource[T]{
+		f: f,
+	}
+}
+
 func (t CustomSource[T]) Get() (T, error) {
 	return t.f()
 }
@@ -85,45 +89,6 @@ func (e EnvironmentSource) Must() string {
 	return v
 }
 
-// MARK: HTTP
-type httpResponse struct {
-	Value string `json:"Value"`
-}
-
-type HttpSource struct {
-	name    string
-	baseUrl string
-}
-
-func (h HttpSource) Get() (string, error) {
-	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/%s", h.baseUrl, h.name), nil)
-	if err != nil {
-		return EMPTY_STRING, err
-	}
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return EMPTY_STRING, err
-	}
-
-	var parsedResponse httpResponse
-	if err = json.NewDecoder(resp.Body).Decode(&parsedResponse); err != nil {
-		return EMPTY_STRING, err
-	}
-
-	return parsedResponse.Value, nil
-}
-
-func (h HttpSource) Must() string {
-	v, err := h.Get()
-
-	if err != nil {
-		panic(err)
-	}
-
-	return v
-}
-
 type First struct {
 	sources []Configurator
 }
@@ -0,0 +1,280 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MARK: JSON file
+/*
+Reads a key from a JSON file
+
+Nested keys are addressed with dots, e.g., "database.host" for
+{"database": {"host": "localhost"}}. Numbers and booleans are returned as
+they are written in the file.
+
+The file is read on every call to Get so changes are picked up without a restart
+*/
+type JsonFileSource struct {
+	path string
+	key  string
+}
+
+func NewJsonFileSource(path, key string) JsonFileSource {
+	return JsonFileSource{
+		path: path,
+		key:  key,
+	}
+}
+
+func (j JsonFileSource) Get() (string, error) {
+	data, err := os.ReadFile(j.path)
+	if err != nil {
+		return EMPTY_STRING, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var current any
+	if err := decoder.Decode(&current); err != nil {
+		return EMPTY_STRING, fmt.Errorf("cannot parse %s: %w", j.path, err)
+	}
+
+	for _, part := range strings.Split(j.key, ".") {
+		object, ok := current.(map[string]any)
+		if !ok {
+			return EMPTY_STRING, fmt.Errorf("cannot find key %s in %s", j.key, j.path)
+		}
+
+		current, ok = object[part]
+		if !ok {
+			return EMPTY_STRING, fmt.Errorf("cannot find key %s in %s", j.key, j.path)
+		}
+	}
+
+	switch v := current.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return fmt.Sprintf("%t", v), nil
+	default:
+		return EMPTY_STRING, fmt.Errorf("key %s in %s is not a string, number or boolean", j.key, j.path)
+	}
+}
+
+func (j JsonFileSource) Must() string {
+	v, err := j.Get()
+
+	if err != nil {
+		panic(err)
+	}
+
+	return v
+}
+
+// MARK: Dotenv
+/*
+Reads a key from a .env file
+
+Supports the common dotenv syntax:
+  - KEY=value and KEY: value
+  - an optional "export " prefix
+  - single quoted values, taken literally
+  - double quoted values, with \n, \t, \" and \\ escapes
+  - comments, on their own line or after an unquoted value (" #")
+
+The file is read on every call to Get so changes are picked up without a restart
+*/
+type DotenvSource struct {
+	path string
+	key  string
+}
+
+func NewDotenvSource(path, key string) DotenvSource {
+	return DotenvSource{
+		path: path,
+		key:  key,
+	}
+}
+
+func (d DotenvSource) Get() (string, error) {
+	f, err := os.Open(d.path)
+	if err != nil {
+		return EMPTY_STRING, err
+	}
+	defer f.Close()
+
+	values, err := ParseDotenv(f)
+	if err != nil {
+		return EMPTY_STRING, fmt.Errorf("cannot parse %s: %w", d.path, err)
+	}
+
+	v, ok := values[d.key]
+	if !ok {
+		return EMPTY_STRING, fmt.Errorf("cannot find key %s in %s", d.key, d.path)
+	}
+
+	return v, nil
+}
+
+func (d DotenvSource) Must() string {
+	v, err := d.Get()
+
+	if err != nil {
+		panic(err)
+	}
+
+	return v
+}
+
+/*
+Parses the contents of a .env file, later keys overwrite earlier ones
+*/
+func ParseDotenv(r io.Reader) (map[string]string, error) {
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		line = strings.TrimPrefix(line, "export ")
+
+		sep := strings.IndexAny(line, "=:")
+		if sep < 1 {
+			return nil, fmt.Errorf("line %d: expected KEY=value", lineNumber)
+		}
+
+		key := strings.TrimSpace(line[:sep])
+		if strings.ContainsAny(key, " \t") {
+			return nil, fmt.Errorf("line %d: invalid key %q", lineNumber, key)
+		}
+
+		value, err := parseDotenvValue(strings.TrimSpace(line[sep+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+
+		values[key] = value
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
+
+func parseDotenvValue(raw string) (string, error) {
+	if len(raw) == 0 {
+		return EMPTY_STRING, nil
+	}
+
+	switch raw[0] {
+	case '\'':
+		end := strings.IndexByte(raw[1:], '\'')
+		if end < 0 {
+			return EMPTY_STRING, fmt.Errorf("unterminated single quoted value")
+		}
+
+		return raw[1 : end+1], nil
+	case '"':
+		var b strings.Builder
+
+		for i := 1; i < len(raw); i++ {
+			c := raw[i]
+
+			switch {
+			case c == '"':
+				return b.String(), nil
+			case c == '\\' && i+1 < len(raw):
+				i++
+
+				switch raw[i] {
+				case 'n':
+					b.WriteByte('\n')
+				case 't':
+					b.WriteByte('\t')
+				default:
+					b.WriteByte(raw[i])
+				}
+			default:
+				b.WriteByte(c)
+			}
+		}
+
+		return EMPTY_STRING, fmt.Errorf("unterminated double quoted value")
+	default:
+		// Unquoted values end at an inline comment
+		if idx := strings.Index(raw, " #"); idx >= 0 {
+			raw = raw[:idx]
+		}
+
+		return strings.TrimSpace(raw), nil
+	}
+}
+
+// MARK: Directory
+/*
+Reads a key from a directory where every file is a key, e.g., Kubernetes or
+Docker secrets mounted at /run/secrets
+
+The file name is either the key itself or the key in lower case, so DB_PASS
+can be read from /run/secrets/db_pass. A single trailing newline is removed.
+*/
+type DirectorySource struct {
+	dir  string
+	name string
+}
+
+func NewDirectorySource(dir, name string) DirectorySource {
+	return DirectorySource{
+		dir:  dir,
+		name: name,
+	}
+}
+
+func (d DirectorySource) Get() (string, error) {
+	// Keys are file names, never paths
+	if len(d.name) == 0 || filepath.Base(d.name) != d.name {
+		return EMPTY_STRING, fmt.Errorf("invalid key %s", d.name)
+	}
+
+	for _, name := range []string{d.name, strings.ToLower(d.name)} {
+		data, err := os.ReadFile(filepath.Join(d.dir, name))
+		if err != nil {
+			continue
+		}
+
+		value := strings.TrimSuffix(string(data), "\n")
+		value = strings.TrimSuffix(value, "\r")
+
+		return value, nil
+	}
+
+	return EMPTY_STRING, fmt.Errorf("cannot find %s in %s", d.name, d.dir)
+}
+
+func (d DirectorySource) Must() string {
+	v, err := d.Get()
+
+	if err != nil {
+		panic(err)
+	}
+
+	return v
+}
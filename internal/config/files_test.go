@@ -0,0 +1,252 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestJsonFileSource(t *testing.T) {
+	tests := []struct {
+		name       string
+		key        string
+		expected   string
+		errMessage string
+	}{
+		{
+			name:     "PassingCase-TopLevel",
+			key:      "port",
+			expected: ":8080",
+		},
+		{
+			name:     "PassingCase-Nested",
+			key:      "database.host",
+			expected: "localhost",
+		},
+		{
+			name:     "PassingCase-Number",
+			key:      "database.pool",
+			expected: "10",
+		},
+		{
+			name:     "PassingCase-Bool",
+			key:      "profiling",
+			expected: "true",
+		},
+		{
+			name:       "FailingCase-Missing",
+			key:        "database.user",
+			errMessage: "cannot find key database.user",
+		},
+		{
+			name:       "FailingCase-Object",
+			key:        "database",
+			errMessage: "is not a string, number or boolean",
+		},
+	}
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	os.WriteFile(path, []byte(`{"port": ":8080", "profiling": true, "database": {"host": "localhost", "pool": 10}}`), 0o600)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			source := NewJsonFileSource(path, tc.key)
+
+			// When
+			v, err := source.Get()
+
+			// Then
+			var errMessage string
+			if err != nil {
+				errMessage = err.Error()
+			}
+
+			if !strings.Contains(errMessage, tc.errMessage) || (tc.errMessage == "" && err != nil) {
+				t.Errorf("expected error containing %s, got %s", tc.errMessage, errMessage)
+			}
+
+			if v != tc.expected {
+				t.Errorf("expected %s, got %s", tc.expected, v)
+			}
+		})
+	}
+}
+
+func TestParseDotenv(t *testing.T) {
+	tests := []struct {
+		name       string
+		contents   string
+		key        string
+		expected   string
+		errMessage string
+	}{
+		{
+			name:     "PassingCase-Plain",
+			contents: "DB_HOST=localhost",
+			key:      "DB_HOST",
+			expected: "localhost",
+		},
+		{
+			name:     "PassingCase-Colon",
+			contents: "DB_HOST: localhost",
+			key:      "DB_HOST",
+			expected: "localhost",
+		},
+		{
+			name:     "PassingCase-Export",
+			contents: "export DB_HOST=localhost",
+			key:      "DB_HOST",
+			expected: "localhost",
+		},
+		{
+			name:     "PassingCase-Comments",
+			contents: "# database\nDB_HOST=localhost # the host\n",
+			key:      "DB_HOST",
+			expected: "localhost",
+		},
+		{
+			name:     "PassingCase-SingleQuoted",
+			contents: `DB_PASS='pa$$ #word\n'`,
+			key:      "DB_PASS",
+			expected: `pa$$ #word\n`,
+		},
+		{
+			name:     "PassingCase-DoubleQuoted",
+			contents: `GREETING="hello\n\"world\""`,
+			key:      "GREETING",
+			expected: "hello\n\"world\"",
+		},
+		{
+			name:     "PassingCase-ValueWithSeparators",
+			contents: "CACHE_HOST=localhost:6379",
+			key:      "CACHE_HOST",
+			expected: "localhost:6379",
+		},
+		{
+			name:     "PassingCase-Empty",
+			contents: "PROFILING_ENABLED=",
+			key:      "PROFILING_ENABLED",
+			expected: "",
+		},
+		{
+			name:       "FailingCase-NoSeparator",
+			contents:   "DB_HOST",
+			errMessage: "line 1: expected KEY=value",
+		},
+		{
+			name:       "FailingCase-Unterminated",
+			contents:   "A=1\nDB_PASS=\"password",
+			errMessage: "line 2: unterminated double quoted value",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// When
+			values, err := ParseDotenv(strings.NewReader(tc.contents))
+
+			// Then
+			var errMessage string
+			if err != nil {
+				errMessage = err.Error()
+			}
+
+			if errMessage != tc.errMessage {
+				t.Errorf("expected error message %s, got %s", tc.errMessage, errMessage)
+			}
+
+			if v := values[tc.key]; v != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, v)
+			}
+		})
+	}
+}
+
+func TestDirectorySource(t *testing.T) {
+	tests := []struct {
+		name       string
+		key        string
+		expected   string
+		errMessage string
+	}{
+		{
+			name:     "PassingCase-ExactName",
+			key:      "CACHE_HOST",
+			expected: "localhost:6379",
+		},
+		{
+			name:     "PassingCase-LowerCaseName",
+			key:      "DB_PASS",
+			expected: "password",
+		},
+		{
+			name:       "FailingCase-Missing",
+			key:        "DB_USER",
+			errMessage: "cannot find DB_USER",
+		},
+		{
+			name:       "FailingCase-Path",
+			key:        "../DB_PASS",
+			errMessage: "invalid key ../DB_PASS",
+		},
+	}
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "db_pass"), []byte("password\n"), 0o600)
+	os.WriteFile(filepath.Join(dir, "CACHE_HOST"), []byte("localhost:6379"), 0o600)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			source := NewDirectorySource(dir, tc.key)
+
+			// When
+			v, err := source.Get()
+
+			// Then
+			var errMessage string
+			if err != nil {
+				errMessage = err.Error()
+			}
+
+			if !strings.HasPrefix(errMessage, tc.errMessage) || (tc.errMessage == "" && err != nil) {
+				t.Errorf("expected error message %s, got %s", tc.errMessage, errMessage)
+			}
+
+			if v != tc.expected {
+				t.Errorf("expected %s, got %s", tc.expected, v)
+			}
+		})
+	}
+}
+
+func TestFirstWithFileSources(t *testing.T) {
+	// Given
+	dir := t.TempDir()
+	envFile := filepath.Join(dir, ".env")
+	os.WriteFile(envFile, []byte("DB_PASS=from-dotenv\nDB_USER=root\n"), 0o600)
+	os.Mkdir(filepath.Join(dir, "secrets"), 0o700)
+	os.WriteFile(filepath.Join(dir, "secrets", "db_pass"), []byte("from-secret\n"), 0o600)
+
+	lookup := func(key string) *First {
+		return NewFirst(
+			NewDirectorySource(filepath.Join(dir, "secrets"), key),
+			NewDotenvSource(envFile, key),
+		)
+	}
+
+	// When
+	pass := lookup("DB_PASS").Must()
+	user := lookup("DB_USER").Must()
+
+	// Then
+	if pass != "from-secret" {
+		t.Errorf("expected secret to take precedence, got %s", pass)
+	}
+
+	if user != "root" {
+		t.Errorf("expected dotenv fallback, got %s", user)
+	}
+}
@@ -0,0 +1,445 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Config responses are small, anything bigger is a misbehaving server
+	maxHttpResponseBytes = 1 << 20
+	configRefreshFailed  = "CONFIG_REFRESH_FAILED"
+)
+
+var HttpNotFoundError = errors.New("config key not found")
+
+/*
+Returned for responses that are worth retrying (e.g., 503), anything else
+(e.g., 401) fails straight away
+*/
+type httpStatusError struct {
+	status int
+}
+
+func (h httpStatusError) Error() string {
+	return fmt.Sprintf("config server responded with status %d", h.status)
+}
+
+func (h httpStatusError) retryable() bool {
+	return h.status == http.StatusTooManyRequests || h.status >= http.StatusInternalServerError
+}
+
+// MARK: Wire format
+/*
+Response for a single key, GET {baseUrl}/{name}
+*/
+type httpResponse struct {
+	Value string `json:"Value"`
+}
+
+/*
+Response for a batch, GET {baseUrl}?key=A&key=B
+
+Keys the server doesn't know are left out
+*/
+type httpBatchResponse struct {
+	Values map[string]string `json:"Values"`
+}
+
+// MARK: Options
+type HttpOption func(*HttpClient)
+
+/*
+Timeout for a single request, retries get their own timeout
+*/
+func WithTimeout(d time.Duration) HttpOption {
+	return func(c *HttpClient) {
+		c.client.Timeout = d
+	}
+}
+
+/*
+Sends an Authorization: Bearer header, an empty token sends nothing
+*/
+func WithBearerToken(token string) HttpOption {
+	return func(c *HttpClient) {
+		if len(token) != 0 {
+			c.headers.Set("Authorization", "Bearer "+token)
+		}
+	}
+}
+
+func WithHeader(key, value string) HttpOption {
+	return func(c *HttpClient) {
+		c.headers.Set(key, value)
+	}
+}
+
+/*
+Retries failed requests, waiting backoff before the first retry and doubling it every time after
+*/
+func WithRetries(retries int, backoff time.Duration) HttpOption {
+	return func(c *HttpClient) {
+		c.retries = retries
+		c.backoff = backoff
+	}
+}
+
+/*
+Caches values for ttl, 0 disables caching
+*/
+func WithTTL(ttl time.Duration) HttpOption {
+	return func(c *HttpClient) {
+		c.ttl = ttl
+	}
+}
+
+/*
+Resolves many keys in one request when prefetching and refreshing
+*/
+func WithBatch() HttpOption {
+	return func(c *HttpClient) {
+		c.batch = true
+	}
+}
+
+func WithHttpClient(client *http.Client) HttpOption {
+	return func(c *HttpClient) {
+		c.client = client
+	}
+}
+
+// MARK: Client
+/*
+A value, or the error reading it failed with, e.g., a key the server doesn't know
+*/
+type httpCacheEntry struct {
+	value   string
+	err     error
+	expires time.Time
+}
+
+/*
+Client for a remote config server, shared by every HttpSource pointing at it
+*/
+type HttpClient struct {
+	baseUrl string
+	client  *http.Client
+	headers http.Header
+	retries int
+	backoff time.Duration
+	ttl     time.Duration
+	batch   bool
+	now     func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]httpCacheEntry
+}
+
+func NewHttpClient(baseUrl string, opts ...HttpOption) *HttpClient {
+	c := &HttpClient{
+		baseUrl: strings.TrimSuffix(baseUrl, "/"),
+		client:  &http.Client{Timeout: 5 * time.Second},
+		headers: make(http.Header),
+		now:     time.Now,
+		cache:   make(map[string]httpCacheEntry),
+	}
+
+	c.headers.Set("Accept", "application/json")
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+/*
+Returns the value of name, from the cache while it is fresh
+
+Misses and failed batches are cached too, so they aren't asked for again one by one
+*/
+func (c *HttpClient) Fetch(ctx context.Context, name string) (string, error) {
+	if entry, ok := c.cached(name); ok {
+		return entry.value, entry.err
+	}
+
+	var parsed httpResponse
+	err := c.get(ctx, fmt.Sprintf("%s/%s", c.baseUrl, url.PathEscape(name)), &parsed)
+
+	if err != nil {
+		if errors.Is(err, HttpNotFoundError) {
+			missing := c.notFound(name)
+			c.fail([]string{name}, missing)
+
+			return EMPTY_STRING, missing
+		}
+
+		return EMPTY_STRING, err
+	}
+
+	c.store(map[string]string{name: parsed.Value})
+
+	return parsed.Value, nil
+}
+
+/*
+Fetches names into the cache, in a single request in batch mode
+
+Keys the server doesn't know are cached as missing. When the batch fails, keys
+without a fresh value fail with its error until they expire, rather than being
+retried one by one.
+*/
+func (c *HttpClient) Prefetch(ctx context.Context, names ...string) error {
+	if len(names) == 0 {
+		return nil
+	}
+
+	if !c.batch {
+		errs := make([]error, 0)
+
+		for _, name := range names {
+			c.forget(name)
+
+			if _, err := c.Fetch(ctx, name); err != nil && !errors.Is(err, HttpNotFoundError) {
+				errs = append(errs, err)
+			}
+		}
+
+		return errors.Join(errs...)
+	}
+
+	query := url.Values{"key": names}
+
+	var parsed httpBatchResponse
+	if err := c.get(ctx, fmt.Sprintf("%s?%s", c.baseUrl, query.Encode()), &parsed); err != nil {
+		c.fail(names, err)
+		return err
+	}
+
+	c.store(parsed.Values)
+
+	for _, name := range names {
+		if _, ok := parsed.Values[name]; !ok {
+			c.fail([]string{name}, c.notFound(name))
+		}
+	}
+
+	return nil
+}
+
+/*
+Re-fetches every cached key every interval so reads never wait on the server
+
+A failed refresh is logged, the cached values are served until they expire.
+Runs until done is closed.
+*/
+func (c *HttpClient) Refresh(done <-chan struct{}, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		err := c.Prefetch(ctx, c.cachedKeys()...)
+		cancel()
+
+		if err != nil {
+			slog.LogAttrs(context.Background(), slog.LevelWarn, configRefreshFailed, slog.String(logKeyError, err.Error()))
+		}
+	}
+}
+
+/*
+Builds a source for name, matches the signature Lookup.Then expects
+*/
+func (c *HttpClient) Source(name string) Configurator {
+	return NewHttpSource(c, name)
+}
+
+func (c *HttpClient) cached(name string) (httpCacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.cache[name]
+	if !ok || !c.now().Before(entry.expires) {
+		return httpCacheEntry{}, false
+	}
+
+	return entry, true
+}
+
+func (c *HttpClient) cachedKeys() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	keys := make([]string, 0, len(c.cache))
+	for key := range c.cache {
+		keys = append(keys, key)
+	}
+
+	slices.Sort(keys)
+
+	return keys
+}
+
+func (c *HttpClient) store(values map[string]string) {
+	if c.ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expires := c.now().Add(c.ttl)
+	for name, value := range values {
+		c.cache[name] = httpCacheEntry{value: value, expires: expires}
+	}
+}
+
+/*
+Caches err for names, a fresh value is kept so a failed refresh serves it until it expires
+*/
+func (c *HttpClient) fail(names []string, err error) {
+	if c.ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	expires := now.Add(c.ttl)
+
+	for _, name := range names {
+		if entry, ok := c.cache[name]; ok && entry.err == nil && now.Before(entry.expires) {
+			continue
+		}
+
+		c.cache[name] = httpCacheEntry{err: err, expires: expires}
+	}
+}
+
+func (c *HttpClient) notFound(name string) error {
+	return fmt.Errorf("%w: %s at %s", HttpNotFoundError, name, c.baseUrl)
+}
+
+func (c *HttpClient) forget(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.cache, name)
+}
+
+// MARK: Transport
+func (c *HttpClient) get(ctx context.Context, u string, v any) error {
+	var err error
+
+	for attempt := 0; attempt <= c.retries; attempt++ {
+		if attempt > 0 {
+			wait := c.backoff << (attempt - 1)
+
+			select {
+			case <-ctx.Done():
+				return errors.Join(err, ctx.Err())
+			case <-time.After(wait):
+			}
+		}
+
+		var retry bool
+		retry, err = c.getOnce(ctx, u, v)
+
+		if err == nil || !retry {
+			return err
+		}
+	}
+
+	return err
+}
+
+/*
+Makes a single request, reports whether a failure is worth retrying
+*/
+func (c *HttpClient) getOnce(ctx context.Context, u string, v any) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return false, err
+	}
+
+	req.Header = c.headers.Clone()
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		// Network errors and timeouts are retried, unless the caller gave up
+		return ctx.Err() == nil, err
+	}
+
+	defer resp.Body.Close()
+	body := io.LimitReader(resp.Body, maxHttpResponseBytes)
+
+	if resp.StatusCode != http.StatusOK {
+		// Drain so the connection can be reused
+		io.Copy(io.Discard, body)
+
+		if resp.StatusCode == http.StatusNotFound {
+			return false, HttpNotFoundError
+		}
+
+		statusErr := httpStatusError{status: resp.StatusCode}
+		return statusErr.retryable(), statusErr
+	}
+
+	if err := json.NewDecoder(body).Decode(v); err != nil {
+		return false, fmt.Errorf("cannot parse config server response: %w", err)
+	}
+
+	return false, nil
+}
+
+// MARK: Source
+/*
+Reads a key from a config server
+
+Use NewHttpClient to configure timeouts, auth, retries and caching, sources
+for many keys should share one client
+*/
+type HttpSource struct {
+	client *HttpClient
+	name   string
+}
+
+func NewHttpSource(client *HttpClient, name string) HttpSource {
+	return HttpSource{
+		client: client,
+		name:   name,
+	}
+}
+
+func (h HttpSource) Get() (string, error) {
+	return h.client.Fetch(context.Background(), h.name)
+}
+
+func (h HttpSource) Must() string {
+	v, err := h.Get()
+
+	if err != nil {
+		panic(err)
+	}
+
+	return v
+}
@@ -0,0 +1,274 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+/*
+A config server that fails the first `failures` requests with `status`
+*/
+type fakeConfigServer struct {
+	values   map[string]string
+	token    string
+	failures int32
+	status   int
+	requests atomic.Int32
+}
+
+func (f *fakeConfigServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	n := f.requests.Add(1)
+
+	if len(f.token) != 0 && r.Header.Get("Authorization") != "Bearer "+f.token {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if n <= f.failures {
+		w.WriteHeader(f.status)
+		return
+	}
+
+	if keys := r.URL.Query()["key"]; len(keys) > 0 {
+		values := make(map[string]string)
+		for _, key := range keys {
+			if v, ok := f.values[key]; ok {
+				values[key] = v
+			}
+		}
+
+		json.NewEncoder(w).Encode(httpBatchResponse{Values: values})
+		return
+	}
+
+	v, ok := f.values[strings.TrimPrefix(r.URL.Path, "/")]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	json.NewEncoder(w).Encode(httpResponse{Value: v})
+}
+
+func TestHttpSource(t *testing.T) {
+	tests := []struct {
+		name             string
+		key              string
+		token            string
+		failures         int32
+		status           int
+		expected         string
+		expectedRequests int32
+		errMessage       string
+	}{
+		{
+			name:             "PassingCase",
+			key:              "DB_HOST",
+			expected:         "localhost",
+			expectedRequests: 1,
+		},
+		{
+			name:             "PassingCase-BearerToken",
+			key:              "DB_HOST",
+			token:            "secret",
+			expected:         "localhost",
+			expectedRequests: 1,
+		},
+		{
+			name:             "PassingCase-RetriesServerErrors",
+			key:              "DB_HOST",
+			failures:         2,
+			status:           http.StatusServiceUnavailable,
+			expected:         "localhost",
+			expectedRequests: 3,
+		},
+		{
+			name:             "FailingCase-RetriesExhausted",
+			key:              "DB_HOST",
+			failures:         5,
+			status:           http.StatusInternalServerError,
+			expectedRequests: 3,
+			errMessage:       "config server responded with status 500",
+		},
+		{
+			name:             "FailingCase-ClientErrorsAreNotRetried",
+			key:              "DB_HOST",
+			failures:         5,
+			status:           http.StatusForbidden,
+			expectedRequests: 1,
+			errMessage:       "config server responded with status 403",
+		},
+		{
+			name:             "FailingCase-NotFound",
+			key:              "DB_USER",
+			expectedRequests: 1,
+			errMessage:       "config key not found: DB_USER",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			fake := &fakeConfigServer{
+				values:   map[string]string{"DB_HOST": "localhost"},
+				token:    tc.token,
+				failures: tc.failures,
+				status:   tc.status,
+			}
+			srv := httptest.NewServer(fake)
+			defer srv.Close()
+
+			client := NewHttpClient(
+				srv.URL,
+				WithTimeout(time.Second),
+				WithBearerToken(tc.token),
+				WithRetries(2, time.Millisecond),
+			)
+
+			// When
+			v, err := NewHttpSource(client, tc.key).Get()
+
+			// Then
+			var errMessage string
+			if err != nil {
+				errMessage = err.Error()
+			}
+
+			if !strings.HasPrefix(errMessage, tc.errMessage) || (tc.errMessage == "" && err != nil) {
+				t.Errorf("expected error message %s, got %s", tc.errMessage, errMessage)
+			}
+
+			if v != tc.expected {
+				t.Errorf("expected %s, got %s", tc.expected, v)
+			}
+
+			if n := fake.requests.Load(); n != tc.expectedRequests {
+				t.Errorf("expected %d requests, got %d", tc.expectedRequests, n)
+			}
+		})
+	}
+}
+
+func TestHttpClientCache(t *testing.T) {
+	// Given
+	fake := &fakeConfigServer{values: map[string]string{"DB_HOST": "localhost"}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	now := time.Now()
+	client := NewHttpClient(srv.URL, WithTTL(time.Minute))
+	client.now = func() time.Time { return now }
+	source := client.Source("DB_HOST")
+
+	// When
+	source.Get()
+	source.Get()
+	cachedRequests := fake.requests.Load()
+
+	now = now.Add(2 * time.Minute)
+	source.Get()
+
+	// Then
+	if cachedRequests != 1 {
+		t.Errorf("expected the second read to be served from the cache, got %d requests", cachedRequests)
+	}
+
+	if n := fake.requests.Load(); n != 2 {
+		t.Errorf("expected an expired value to be fetched again, got %d requests", n)
+	}
+}
+
+func TestHttpClientBatch(t *testing.T) {
+	// Given
+	fake := &fakeConfigServer{values: map[string]string{"DB_HOST": "localhost", "DB_NAME": "example"}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	client := NewHttpClient(srv.URL, WithTTL(time.Minute), WithBatch())
+
+	// When
+	err := client.Prefetch(context.TODO(), "DB_HOST", "DB_NAME", "DB_USER")
+	host, _ := client.Source("DB_HOST").Get()
+	name, _ := client.Source("DB_NAME").Get()
+
+	// Then
+	if err != nil {
+		t.Errorf("unexpected error %s", err)
+	}
+
+	if host != "localhost" || name != "example" {
+		t.Errorf("expected prefetched values, got %s and %s", host, name)
+	}
+
+	if n := fake.requests.Load(); n != 1 {
+		t.Errorf("expected a single request, got %d", n)
+	}
+
+	if _, err := client.Source("DB_USER").Get(); !errors.Is(err, HttpNotFoundError) {
+		t.Errorf("expected unknown keys to fail when read, got %v", err)
+	}
+
+	if n := fake.requests.Load(); n != 1 {
+		t.Errorf("expected unknown keys to be cached as missing, got %d requests", n)
+	}
+}
+
+func TestHttpClientBatchFailure(t *testing.T) {
+	// Given
+	fake := &fakeConfigServer{values: map[string]string{"DB_HOST": "localhost", "DB_NAME": "example"}, failures: 100, status: http.StatusServiceUnavailable}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	client := NewHttpClient(srv.URL, WithTTL(time.Minute), WithBatch(), WithRetries(2, time.Millisecond))
+
+	// When
+	err := client.Prefetch(context.TODO(), "DB_HOST", "DB_NAME")
+	_, hostErr := client.Source("DB_HOST").Get()
+	_, nameErr := client.Source("DB_NAME").Get()
+
+	// Then
+	var statusErr httpStatusError
+	if !errors.As(err, &statusErr) || !errors.As(hostErr, &statusErr) || !errors.As(nameErr, &statusErr) {
+		t.Errorf("expected the batch error for every key, got %v, %v and %v", err, hostErr, nameErr)
+	}
+
+	if n := fake.requests.Load(); n != 3 {
+		t.Errorf("expected only the batch to be retried, got %d requests", n)
+	}
+}
+
+func TestHttpClientRefresh(t *testing.T) {
+	// Given
+	fake := &fakeConfigServer{values: map[string]string{"LOG_LEVEL": "INFO"}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	client := NewHttpClient(srv.URL, WithTTL(time.Minute), WithBatch())
+	client.Source("LOG_LEVEL").Get()
+
+	done := make(chan struct{})
+	defer close(done)
+
+	// When
+	fake.values = map[string]string{"LOG_LEVEL": "DEBUG"}
+	go client.Refresh(done, 10*time.Millisecond)
+
+	// Then
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if entry, _ := client.cached("LOG_LEVEL"); entry.value == "DEBUG" {
+			return
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	t.Errorf("expected the cached value to be refreshed in the background")
+}
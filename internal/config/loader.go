@@ -0,0 +1,203 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// MARK: Lookup
+/*
+Builds the source for a config key, the fallbacks are used when the key isn't set
+*/
+type Lookup func(name string, fallbacks ...Configurator) Configurator
+
+/*
+Resolves keys from the environment only
+*/
+func EnvironmentLookup(name string, fallbacks ...Configurator) Configurator {
+	return NewFirst(append([]Configurator{NewEnvironmentSource(name)}, fallbacks...)...)
+}
+
+/*
+Resolves keys from the environment, then the secrets directory, then the .env file
+*/
+func NewFileLookup(secretsDir, envFile string) Lookup {
+	return func(name string, fallbacks ...Configurator) Configurator {
+		sources := []Configurator{
+			NewEnvironmentSource(name),
+			NewDirectorySource(secretsDir, name),
+			NewDotenvSource(envFile, name),
+		}
+
+		return NewFirst(append(sources, fallbacks...)...)
+	}
+}
+
+/*
+Adds a source after the ones from l and before the fallbacks, e.g., a config server
+*/
+func (l Lookup) Then(source func(name string) Configurator) Lookup {
+	return func(name string, fallbacks ...Configurator) Configurator {
+		return l(name, append([]Configurator{source(name)}, fallbacks...)...)
+	}
+}
+
+// MARK: Errors
+type FieldError struct {
+	Name string
+	Err  error
+}
+
+func (f FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", f.Name, f.Err.Error())
+}
+
+func (f FieldError) Unwrap() error {
+	return f.Err
+}
+
+/*
+Every config value that was missing or invalid, so they can all be fixed at once
+*/
+type LoadError struct {
+	Fields []FieldError
+}
+
+func (l *LoadError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "invalid configuration, %d problem(s):", len(l.Fields))
+
+	for _, f := range l.Fields {
+		b.WriteString("\n  ")
+		b.WriteString(f.Error())
+	}
+
+	return b.String()
+}
+
+func (l *LoadError) Unwrap() []error {
+	errs := make([]error, len(l.Fields))
+
+	for idx, f := range l.Fields {
+		errs[idx] = f
+	}
+
+	return errs
+}
+
+// MARK: Loader
+type Getter[T any] interface {
+	Get() (T, error)
+}
+
+type binding struct {
+	name   string
+	value  any
+	secret bool
+}
+
+type BindOption func(*binding)
+
+/*
+Marks a value as secret, it is redacted when the effective config is logged
+*/
+func Secret() BindOption {
+	return func(b *binding) {
+		b.secret = true
+	}
+}
+
+/*
+Resolves config values up front
+
+Every value is bound with Bind, failures are collected instead of returned
+so Err reports everything that is wrong with the config in one go
+*/
+type Loader struct {
+	bindings []binding
+	errs     []FieldError
+}
+
+func NewLoader() *Loader {
+	return &Loader{}
+}
+
+/*
+Resolves src into dst, on failure dst is left untouched and the error is recorded
+*/
+func Bind[T any](l *Loader, name string, src Getter[T], dst *T, opts ...BindOption) {
+	v, err := src.Get()
+	if err != nil {
+		l.errs = append(l.errs, FieldError{Name: name, Err: err})
+		return
+	}
+
+	*dst = v
+
+	b := binding{name: name, value: v}
+	for _, opt := range opts {
+		opt(&b)
+	}
+
+	l.bindings = append(l.bindings, b)
+}
+
+/*
+Returns a *LoadError when any value failed to resolve
+*/
+func (l *Loader) Err() error {
+	if len(l.errs) == 0 {
+		return nil
+	}
+
+	return &LoadError{Fields: l.errs}
+}
+
+/*
+The effective config as log attributes, with secrets redacted
+*/
+func (l *Loader) Attrs() []slog.Attr {
+	attrs := make([]slog.Attr, len(l.bindings))
+
+	for idx, b := range l.bindings {
+		if b.secret {
+			attrs[idx] = slog.String(b.name, redacted)
+			continue
+		}
+
+		switch v := b.value.(type) {
+		case *url.URL:
+			// Credentials in a URL are always redacted
+			attrs[idx] = slog.String(b.name, v.Redacted())
+		default:
+			attrs[idx] = slog.Any(b.name, v)
+		}
+	}
+
+	return attrs
+}
+
+/*
+The keys load looks up, found by running it against sources that resolve nothing
+
+Lets every key be fetched at once, e.g., with HttpClient.Prefetch, before the
+load that uses them. The values and errors of the dry run are thrown away.
+*/
+func Keys(load func(l *Loader, lookup Lookup)) []string {
+	names := make([]string, 0)
+
+	load(NewLoader(), func(name string, fallbacks ...Configurator) Configurator {
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+
+		return NewFirst()
+	})
+
+	return names
+}
@@ -0,0 +1,215 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	configReloadedMsg     = "CONFIG_RELOADED"
+	configReloadFailedMsg = "CONFIG_RELOAD_FAILED"
+	logKeyKeys            = "keys"
+	logKeyError           = "error"
+)
+
+// MARK: Reloader
+/*
+Re-reads a set of config keys while the process is running
+
+Values are read into a snapshot. A reload either replaces the whole snapshot
+or, when any key is missing or fails validation, keeps the previous one, so
+readers never see a mix of old and new values. Subscribers are called after
+the swap for every key whose value changed.
+
+The first call to Reload loads the initial values and notifies every subscriber.
+*/
+type Reloader struct {
+	sources    map[string]Configurator
+	validators map[string]func(string) error
+
+	// Serializes reloads so an older read can't overwrite a newer one
+	reloadMu sync.Mutex
+
+	mu          sync.RWMutex
+	values      map[string]string
+	loaded      bool
+	subscribers map[string][]func(string)
+}
+
+func NewReloader(sources map[string]Configurator) *Reloader {
+	return &Reloader{
+		sources:     sources,
+		validators:  make(map[string]func(string) error),
+		values:      make(map[string]string),
+		subscribers: make(map[string][]func(string)),
+	}
+}
+
+/*
+Rejects a reload when the new value of key doesn't pass f
+*/
+func (r *Reloader) Validate(key string, f func(string) error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.validators[key] = f
+}
+
+/*
+Calls fn with the new value whenever key changes
+*/
+func (r *Reloader) Subscribe(key string, fn func(string)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.subscribers[key] = append(r.subscribers[key], fn)
+}
+
+/*
+Returns the value of key from the current snapshot
+*/
+func (r *Reloader) Get(key string) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	v, ok := r.values[key]
+	return v, ok
+}
+
+/*
+A Configurator that reads key from the current snapshot
+*/
+func (r *Reloader) Source(key string) Configurator {
+	return reloadSource{reloader: r, key: key}
+}
+
+/*
+Re-reads every source and swaps the snapshot when all of them succeed
+
+Returns a *LoadError and keeps the previous snapshot otherwise
+*/
+func (r *Reloader) Reload() error {
+	r.reloadMu.Lock()
+	defer r.reloadMu.Unlock()
+
+	next := make(map[string]string, len(r.sources))
+	var errs []FieldError
+
+	r.mu.RLock()
+	validators := maps.Clone(r.validators)
+	r.mu.RUnlock()
+
+	for key, source := range r.sources {
+		v, err := source.Get()
+		if err == nil {
+			if validate, ok := validators[key]; ok {
+				err = validate(v)
+			}
+		}
+
+		if err != nil {
+			errs = append(errs, FieldError{Name: key, Err: err})
+			continue
+		}
+
+		next[key] = v
+	}
+
+	if len(errs) > 0 {
+		slices.SortFunc(errs, func(a, b FieldError) int { return strings.Compare(a.Name, b.Name) })
+		return &LoadError{Fields: errs}
+	}
+
+	r.mu.Lock()
+	changed := make([]string, 0)
+	for key, v := range next {
+		if old, ok := r.values[key]; !ok || old != v || !r.loaded {
+			changed = append(changed, key)
+		}
+	}
+
+	r.values = next
+	r.loaded = true
+
+	// Copy the subscribers so they run without holding the lock
+	notify := make(map[string][]func(string), len(changed))
+	for _, key := range changed {
+		notify[key] = slices.Clone(r.subscribers[key])
+	}
+	r.mu.Unlock()
+
+	slices.Sort(changed)
+	for _, key := range changed {
+		for _, fn := range notify[key] {
+			fn(next[key])
+		}
+	}
+
+	if len(changed) > 0 {
+		slog.LogAttrs(context.Background(), slog.LevelInfo, configReloadedMsg, slog.Any(logKeyKeys, changed))
+	}
+
+	return nil
+}
+
+/*
+Reloads whenever a signal arrives on trigger (e.g., SIGHUP) and every interval
+
+An interval of 0 disables polling. Runs until done is closed.
+*/
+func (r *Reloader) Watch(done <-chan struct{}, trigger <-chan os.Signal, interval time.Duration) {
+	var tick <-chan time.Time
+
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-trigger:
+		case <-tick:
+		}
+
+		if err := r.Reload(); err != nil {
+			slog.LogAttrs(context.Background(), slog.LevelError, configReloadFailedMsg, slog.String(logKeyError, err.Error()))
+		}
+	}
+}
+
+// MARK: Source
+type reloadSource struct {
+	reloader *Reloader
+	key      string
+}
+
+func (r reloadSource) Get() (string, error) {
+	v, ok := r.reloader.Get(r.key)
+
+	if !ok {
+		return EMPTY_STRING, fmt.Errorf("cannot find reloadable key %s", r.key)
+	}
+
+	return v, nil
+}
+
+func (r reloadSource) Must() string {
+	v, err := r.Get()
+
+	if err != nil {
+		panic(err)
+	}
+
+	return v
+}
@@ -0,0 +1,146 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestReloader(t *testing.T) {
+	tests := []struct {
+		name          string
+		initial       string
+		updated       string
+		expected      string
+		notifications int
+		errMessage    string
+	}{
+		{
+			name:          "PassingCase-Changed",
+			initial:       "LOG_LEVEL=INFO\nRATE=10\n",
+			updated:       "LOG_LEVEL=DEBUG\nRATE=10\n",
+			expected:      "DEBUG",
+			notifications: 2,
+		},
+		{
+			name:          "PassingCase-Unchanged",
+			initial:       "LOG_LEVEL=INFO\nRATE=10\n",
+			updated:       "LOG_LEVEL=INFO\nRATE=20\n",
+			expected:      "INFO",
+			notifications: 1,
+		},
+		{
+			name:          "FailingCase-InvalidValueKeepsSnapshot",
+			initial:       "LOG_LEVEL=INFO\nRATE=10\n",
+			updated:       "LOG_LEVEL=LOUD\nRATE=20\n",
+			expected:      "INFO",
+			notifications: 1,
+			errMessage:    "invalid configuration, 1 problem(s):\n  LOG_LEVEL: invalid value",
+		},
+		{
+			name:          "FailingCase-MissingValueKeepsSnapshot",
+			initial:       "LOG_LEVEL=INFO\nRATE=10\n",
+			updated:       "LOG_LEVEL=DEBUG\n",
+			expected:      "INFO",
+			notifications: 1,
+			errMessage:    "invalid configuration, 1 problem(s):\n  RATE: cannot find key RATE in ",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			path := filepath.Join(t.TempDir(), ".env")
+			os.WriteFile(path, []byte(tc.initial), 0o600)
+
+			reloader := NewReloader(map[string]Configurator{
+				"LOG_LEVEL": NewDotenvSource(path, "LOG_LEVEL"),
+				"RATE":      NewDotenvSource(path, "RATE"),
+			})
+			reloader.Validate("LOG_LEVEL", func(v string) error {
+				if v == "LOUD" {
+					return InvalidValueError
+				}
+				return nil
+			})
+
+			var notifications int
+			reloader.Subscribe("LOG_LEVEL", func(string) { notifications++ })
+
+			if err := reloader.Reload(); err != nil {
+				t.Fatalf("unexpected error on initial load %s", err)
+			}
+
+			// When
+			os.WriteFile(path, []byte(tc.updated), 0o600)
+			err := reloader.Reload()
+
+			// Then
+			var errMessage string
+			if err != nil {
+				errMessage = err.Error()
+			}
+
+			if !strings.HasPrefix(errMessage, tc.errMessage) || (tc.errMessage == "" && err != nil) {
+				t.Errorf("expected error message %q, got %q", tc.errMessage, errMessage)
+			}
+
+			if v := reloader.Source("LOG_LEVEL").Must(); v != tc.expected {
+				t.Errorf("expected %s, got %s", tc.expected, v)
+			}
+
+			// The whole snapshot is kept when a reload fails
+			if rate, _ := reloader.Get("RATE"); err != nil && rate != "10" {
+				t.Errorf("expected RATE to be unchanged, got %s", rate)
+			}
+
+			if notifications != tc.notifications {
+				t.Errorf("expected %d notifications, got %d", tc.notifications, notifications)
+			}
+		})
+	}
+}
+
+func TestReloaderWatch(t *testing.T) {
+	// Given
+	path := filepath.Join(t.TempDir(), ".env")
+	os.WriteFile(path, []byte("LOG_LEVEL=INFO\n"), 0o600)
+
+	reloader := NewReloader(map[string]Configurator{
+		"LOG_LEVEL": NewDotenvSource(path, "LOG_LEVEL"),
+	})
+
+	changes := make(chan string, 1)
+	reloader.Subscribe("LOG_LEVEL", func(v string) { changes <- v })
+	reloader.Reload()
+	<-changes
+
+	done := make(chan struct{})
+	defer close(done)
+
+	trigger := make(chan os.Signal, 1)
+	go reloader.Watch(done, trigger, 0)
+
+	// When
+	os.WriteFile(path, []byte("LOG_LEVEL=DEBUG\n"), 0o600)
+	trigger <- syscall.SIGHUP
+
+	// Then
+	select {
+	case v := <-changes:
+		if v != "DEBUG" {
+			t.Errorf("expected DEBUG, got %s", v)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("expected a change notification after SIGHUP")
+	}
+
+	var loadErr *LoadError
+	if errors.As(reloader.Reload(), &loadErr) {
+		t.Errorf("unexpected load error %s", loadErr)
+	}
+}
@@ -0,0 +1,115 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+Returned when a value was found but can't be parsed into the requested type
+*/
+var InvalidValueError = errors.New("invalid value")
+
+// MARK: Typed accessors
+/*
+Parses a duration, e.g., "10s" or "1m30s"
+*/
+func Duration(src Configurator) CustomSource[time.Duration] {
+	return NewCustomSource(func() (time.Duration, error) {
+		v, err := src.Get()
+		if err != nil {
+			return 0, err
+		}
+
+		d, err := time.ParseDuration(strings.TrimSpace(v))
+		if err != nil {
+			return 0, fmt.Errorf("%w: %q is not a duration", InvalidValueError, v)
+		}
+
+		return d, nil
+	})
+}
+
+func Int(src Configurator) CustomSource[int] {
+	return NewCustomSource(func() (int, error) {
+		v, err := src.Get()
+		if err != nil {
+			return 0, err
+		}
+
+		i, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return 0, fmt.Errorf("%w: %q is not an integer", InvalidValueError, v)
+		}
+
+		return i, nil
+	})
+}
+
+/*
+Parses a boolean, an empty value is false
+*/
+func Bool(src Configurator) CustomSource[bool] {
+	return NewCustomSource(func() (bool, error) {
+		v, err := src.Get()
+		if err != nil {
+			return false, err
+		}
+
+		v = strings.TrimSpace(v)
+		if len(v) == 0 {
+			return false, nil
+		}
+
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return false, fmt.Errorf("%w: %q is not a boolean", InvalidValueError, v)
+		}
+
+		return b, nil
+	})
+}
+
+/*
+Parses an absolute URL
+*/
+func URL(src Configurator) CustomSource[*url.URL] {
+	return NewCustomSource(func() (*url.URL, error) {
+		v, err := src.Get()
+		if err != nil {
+			return nil, err
+		}
+
+		u, err := url.Parse(strings.TrimSpace(v))
+		if err != nil || !u.IsAbs() || len(u.Host) == 0 {
+			return nil, fmt.Errorf("%w: not an absolute url", InvalidValueError)
+		}
+
+		return u, nil
+	})
+}
+
+/*
+Parses a comma separated list, surrounding whitespace and empty items are dropped
+*/
+func StringList(src Configurator) CustomSource[[]string] {
+	return NewCustomSource(func() ([]string, error) {
+		v, err := src.Get()
+		if err != nil {
+			return nil, err
+		}
+
+		items := make([]string, 0)
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); len(item) != 0 {
+				items = append(items, item)
+			}
+		}
+
+		return items, nil
+	})
+}
@@ -1,14 +1,44 @@
 package exampleservice
 
 import (
+	"bytes"
 	"encoding/json"
-	"errors"
 	"log/slog"
+	"slices"
+	"strconv"
 	"strings"
+
+	"github.com/moonmoon1919/go-api-reference/internal/requests"
+	"github.com/moonmoon1919/go-api-reference/internal/store"
+	"github.com/moonmoon1919/go-api-reference/pkg/example"
 )
 
+/*
+Filters and sorts accepted when listing examples, e.g.,
+?message[search]=hello&created_at[gte]=2025-01-01T00:00:00Z&sort=-created_at
+*/
+var listQuerySchema = requests.QuerySchema{
+	Filters: map[string]requests.FilterField{
+		"message": {
+			Type:      requests.TextField,
+			Operators: []store.Operator{store.Contains, store.Search},
+		},
+		"created_at": {
+			Type:      requests.TimeField,
+			Operators: []store.Operator{store.Gt, store.Gte, store.Lt, store.Lte},
+		},
+	},
+	Sorts: []string{"created_at", "updated_at", "message"},
+}
+
 type CreateExampleRequest struct {
-	Message string `json:"message"`
+	Title   string   `json:"title"`
+	Message string   `json:"message"`
+	Tags    []string `json:"tags"`
+}
+
+func (r CreateExampleRequest) Content() Content {
+	return Content{Title: r.Title, Message: r.Message, Tags: r.Tags}
 }
 
 func (r *CreateExampleRequest) UnmarshalJSON(data []byte) error {
@@ -21,7 +51,7 @@ func (r *CreateExampleRequest) UnmarshalJSON(data []byte) error {
 
 	if err := json.Unmarshal(data, aux); err != nil {
 		slog.Error("UNMARSHAL_CREATE_EXAMPLE_REQUEST_ERROR", "error", err)
-		return errors.New("INVALID_REQUEST_BODY")
+		return requests.InvalidBodyError()
 	}
 
 	missingRequiredFields := []string{}
@@ -31,14 +61,24 @@ func (r *CreateExampleRequest) UnmarshalJSON(data []byte) error {
 	}
 
 	if len(missingRequiredFields) > 0 {
-		return errors.New("MISSING_REQUIRED_FIELDS: " + strings.Join(missingRequiredFields, ", "))
+		return requests.MissingFieldsError(missingRequiredFields...)
 	}
 
 	return nil
 }
 
+/*
+Only the fields in the request are changed, at least one is required
+*/
 type PatchExampleRequest struct {
-	Message string `json:"message"`
+	Title   *string  `json:"title"`
+	Message string   `json:"message"`
+	Tags    []string `json:"tags"`
+	Status  string   `json:"status"`
+}
+
+func (r PatchExampleRequest) Changes() Changes {
+	return Changes{Title: r.Title, Message: r.Message, Tags: r.Tags, Status: example.Status(r.Status)}
 }
 
 func (r *PatchExampleRequest) UnmarshalJSON(data []byte) error {
@@ -51,7 +91,50 @@ func (r *PatchExampleRequest) UnmarshalJSON(data []byte) error {
 
 	if err := json.Unmarshal(data, aux); err != nil {
 		slog.Warn("UNMARSHAL_PATCH_EXAMPLE_REQUEST_ERROR", "error", err)
-		return errors.New("INVALID_REQUEST_BODY")
+		return requests.InvalidBodyError()
+	}
+
+	missingRequiredFields := []string{}
+
+	if aux.Message == "" && aux.Title == nil && aux.Tags == nil && aux.Status == "" {
+		missingRequiredFields = append(missingRequiredFields, "message")
+	}
+
+	if len(missingRequiredFields) > 0 {
+		return requests.MissingFieldsError(missingRequiredFields...)
+	}
+
+	return nil
+}
+
+/*
+Replaces every field of an example, Status is optional
+*/
+type PutExampleRequest struct {
+	Title   string   `json:"title"`
+	Message string   `json:"message"`
+	Tags    []string `json:"tags"`
+	Status  string   `json:"status"`
+}
+
+func (r PutExampleRequest) Replacement() Replacement {
+	return Replacement{
+		Content: Content{Title: r.Title, Message: r.Message, Tags: r.Tags},
+		Status:  example.Status(r.Status),
+	}
+}
+
+func (r *PutExampleRequest) UnmarshalJSON(data []byte) error {
+	type Aux PutExampleRequest
+	aux := &struct {
+		*Aux
+	}{
+		Aux: (*Aux)(r),
+	}
+
+	if err := json.Unmarshal(data, aux); err != nil {
+		slog.Warn("UNMARSHAL_PUT_EXAMPLE_REQUEST_ERROR", "error", err)
+		return requests.InvalidBodyError()
 	}
 
 	missingRequiredFields := []string{}
@@ -61,7 +144,231 @@ func (r *PatchExampleRequest) UnmarshalJSON(data []byte) error {
 	}
 
 	if len(missingRequiredFields) > 0 {
-		return errors.New("MISSING_REQUIRED_FIELDS: " + strings.Join(missingRequiredFields, ", "))
+		return requests.MissingFieldsError(missingRequiredFields...)
+	}
+
+	return nil
+}
+
+// MARK: Patch documents
+/*
+The fields of an example a merge patch or JSON patch can change, as clients
+see them in responses
+*/
+type patchDocument struct {
+	Title   string   `json:"title"`
+	Message string   `json:"message"`
+	Tags    []string `json:"tags"`
+	Status  string   `json:"status"`
+}
+
+func newPatchDocument(item example.Example) (any, error) {
+	tags := item.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+
+	b, err := json.Marshal(patchDocument{Title: item.Title, Message: item.Message, Tags: tags, Status: string(item.Status)})
+	if err != nil {
+		return nil, err
+	}
+
+	var doc any
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+
+	return doc, nil
+}
+
+/*
+Runs a patched document through the setters so it passes the same rules as
+Changes, only fields that differ are set so an unchanged field is never rejected
+
+A document with other fields or the wrong types can't be applied
+*/
+func applyPatchDocument(item *example.Example, patched any) error {
+	b, err := json.Marshal(patched)
+	if err != nil {
+		return requests.PatchNotApplicableError
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.DisallowUnknownFields()
+
+	var doc patchDocument
+	if err := decoder.Decode(&doc); err != nil {
+		return requests.PatchNotApplicableError
+	}
+
+	var invalid example.ValidationError
+
+	if doc.Title != item.Title {
+		invalid.Collect(item.SetTitle(doc.Title))
+	}
+
+	if doc.Message != item.Message {
+		invalid.Collect(item.SetMessage(doc.Message))
+	}
+
+	if !slices.Equal(doc.Tags, item.Tags) {
+		invalid.Collect(item.SetTags(doc.Tags))
+	}
+
+	if example.Status(doc.Status) != item.Status {
+		invalid.Collect(item.SetStatus(example.Status(doc.Status)))
+	}
+
+	return invalid.Err()
+}
+
+/*
+An RFC 7396 merge patch, e.g., {"title": null, "tags": ["go"]}
+*/
+type MergePatchRequest struct {
+	Patch any
+}
+
+func (r MergePatchRequest) apply(item *example.Example) error {
+	doc, err := newPatchDocument(*item)
+	if err != nil {
+		return err
+	}
+
+	return applyPatchDocument(item, requests.MergePatch(doc, r.Patch))
+}
+
+/*
+An RFC 6902 patch, e.g., [{"op": "test", "path": "/status", "value": "draft"}]
+*/
+type JSONPatchRequest struct {
+	Patch requests.JSONPatch
+}
+
+func (r JSONPatchRequest) apply(item *example.Example) error {
+	doc, err := newPatchDocument(*item)
+	if err != nil {
+		return err
+	}
+
+	patched, err := r.Patch.Apply(doc)
+	if err != nil {
+		return err
+	}
+
+	return applyPatchDocument(item, patched)
+}
+
+// MARK: Batch
+const (
+	batchAtomic     = "atomic"
+	batchBestEffort = "best_effort"
+	opCreate        = "create"
+	opUpdate        = "update"
+	opDelete        = "delete"
+)
+
+/*
+A create takes the fields of CreateExampleRequest, an update those of
+PatchExampleRequest and a delete only its id
+*/
+type BatchOperationRequest struct {
+	Op      string   `json:"op"`
+	Id      string   `json:"id"`
+	Version int      `json:"version"`
+	Title   *string  `json:"title"`
+	Message string   `json:"message"`
+	Tags    []string `json:"tags"`
+	Status  string   `json:"status"`
+}
+
+func (r BatchOperationRequest) Operation() Operation {
+	switch r.Op {
+	case opCreate:
+		content := Content{Message: r.Message, Tags: r.Tags}
+		if r.Title != nil {
+			content.Title = *r.Title
+		}
+
+		return Operation{Kind: CreateOperation, Content: content}
+	case opUpdate:
+		changes := Changes{Title: r.Title, Message: r.Message, Tags: r.Tags, Status: example.Status(r.Status)}
+
+		return Operation{Kind: UpdateOperation, Id: r.Id, Version: r.Version, Changes: changes}
+	default:
+		return Operation{Kind: DeleteOperation, Id: r.Id, Version: r.Version}
+	}
+}
+
+func (r BatchOperationRequest) valid() bool {
+	switch r.Op {
+	case opCreate:
+		return r.Message != ""
+	case opUpdate:
+		return r.Id != "" && (r.Message != "" || r.Title != nil || r.Tags != nil || r.Status != "")
+	case opDelete:
+		return r.Id != ""
+	default:
+		return false
+	}
+}
+
+/*
+Mode is atomic unless a client asks for best_effort
+*/
+type BatchRequest struct {
+	Mode       string                  `json:"mode"`
+	Operations []BatchOperationRequest `json:"operations"`
+}
+
+func (r BatchRequest) Atomic() bool {
+	return r.Mode != batchBestEffort
+}
+
+func (r BatchRequest) Batch() []Operation {
+	ops := make([]Operation, 0, len(r.Operations))
+	for _, op := range r.Operations {
+		ops = append(ops, op.Operation())
+	}
+
+	return ops
+}
+
+func (r *BatchRequest) UnmarshalJSON(data []byte) error {
+	type Aux BatchRequest
+	aux := &struct {
+		*Aux
+	}{
+		Aux: (*Aux)(r),
+	}
+
+	if err := json.Unmarshal(data, aux); err != nil {
+		slog.Warn("UNMARSHAL_BATCH_REQUEST_ERROR", "error", err)
+		return requests.InvalidBodyError()
+	}
+
+	if aux.Mode != "" && aux.Mode != batchAtomic && aux.Mode != batchBestEffort {
+		return requests.BodyError{Code: "INVALID_BATCH_MODE"}
+	}
+
+	if len(aux.Operations) == 0 {
+		return requests.MissingFieldsError("operations")
+	}
+
+	if len(aux.Operations) > MaxBatchOperations {
+		return requests.BodyError{Code: "TOO_MANY_OPERATIONS", Detail: strconv.Itoa(MaxBatchOperations)}
+	}
+
+	// Reported by index so a client can find them in a large batch
+	invalid := []string{}
+	for i, op := range aux.Operations {
+		if !op.valid() {
+			invalid = append(invalid, strconv.Itoa(i))
+		}
+	}
+
+	if len(invalid) > 0 {
+		return requests.BodyError{Code: "INVALID_OPERATIONS", Detail: strings.Join(invalid, ", ")}
 	}
 
 	return nil
@@ -2,6 +2,7 @@ package exampleservice
 
 import (
 	"encoding/json"
+	"strings"
 	"testing"
 )
 
@@ -18,6 +19,12 @@ func TestCreateExampleRequestUnmarshalJson(t *testing.T) {
 			want:       CreateExampleRequest{Message: "hello"},
 			errMessage: "",
 		},
+		{
+			name:       "valid request with title and tags",
+			json:       `{"title": "Greeting", "message": "hello", "tags": ["intro"]}`,
+			want:       CreateExampleRequest{Title: "Greeting", Message: "hello", Tags: []string{"intro"}},
+			errMessage: "",
+		},
 		{
 			name:       "missing message",
 			json:       `{"invalid": "request"}`,
@@ -68,6 +75,12 @@ func TestPatchExampleRequestUnmarshalJson(t *testing.T) {
 			want:       PatchExampleRequest{Message: "hello"},
 			errMessage: "",
 		},
+		{
+			name:       "status only",
+			json:       `{"status": "published"}`,
+			want:       PatchExampleRequest{Status: "published"},
+			errMessage: "",
+		},
 		{
 			name:       "missing message",
 			json:       `{"invalid": "request"}`,
@@ -105,3 +118,66 @@ func TestPatchExampleRequestUnmarshalJson(t *testing.T) {
 		})
 	}
 }
+
+func TestBatchRequestUnmarshalJson(t *testing.T) {
+	tests := []struct {
+		name       string
+		json       string
+		atomic     bool
+		errMessage string
+	}{
+		{
+			name:       "valid request",
+			json:       `{"operations": [{"op": "create", "message": "hello"}, {"op": "delete", "id": "1234"}]}`,
+			atomic:     true,
+			errMessage: "",
+		},
+		{
+			name:       "best effort",
+			json:       `{"mode": "best_effort", "operations": [{"op": "update", "id": "1234", "status": "published"}]}`,
+			atomic:     false,
+			errMessage: "",
+		},
+		{
+			name:       "unknown mode",
+			json:       `{"mode": "sometimes", "operations": [{"op": "create", "message": "hello"}]}`,
+			errMessage: "INVALID_BATCH_MODE",
+		},
+		{
+			name:       "missing operations",
+			json:       `{"mode": "atomic"}`,
+			errMessage: "MISSING_REQUIRED_FIELDS: operations",
+		},
+		{
+			name:       "invalid operations",
+			json:       `{"operations": [{"op": "create"}, {"op": "delete", "id": "1234"}, {"op": "update", "id": "1234"}, {"op": "upsert"}]}`,
+			errMessage: "INVALID_OPERATIONS: 0, 2, 3",
+		},
+		{
+			name:       "too many operations",
+			json:       `{"operations": [` + strings.Repeat(`{"op": "delete", "id": "1234"},`, MaxBatchOperations) + `{"op": "delete", "id": "1234"}]}`,
+			errMessage: "TOO_MANY_OPERATIONS: 100",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var request BatchRequest
+
+			err := json.Unmarshal([]byte(tc.json), &request)
+
+			var errMsg string
+			if err != nil {
+				errMsg = err.Error()
+			}
+
+			if errMsg != tc.errMessage {
+				t.Errorf("expected error message %s, got %s", tc.errMessage, errMsg)
+			}
+
+			if err == nil && request.Atomic() != tc.atomic {
+				t.Errorf("expected atomic to be %t, got %t", tc.atomic, request.Atomic())
+			}
+		})
+	}
+}
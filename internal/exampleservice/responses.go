@@ -1,55 +1,162 @@
 package exampleservice
 
-import "github.com/moonmoon1919/go-api-reference/pkg/example"
+import (
+	"time"
+
+	"github.com/moonmoon1919/go-api-reference/internal/requests"
+	"github.com/moonmoon1919/go-api-reference/internal/responses"
+	"github.com/moonmoon1919/go-api-reference/internal/store"
+	"github.com/moonmoon1919/go-api-reference/pkg/example"
+	"github.com/moonmoon1919/go-api-reference/pkg/users"
+)
+
+/*
+Fields every example response has, converted to each response type
+*/
+func newExampleResponse(e example.Example) GetExampleResponse {
+	tags := e.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+
+	return GetExampleResponse{
+		Id:        e.Id,
+		Title:     e.Title,
+		Message:   e.Message,
+		Tags:      tags,
+		Status:    string(e.Status),
+		CreatedAt: e.CreatedAt,
+		UpdatedAt: e.UpdatedAt,
+	}
+}
 
 type PatchExampleResponse struct {
-	Id      string `json:"id"`
-	Message string `json:"message"`
+	Id        string    `json:"id"`
+	Title     string    `json:"title"`
+	Message   string    `json:"message"`
+	Tags      []string  `json:"tags"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
 func NewPatchExampleResponseFromExample(e example.Example) PatchExampleResponse {
-	return PatchExampleResponse{
-		Id:      e.Id,
-		Message: e.Message,
-	}
+	return PatchExampleResponse(newExampleResponse(e))
+}
+
+type PutExampleResponse struct {
+	Id        string    `json:"id"`
+	Title     string    `json:"title"`
+	Message   string    `json:"message"`
+	Tags      []string  `json:"tags"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func NewPutExampleResponseFromExample(e example.Example) PutExampleResponse {
+	return PutExampleResponse(newExampleResponse(e))
 }
 
 type CreateExampleResponse struct {
-	Id      string `json:"id"`
-	Message string `json:"message"`
+	Id        string    `json:"id"`
+	Title     string    `json:"title"`
+	Message   string    `json:"message"`
+	Tags      []string  `json:"tags"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
 func NewCreateExampleResponseFromExample(e example.Example) CreateExampleResponse {
-	return CreateExampleResponse{
-		Id:      e.Id,
-		Message: e.Message,
-	}
+	return CreateExampleResponse(newExampleResponse(e))
 }
 
 type GetExampleResponse struct {
-	Id      string `json:"id"`
-	Message string `json:"message"`
+	Id        string    `json:"id"`
+	Title     string    `json:"title"`
+	Message   string    `json:"message"`
+	Tags      []string  `json:"tags"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
 func NewGetExampleResponseFromExample(e example.Example) GetExampleResponse {
-	return GetExampleResponse{
-		Id:      e.Id,
-		Message: e.Message,
-	}
+	return newExampleResponse(e)
 }
 
-type ListExampleResponse struct {
-	Items []GetExampleResponse `json:"items"`
+type ListExampleResponse = responses.Paged[GetExampleResponse]
+
+func NewListExampleResponseFromPage(page store.Page[example.Example], p store.Pagination, cursors requests.CursorCodec) ListExampleResponse {
+	return responses.NewPaged(page, p, NewGetExampleResponseFromExample).
+		WithCursors(cursors.Token(page.Next), cursors.Token(page.Prev))
 }
 
-func NewListExampleResponseFromExamples(e []example.Example) ListExampleResponse {
-	items := make([]GetExampleResponse, len(e))
+type RevisionResponse struct {
+	Revision  int       `json:"revision"`
+	Title     string    `json:"title"`
+	Message   string    `json:"message"`
+	Tags      []string  `json:"tags"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func NewRevisionResponseFromRevision(r example.Revision) RevisionResponse {
+	tags := r.Tags
+	if tags == nil {
+		tags = []string{}
+	}
 
-	for idx, i := range e {
-		items[idx] = NewGetExampleResponseFromExample(i)
+	return RevisionResponse{
+		Revision:  r.Revision,
+		Title:     r.Title,
+		Message:   r.Message,
+		Tags:      tags,
+		Status:    string(r.Status),
+		CreatedAt: r.CreatedAt,
 	}
+}
+
+type ListRevisionResponse = responses.Paged[RevisionResponse]
+
+func NewListRevisionResponseFromPage(page store.Page[example.Revision], p store.Pagination, cursors requests.CursorCodec) ListRevisionResponse {
+	return responses.NewPaged(page, p, NewRevisionResponseFromRevision).
+		WithCursors(cursors.Token(page.Next), cursors.Token(page.Prev))
+}
+
+/*
+Example is only set for creates and updates, Error and Details only on failure
+*/
+type BatchResultResponse struct {
+	Status  int                    `json:"status"`
+	Example *GetExampleResponse    `json:"example,omitempty"`
+	Error   string                 `json:"error,omitempty"`
+	Details []responses.FieldError `json:"details,omitempty"`
+}
+
+type BatchResponse struct {
+	Results []BatchResultResponse `json:"results"`
+}
+
+/*
+A limit of -1 is unlimited
+*/
+type QuotaResponse struct {
+	Examples int `json:"examples"`
+	Bytes    int `json:"bytes"`
+}
+
+type UsageResponse struct {
+	Examples int           `json:"examples"`
+	Bytes    int           `json:"bytes"`
+	Quota    QuotaResponse `json:"quota"`
+}
 
-	return ListExampleResponse{
-		Items: items,
+func NewUsageResponse(usage users.Usage, quota users.Quota) UsageResponse {
+	return UsageResponse{
+		Examples: usage.Examples,
+		Bytes:    usage.Bytes,
+		Quota:    QuotaResponse{Examples: quota.Examples, Bytes: quota.Bytes},
 	}
 }
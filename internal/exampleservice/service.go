@@ -3,73 +3,194 @@ package exampleservice
 import (
 	"context"
 	"errors"
-	"fmt"
 	"log/slog"
 
 	"github.com/moonmoon1919/go-api-reference/internal/bus"
+	"github.com/moonmoon1919/go-api-reference/internal/store"
 	"github.com/moonmoon1919/go-api-reference/pkg/events"
 	"github.com/moonmoon1919/go-api-reference/pkg/example"
+	"github.com/moonmoon1919/go-api-reference/pkg/users"
 )
 
 // MARK: Logging constants
 const (
-	exampleServiceAdd    = "EXAMPLE_SERVICE_ADD"
-	exampleServiceGet    = "EXAMPLE_SERVICE_GET"
-	exampleServiceList   = "EXAMPLE_SERVICE_LIST"
-	exampleServicePatch  = "EXAMPLE_SERVICE_PATCH"
-	exampleServiceDelete = "EXAMPLE_SERVICE_DELETE"
-	storeError           = "STORE_ERROR"
-	domainError          = "DOMAIN_ERROR"
-	logKeyMessage        = "message"
-	logKeyId             = "id"
-	logKeyUserId         = "uid"
-	logKeyError          = "ERROR"
+	exampleServiceAdd     = "EXAMPLE_SERVICE_ADD"
+	exampleServiceGet     = "EXAMPLE_SERVICE_GET"
+	exampleServiceList    = "EXAMPLE_SERVICE_LIST"
+	exampleServicePatch   = "EXAMPLE_SERVICE_PATCH"
+	exampleServiceDelete  = "EXAMPLE_SERVICE_DELETE"
+	exampleServiceRestore = "EXAMPLE_SERVICE_RESTORE"
+	exampleServiceRevs    = "EXAMPLE_SERVICE_LIST_REVISIONS"
+	exampleServiceGetRev  = "EXAMPLE_SERVICE_GET_REVISION"
+	exampleServiceRevert  = "EXAMPLE_SERVICE_REVERT"
+	exampleServiceBatch   = "EXAMPLE_SERVICE_BATCH"
+	exampleServicePut     = "EXAMPLE_SERVICE_PUT"
+	exampleServiceUsage   = "EXAMPLE_SERVICE_USAGE"
+	overQuotaMsg          = "OVER_QUOTA"
+	storeError            = "STORE_ERROR"
+	domainError           = "DOMAIN_ERROR"
+	versionMismatchMsg    = "VERSION_MISMATCH"
+	logKeyMessage         = "message"
+	logKeyId              = "id"
+	logKeyUserId          = "uid"
+	logKeyVersion         = "version"
+	logKeyRevision        = "revision"
+	logKeyOperations      = "operations"
+	logKeyError           = "ERROR"
 )
 
 // MARK: Errors
-type InvalidMessageError struct {
-	wrappedErr error
-}
-
-func (i InvalidMessageError) Error() string {
-	return fmt.Sprintf("invalid message. error: %s", i.wrappedErr.Error())
-}
-
 var serviceError = errors.New("service layer error")
 var repositoryAddError = errors.New("error storing record")
 var repositoryUpdateError = errors.New("error updating record")
 var repositoryNotFoundError = errors.New("item not found")
+var revisionNotFoundServiceError = errors.New("revision not found")
+var repositoryConflictError = errors.New("item was modified by another request")
+var preconditionFailedError = errors.New("item version does not match")
 var RepositoryListError = errors.New("error listing items")
 var limitToLargeError = errors.New("maximum limit is 50")
 var invalidPageError = errors.New("page must be greater than 0")
+var batchTooLargeError = errors.New("too many operations in batch")
+var notAppliedError = errors.New("operation was not applied because another operation failed")
+var overQuotaError = errors.New("storage quota exceeded")
 
 // MARK: Service
 type Service struct {
 	Store Storer
 	Bus   bus.Busser
+	// For users without a quota of their own
+	Quota users.Quota
+}
+
+// MARK: Input
+/*
+What a client sets when adding an example, only Message is required
+*/
+type Content struct {
+	Title   string
+	Message string
+	Tags    []string
+}
+
+/*
+What a client changes with a patch
+
+An empty Message or Status and a nil Title or Tags are left as they are,
+an empty Title or Tags clears them
+*/
+type Changes struct {
+	Title   *string
+	Message string
+	Tags    []string
+	Status  example.Status
+}
+
+/*
+Anything Update can apply to an example, Changes or a patch document
+*/
+type Patch interface {
+	apply(item *example.Example) error
+}
+
+/*
+Every violation is collected so a client can fix them all in one go
+*/
+func (c Content) apply(item *example.Example) error {
+	var invalid example.ValidationError
+	invalid.Collect(item.SetTitle(c.Title))
+	invalid.Collect(item.SetTags(c.Tags))
+
+	return invalid.Err()
+}
+
+/*
+A patch has to change something, without other changes it needs a message
+*/
+func (c Changes) apply(item *example.Example) error {
+	if c.Title == nil && c.Message == "" && c.Tags == nil && c.Status == "" {
+		return example.ValidationError{Violations: example.Rules{example.NotEmpty()}.Check("message", "")}
+	}
+
+	var invalid example.ValidationError
+
+	if c.Title != nil {
+		invalid.Collect(item.SetTitle(*c.Title))
+	}
+
+	if c.Message != "" {
+		invalid.Collect(item.SetMessage(c.Message))
+	}
+
+	if c.Tags != nil {
+		invalid.Collect(item.SetTags(c.Tags))
+	}
+
+	if c.Status != "" {
+		invalid.Collect(item.SetStatus(c.Status))
+	}
+
+	return invalid.Err()
+}
+
+/*
+What a client sends to create or replace an example with PUT
+
+Every field is replaced, except an empty Status which keeps the current one
+*/
+type Replacement struct {
+	Content
+	Status example.Status
+}
+
+func (r Replacement) apply(item *example.Example) error {
+	var invalid example.ValidationError
+	invalid.Collect(item.SetTitle(r.Title))
+	invalid.Collect(item.SetMessage(r.Message))
+	invalid.Collect(item.SetTags(r.Tags))
+
+	if r.Status != "" && r.Status != item.Status {
+		invalid.Collect(item.SetStatus(r.Status))
+	}
+
+	return invalid.Err()
 }
 
 // MARK: Add
-func (e Service) Add(ctx context.Context, userId, message string) (example.Example, error) {
+func (e Service) Add(ctx context.Context, userId string, content Content) (example.Example, error) {
 	slog.LogAttrs(
 		ctx,
 		slog.LevelInfo,
 		exampleServiceAdd,
-		slog.String(logKeyMessage, message),
+		slog.String(logKeyMessage, content.Message),
 	)
 
-	item, err := example.New(userId, message)
-	if err != nil {
+	item, err := example.New(userId, content.Message)
+
+	var invalid example.ValidationError
+	invalid.Collect(err)
+	invalid.Collect(content.apply(&item))
+
+	if err := invalid.Err(); err != nil {
 		slog.LogAttrs(
 			ctx,
 			slog.LevelError,
 			domainError,
 			slog.String(logKeyError, err.Error()),
 		)
-		return example.Nil(), &InvalidMessageError{wrappedErr: err}
+		return example.Nil(), err
+	}
+
+	storedItem, err := e.Store.Add(ctx, item, e.Quota)
+	if errors.Is(err, quotaExceededError) {
+		slog.LogAttrs(
+			ctx,
+			slog.LevelInfo,
+			overQuotaMsg,
+			slog.String(logKeyUserId, userId),
+		)
+		return example.Nil(), overQuotaError
 	}
 
-	storedItem, err := e.Store.Add(ctx, item)
 	if err != nil {
 		slog.LogAttrs(
 			ctx,
@@ -91,29 +212,40 @@ func (e Service) Add(ctx context.Context, userId, message string) (example.Examp
 }
 
 // MARK: Update
-func (e Service) Update(ctx context.Context, userId, id, message string) (example.Example, error) {
+/*
+Applies a patch to one of the user's examples, the result is validated by the domain
+
+A non-zero version makes the update conditional, it only succeeds when the
+example is still at that version. Without one the version read here is used,
+so a concurrent update still results in a conflict instead of a lost update.
+*/
+func (e Service) Update(ctx context.Context, userId, id string, patch Patch, version int) (example.Example, error) {
 	slog.LogAttrs(
 		ctx,
 		slog.LevelInfo,
 		exampleServicePatch,
-		slog.String(logKeyMessage, message),
+		slog.String(logKeyId, id),
 	)
 
-	item, err := e.Store.Get(ctx, id)
-
-	// Check if the error is not found or something else
+	// Another user's example is as good as missing
+	item, err := e.owned(ctx, userId, id)
 	if err != nil {
+		// item is example.Nil, so dont bother creating another empty struct
+		return item, repositoryNotFoundError
+	}
+
+	if version != 0 && item.Version != version {
 		slog.LogAttrs(
 			ctx,
-			slog.LevelError,
-			storeError,
-			slog.String(logKeyError, err.Error()),
+			slog.LevelInfo,
+			versionMismatchMsg,
+			slog.String(logKeyId, id),
+			slog.Int(logKeyVersion, version),
 		)
-		// item is example.Nil, so dont bother creating another empty struct
-		return item, repositoryNotFoundError
+		return example.Nil(), preconditionFailedError
 	}
 
-	err = item.SetMessage(message)
+	err = patch.apply(&item)
 	if err != nil {
 		slog.LogAttrs(
 			ctx,
@@ -121,21 +253,53 @@ func (e Service) Update(ctx context.Context, userId, id, message string) (exampl
 			domainError,
 			slog.String(logKeyError, err.Error()),
 		)
-		return example.Nil(), &InvalidMessageError{wrappedErr: err}
+		return example.Nil(), err
 	}
 
-	storedItem, err := e.Store.Update(ctx, item)
+	return e.save(ctx, userId, item, version)
+}
+
+/*
+Stores an updated example and tells everyone it changed
+
+version is the one the caller asked for, it decides whether losing a race
+is a failed precondition or a conflict
+*/
+func (e Service) save(ctx context.Context, userId string, item example.Example, version int) (example.Example, error) {
+	storedItem, err := e.Store.Update(ctx, item, e.Quota)
 	if err != nil {
 		switch {
+		case errors.Is(err, quotaExceededError):
+			slog.LogAttrs(
+				ctx,
+				slog.LevelInfo,
+				overQuotaMsg,
+				slog.String(logKeyUserId, userId),
+			)
+			return storedItem, overQuotaError
 		case errors.Is(err, notFoundError):
 			slog.LogAttrs(
 				ctx,
 				slog.LevelInfo,
 				notFoundMsg,
-				slog.String(logKeyId, id),
+				slog.String(logKeyId, item.Id),
 			)
 			// storedItem is example.Nil, no need to create an empty struct again
 			return storedItem, repositoryNotFoundError
+		case errors.Is(err, versionMismatchError):
+			slog.LogAttrs(
+				ctx,
+				slog.LevelInfo,
+				versionMismatchMsg,
+				slog.String(logKeyId, item.Id),
+				slog.Int(logKeyVersion, item.Version),
+			)
+
+			if version != 0 {
+				return storedItem, preconditionFailedError
+			}
+
+			return storedItem, repositoryConflictError
 		default:
 			slog.LogAttrs(
 				ctx,
@@ -156,8 +320,158 @@ func (e Service) Update(ctx context.Context, userId, id, message string) (exampl
 	return storedItem, nil
 }
 
+// MARK: Put
+/*
+Whether a PUT may create, replace or do either
+*/
+type PutCondition int
+
+const (
+	CreateOrReplace PutCondition = iota
+	// If-None-Match: *
+	CreateOnly
+	// If-Match
+	ReplaceOnly
+)
+
+/*
+Creates an example with a client chosen id or replaces the one that has it,
+created is true when the example is new
+
+A non-zero version makes a replace conditional like Update. A create that
+loses a race to another create with the same id fails instead of replacing it.
+Another user's example is not found, whatever the condition, so its id isn't
+given away.
+*/
+func (e Service) Put(ctx context.Context, userId, id string, r Replacement, condition PutCondition, version int) (item example.Example, created bool, err error) {
+	slog.LogAttrs(
+		ctx,
+		slog.LevelInfo,
+		exampleServicePut,
+		slog.String(logKeyId, id),
+	)
+
+	item, err = e.Store.Get(ctx, id)
+
+	switch {
+	case err == nil && item.UserId != userId:
+		slog.LogAttrs(
+			ctx,
+			slog.LevelInfo,
+			notFoundMsg,
+			slog.String(logKeyId, id),
+			slog.String(logKeyUserId, userId),
+		)
+		return example.Nil(), false, repositoryNotFoundError
+	case err == nil:
+		if condition == CreateOnly || (version != 0 && item.Version != version) {
+			slog.LogAttrs(
+				ctx,
+				slog.LevelInfo,
+				versionMismatchMsg,
+				slog.String(logKeyId, id),
+				slog.Int(logKeyVersion, version),
+			)
+			return example.Nil(), false, preconditionFailedError
+		}
+
+		if err := r.apply(&item); err != nil {
+			slog.LogAttrs(
+				ctx,
+				slog.LevelError,
+				domainError,
+				slog.String(logKeyError, err.Error()),
+			)
+			return example.Nil(), false, err
+		}
+
+		item, err = e.save(ctx, userId, item, version)
+		return item, false, err
+	case errors.Is(err, notFoundError):
+		if condition == ReplaceOnly {
+			slog.LogAttrs(
+				ctx,
+				slog.LevelInfo,
+				notFoundMsg,
+				slog.String(logKeyId, id),
+			)
+			return example.Nil(), false, preconditionFailedError
+		}
+
+		item, err = e.create(ctx, userId, id, r, condition)
+		return item, err == nil, err
+	default:
+		slog.LogAttrs(
+			ctx,
+			slog.LevelError,
+			storeError,
+			slog.String(logKeyError, err.Error()),
+		)
+		return example.Nil(), false, serviceError
+	}
+}
+
+func (e Service) create(ctx context.Context, userId, id string, r Replacement, condition PutCondition) (example.Example, error) {
+	item, err := example.New(userId, r.Message)
+	item.Id = id
+
+	// New has checked the message, the rest is set the way Add sets it
+	var invalid example.ValidationError
+	invalid.Collect(err)
+	invalid.Collect(r.Content.apply(&item))
+
+	if r.Status != "" && r.Status != item.Status {
+		invalid.Collect(item.SetStatus(r.Status))
+	}
+
+	if err := invalid.Err(); err != nil {
+		slog.LogAttrs(
+			ctx,
+			slog.LevelError,
+			domainError,
+			slog.String(logKeyError, err.Error()),
+		)
+		return example.Nil(), err
+	}
+
+	storedItem, err := e.Store.Add(ctx, item, e.Quota)
+	if err != nil {
+		slog.LogAttrs(
+			ctx,
+			slog.LevelError,
+			storeError,
+			slog.String(logKeyError, err.Error()),
+		)
+
+		switch {
+		case errors.Is(err, quotaExceededError):
+			return example.Nil(), overQuotaError
+		case errors.Is(err, alreadyExistsError) && condition == CreateOnly:
+			return example.Nil(), preconditionFailedError
+		case errors.Is(err, alreadyExistsError):
+			// Created by someone else since it was read, or sitting in the trash
+			return example.Nil(), repositoryConflictError
+		default:
+			return example.Nil(), repositoryAddError
+		}
+	}
+
+	e.Bus.Notify(
+		events.NewEvent(
+			userId,
+			example.ExampleCreated{Id: storedItem.Id},
+		),
+	)
+
+	return storedItem, nil
+}
+
 // MARK: DELETE
-func (e Service) Delete(ctx context.Context, userId, id string) error {
+/*
+Moves one of the user's examples to the trash, a non-zero version makes the
+delete conditional. Another user's example is not found.
+*/
+func (e Service) Delete(ctx context.Context, userId, id string, version int) error {
 	slog.LogAttrs(
 		ctx,
 		slog.LevelInfo,
@@ -165,7 +479,12 @@ func (e Service) Delete(ctx context.Context, userId, id string) error {
 		slog.String(logKeyId, id),
 	)
 
-	err := e.Store.Delete(ctx, id)
+	// An example never changes owner, so it's still theirs when it's deleted
+	if _, err := e.owned(ctx, userId, id); err != nil {
+		return err
+	}
+
+	err := e.Store.Delete(ctx, id, userId, version)
 
 	if err != nil {
 		switch {
@@ -177,6 +496,15 @@ func (e Service) Delete(ctx context.Context, userId, id string) error {
 				slog.String(logKeyId, id),
 			)
 			return repositoryNotFoundError
+		case errors.Is(err, versionMismatchError):
+			slog.LogAttrs(
+				ctx,
+				slog.LevelInfo,
+				versionMismatchMsg,
+				slog.String(logKeyId, id),
+				slog.Int(logKeyVersion, version),
+			)
+			return preconditionFailedError
 		default:
 			slog.LogAttrs(
 				ctx,
@@ -195,6 +523,392 @@ func (e Service) Delete(ctx context.Context, userId, id string) error {
 	return nil
 }
 
+// MARK: RESTORE
+/*
+Takes an example out of the trash, examples that aren't in the trash or belong
+to another user are not found. It counts against the quota again once restored
+*/
+func (e Service) Restore(ctx context.Context, userId, id string) (example.Example, error) {
+	slog.LogAttrs(
+		ctx,
+		slog.LevelInfo,
+		exampleServiceRestore,
+		slog.String(logKeyId, id),
+	)
+
+	item, err := e.Store.Restore(ctx, userId, id, e.Quota)
+	if err != nil {
+		switch {
+		case errors.Is(err, quotaExceededError):
+			slog.LogAttrs(
+				ctx,
+				slog.LevelInfo,
+				overQuotaMsg,
+				slog.String(logKeyUserId, userId),
+			)
+			return example.Nil(), overQuotaError
+		case errors.Is(err, notFoundError):
+			slog.LogAttrs(
+				ctx,
+				slog.LevelInfo,
+				notFoundMsg,
+				slog.String(logKeyId, id),
+			)
+			return example.Nil(), repositoryNotFoundError
+		default:
+			slog.LogAttrs(
+				ctx,
+				slog.LevelError,
+				storeError,
+				slog.String(logKeyError, err.Error()),
+			)
+			return example.Nil(), repositoryUpdateError
+		}
+	}
+
+	e.Bus.Notify(events.NewEvent(
+		userId,
+		example.ExampleRestored{Id: id},
+	))
+
+	return item, nil
+}
+
+// MARK: REVISIONS
+/*
+Loads an example for its owner, other users' examples are not found
+*/
+func (e Service) owned(ctx context.Context, userId, id string) (example.Example, error) {
+	item, err := e.Get(ctx, id)
+	if err != nil {
+		return example.Nil(), err
+	}
+
+	if item.UserId != userId {
+		slog.LogAttrs(
+			ctx,
+			slog.LevelInfo,
+			notFoundMsg,
+			slog.String(logKeyId, id),
+			slog.String(logKeyUserId, userId),
+		)
+		return example.Nil(), repositoryNotFoundError
+	}
+
+	return item, nil
+}
+
+func (e Service) ListRevisions(ctx context.Context, userId, id string, p store.Pagination) (store.Page[example.Revision], error) {
+	slog.LogAttrs(
+		ctx,
+		slog.LevelInfo,
+		exampleServiceRevs,
+		slog.String(logKeyId, id),
+	)
+
+	if p.Limit > 50 {
+		return store.Page[example.Revision]{}, limitToLargeError
+	}
+
+	if p.Cursor == nil && p.Page < 1 {
+		return store.Page[example.Revision]{}, invalidPageError
+	}
+
+	if _, err := e.owned(ctx, userId, id); err != nil {
+		return store.Page[example.Revision]{}, err
+	}
+
+	res, err := e.Store.ListRevisions(ctx, id, p)
+	if err != nil {
+		slog.LogAttrs(
+			ctx,
+			slog.LevelError,
+			storeError,
+			slog.String(logKeyError, err.Error()),
+		)
+		return store.Page[example.Revision]{}, RepositoryListError
+	}
+
+	return res, nil
+}
+
+func (e Service) GetRevision(ctx context.Context, userId, id string, revision int) (example.Revision, error) {
+	slog.LogAttrs(
+		ctx,
+		slog.LevelInfo,
+		exampleServiceGetRev,
+		slog.String(logKeyId, id),
+		slog.Int(logKeyRevision, revision),
+	)
+
+	if _, err := e.owned(ctx, userId, id); err != nil {
+		return example.Revision{}, err
+	}
+
+	return e.revision(ctx, id, revision)
+}
+
+func (e Service) revision(ctx context.Context, id string, revision int) (example.Revision, error) {
+	rev, err := e.Store.GetRevision(ctx, id, revision)
+	if err != nil {
+		switch {
+		case errors.Is(err, revisionNotFoundError):
+			slog.LogAttrs(
+				ctx,
+				slog.LevelInfo,
+				notFoundMsg,
+				slog.String(logKeyId, id),
+				slog.Int(logKeyRevision, revision),
+			)
+			return example.Revision{}, revisionNotFoundServiceError
+		default:
+			slog.LogAttrs(
+				ctx,
+				slog.LevelError,
+				storeError,
+				slog.String(logKeyError, err.Error()),
+			)
+			return example.Revision{}, err
+		}
+	}
+
+	return rev, nil
+}
+
+/*
+Puts an example's content back to how it was at revision
+
+Reverting is an update like any other, it writes a new revision and takes the
+same version as Update
+*/
+func (e Service) Revert(ctx context.Context, userId, id string, revision, version int) (example.Example, error) {
+	slog.LogAttrs(
+		ctx,
+		slog.LevelInfo,
+		exampleServiceRevert,
+		slog.String(logKeyId, id),
+		slog.Int(logKeyRevision, revision),
+	)
+
+	item, err := e.owned(ctx, userId, id)
+	if err != nil {
+		return example.Nil(), err
+	}
+
+	if version != 0 && item.Version != version {
+		slog.LogAttrs(
+			ctx,
+			slog.LevelInfo,
+			versionMismatchMsg,
+			slog.String(logKeyId, id),
+			slog.Int(logKeyVersion, version),
+		)
+		return example.Nil(), preconditionFailedError
+	}
+
+	rev, err := e.revision(ctx, id, revision)
+	if err != nil {
+		return example.Nil(), err
+	}
+
+	if err := item.Revert(rev); err != nil {
+		slog.LogAttrs(
+			ctx,
+			slog.LevelError,
+			domainError,
+			slog.String(logKeyError, err.Error()),
+		)
+		return example.Nil(), err
+	}
+
+	return e.save(ctx, userId, item, version)
+}
+
+// MARK: BATCH
+/*
+Most operations a single batch can carry
+*/
+const MaxBatchOperations = 100
+
+type OperationKind int
+
+const (
+	CreateOperation OperationKind = iota
+	UpdateOperation
+	DeleteOperation
+)
+
+/*
+One operation in a batch, Content is used by creates and Changes by updates
+
+Version works like it does for Update and Delete, 0 is unconditional
+*/
+type Operation struct {
+	Kind    OperationKind
+	Id      string
+	Version int
+	Content Content
+	Changes Changes
+}
+
+/*
+Item is empty for deletes and failed operations
+*/
+type OperationResult struct {
+	Item example.Example
+	Err  error
+}
+
+/*
+Prepares the write for an operation the same way Add, Update and Delete do
+*/
+func (e Service) prepare(ctx context.Context, userId string, op Operation) (Write, error) {
+	switch op.Kind {
+	case CreateOperation:
+		item, err := example.New(userId, op.Content.Message)
+
+		var invalid example.ValidationError
+		invalid.Collect(err)
+		invalid.Collect(op.Content.apply(&item))
+
+		return Write{Kind: CreateWrite, Item: item}, invalid.Err()
+	}
+
+	// Another user's example is not found, they can't write to it
+	item, err := e.owned(ctx, userId, op.Id)
+	if errors.Is(err, repositoryNotFoundError) {
+		return Write{}, err
+	}
+
+	if err != nil {
+		return Write{}, repositoryUpdateError
+	}
+
+	if op.Kind == DeleteOperation {
+		return Write{Kind: DeleteWrite, Item: example.Example{Id: op.Id, UserId: userId, Version: op.Version}, DeletedBy: userId}, nil
+	}
+
+	if op.Version != 0 && item.Version != op.Version {
+		return Write{}, preconditionFailedError
+	}
+
+	err = op.Changes.apply(&item)
+
+	return Write{Kind: UpdateWrite, Item: item}, err
+}
+
+/*
+The same errors Update and Delete return for a failed write
+*/
+func writeError(err error, version int) error {
+	switch {
+	case errors.Is(err, rolledBackError):
+		return notAppliedError
+	case errors.Is(err, quotaExceededError):
+		return overQuotaError
+	case errors.Is(err, notFoundError):
+		return repositoryNotFoundError
+	case errors.Is(err, versionMismatchError) && version != 0:
+		return preconditionFailedError
+	case errors.Is(err, versionMismatchError):
+		return repositoryConflictError
+	default:
+		return repositoryUpdateError
+	}
+}
+
+/*
+Applies many operations with one transaction and one batch of events
+
+An atomic batch applies every operation or none of them, when one fails the
+others report notAppliedError. Otherwise each operation succeeds or fails on
+its own. Results are in the same order as ops.
+*/
+func (e Service) Batch(ctx context.Context, userId string, ops []Operation, atomic bool) ([]OperationResult, error) {
+	slog.LogAttrs(
+		ctx,
+		slog.LevelInfo,
+		exampleServiceBatch,
+		slog.String(logKeyUserId, userId),
+		slog.Int(logKeyOperations, len(ops)),
+	)
+
+	if len(ops) > MaxBatchOperations {
+		return nil, batchTooLargeError
+	}
+
+	results := make([]OperationResult, len(ops))
+
+	// Operations that can't be written are left out of the batch
+	var writes []Write
+	var written []int
+
+	for i, op := range ops {
+		write, err := e.prepare(ctx, userId, op)
+		if err != nil {
+			slog.LogAttrs(
+				ctx,
+				slog.LevelInfo,
+				domainError,
+				slog.String(logKeyId, op.Id),
+				slog.String(logKeyError, err.Error()),
+			)
+
+			results[i].Err = err
+			continue
+		}
+
+		writes = append(writes, write)
+		written = append(written, i)
+	}
+
+	if atomic && len(written) < len(ops) {
+		for _, i := range written {
+			results[i].Err = notAppliedError
+		}
+
+		return results, nil
+	}
+
+	if len(writes) == 0 {
+		return results, nil
+	}
+
+	stored, err := e.Store.Batch(ctx, userId, writes, atomic, e.Quota)
+	if err != nil {
+		slog.LogAttrs(
+			ctx,
+			slog.LevelError,
+			storeError,
+			slog.String(logKeyError, err.Error()),
+		)
+		return nil, repositoryUpdateError
+	}
+
+	var notifications []events.Event
+	for n, i := range written {
+		if stored[n].Err != nil {
+			results[i].Err = writeError(stored[n].Err, ops[i].Version)
+			continue
+		}
+
+		results[i].Item = stored[n].Item
+
+		switch ops[i].Kind {
+		case CreateOperation:
+			notifications = append(notifications, events.NewEvent(userId, example.ExampleCreated{Id: stored[n].Item.Id}))
+		case UpdateOperation:
+			notifications = append(notifications, events.NewEvent(userId, example.ExampleUpdated{Id: stored[n].Item.Id}))
+		case DeleteOperation:
+			notifications = append(notifications, events.NewEvent(userId, example.ExampleDeleted{Id: ops[i].Id}))
+		}
+	}
+
+	e.Bus.NotifyAll(notifications)
+
+	return results, nil
+}
+
 // MARK: GET
 func (e Service) Get(ctx context.Context, id string) (example.Example, error) {
 	slog.LogAttrs(
@@ -230,7 +944,7 @@ func (e Service) Get(ctx context.Context, id string) (example.Example, error) {
 }
 
 // MARK: LIST
-func (e Service) List(ctx context.Context, userId string, limit int, page int) ([]example.Example, error) {
+func (e Service) List(ctx context.Context, userId string, filters []store.Filter, p store.Pagination) (store.Page[example.Example], error) {
 	slog.LogAttrs(
 		ctx,
 		slog.LevelInfo,
@@ -240,15 +954,20 @@ func (e Service) List(ctx context.Context, userId string, limit int, page int) (
 
 	// Maximimum limit we support is 50
 	// Chosen arbitrarily to keep load on DB to "something reasonable"
-	if limit > 50 {
-		return nil, limitToLargeError
+	if p.Limit > 50 {
+		return store.Page[example.Example]{}, limitToLargeError
+	}
+
+	// Page is only used when there is no cursor
+	if p.Cursor == nil && p.Page < 1 {
+		return store.Page[example.Example]{}, invalidPageError
 	}
 
-	if page < 1 {
-		return nil, invalidPageError
+	if err := p.Validate(); err != nil {
+		return store.Page[example.Example]{}, err
 	}
 
-	res, err := e.Store.List(ctx, userId, limit, page)
+	res, err := e.Store.List(ctx, userId, filters, p)
 	if err != nil {
 		slog.LogAttrs(
 			ctx,
@@ -256,8 +975,34 @@ func (e Service) List(ctx context.Context, userId string, limit int, page int) (
 			storeError,
 			slog.String(logKeyError, err.Error()),
 		)
-		return []example.Example{}, RepositoryListError
+		return store.Page[example.Example]{Items: []example.Example{}}, RepositoryListError
 	}
 
 	return res, err
 }
+
+// MARK: USAGE
+/*
+What a user is storing and the quota it counts against
+*/
+func (e Service) Usage(ctx context.Context, userId string) (users.Usage, users.Quota, error) {
+	slog.LogAttrs(
+		ctx,
+		slog.LevelInfo,
+		exampleServiceUsage,
+		slog.String(logKeyUserId, userId),
+	)
+
+	usage, quota, err := e.Store.Usage(ctx, userId, e.Quota)
+	if err != nil {
+		slog.LogAttrs(
+			ctx,
+			slog.LevelError,
+			storeError,
+			slog.String(logKeyError, err.Error()),
+		)
+		return users.Usage{}, users.Quota{}, serviceError
+	}
+
+	return usage, quota, nil
+}
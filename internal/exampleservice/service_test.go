@@ -2,11 +2,17 @@ package exampleservice
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
 	"testing"
 
 	"github.com/google/uuid"
 	"github.com/moonmoon1919/go-api-reference/internal/bus"
+	"github.com/moonmoon1919/go-api-reference/internal/store"
+	"github.com/moonmoon1919/go-api-reference/pkg/example"
+	"github.com/moonmoon1919/go-api-reference/pkg/users"
 )
 
 // MARK: Add
@@ -27,17 +33,17 @@ func TestExampleAdd(t *testing.T) {
 			name:       "FailingCase",
 			userId:     uuid.NewString(),
 			message:    "",
-			errMessage: "invalid message. error: message length must be greater than 0",
+			errMessage: "message length must be greater than 0",
 		},
 	}
 
 	b := bus.NewFake()
 	repo := NewInMemoryExampleRepository()
-	service := Service{Store: repo, Bus: b}
+	service := Service{Store: repo, Bus: b, Quota: users.NoQuota()}
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			item, err := service.Add(context.TODO(), tc.userId, tc.message)
+			item, err := service.Add(context.TODO(), tc.userId, Content{Message: tc.message})
 
 			var errMessage string
 			if err != nil {
@@ -56,6 +62,198 @@ func TestExampleAdd(t *testing.T) {
 	}
 }
 
+func TestExampleAddViolations(t *testing.T) {
+	tests := []struct {
+		name          string
+		content       Content
+		expectedCodes []string
+	}{
+		{
+			name:          "PassingCase",
+			content:       Content{Title: "Café", Message: "Line one\nLine two"},
+			expectedCodes: nil,
+		},
+		{
+			name:          "FailingCase-Blank",
+			content:       Content{Message: "   "},
+			expectedCodes: []string{example.CodeBlank},
+		},
+		{
+			name:          "FailingCase-ControlCharacterAndInvalidUTF8",
+			content:       Content{Message: "bell\a\xff"},
+			expectedCodes: []string{example.CodeInvalidUTF8, example.CodeControlCharacter},
+		},
+		{
+			name:          "FailingCase-ForbiddenWord",
+			content:       Content{Message: "This is SPAM"},
+			expectedCodes: []string{example.CodeForbiddenWord},
+		},
+		{
+			name:          "FailingCase-EveryFieldIsReported",
+			content:       Content{Title: strings.Repeat("t", 129), Message: strings.Repeat("m", 4097), Tags: []string{"no spaces"}},
+			expectedCodes: []string{example.CodeTooLong, example.CodeTooLong, example.CodeInvalidTag},
+		},
+	}
+
+	rules := example.MessageRules
+	example.MessageRules = append(slices.Clone(rules), example.ForbiddenWords("spam"))
+	defer func() { example.MessageRules = rules }()
+
+	service := Service{Store: NewInMemoryExampleRepository(), Bus: bus.NewFake(), Quota: users.NoQuota()}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			// When
+			_, err := service.Add(context.TODO(), uuid.NewString(), tc.content)
+
+			// Then
+			var codes []string
+			var invalid example.ValidationError
+			if errors.As(err, &invalid) {
+				for _, v := range invalid.Violations {
+					codes = append(codes, v.Code)
+				}
+			}
+
+			if !slices.Equal(codes, tc.expectedCodes) {
+				t.Errorf("expected violations %v, got %v", tc.expectedCodes, codes)
+			}
+		})
+	}
+}
+
+func TestExampleAddQuota(t *testing.T) {
+	tests := []struct {
+		name       string
+		quota      users.Quota
+		own        *users.Quota
+		existing   []string
+		trashed    bool
+		message    string
+		errMessage string
+	}{
+		{
+			name:     "PassingCase-WithinQuota",
+			quota:    users.Quota{Examples: 2, Bytes: 100},
+			existing: []string{"first"},
+			message:  "second",
+		},
+		{
+			name:    "PassingCase-Unlimited",
+			quota:   users.NoQuota(),
+			message: strings.Repeat("a", example.MaxMessageLength),
+		},
+		{
+			name:     "PassingCase-TrashDoesNotCount",
+			quota:    users.Quota{Examples: 1, Bytes: users.Unlimited},
+			existing: []string{"first"},
+			trashed:  true,
+			message:  "second",
+		},
+		{
+			name:     "PassingCase-OwnQuota",
+			quota:    users.Quota{Examples: 1, Bytes: users.Unlimited},
+			own:      &users.Quota{Examples: 5, Bytes: users.Unlimited},
+			existing: []string{"first"},
+			message:  "second",
+		},
+		{
+			name:       "FailingCase-TooManyExamples",
+			quota:      users.Quota{Examples: 1, Bytes: users.Unlimited},
+			existing:   []string{"first"},
+			message:    "second",
+			errMessage: "storage quota exceeded",
+		},
+		{
+			name:       "FailingCase-TooManyBytes",
+			quota:      users.Quota{Examples: users.Unlimited, Bytes: 10},
+			existing:   []string{"first"},
+			message:    "second",
+			errMessage: "storage quota exceeded",
+		},
+		{
+			name:       "FailingCase-OwnQuota",
+			quota:      users.Quota{Examples: 5, Bytes: users.Unlimited},
+			own:        &users.Quota{Examples: 1, Bytes: users.Unlimited},
+			existing:   []string{"first"},
+			message:    "second",
+			errMessage: "storage quota exceeded",
+		},
+		{
+			name:       "FailingCase-ZeroQuota",
+			quota:      users.NoQuota(),
+			own:        &users.Quota{Examples: 0, Bytes: 0},
+			message:    "first",
+			errMessage: "storage quota exceeded",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			repo := NewInMemoryExampleRepository()
+			service := Service{Store: repo, Bus: bus.NewFake(), Quota: users.NoQuota()}
+			userId := uuid.NewString()
+
+			for _, message := range tc.existing {
+				item, _ := service.Add(context.TODO(), userId, Content{Message: message})
+
+				if tc.trashed {
+					service.Delete(context.TODO(), userId, item.Id, 0)
+				}
+			}
+
+			if tc.own != nil {
+				repo.quotas[userId] = *tc.own
+			}
+
+			service.Quota = tc.quota
+
+			// When
+			_, err := service.Add(context.TODO(), userId, Content{Message: tc.message})
+
+			// Then
+			var errMessage string
+			if err != nil {
+				errMessage = err.Error()
+			}
+
+			if errMessage != tc.errMessage {
+				t.Errorf("expected error message %s, got %s", tc.errMessage, errMessage)
+			}
+		})
+	}
+}
+
+func TestExampleUsage(t *testing.T) {
+	// Given
+	service := Service{Store: NewInMemoryExampleRepository(), Bus: bus.NewFake(), Quota: users.Quota{Examples: 10, Bytes: 1000}}
+	userId := uuid.NewString()
+
+	service.Add(context.TODO(), userId, Content{Title: "Café", Message: "hello", Tags: []string{"go"}})
+	trashed, _ := service.Add(context.TODO(), userId, Content{Message: "gone"})
+	service.Delete(context.TODO(), userId, trashed.Id, 0)
+	service.Add(context.TODO(), uuid.NewString(), Content{Message: "someone else"})
+
+	// When
+	usage, quota, err := service.Usage(context.TODO(), userId)
+
+	// Then
+	if err != nil {
+		t.Errorf("unexpected error %s", err.Error())
+	}
+
+	// Café is 5 bytes in UTF-8
+	if usage != (users.Usage{Examples: 1, Bytes: 12}) {
+		t.Errorf("expected 1 example of 12 bytes, got %+v", usage)
+	}
+
+	if quota != service.Quota {
+		t.Errorf("expected the default quota, got %+v", quota)
+	}
+}
+
 // MARK: Update
 func TestExampleUpdate(t *testing.T) {
 	tests := []struct {
@@ -63,6 +261,7 @@ func TestExampleUpdate(t *testing.T) {
 		initialMessage string
 		userId         string
 		updatedMessage string
+		otherOwner     bool
 		errMessage     string
 	}{
 		{
@@ -77,23 +276,36 @@ func TestExampleUpdate(t *testing.T) {
 			initialMessage: "Hi",
 			userId:         uuid.NewString(),
 			updatedMessage: "",
-			errMessage:     "invalid message. error: message length must be greater than 0",
+			errMessage:     "message length must be greater than 0",
+		},
+		{
+			name:           "FailingCase-OtherUsersExample",
+			initialMessage: "Hi",
+			userId:         uuid.NewString(),
+			updatedMessage: "Bye",
+			otherOwner:     true,
+			errMessage:     "item not found",
 		},
 	}
 
 	b := bus.NewFake()
 	repo := NewInMemoryExampleRepository()
-	service := Service{Store: repo, Bus: b}
+	service := Service{Store: repo, Bus: b, Quota: users.NoQuota()}
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			item, err := service.Add(context.TODO(), tc.userId, tc.initialMessage)
+			owner := tc.userId
+			if tc.otherOwner {
+				owner = uuid.NewString()
+			}
+
+			item, err := service.Add(context.TODO(), owner, Content{Message: tc.initialMessage})
 
 			if err != nil {
 				t.Errorf("unexpected error message %s", err)
 			}
 
-			updatedItem, err := service.Update(context.TODO(), tc.userId, item.Id, tc.updatedMessage)
+			updatedItem, err := service.Update(context.TODO(), tc.userId, item.Id, Changes{Message: tc.updatedMessage}, 0)
 
 			var errMessage string
 			if err != nil {
@@ -104,9 +316,402 @@ func TestExampleUpdate(t *testing.T) {
 				t.Errorf("expected error message %s, got %s", tc.errMessage, errMessage)
 			}
 
-			if updatedItem.Message != tc.updatedMessage {
+			if err == nil && updatedItem.Message != tc.updatedMessage {
 				t.Errorf("expected message %s, got %s", tc.updatedMessage, updatedItem.Message)
 			}
+
+			if stored, _ := service.Get(context.TODO(), item.Id); tc.otherOwner && stored.Message != tc.initialMessage {
+				t.Errorf("expected the other user's example to be unchanged, got %s", stored.Message)
+			}
+		})
+	}
+}
+
+func TestExampleUpdateFields(t *testing.T) {
+	empty := ""
+	longTitle := strings.Repeat("t", 129)
+
+	tests := []struct {
+		name           string
+		steps          []Changes
+		expectedTitle  string
+		expectedTags   []string
+		expectedStatus example.Status
+		errMessage     string
+	}{
+		{
+			name:           "PassingCase-TagsAreNormalized",
+			steps:          []Changes{{Tags: []string{"Go", " api ", "go"}}},
+			expectedTitle:  "Hello",
+			expectedTags:   []string{"api", "go"},
+			expectedStatus: example.Draft,
+		},
+		{
+			name:           "PassingCase-ClearTitle",
+			steps:          []Changes{{Title: &empty}},
+			expectedTags:   []string{"first"},
+			expectedStatus: example.Draft,
+		},
+		{
+			name:           "PassingCase-PublishThenArchive",
+			steps:          []Changes{{Status: example.Published}, {Status: example.Archived}},
+			expectedTitle:  "Hello",
+			expectedTags:   []string{"first"},
+			expectedStatus: example.Archived,
+		},
+		{
+			name:       "FailingCase-ArchivedIsFinal",
+			steps:      []Changes{{Status: example.Archived}, {Status: example.Published}},
+			errMessage: "status cannot change from archived to published",
+		},
+		{
+			name:       "FailingCase-UnknownStatus",
+			steps:      []Changes{{Status: "deleted"}},
+			errMessage: "status must be draft, published or archived",
+		},
+		{
+			name:       "FailingCase-InvalidTag",
+			steps:      []Changes{{Tags: []string{"no spaces"}}},
+			errMessage: "tags must be 1-32 lowercase letters, digits or dashes",
+		},
+		{
+			name:       "FailingCase-TitleTooLong",
+			steps:      []Changes{{Title: &longTitle}},
+			errMessage: "title length must be at most 128",
+		},
+	}
+
+	service := Service{Store: NewInMemoryExampleRepository(), Bus: bus.NewFake(), Quota: users.NoQuota()}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			userId := uuid.NewString()
+			item, _ := service.Add(context.TODO(), userId, Content{Title: "Hello", Message: "Hi", Tags: []string{"first"}})
+
+			// When
+			var err error
+			for _, changes := range tc.steps {
+				item, err = service.Update(context.TODO(), userId, item.Id, changes, 0)
+			}
+
+			// Then
+			var errMessage string
+			if err != nil {
+				errMessage = err.Error()
+			}
+
+			if errMessage != tc.errMessage {
+				t.Errorf("expected error message %s, got %s", tc.errMessage, errMessage)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if item.Title != tc.expectedTitle || item.Status != tc.expectedStatus || !slices.Equal(item.Tags, tc.expectedTags) {
+				t.Errorf("expected %q %v %s, got %q %v %s", tc.expectedTitle, tc.expectedTags, tc.expectedStatus, item.Title, item.Tags, item.Status)
+			}
+		})
+	}
+}
+
+func TestExampleUpdateVersion(t *testing.T) {
+	tests := []struct {
+		name            string
+		version         int
+		concurrent      bool
+		expectedVersion int
+		errMessage      string
+	}{
+		{
+			name:            "PassingCase-Unconditional",
+			version:         0,
+			expectedVersion: 2,
+			errMessage:      "",
+		},
+		{
+			name:            "PassingCase-MatchingVersion",
+			version:         1,
+			expectedVersion: 2,
+			errMessage:      "",
+		},
+		{
+			name:       "FailingCase-StaleVersion",
+			version:    1,
+			concurrent: true,
+			errMessage: "item version does not match",
+		},
+		{
+			name:       "FailingCase-UnknownVersion",
+			version:    7,
+			errMessage: "item version does not match",
+		},
+	}
+
+	b := bus.NewFake()
+	repo := NewInMemoryExampleRepository()
+	service := Service{Store: repo, Bus: b, Quota: users.NoQuota()}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			userId := uuid.NewString()
+			item, _ := service.Add(context.TODO(), userId, Content{Message: "Hi"})
+
+			if tc.concurrent {
+				service.Update(context.TODO(), userId, item.Id, Changes{Message: "First"}, 0)
+			}
+
+			// When
+			updatedItem, err := service.Update(context.TODO(), userId, item.Id, Changes{Message: "Bye"}, tc.version)
+
+			// Then
+			var errMessage string
+			if err != nil {
+				errMessage = err.Error()
+			}
+
+			if errMessage != tc.errMessage {
+				t.Errorf("expected error message %s, got %s", tc.errMessage, errMessage)
+			}
+
+			if updatedItem.Version != tc.expectedVersion {
+				t.Errorf("expected version %d, got %d", tc.expectedVersion, updatedItem.Version)
+			}
+		})
+	}
+}
+
+func TestExampleUpdateConflict(t *testing.T) {
+	// Given
+	b := bus.NewFake()
+	repo := NewInMemoryExampleRepository()
+	service := Service{Store: repo, Bus: b, Quota: users.NoQuota()}
+
+	item, _ := service.Add(context.TODO(), uuid.NewString(), Content{Message: "Hi"})
+
+	// Another writer got there first
+	stale := item
+	stale.SetMessage("First")
+	repo.Update(context.TODO(), stale, users.NoQuota())
+
+	// When
+	_, err := repo.Update(context.TODO(), item, users.NoQuota())
+
+	// Then
+	if !errors.Is(err, versionMismatchError) {
+		t.Errorf("expected %s, got %v", versionMismatchError, err)
+	}
+}
+
+func TestExampleUpdateQuota(t *testing.T) {
+	tests := []struct {
+		name       string
+		quota      users.Quota
+		message    string
+		errMessage string
+	}{
+		{
+			name:    "PassingCase-WithinQuota",
+			quota:   users.Quota{Examples: users.Unlimited, Bytes: 10},
+			message: "0123456789",
+		},
+		{
+			name:    "PassingCase-ShrinksWhileOverQuota",
+			quota:   users.Quota{Examples: users.Unlimited, Bytes: 2},
+			message: "abc",
+		},
+		{
+			name:       "FailingCase-TooManyBytes",
+			quota:      users.Quota{Examples: users.Unlimited, Bytes: 10},
+			message:    "01234567890",
+			errMessage: "storage quota exceeded",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			service := Service{Store: NewInMemoryExampleRepository(), Bus: bus.NewFake(), Quota: users.NoQuota()}
+			userId := uuid.NewString()
+			item, _ := service.Add(context.TODO(), userId, Content{Message: "hello"})
+
+			// The quota was lowered after the example was stored
+			service.Quota = tc.quota
+
+			// When
+			_, err := service.Update(context.TODO(), userId, item.Id, Changes{Message: tc.message}, 0)
+
+			// Then
+			var errMessage string
+			if err != nil {
+				errMessage = err.Error()
+			}
+
+			if errMessage != tc.errMessage {
+				t.Errorf("expected error message %s, got %s", tc.errMessage, errMessage)
+			}
+		})
+	}
+}
+
+// MARK: Put
+func TestExamplePut(t *testing.T) {
+	tests := []struct {
+		name            string
+		exists          bool
+		otherOwner      bool
+		trashed         bool
+		condition       PutCondition
+		version         int
+		message         string
+		expectedCreated bool
+		expectedVersion int
+		expectedEvent   example.ExampleEvent
+		errMessage      string
+	}{
+		{
+			name:            "PassingCase-Create",
+			message:         "new",
+			expectedCreated: true,
+			expectedVersion: 1,
+			expectedEvent:   example.ExampleCreatedEvent,
+		},
+		{
+			name:            "PassingCase-Replace",
+			exists:          true,
+			message:         "replaced",
+			expectedVersion: 2,
+			expectedEvent:   example.ExampleUpdatedEvent,
+		},
+		{
+			name:            "PassingCase-CreateOnly",
+			condition:       CreateOnly,
+			message:         "new",
+			expectedCreated: true,
+			expectedVersion: 1,
+			expectedEvent:   example.ExampleCreatedEvent,
+		},
+		{
+			name:            "PassingCase-ReplaceMatchingVersion",
+			exists:          true,
+			condition:       ReplaceOnly,
+			version:         1,
+			message:         "replaced",
+			expectedVersion: 2,
+			expectedEvent:   example.ExampleUpdatedEvent,
+		},
+		{
+			name:       "FailingCase-CreateOnlyExists",
+			exists:     true,
+			condition:  CreateOnly,
+			message:    "new",
+			errMessage: "item version does not match",
+		},
+		{
+			name:       "FailingCase-ReplaceOnlyMissing",
+			condition:  ReplaceOnly,
+			message:    "replaced",
+			errMessage: "item version does not match",
+		},
+		{
+			name:       "FailingCase-StaleVersion",
+			exists:     true,
+			condition:  ReplaceOnly,
+			version:    7,
+			message:    "replaced",
+			errMessage: "item version does not match",
+		},
+		{
+			name:       "FailingCase-OtherUsersExample",
+			exists:     true,
+			otherOwner: true,
+			message:    "replaced",
+			errMessage: "item not found",
+		},
+		{
+			name:       "FailingCase-OtherUsersExampleCreateOnly",
+			exists:     true,
+			otherOwner: true,
+			condition:  CreateOnly,
+			message:    "new",
+			errMessage: "item not found",
+		},
+		{
+			name:       "FailingCase-InTrash",
+			exists:     true,
+			trashed:    true,
+			message:    "new",
+			errMessage: "item was modified by another request",
+		},
+		{
+			name:       "FailingCase-EmptyMessage",
+			message:    "",
+			errMessage: "message length must be greater than 0",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			b := bus.NewFake()
+			repo := NewInMemoryExampleRepository()
+			service := Service{Store: repo, Bus: b, Quota: users.NoQuota()}
+
+			userId := uuid.NewString()
+			id := uuid.NewString()
+
+			if tc.exists {
+				owner := userId
+				if tc.otherOwner {
+					owner = uuid.NewString()
+				}
+
+				item, _ := example.New(owner, "existing")
+				item.Id = id
+				repo.Add(context.TODO(), item, users.NoQuota())
+			}
+
+			if tc.trashed {
+				repo.Delete(context.TODO(), id, userId, 0)
+			}
+
+			// When
+			item, created, err := service.Put(context.TODO(), userId, id, Replacement{Content: Content{Message: tc.message, Tags: []string{"sync"}}}, tc.condition, tc.version)
+
+			// Then
+			var errMessage string
+			if err != nil {
+				errMessage = err.Error()
+			}
+
+			if errMessage != tc.errMessage {
+				t.Errorf("expected error message %s, got %s", tc.errMessage, errMessage)
+			}
+
+			if tc.errMessage != "" {
+				if len(b.Messages) != 0 {
+					t.Errorf("expected no events, got %d", len(b.Messages))
+				}
+
+				if stored, err := repo.Get(context.TODO(), id); err == nil && stored.Message != "existing" {
+					t.Errorf("expected the example to be left alone, got %+v", stored)
+				}
+				return
+			}
+
+			if created != tc.expectedCreated || item.Id != id || item.Version != tc.expectedVersion {
+				t.Errorf("expected created %t at version %d with id %s, got %t, %d, %s", tc.expectedCreated, tc.expectedVersion, id, created, item.Version, item.Id)
+			}
+
+			if item.Message != tc.message || !slices.Equal(item.Tags, []string{"sync"}) {
+				t.Errorf("expected every field to be replaced, got %+v", item)
+			}
+
+			if len(b.Messages) != 1 || b.Messages[0].Name != tc.expectedEvent {
+				t.Errorf("expected one %s event, got %+v", tc.expectedEvent, b.Messages)
+			}
 		})
 	}
 }
@@ -117,6 +722,7 @@ func TestExampleDelete(t *testing.T) {
 		name       string
 		message    string
 		userId     string
+		otherOwner bool
 		errMessage string
 	}{
 		{
@@ -124,21 +730,33 @@ func TestExampleDelete(t *testing.T) {
 			userId:  uuid.NewString(),
 			message: "Hi",
 		},
+		{
+			name:       "FailingCase-OtherUsersExample",
+			userId:     uuid.NewString(),
+			message:    "Hi",
+			otherOwner: true,
+			errMessage: "item not found",
+		},
 	}
 
 	b := bus.NewFake()
 	repo := NewInMemoryExampleRepository()
-	service := Service{Store: repo, Bus: b}
+	service := Service{Store: repo, Bus: b, Quota: users.NoQuota()}
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			item, err := service.Add(context.TODO(), tc.userId, tc.message)
+			owner := tc.userId
+			if tc.otherOwner {
+				owner = uuid.NewString()
+			}
+
+			item, err := service.Add(context.TODO(), owner, Content{Message: tc.message})
 
 			if err != nil {
 				t.Errorf("unexpeced error %s", err)
 			}
 
-			err = service.Delete(context.TODO(), tc.userId, item.Id)
+			err = service.Delete(context.TODO(), tc.userId, item.Id, 0)
 
 			var errMessage string
 			if err != nil {
@@ -148,10 +766,114 @@ func TestExampleDelete(t *testing.T) {
 			if errMessage != tc.errMessage {
 				t.Errorf("expected error message %s, got %s", tc.errMessage, errMessage)
 			}
+
+			if _, err := service.Get(context.TODO(), item.Id); tc.otherOwner && err != nil {
+				t.Errorf("expected the other user's example not to be deleted, got %s", err.Error())
+			}
 		})
 	}
 }
 
+// MARK: Restore
+func TestExampleRestore(t *testing.T) {
+	tests := []struct {
+		name          string
+		delete        bool
+		otherUser     bool
+		expectedItems int
+		errMessage    string
+	}{
+		{
+			name:          "PassingCase",
+			delete:        true,
+			expectedItems: 1,
+		},
+		{
+			name:          "FailingCase-NotInTrash",
+			delete:        false,
+			expectedItems: 1,
+			errMessage:    "item not found",
+		},
+		{
+			name:          "FailingCase-OtherUser",
+			delete:        true,
+			otherUser:     true,
+			expectedItems: 0,
+			errMessage:    "item not found",
+		},
+	}
+
+	service := Service{Store: NewInMemoryExampleRepository(), Bus: bus.NewFake(), Quota: users.NoQuota()}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			userId := uuid.NewString()
+			item, _ := service.Add(context.TODO(), userId, Content{Message: "Hi"})
+
+			if tc.delete {
+				service.Delete(context.TODO(), userId, item.Id, 0)
+
+				if _, err := service.Get(context.TODO(), item.Id); err == nil {
+					t.Errorf("expected a deleted example to be hidden")
+				}
+			}
+
+			restoredBy := userId
+			if tc.otherUser {
+				restoredBy = uuid.NewString()
+			}
+
+			// When
+			restored, err := service.Restore(context.TODO(), restoredBy, item.Id)
+
+			// Then
+			var errMessage string
+			if err != nil {
+				errMessage = err.Error()
+			}
+
+			if errMessage != tc.errMessage {
+				t.Errorf("expected error message %s, got %s", tc.errMessage, errMessage)
+			}
+
+			// Deleting and restoring each wrote a version, both have a revision
+			if err == nil {
+				for version := 1; version <= restored.Version; version++ {
+					if _, err := service.GetRevision(context.TODO(), userId, item.Id, version); err != nil {
+						t.Errorf("expected a revision for version %d, got %s", version, err.Error())
+					}
+				}
+			}
+
+			page, _ := service.List(context.TODO(), userId, nil, store.Pagination{Limit: 10, Page: 1})
+			if len(page.Items) != tc.expectedItems {
+				t.Errorf("expected %d listed examples, got %d", tc.expectedItems, len(page.Items))
+			}
+		})
+	}
+}
+
+func TestExampleRestoreQuota(t *testing.T) {
+	// Given
+	service := Service{Store: NewInMemoryExampleRepository(), Bus: bus.NewFake(), Quota: users.Quota{Examples: 1, Bytes: users.Unlimited}}
+	userId := uuid.NewString()
+
+	item, _ := service.Add(context.TODO(), userId, Content{Message: "first"})
+	service.Delete(context.TODO(), userId, item.Id, 0)
+
+	// The trash doesn't count, so there's room for another
+	service.Add(context.TODO(), userId, Content{Message: "second"})
+
+	// When
+	_, err := service.Restore(context.TODO(), userId, item.Id)
+
+	// Then
+	if !errors.Is(err, overQuotaError) {
+		t.Errorf("expected %s, got %v", overQuotaError, err)
+	}
+}
+
 // MARK: Get
 func TestExampleGet(t *testing.T) {
 	tests := []struct {
@@ -179,14 +901,14 @@ func TestExampleGet(t *testing.T) {
 
 	b := bus.NewFake()
 	repo := NewInMemoryExampleRepository()
-	service := Service{Store: repo, Bus: b}
+	service := Service{Store: repo, Bus: b, Quota: users.NoQuota()}
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
 			itemId := uuid.NewString()
 
 			if tc.create {
-				item, _ := service.Add(context.TODO(), tc.userId, tc.message)
+				item, _ := service.Add(context.TODO(), tc.userId, Content{Message: tc.message})
 				itemId = item.Id
 			}
 
@@ -252,20 +974,20 @@ func TestList(t *testing.T) {
 
 	b := bus.NewFake()
 	repo := NewInMemoryExampleRepository()
-	service := Service{Store: repo, Bus: b}
+	service := Service{Store: repo, Bus: b, Quota: users.NoQuota()}
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
 			// Create the expected items
 			for i := range tc.numItems {
-				_, err := service.Add(context.TODO(), tc.userId, fmt.Sprintf("Item number %d", i+1))
+				_, err := service.Add(context.TODO(), tc.userId, Content{Message: fmt.Sprintf("Item number %d", i+1)})
 
 				if err != nil {
 					t.Errorf("Got unexpected error %s when adding item", err.Error())
 				}
 			}
 
-			retrievedItems, err := service.List(context.TODO(), tc.userId, tc.limit, tc.page)
+			retrievedItems, err := service.List(context.TODO(), tc.userId, nil, store.Pagination{Limit: tc.limit, Page: tc.page})
 
 			var errMessage string
 			if err != nil {
@@ -276,8 +998,394 @@ func TestList(t *testing.T) {
 				t.Errorf("Got unexpected error %s, expecting %s", errMessage, tc.errMessage)
 			}
 
-			if len(retrievedItems) != tc.numItems {
-				t.Errorf("Found %d items, expected %d", len(retrievedItems), tc.numItems)
+			if len(retrievedItems.Items) != tc.numItems {
+				t.Errorf("Found %d items, expected %d", len(retrievedItems.Items), tc.numItems)
+			}
+		})
+	}
+}
+
+func TestListCursor(t *testing.T) {
+	// Given
+	userId := uuid.NewString()
+	service := Service{Store: NewInMemoryExampleRepository(), Bus: bus.NewFake(), Quota: users.NoQuota()}
+
+	for i := range 5 {
+		service.Add(context.TODO(), userId, Content{Message: fmt.Sprintf("Item number %d", i+1)})
+	}
+
+	// When
+	first, _ := service.List(context.TODO(), userId, nil, store.Pagination{Limit: 2, Page: 1})
+	second, _ := service.List(context.TODO(), userId, nil, store.Pagination{Limit: 2, Cursor: first.Next})
+
+	back, _ := service.List(context.TODO(), userId, nil, store.Pagination{Limit: 2, Cursor: second.Prev})
+
+	// Adding an example while paging doesn't repeat items on later pages
+	service.Add(context.TODO(), userId, Content{Message: "Late item"})
+	third, _ := service.List(context.TODO(), userId, nil, store.Pagination{Limit: 2, Cursor: second.Next})
+
+	// Then
+	if first.Prev != nil || first.Next == nil {
+		t.Errorf("expected the first page to only have a next cursor")
+	}
+
+	if second.Prev == nil || second.Next == nil {
+		t.Errorf("expected the second page to have both cursors")
+	}
+
+	seen := make(map[string]bool)
+	for _, page := range [][]example.Example{first.Items, second.Items} {
+		for _, item := range page {
+			if seen[item.Id] {
+				t.Errorf("item %s returned on more than one page", item.Id)
+			}
+			seen[item.Id] = true
+		}
+	}
+
+	for _, item := range third.Items {
+		if seen[item.Id] {
+			t.Errorf("item %s from an earlier page returned again", item.Id)
+		}
+	}
+
+	if len(back.Items) != 2 || back.Items[0].Id != first.Items[0].Id || back.Items[1].Id != first.Items[1].Id {
+		t.Errorf("expected the previous page to be the first page")
+	}
+
+	if back.Prev != nil {
+		t.Errorf("expected no cursor before the first page")
+	}
+}
+
+func TestListFilterAndSort(t *testing.T) {
+	// Given
+	userId := uuid.NewString()
+	service := Service{Store: NewInMemoryExampleRepository(), Bus: bus.NewFake(), Quota: users.NoQuota()}
+
+	for _, msg := range []string{"Buy milk", "Walk the dog", "Buy dog food", "Call mum"} {
+		service.Add(context.TODO(), userId, Content{Message: msg})
+	}
+
+	sort := store.Sort{Field: "message", Desc: true}
+	filters := []store.Filter{{Field: "message", Op: store.Search, Value: "buy"}}
+
+	// When
+	first, err := service.List(context.TODO(), userId, filters, store.Pagination{Limit: 1, Page: 1, Sort: sort})
+	second, _ := service.List(context.TODO(), userId, filters, store.Pagination{Limit: 1, Cursor: first.Next, Sort: sort})
+	_, mismatchErr := service.List(context.TODO(), userId, filters, store.Pagination{Limit: 1, Cursor: first.Next})
+
+	// Then
+	if err != nil {
+		t.Fatalf("unexpected error %s", err.Error())
+	}
+
+	if len(first.Items) != 1 || first.Items[0].Message != "Buy milk" {
+		t.Errorf("expected Buy milk first, got %+v", first.Items)
+	}
+
+	if len(second.Items) != 1 || second.Items[0].Message != "Buy dog food" || second.HasMore() {
+		t.Errorf("expected only Buy dog food on the last page, got %+v", second.Items)
+	}
+
+	if !errors.Is(mismatchErr, store.CursorSortMismatchError) {
+		t.Errorf("expected %v, got %v", store.CursorSortMismatchError, mismatchErr)
+	}
+}
+
+func TestListSortLongMessages(t *testing.T) {
+	// Given
+	userId := uuid.NewString()
+	service := Service{Store: NewInMemoryExampleRepository(), Bus: bus.NewFake(), Quota: users.NoQuota()}
+
+	// Messages are sorted by their first characters, these only differ after them
+	prefix := strings.Repeat("語", messageSortLength)
+	var ids []string
+	for _, suffix := range []string{"b", "a", "c"} {
+		item, _ := service.Add(context.TODO(), userId, Content{Message: prefix + suffix})
+		ids = append(ids, item.Id)
+	}
+	slices.Sort(ids)
+
+	sort := store.Sort{Field: "message"}
+
+	// When
+	var listed []string
+	page, err := service.List(context.TODO(), userId, nil, store.Pagination{Limit: 1, Page: 1, Sort: sort})
+	for err == nil {
+		for _, item := range page.Items {
+			listed = append(listed, item.Id)
+		}
+
+		if !page.HasMore() {
+			break
+		}
+
+		page, err = service.List(context.TODO(), userId, nil, store.Pagination{Limit: 1, Cursor: page.Next, Sort: sort})
+	}
+
+	// Then
+	if err != nil {
+		t.Fatalf("unexpected error %s", err.Error())
+	}
+
+	if !slices.Equal(listed, ids) {
+		t.Errorf("expected messages that start the same in id order %v, got %v", ids, listed)
+	}
+}
+
+func TestExampleRevert(t *testing.T) {
+	tests := []struct {
+		name            string
+		owner           bool
+		revision        int
+		expectedMessage string
+		errMessage      string
+	}{
+		{
+			name:            "PassingCase",
+			owner:           true,
+			revision:        1,
+			expectedMessage: "first",
+		},
+		{
+			name:       "FailingCase-RevisionNotFound",
+			owner:      true,
+			revision:   9,
+			errMessage: "revision not found",
+		},
+		{
+			name:       "FailingCase-NotOwner",
+			owner:      false,
+			revision:   1,
+			errMessage: "item not found",
+		},
+	}
+
+	service := Service{Store: NewInMemoryExampleRepository(), Bus: bus.NewFake(), Quota: users.NoQuota()}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			userId := uuid.NewString()
+			item, _ := service.Add(context.TODO(), userId, Content{Message: "first"})
+			service.Update(context.TODO(), userId, item.Id, Changes{Message: "second"}, 0)
+
+			requester := userId
+			if !tc.owner {
+				requester = uuid.NewString()
+			}
+
+			// When
+			reverted, err := service.Revert(context.TODO(), requester, item.Id, tc.revision, 0)
+
+			// Then
+			var errMessage string
+			if err != nil {
+				errMessage = err.Error()
+			}
+
+			if errMessage != tc.errMessage {
+				t.Errorf("expected error message %s, got %s", tc.errMessage, errMessage)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if reverted.Message != tc.expectedMessage {
+				t.Errorf("expected message %s, got %s", tc.expectedMessage, reverted.Message)
+			}
+
+			// Reverting writes a revision of its own
+			page, _ := service.ListRevisions(context.TODO(), userId, item.Id, store.Pagination{Limit: 10, Page: 1})
+			if len(page.Items) != 3 {
+				t.Errorf("expected 3 revisions, got %d", len(page.Items))
+			}
+		})
+	}
+}
+
+func TestExampleBatch(t *testing.T) {
+	tests := []struct {
+		name        string
+		atomic      bool
+		invalid     bool
+		staleDelete bool
+		otherOwner  bool
+		errMessages []string
+		listed      int
+	}{
+		{
+			name:        "PassingCase-Atomic",
+			atomic:      true,
+			errMessages: []string{"", "", ""},
+			listed:      2,
+		},
+		{
+			name:        "FailingCase-AtomicValidation",
+			atomic:      true,
+			invalid:     true,
+			errMessages: []string{"operation was not applied because another operation failed", "message length must be at most 4096", "operation was not applied because another operation failed"},
+			listed:      2,
+		},
+		{
+			name:        "FailingCase-AtomicStaleVersion",
+			atomic:      true,
+			staleDelete: true,
+			errMessages: []string{"operation was not applied because another operation failed", "operation was not applied because another operation failed", "item version does not match"},
+			listed:      2,
+		},
+		{
+			name:        "PassingCase-BestEffortStaleVersion",
+			atomic:      false,
+			staleDelete: true,
+			errMessages: []string{"", "", "item version does not match"},
+			listed:      3,
+		},
+		{
+			name:        "FailingCase-OtherUsersExamples",
+			atomic:      false,
+			otherOwner:  true,
+			errMessages: []string{"", "item not found", "item not found"},
+			listed:      1,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			b := bus.NewFake()
+			service := Service{Store: NewInMemoryExampleRepository(), Bus: b, Quota: users.NoQuota()}
+			userId := uuid.NewString()
+
+			owner := userId
+			if tc.otherOwner {
+				owner = uuid.NewString()
+			}
+
+			kept, _ := service.Add(context.TODO(), owner, Content{Message: "kept"})
+			removed, _ := service.Add(context.TODO(), owner, Content{Message: "removed"})
+			b.Messages = nil
+
+			message := "updated"
+			if tc.invalid {
+				message = strings.Repeat("a", example.MaxMessageLength+1)
+			}
+
+			version := removed.Version
+			if tc.staleDelete {
+				version = removed.Version + 5
+			}
+
+			ops := []Operation{
+				{Kind: CreateOperation, Content: Content{Message: "created"}},
+				{Kind: UpdateOperation, Id: kept.Id, Changes: Changes{Message: message}},
+				{Kind: DeleteOperation, Id: removed.Id, Version: version},
+			}
+
+			// When
+			results, err := service.Batch(context.TODO(), userId, ops, tc.atomic)
+
+			// Then
+			if err != nil {
+				t.Errorf("unexpected error %s", err.Error())
+			}
+
+			succeeded := 0
+			for i, result := range results {
+				var errMessage string
+				if result.Err != nil {
+					errMessage = result.Err.Error()
+				} else {
+					succeeded++
+				}
+
+				if errMessage != tc.errMessages[i] {
+					t.Errorf("expected operation %d to fail with %s, got %s", i, tc.errMessages[i], errMessage)
+				}
+			}
+
+			// One event for every operation that was applied
+			if len(b.Messages) != succeeded {
+				t.Errorf("expected %d events, got %d", succeeded, len(b.Messages))
+			}
+
+			page, _ := service.List(context.TODO(), userId, nil, store.Pagination{Limit: 10, Page: 1})
+			if len(page.Items) != tc.listed {
+				t.Errorf("expected %d examples, got %d", tc.listed, len(page.Items))
+			}
+
+			// Another user's examples are left as they were
+			if tc.otherOwner {
+				if item, err := service.Get(context.TODO(), kept.Id); err != nil || item.Message != "kept" {
+					t.Errorf("expected the other user's example to be unchanged, got %+v %v", item, err)
+				}
+
+				if _, err := service.Get(context.TODO(), removed.Id); err != nil {
+					t.Errorf("expected the other user's example not to be deleted, got %s", err.Error())
+				}
+			}
+		})
+	}
+}
+
+func TestExampleBatchQuota(t *testing.T) {
+	tests := []struct {
+		name        string
+		atomic      bool
+		errMessages []string
+		listed      int
+	}{
+		{
+			name:        "PassingCase-BestEffort",
+			atomic:      false,
+			errMessages: []string{"", "storage quota exceeded"},
+			listed:      2,
+		},
+		{
+			name:        "FailingCase-Atomic",
+			atomic:      true,
+			errMessages: []string{"operation was not applied because another operation failed", "storage quota exceeded"},
+			listed:      1,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			service := Service{Store: NewInMemoryExampleRepository(), Bus: bus.NewFake(), Quota: users.Quota{Examples: 2, Bytes: users.Unlimited}}
+			userId := uuid.NewString()
+
+			service.Add(context.TODO(), userId, Content{Message: "existing"})
+
+			ops := []Operation{
+				{Kind: CreateOperation, Content: Content{Message: "fits"}},
+				{Kind: CreateOperation, Content: Content{Message: "doesn't fit"}},
+			}
+
+			// When
+			results, err := service.Batch(context.TODO(), userId, ops, tc.atomic)
+
+			// Then
+			if err != nil {
+				t.Errorf("unexpected error %s", err.Error())
+			}
+
+			for i, result := range results {
+				var errMessage string
+				if result.Err != nil {
+					errMessage = result.Err.Error()
+				}
+
+				if errMessage != tc.errMessages[i] {
+					t.Errorf("expected operation %d to fail with %s, got %s", i, tc.errMessages[i], errMessage)
+				}
+			}
+
+			page, _ := service.List(context.TODO(), userId, nil, store.Pagination{Limit: 10, Page: 1})
+			if len(page.Items) != tc.listed {
+				t.Errorf("expected %d examples, got %d", tc.listed, len(page.Items))
 			}
 		})
 	}
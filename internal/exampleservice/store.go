@@ -4,13 +4,20 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log/slog"
+	"maps"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/jackc/pgx"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/moonmoon1919/go-api-reference/internal/store"
 	"github.com/moonmoon1919/go-api-reference/pkg/example"
+	"github.com/moonmoon1919/go-api-reference/pkg/users"
 	"github.com/valkey-io/valkey-go/valkeyaside"
 )
 
@@ -18,36 +25,152 @@ const (
 	notFoundMsg = "NOT FOUND"
 	cacheErrMsg = "CACHE_ERROR"
 	errKey      = "ERR"
+
+	// Postgres error code for a duplicate key
+	uniqueViolation = "23505"
+
+	// How long an example is cached, by reads and by writes that refresh it
+	cacheTTL = time.Minute
 )
 
 var notFoundError = errors.New("example not found")
-var cacheError = errors.New("error caching example")
+var versionMismatchError = errors.New("example version does not match")
+var unsupportedFilterError = errors.New("example filter is not supported")
+var unsupportedSortError = errors.New("example sort is not supported")
+var revisionNotFoundError = errors.New("example revision not found")
+var rolledBackError = errors.New("example write was rolled back")
+var alreadyExistsError = errors.New("example with this id already exists")
+var quotaExceededError = errors.New("example does not fit in the user's quota")
 
 // MARK: Interface
 type Storer interface {
-	Add(ctx context.Context, item example.Example) (example.Example, error)
+	// A new id is generated unless item has one, an id that is taken, even in the trash, fails
+	// Fails with quotaExceededError when the example doesn't fit in the user's quota, quota
+	// is used unless the user has one of their own
+	Add(ctx context.Context, item example.Example, quota users.Quota) (example.Example, error)
 	Get(ctx context.Context, id string) (example.Example, error)
-	List(ctx context.Context, id string, limit int, page int) ([]example.Example, error)
-	Update(ctx context.Context, item example.Example) (example.Example, error)
-	Delete(ctx context.Context, userId string) error
+	// Examples are listed in id order unless p.Sort says otherwise
+	List(ctx context.Context, id string, filters []store.Filter, p store.Pagination) (store.Page[example.Example], error)
+	// Update only succeeds when item.Version is the version currently stored
+	// Fails with quotaExceededError when the example grows past the user's quota, like Add
+	Update(ctx context.Context, item example.Example, quota users.Quota) (example.Example, error)
+	// Moves an example to the trash, a version of 0 deletes unconditionally
+	Delete(ctx context.Context, id, deletedBy string, version int) error
+	// Takes one of a user's examples back out of the trash, other users' examples are not found
+	// It counts against the quota again, so it has to fit like Add
+	Restore(ctx context.Context, userId, id string, quota users.Quota) (example.Example, error)
+	// Every write records a revision, even Delete and Restore, so there's one for every version
+	// Revisions are listed oldest first
+	ListRevisions(ctx context.Context, id string, p store.Pagination) (store.Page[example.Revision], error)
+	GetRevision(ctx context.Context, id string, revision int) (example.Revision, error)
+	// Applies a user's writes in one transaction, results are in the same order as writes
+	// Creates and updates are checked against userId's quota like Add and Update, in order
+	Batch(ctx context.Context, userId string, writes []Write, atomic bool, quota users.Quota) ([]WriteResult, error)
+	// What a user is storing and the quota it counts against, quota unless they have their own
+	Usage(ctx context.Context, userId string, quota users.Quota) (users.Usage, users.Quota, error)
+}
+
+// MARK: Batches
+type WriteKind int
+
+const (
+	CreateWrite WriteKind = iota
+	UpdateWrite
+	DeleteWrite
+)
+
+/*
+One write in a batch
+
+Updates only succeed when Item.Version is the version currently stored,
+deletes with a Version of 0 are unconditional like Delete
+*/
+type Write struct {
+	Kind      WriteKind
+	Item      example.Example
+	DeletedBy string
+}
+
+/*
+Item is the example as stored, it is empty for deletes and failed writes
+*/
+type WriteResult struct {
+	Item example.Example
+	Err  error
+}
+
+/*
+An atomic batch with a failed write is rolled back, so the writes that
+succeeded didn't happen either
+*/
+func rollBack(results []WriteResult) bool {
+	failed := false
+	for _, r := range results {
+		failed = failed || r.Err != nil
+	}
+
+	if !failed {
+		return false
+	}
+
+	for i := range results {
+		if results[i].Err == nil {
+			results[i] = WriteResult{Err: rolledBackError}
+		}
+	}
+
+	return true
+}
+
+// Examples in the trash are left out of every read
+const notDeleted = "deleted_at IS NULL"
+
+/*
+Whether an example that changes from before to after bytes fits in the quota
+
+An example that doesn't grow always fits, so a user over a lowered quota can
+still edit their examples down
+*/
+func fits(quota users.Quota, usage users.Usage, before, after int) bool {
+	return after <= before || quota.Allows(usage.Grow(after-before))
 }
 
 // MARK: Memory
 type exampleRepository struct {
 	items       map[string]example.Example
 	byUserIndex map[string][]example.Example
+	revisions   map[string][]example.Revision
+	// Quotas an administrator has set
+	quotas map[string]users.Quota
 }
 
 func NewInMemoryExampleRepository() *exampleRepository {
 	return &exampleRepository{
 		items:       make(map[string]example.Example),
 		byUserIndex: make(map[string][]example.Example),
+		revisions:   make(map[string][]example.Revision),
+		quotas:      make(map[string]users.Quota),
 	}
 }
 
-func (e *exampleRepository) Add(ctx context.Context, item example.Example) (example.Example, error) {
+func (e *exampleRepository) Add(ctx context.Context, item example.Example, quota users.Quota) (example.Example, error) {
 	// Pretend to be a DB
-	item.Id = uuid.NewString()
+	if item.Id == "" {
+		item.Id = uuid.NewString()
+	}
+
+	if _, ok := e.items[item.Id]; ok {
+		return example.Nil(), alreadyExistsError
+	}
+
+	usage, quota, _ := e.Usage(ctx, item.UserId, quota)
+	if !quota.Allows(usage.Add(item.Size())) {
+		return example.Nil(), quotaExceededError
+	}
+
+	item.Version = 1
+	item.CreatedAt = time.Now().UTC()
+	item.UpdatedAt = item.CreatedAt
 
 	e.items[item.Id] = item
 
@@ -59,47 +182,371 @@ func (e *exampleRepository) Add(ctx context.Context, item example.Example) (exam
 		e.byUserIndex[item.UserId] = append(e.byUserIndex[item.UserId], item)
 	}
 
+	e.revisions[item.Id] = append(e.revisions[item.Id], example.NewRevision(item))
+
 	return item, nil
 }
 
-func (e *exampleRepository) Update(ctx context.Context, item example.Example) (example.Example, error) {
-	e.items[item.Id] = item
+func (e *exampleRepository) Update(ctx context.Context, item example.Example, quota users.Quota) (example.Example, error) {
+	existing, ok := e.items[item.Id]
+
+	if !ok || existing.Deleted() {
+		return example.Nil(), notFoundError
+	}
+
+	if existing.Version != item.Version {
+		return example.Nil(), versionMismatchError
+	}
+
+	usage, quota, _ := e.Usage(ctx, existing.UserId, quota)
+	if !fits(quota, usage, existing.Size(), item.Size()) {
+		return example.Nil(), quotaExceededError
+	}
+
+	item.Version++
+	item.CreatedAt = existing.CreatedAt
+	item.UpdatedAt = time.Now().UTC()
+	e.put(item)
+
+	e.revisions[item.Id] = append(e.revisions[item.Id], example.NewRevision(item))
 
 	return item, nil
 }
 
+/*
+Replaces a stored example in both indexes
+*/
+func (e *exampleRepository) put(item example.Example) {
+	e.items[item.Id] = item
+
+	for idx, x := range e.byUserIndex[item.UserId] {
+		if x.Id == item.Id {
+			e.byUserIndex[item.UserId][idx] = item
+		}
+	}
+}
+
+func (e *exampleRepository) Usage(ctx context.Context, userId string, quota users.Quota) (users.Usage, users.Quota, error) {
+	var usage users.Usage
+	for _, item := range e.byUserIndex[userId] {
+		if !item.Deleted() {
+			usage = usage.Add(item.Size())
+		}
+	}
+
+	if q, ok := e.quotas[userId]; ok {
+		quota = q
+	}
+
+	return usage, quota, nil
+}
+
 func (e *exampleRepository) Get(ctx context.Context, i string) (example.Example, error) {
-	if item, ok := e.items[i]; !ok {
+	if item, ok := e.items[i]; !ok || item.Deleted() {
 		return example.Nil(), notFoundError
 	} else {
 		return item, nil
 	}
 }
 
-func (e *exampleRepository) List(ctx context.Context, i string, limit int, page int) ([]example.Example, error) {
-	items := e.byUserIndex[i]
+func exampleKey(e example.Example) string {
+	return e.Id
+}
+
+/*
+Approximates Postgres full-text search, every word of the query has to be in
+the message. There's no stemming, so "run" doesn't find "running".
+*/
+func matchesSearch(message string, query string) bool {
+	message = strings.ToLower(message)
+
+	for _, word := range strings.Fields(strings.ToLower(query)) {
+		if !strings.Contains(message, strings.Trim(word, `"`)) {
+			return false
+		}
+	}
 
-	return items, nil
+	return true
 }
 
-func (e *exampleRepository) Delete(ctx context.Context, i string) error {
-	item := e.items[i]
+func matchesFilter(e example.Example, f store.Filter) (bool, error) {
+	switch {
+	case f.Field == "message" && f.Op == store.Contains:
+		return strings.Contains(strings.ToLower(e.Message), strings.ToLower(f.Value)), nil
+	case f.Field == "message" && f.Op == store.Search:
+		return matchesSearch(e.Message, f.Value), nil
+	case f.Field == "created_at":
+		switch f.Op {
+		case store.Gt:
+			return e.CreatedAt.After(f.Time), nil
+		case store.Gte:
+			return !e.CreatedAt.Before(f.Time), nil
+		case store.Lt:
+			return e.CreatedAt.Before(f.Time), nil
+		case store.Lte:
+			return !e.CreatedAt.After(f.Time), nil
+		}
+	}
+
+	return false, unsupportedFilterError
+}
+
+func (e *exampleRepository) List(ctx context.Context, i string, filters []store.Filter, p store.Pagination) (store.Page[example.Example], error) {
+	if _, err := sortColumn(p.Sort); err != nil {
+		return store.Page[example.Example]{}, err
+	}
+
+	var matches []example.Example
+
+	for _, item := range e.byUserIndex[i] {
+		ok := !item.Deleted()
+		for _, f := range filters {
+			matched, err := matchesFilter(item, f)
+			if err != nil {
+				return store.Page[example.Example]{}, err
+			}
+
+			ok = ok && matched
+		}
 
-	// Delete from user index
-	b := e.byUserIndex[item.UserId][:0]
-	for _, x := range e.byUserIndex[item.UserId] {
-		if x.Id != i {
-			b = append(b, x)
+		if ok {
+			matches = append(matches, item)
 		}
 	}
-	e.byUserIndex[item.UserId] = b
 
-	delete(e.items, i)
+	return store.PageSortedSlice(matches, exampleKey, sortValue(p.Sort), p), nil
+}
+
+func (e *exampleRepository) Delete(ctx context.Context, i, deletedBy string, version int) error {
+	item, ok := e.items[i]
+
+	if !ok || item.Deleted() {
+		return notFoundError
+	}
+
+	if version != 0 && item.Version != version {
+		return versionMismatchError
+	}
+
+	now := time.Now().UTC()
+	item.DeletedAt = &now
+	item.DeletedBy = deletedBy
+	item.Version++
+	item.UpdatedAt = now
+	e.put(item)
+
+	e.revisions[item.Id] = append(e.revisions[item.Id], example.NewRevision(item))
 
 	return nil
 }
 
+func (e *exampleRepository) Restore(ctx context.Context, userId, i string, quota users.Quota) (example.Example, error) {
+	item, ok := e.items[i]
+
+	if !ok || !item.Deleted() || item.UserId != userId {
+		return example.Nil(), notFoundError
+	}
+
+	usage, quota, _ := e.Usage(ctx, userId, quota)
+	if !quota.Allows(usage.Add(item.Size())) {
+		return example.Nil(), quotaExceededError
+	}
+
+	item.DeletedAt = nil
+	item.DeletedBy = ""
+	item.Version++
+	item.UpdatedAt = time.Now().UTC()
+	e.put(item)
+
+	e.revisions[item.Id] = append(e.revisions[item.Id], example.NewRevision(item))
+
+	return item, nil
+}
+
+/*
+A deep copy, atomic batches write to one and keep it only if every write succeeds
+*/
+func (e *exampleRepository) clone() *exampleRepository {
+	c := NewInMemoryExampleRepository()
+	maps.Copy(c.items, e.items)
+
+	for k, v := range e.byUserIndex {
+		c.byUserIndex[k] = slices.Clone(v)
+	}
+
+	for k, v := range e.revisions {
+		c.revisions[k] = slices.Clone(v)
+	}
+
+	maps.Copy(c.quotas, e.quotas)
+
+	return c
+}
+
+func (e *exampleRepository) Batch(ctx context.Context, userId string, writes []Write, atomic bool, quota users.Quota) ([]WriteResult, error) {
+	target := e
+	if atomic {
+		target = e.clone()
+	}
+
+	results := make([]WriteResult, len(writes))
+	for i, w := range writes {
+		switch w.Kind {
+		case CreateWrite:
+			results[i].Item, results[i].Err = target.Add(ctx, w.Item, quota)
+		case UpdateWrite:
+			results[i].Item, results[i].Err = target.Update(ctx, w.Item, quota)
+		case DeleteWrite:
+			results[i].Err = target.Delete(ctx, w.Item.Id, w.DeletedBy, w.Item.Version)
+		}
+	}
+
+	if atomic && !rollBack(results) {
+		*e = *target
+	}
+
+	return results, nil
+}
+
+/*
+Zero padded so revisions sort by number
+*/
+func revisionKey(r example.Revision) string {
+	return fmt.Sprintf("%010d", r.Revision)
+}
+
+func (e *exampleRepository) ListRevisions(ctx context.Context, i string, p store.Pagination) (store.Page[example.Revision], error) {
+	return store.PageSlice(e.revisions[i], revisionKey, p), nil
+}
+
+func (e *exampleRepository) GetRevision(ctx context.Context, i string, revision int) (example.Revision, error) {
+	for _, r := range e.revisions[i] {
+		if r.Revision == revision {
+			return r, nil
+		}
+	}
+
+	return example.Revision{}, revisionNotFoundError
+}
+
+// MARK: Query
+var exampleIdColumn = store.Column{Name: "id", Type: "uuid"}
+
+/*
+Messages are sorted by their first characters, a whole message can be too
+big for a btree entry. Messages that start the same are ordered by id.
+*/
+const messageSortLength = 256
+
+// Every sort has an index on (uid, column, id) in sql/create_examples.sql
+var exampleSortColumns = map[string]store.Column{
+	"created_at": {Name: "created_at", Type: "timestamptz"},
+	"updated_at": {Name: "updated_at", Type: "timestamptz"},
+	"message":    {Name: fmt.Sprintf("left(message, %d)", messageSortLength), Type: "text"},
+}
+
+var comparisons = map[store.Operator]string{
+	store.Gt:  ">",
+	store.Gte: ">=",
+	store.Lt:  "<",
+	store.Lte: "<=",
+}
+
+func sortColumn(sort store.Sort) (store.Column, error) {
+	if sort.Field == "" {
+		return exampleIdColumn, nil
+	}
+
+	column, ok := exampleSortColumns[sort.Field]
+	if !ok {
+		return store.Column{}, unsupportedSortError
+	}
+
+	return column, nil
+}
+
+/*
+Returns an example's value for the sort, as it is kept in cursors
+*/
+func sortValue(sort store.Sort) func(example.Example) string {
+	return func(e example.Example) string {
+		switch sort.Field {
+		case "created_at":
+			return store.TimeValue(e.CreatedAt)
+		case "updated_at":
+			return store.TimeValue(e.UpdatedAt)
+		case "message":
+			// Characters like Postgres' left, not bytes
+			if runes := []rune(e.Message); len(runes) > messageSortLength {
+				return string(runes[:messageSortLength])
+			}
+
+			return e.Message
+		default:
+			return e.Id
+		}
+	}
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+/*
+Builds the conditions for filters, each one starts with AND
+
+Only fields and operators listed here end up in SQL, values are always
+passed as parameters numbered from next
+*/
+func exampleConditions(filters []store.Filter, next int) (string, []any, error) {
+	var b strings.Builder
+	var args []any
+
+	for _, f := range filters {
+		n := next + len(args)
+
+		switch {
+		case f.Field == "message" && f.Op == store.Contains:
+			fmt.Fprintf(&b, " AND message ILIKE $%d", n)
+			args = append(args, "%"+likeEscaper.Replace(f.Value)+"%")
+		case f.Field == "message" && f.Op == store.Search:
+			// websearch_to_tsquery never fails on user input, unlike to_tsquery
+			fmt.Fprintf(&b, " AND search @@ websearch_to_tsquery('english', $%d)", n)
+			args = append(args, f.Value)
+		case f.Field == "created_at" && comparisons[f.Op] != "":
+			fmt.Fprintf(&b, " AND created_at %s $%d", comparisons[f.Op], n)
+			args = append(args, f.Time)
+		default:
+			return "", nil, unsupportedFilterError
+		}
+	}
+
+	return b.String(), args, nil
+}
+
 // MARK: SQL
+const exampleColumns = "id, title, message, tags, status, uid, version, created_at, updated_at"
+
+func scanExample(row pgx.Row, e *example.Example) error {
+	return row.Scan(&e.Id, &e.Title, &e.Message, &e.Tags, &e.Status, &e.UserId, &e.Version, &e.CreatedAt, &e.UpdatedAt)
+}
+
+const revisionColumns = "example_id, revision, title, message, tags, status, created_at"
+
+func scanRevision(row pgx.Row, r *example.Revision) error {
+	return row.Scan(&r.ExampleId, &r.Revision, &r.Title, &r.Message, &r.Tags, &r.Status, &r.CreatedAt)
+}
+
+/*
+Wraps an INSERT or UPDATE of an example so the version it writes is copied
+to example_revisions in the same statement
+
+The write can't succeed without its revision, or the other way around
+*/
+func withRevision(write string) string {
+	return "WITH written AS (" + write + " RETURNING " + exampleColumns + "), " +
+		"revision AS (INSERT INTO example_revisions (" + revisionColumns + ") SELECT id, version, title, message, tags, status, updated_at FROM written) " +
+		"SELECT " + exampleColumns + " FROM written"
+}
+
 func serializer(val *example.Example) (string, error) {
 	b, err := json.Marshal(val)
 	return string(b), err
@@ -114,6 +561,13 @@ func deserializer(s string) (*example.Example, error) {
 	return &result, nil
 }
 
+/*
+A pool or a transaction
+*/
+type querier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
 type exampleSQLRepository struct {
 	pool        *pgxpool.Pool
 	cacheClient valkeyaside.TypedCacheAsideClient[example.Example]
@@ -131,7 +585,7 @@ func NewSQLRepository(pool *pgxpool.Pool, cacheClient valkeyaside.CacheAsideClie
 func (e *exampleSQLRepository) refreshCache(ctx context.Context, item *example.Example) error {
 	// Force a cache reset by deleting the existing value from the cache
 	// This could result in a race condition, in which case the requester
-	// Will need to wait until the TTL expires on the record (60s) and request again
+	// Will need to wait until the TTL expires on the record (cacheTTL) and request again
 	// This is required because valkeyaside has a local in-memory cache to reduce the load on
 	// the remote instance
 	err := e.cacheClient.Del(ctx, item.Id)
@@ -144,10 +598,10 @@ func (e *exampleSQLRepository) refreshCache(ctx context.Context, item *example.E
 		return err
 	}
 
-	// Update the cache
+	// Update the cache, the entry expires like one cached by a read
 	err = e.cacheClient.Client().Client().Do(
 		ctx,
-		e.cacheClient.Client().Client().B().Set().Key(item.Id).Value(cacheVal).Build(),
+		e.cacheClient.Client().Client().B().Set().Key(item.Id).Value(cacheVal).Ex(cacheTTL).Build(),
 	).Error()
 
 	if err != nil {
@@ -157,109 +611,325 @@ func (e *exampleSQLRepository) refreshCache(ctx context.Context, item *example.E
 	return nil
 }
 
-func (e *exampleSQLRepository) Add(ctx context.Context, item example.Example) (example.Example, error) {
+/*
+Locks a user's usage until tx ends, so their writes are checked against the
+quota one at a time, and returns it with the quota it counts against
+
+The trigger on examples keeps the usage up to date as rows are written
+*/
+func reserve(ctx context.Context, tx pgx.Tx, userId string, quota users.Quota) (users.Usage, users.Quota, error) {
+	_, err := tx.Exec(ctx, "INSERT INTO example_usage (uid) VALUES ($1) ON CONFLICT (uid) DO NOTHING", userId)
+	if err != nil {
+		return users.Usage{}, users.Quota{}, err
+	}
+
+	return scanUsage(tx.QueryRow(
+		ctx,
+		"SELECT u.examples, u.bytes, q.examples, q.bytes FROM example_usage u LEFT JOIN quotas q ON q.uid = u.uid WHERE u.uid=$1 FOR UPDATE OF u",
+		userId,
+	), quota)
+}
+
+/*
+Scans usage followed by the user's own quota, which is NULL when quota applies
+*/
+func scanUsage(row pgx.Row, quota users.Quota) (users.Usage, users.Quota, error) {
+	var usage users.Usage
+	var examples, bytes *int
+
+	if err := row.Scan(&usage.Examples, &usage.Bytes, &examples, &bytes); err != nil {
+		return users.Usage{}, users.Quota{}, err
+	}
+
+	if examples != nil && bytes != nil {
+		quota = users.Quota{Examples: *examples, Bytes: *bytes}
+	}
+
+	return usage, quota, nil
+}
+
+func (e *exampleSQLRepository) Usage(ctx context.Context, userId string, quota users.Quota) (users.Usage, users.Quota, error) {
+	return scanUsage(e.pool.QueryRow(
+		ctx,
+		"SELECT COALESCE(u.examples, 0), COALESCE(u.bytes, 0), q.examples, q.bytes FROM (SELECT $1::uuid AS uid) AS me "+
+			"LEFT JOIN example_usage u ON u.uid = me.uid LEFT JOIN quotas q ON q.uid = me.uid",
+		userId,
+	), quota)
+}
+
+/*
+Adds an example if it fits in the user's quota
+
+The user's usage is locked while the example is written, so two requests
+racing for the last of a quota can't both succeed
+*/
+func (e *exampleSQLRepository) Add(ctx context.Context, item example.Example, quota users.Quota) (example.Example, error) {
+	// Postgres generates the id unless the client chose one
+	var id *string
+	if item.Id != "" {
+		id = &item.Id
+	}
+
+	tx, err := e.pool.Begin(ctx)
+	if err != nil {
+		return example.Nil(), err
+	}
+	defer tx.Rollback(ctx)
+
+	usage, quota, err := reserve(ctx, tx, item.UserId, quota)
+	if err != nil {
+		return example.Nil(), err
+	}
+
+	if !quota.Allows(usage.Add(item.Size())) {
+		return example.Nil(), quotaExceededError
+	}
+
 	var result example.Example
-	err := e.pool.QueryRow(ctx, "INSERT INTO examples (message, uid) VALUES ($1, $2) RETURNING *", item.Message, item.UserId).Scan(&result.Id, &result.Message, &result.UserId)
+	err = scanExample(tx.QueryRow(
+		ctx,
+		withRevision("INSERT INTO examples (id, title, message, tags, status, uid) VALUES (COALESCE($6::uuid, gen_random_uuid()), $1, $2, $3, $4, $5)"),
+		item.Title,
+		item.Message,
+		item.Tags,
+		item.Status,
+		item.UserId,
+		id,
+	), &result)
 
 	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+			return example.Nil(), alreadyExistsError
+		}
+
+		return example.Nil(), err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
 		return example.Nil(), err
 	}
 
 	return result, nil
 }
 
-func (e *exampleSQLRepository) Update(ctx context.Context, item example.Example) (example.Example, error) {
+/*
+Updates an example if nobody else has updated it since it was read, and it
+still fits in the user's quota
+
+The update is a compare-and-swap on the version column, so two writers racing
+on the same version can't both succeed. The user's usage is locked while the
+size is checked, like Add.
+*/
+func (e *exampleSQLRepository) Update(ctx context.Context, item example.Example, quota users.Quota) (example.Example, error) {
+	tx, err := e.pool.Begin(ctx)
+	if err != nil {
+		return example.Nil(), err
+	}
+	defer tx.Rollback(ctx)
+
+	usage, quota, err := reserve(ctx, tx, item.UserId, quota)
+	if err != nil {
+		return example.Nil(), err
+	}
+
+	var size int
+	err = tx.QueryRow(ctx, "SELECT example_size(title, message, tags) FROM examples WHERE id=$1 AND version=$2 AND "+notDeleted, item.Id, item.Version).Scan(&size)
+	if err == nil && !fits(quota, usage, size, item.Size()) {
+		return example.Nil(), quotaExceededError
+	}
+
 	var result example.Example
-	err := e.pool.QueryRow(ctx, "UPDATE examples SET message=$1 WHERE id=$2 RETURNING *", item.Message, item.Id).Scan(&result.Id, &result.Message, &result.UserId)
+	if err == nil {
+		err = scanExample(tx.QueryRow(
+			ctx,
+			withRevision(updateExample),
+			item.Title,
+			item.Message,
+			item.Tags,
+			item.Status,
+			item.Id,
+			item.Version,
+		), &result)
+	}
 
 	if err != nil {
 		switch {
 		case errors.Is(err, pgx.ErrNoRows):
-			return example.Nil(), notFoundError
+			return example.Nil(), e.writeMissError(ctx, tx, item.Id)
 		default:
 			return example.Nil(), err
 		}
 	}
 
+	if err := tx.Commit(ctx); err != nil {
+		return example.Nil(), err
+	}
+
+	// The update is already committed, a stale cache entry expires with its TTL
+	// so we don't fail the request when the cache is unavailable
 	err = e.refreshCache(ctx, &result)
 
 	if err != nil {
 		slog.LogAttrs(
 			ctx,
-			slog.LevelError,
+			slog.LevelWarn,
 			cacheErrMsg,
 			slog.String(errKey, err.Error()),
 		)
-		return example.Nil(), cacheError
 	}
 
 	return result, nil
 }
 
-func (e *exampleSQLRepository) Get(ctx context.Context, i string) (example.Example, error) {
-	result, err := e.cacheClient.Get(ctx, time.Minute, i, func(ctx context.Context, key string) (val *example.Example, err error) {
-		var result example.Example
-		err = e.pool.QueryRow(ctx, "SELECT * FROM examples WHERE id=$1", i).Scan(&result.Id, &result.Message, &result.UserId)
+/*
+Works out why a conditional write didn't touch any rows, q is the transaction
+the write ran in when there is one
 
-		if err != nil {
-			slog.LogAttrs(
-				ctx,
-				slog.LevelError,
-				notFoundMsg,
-				slog.String(errKey, err.Error()),
-			)
-			return nil, notFoundError
-		}
+Either the example is gone or its version moved on
+*/
+func (e *exampleSQLRepository) writeMissError(ctx context.Context, q querier, i string) error {
+	var exists bool
+	err := q.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM examples WHERE id=$1 AND "+notDeleted+")", i).Scan(&exists)
+
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return notFoundError
+	}
+
+	return versionMismatchError
+}
+
+func (e *exampleSQLRepository) load(ctx context.Context, i string) (*example.Example, error) {
+	var result example.Example
+	err := scanExample(e.pool.QueryRow(ctx, "SELECT "+exampleColumns+" FROM examples WHERE id=$1 AND "+notDeleted, i), &result)
+
+	if err != nil {
+		slog.LogAttrs(
+			ctx,
+			slog.LevelError,
+			notFoundMsg,
+			slog.String(errKey, err.Error()),
+		)
+		return nil, notFoundError
+	}
 
-		return &result, nil
+	return &result, nil
+}
+
+func (e *exampleSQLRepository) Get(ctx context.Context, i string) (example.Example, error) {
+	result, err := e.cacheClient.Get(ctx, cacheTTL, i, func(ctx context.Context, key string) (val *example.Example, err error) {
+		return e.load(ctx, key)
 	})
 
 	if err != nil {
-		return example.Nil(), err
+		if errors.Is(err, notFoundError) {
+			return example.Nil(), err
+		}
+
+		// The cache is unavailable, read straight from the database
+		slog.LogAttrs(
+			ctx,
+			slog.LevelWarn,
+			cacheErrMsg,
+			slog.String(errKey, err.Error()),
+		)
+
+		result, err = e.load(ctx, i)
+		if err != nil {
+			return example.Nil(), err
+		}
 	}
 
 	return *result, nil
 }
 
-func (e *exampleSQLRepository) List(ctx context.Context, userId string, limit int, page int) ([]example.Example, error) {
-	offset := (page - 1) * limit
+/*
+Lists examples for a user matching filters, in id order or by p.Sort
 
-	res, err := e.pool.Query(ctx, "SELECT * FROM examples WHERE uid=$1 ORDER BY id LIMIT $2 OFFSET $3", userId, limit, offset)
+Cursors seek straight to the next row with an index on the sort, so deep
+pages are as fast as the first and rows don't shift between pages when
+examples are added or removed
+*/
+func (e *exampleSQLRepository) List(ctx context.Context, userId string, filters []store.Filter, p store.Pagination) (store.Page[example.Example], error) {
+	column, err := sortColumn(p.Sort)
+	if err != nil {
+		return store.Page[example.Example]{}, err
+	}
 
+	conditions, args, err := exampleConditions(filters, 2)
 	if err != nil {
-		return nil, err
+		return store.Page[example.Example]{}, err
+	}
+
+	where := "uid=$1 AND " + notDeleted + conditions
+	args = append([]any{userId}, args...)
+
+	clause, pageArgs := p.SortedClause(column, exampleIdColumn, len(args)+1)
+
+	res, err := e.pool.Query(ctx, "SELECT "+exampleColumns+" FROM examples WHERE "+where+clause, append(args, pageArgs...)...)
+
+	if err != nil {
+		return store.Page[example.Example]{}, err
 	}
 
 	var results []example.Example
 	for res.Next() {
 		var e example.Example
-		res.Scan(&e.Id, &e.Message, &e.UserId)
+		scanExample(res, &e)
 		results = append(results, e)
 	}
 
 	resErr := res.Err()
 	if resErr != nil {
-		return nil, resErr
+		return store.Page[example.Example]{}, resErr
 	}
 
-	return results, nil
+	page := store.NewSortedPage(results, exampleKey, sortValue(p.Sort), p)
+
+	page.Total, err = store.CountRows(ctx, e.pool, p.Count, "examples", where, args...)
+	if err != nil {
+		return store.Page[example.Example]{}, err
+	}
+
+	return page, nil
 }
 
-func (e *exampleSQLRepository) Delete(ctx context.Context, i string) error {
-	var id string
-	err := e.pool.QueryRow(ctx, "DELETE FROM examples WHERE id=$1 RETURNING id", i).Scan(&id)
+/*
+Moves an example to the trash
+
+The row is kept so the example can be restored, every read skips it until then
+*/
+const (
+	updateExample = "UPDATE examples SET title=$1, message=$2, tags=$3, status=$4, version=version+1, updated_at=now() WHERE id=$5 AND version=$6 AND " + notDeleted
+	deleteExample = "UPDATE examples SET deleted_at=now(), deleted_by=$3, version=version+1, updated_at=now() WHERE id=$1 AND " + notDeleted + " AND ($2::integer = 0 OR version=$2::integer)"
+)
+
+func (e *exampleSQLRepository) Delete(ctx context.Context, i, deletedBy string, version int) error {
+	// The new version is recorded like any other, its content is unchanged
+	var deleted example.Example
+	err := scanExample(e.pool.QueryRow(
+		ctx,
+		withRevision(deleteExample),
+		i,
+		version,
+		deletedBy,
+	), &deleted)
 
 	if err != nil {
 		switch {
 		case errors.Is(err, pgx.ErrNoRows):
-			return notFoundError
+			return e.writeMissError(ctx, e.pool, i)
 		default:
 			return err
 		}
 	}
 
 	// Delete from cache
-	err = e.cacheClient.Del(ctx, id)
+	err = e.cacheClient.Del(ctx, deleted.Id)
 
 	if err != nil {
 		return nil
@@ -267,3 +937,314 @@ func (e *exampleSQLRepository) Delete(ctx context.Context, i string) error {
 
 	return nil
 }
+
+/*
+Takes an example out of the trash if it fits in the user's quota again
+
+The user's usage is locked while it's checked, like Add
+*/
+func (e *exampleSQLRepository) Restore(ctx context.Context, userId, i string, quota users.Quota) (example.Example, error) {
+	tx, err := e.pool.Begin(ctx)
+	if err != nil {
+		return example.Nil(), err
+	}
+	defer tx.Rollback(ctx)
+
+	usage, quota, err := reserve(ctx, tx, userId, quota)
+	if err != nil {
+		return example.Nil(), err
+	}
+
+	var size int
+	err = tx.QueryRow(ctx, "SELECT example_size(title, message, tags) FROM examples WHERE id=$1 AND uid=$2 AND deleted_at IS NOT NULL", i, userId).Scan(&size)
+	if err == nil && !quota.Allows(usage.Add(size)) {
+		return example.Nil(), quotaExceededError
+	}
+
+	var result example.Example
+	if err == nil {
+		err = scanExample(tx.QueryRow(
+			ctx,
+			withRevision("UPDATE examples SET deleted_at=NULL, deleted_by=NULL, version=version+1, updated_at=now() WHERE id=$1 AND uid=$2 AND deleted_at IS NOT NULL"),
+			i,
+			userId,
+		), &result)
+	}
+
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return example.Nil(), notFoundError
+		default:
+			return example.Nil(), err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return example.Nil(), err
+	}
+
+	// Drop anything cached while the example was in the trash
+	if err := e.cacheClient.Del(ctx, i); err != nil {
+		slog.LogAttrs(
+			ctx,
+			slog.LevelWarn,
+			cacheErrMsg,
+			slog.String(errKey, err.Error()),
+		)
+	}
+
+	return result, nil
+}
+
+/*
+Lists the revisions of an example, oldest first
+*/
+func (e *exampleSQLRepository) ListRevisions(ctx context.Context, i string, p store.Pagination) (store.Page[example.Revision], error) {
+	clause, args := p.Clause("revision", 2)
+
+	res, err := e.pool.Query(ctx, "SELECT "+revisionColumns+" FROM example_revisions WHERE example_id=$1"+clause, append([]any{i}, args...)...)
+	if err != nil {
+		return store.Page[example.Revision]{}, err
+	}
+
+	var results []example.Revision
+	for res.Next() {
+		var r example.Revision
+		scanRevision(res, &r)
+		results = append(results, r)
+	}
+
+	resErr := res.Err()
+	if resErr != nil {
+		return store.Page[example.Revision]{}, resErr
+	}
+
+	page := store.NewPage(results, revisionKey, p)
+
+	page.Total, err = store.CountRows(ctx, e.pool, p.Count, "example_revisions", "example_id=$1", i)
+	if err != nil {
+		return store.Page[example.Revision]{}, err
+	}
+
+	return page, nil
+}
+
+func (e *exampleSQLRepository) GetRevision(ctx context.Context, i string, revision int) (example.Revision, error) {
+	var result example.Revision
+	err := scanRevision(e.pool.QueryRow(ctx, "SELECT "+revisionColumns+" FROM example_revisions WHERE example_id=$1 AND revision=$2", i, revision), &result)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return example.Revision{}, revisionNotFoundError
+		default:
+			return example.Revision{}, err
+		}
+	}
+
+	return result, nil
+}
+
+/*
+Reads the size of every example a batch updates, keyed by id
+*/
+func currentSizes(ctx context.Context, tx pgx.Tx, writes []Write) (map[string]int, error) {
+	var ids []string
+	for _, w := range writes {
+		if w.Kind == UpdateWrite {
+			ids = append(ids, w.Item.Id)
+		}
+	}
+
+	sizes := map[string]int{}
+	if len(ids) == 0 {
+		return sizes, nil
+	}
+
+	rows, err := tx.Query(ctx, "SELECT id::text, example_size(title, message, tags) FROM examples WHERE id = ANY($1::uuid[]) AND "+notDeleted, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		var size int
+		if err := rows.Scan(&id, &size); err != nil {
+			return nil, err
+		}
+		sizes[id] = size
+	}
+
+	return sizes, rows.Err()
+}
+
+/*
+Applies a batch of writes in one transaction
+
+Creates are copied in with COPY, ids and timestamps are set here so nothing
+has to be read back. Updates and deletes are sent together as a pgx batch,
+a write that misses its row is reported without aborting the transaction.
+An atomic batch is rolled back when any write fails, otherwise the writes that
+succeeded are committed. A database error fails the whole batch either way.
+*/
+func (e *exampleSQLRepository) Batch(ctx context.Context, userId string, writes []Write, atomic bool, quota users.Quota) ([]WriteResult, error) {
+	tx, err := e.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	results := make([]WriteResult, len(writes))
+
+	// Creates and updates are checked against the user's quota in order
+	var usage users.Usage
+	sizes := map[string]int{}
+	if slices.ContainsFunc(writes, func(w Write) bool { return w.Kind != DeleteWrite }) {
+		usage, quota, err = reserve(ctx, tx, userId, quota)
+		if err != nil {
+			return nil, err
+		}
+
+		sizes, err = currentSizes(ctx, tx, writes)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now().UTC()
+	var exampleRows, revisionRows [][]any
+	for i, w := range writes {
+		if w.Kind == UpdateWrite {
+			// An update that misses its row is reported when it's sent
+			before, ok := sizes[w.Item.Id]
+			if !ok {
+				continue
+			}
+
+			if !fits(quota, usage, before, w.Item.Size()) {
+				results[i].Err = quotaExceededError
+				continue
+			}
+
+			usage = usage.Grow(w.Item.Size() - before)
+			sizes[w.Item.Id] = w.Item.Size()
+			continue
+		}
+
+		if w.Kind != CreateWrite {
+			continue
+		}
+
+		next := usage.Add(w.Item.Size())
+		if !quota.Allows(next) {
+			results[i].Err = quotaExceededError
+			continue
+		}
+		usage = next
+
+		item := w.Item
+		item.Id = uuid.NewString()
+		item.Version = 1
+		item.CreatedAt = now
+		item.UpdatedAt = now
+		results[i].Item = item
+
+		exampleRows = append(exampleRows, []any{item.Id, item.Title, item.Message, item.Tags, item.Status, item.UserId, item.Version, item.CreatedAt, item.UpdatedAt})
+		revisionRows = append(revisionRows, []any{item.Id, item.Version, item.Title, item.Message, item.Tags, item.Status, item.UpdatedAt})
+	}
+
+	if len(exampleRows) > 0 {
+		_, err = tx.CopyFrom(ctx, pgx.Identifier{"examples"}, strings.Split(exampleColumns, ", "), pgx.CopyFromRows(exampleRows))
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = tx.CopyFrom(ctx, pgx.Identifier{"example_revisions"}, strings.Split(revisionColumns, ", "), pgx.CopyFromRows(revisionRows))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Updates and deletes
+	batch := &pgx.Batch{}
+	for i, w := range writes {
+		if results[i].Err != nil {
+			continue
+		}
+
+		switch w.Kind {
+		case UpdateWrite:
+			batch.Queue(withRevision(updateExample), w.Item.Title, w.Item.Message, w.Item.Tags, w.Item.Status, w.Item.Id, w.Item.Version)
+		case DeleteWrite:
+			batch.Queue(withRevision(deleteExample), w.Item.Id, w.Item.Version, w.DeletedBy)
+		}
+	}
+
+	var missed []int
+	var touched []string
+
+	if batch.Len() > 0 {
+		br := tx.SendBatch(ctx, batch)
+
+		for i, w := range writes {
+			if results[i].Err != nil {
+				continue
+			}
+
+			switch w.Kind {
+			case UpdateWrite:
+				err = scanExample(br.QueryRow(), &results[i].Item)
+			case DeleteWrite:
+				// Deletes report no item, what was written is only read to move on
+				var deleted example.Example
+				err = scanExample(br.QueryRow(), &deleted)
+			default:
+				continue
+			}
+
+			switch {
+			case err == nil:
+				touched = append(touched, w.Item.Id)
+			case errors.Is(err, pgx.ErrNoRows):
+				missed = append(missed, i)
+			default:
+				br.Close()
+				return nil, err
+			}
+		}
+
+		if err := br.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, i := range missed {
+		results[i] = WriteResult{Err: e.writeMissError(ctx, tx, writes[i].Item.Id)}
+	}
+
+	if atomic && rollBack(results) {
+		return results, nil
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	// One round trip for every example the batch changed, the writes are
+	// committed so a failure only leaves entries to expire with their TTL
+	if len(touched) > 0 {
+		client := e.cacheClient.Client().Client()
+		if err := client.Do(ctx, client.B().Del().Key(touched...).Build()).Error(); err != nil {
+			slog.LogAttrs(
+				ctx,
+				slog.LevelWarn,
+				cacheErrMsg,
+				slog.String(errKey, err.Error()),
+			)
+		}
+	}
+
+	return results, nil
+}
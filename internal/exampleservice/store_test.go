@@ -6,8 +6,12 @@ package exampleservice
 
 import (
 	"context"
+	"errors"
 	"os"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -15,6 +19,7 @@ import (
 	"github.com/moonmoon1919/go-api-reference/internal/config"
 	"github.com/moonmoon1919/go-api-reference/internal/store"
 	"github.com/moonmoon1919/go-api-reference/pkg/example"
+	"github.com/moonmoon1919/go-api-reference/pkg/users"
 	"github.com/valkey-io/valkey-go"
 	"github.com/valkey-io/valkey-go/valkeyaside"
 )
@@ -27,27 +32,18 @@ type testConfig struct {
 }
 
 func buildConfig() testConfig {
+	loader := config.NewLoader()
+
 	return testConfig{
-		database: store.Config{
-			Host:     config.NewEnvironmentSource("DB_HOST"),
-			User:     config.NewEnvironmentSource("DB_USER"),
-			Password: config.NewEnvironmentSource("DB_PASS"),
-			Database: config.NewEnvironmentSource("DB_NAME"),
-			Schema: config.NewFirst(
-				config.NewEnvironmentSource("DB_SCHEMA"),
-				config.NewDefaultValueSource("schemas"),
-			),
-		},
-		cache: cache.Config{
-			Host: config.NewEnvironmentSource("CACHE_HOST"),
-		},
+		database: store.LoadConfig(loader, config.EnvironmentLookup),
+		cache:    cache.LoadConfig(loader, config.EnvironmentLookup),
 	}
 }
 
 func buildClients(cfg testConfig) (*pgxpool.Pool, valkeyaside.CacheAsideClient, error) {
 	dbCache, err := valkeyaside.NewClient(valkeyaside.ClientOption{
 		ClientOption: valkey.ClientOption{
-			InitAddress: []string{cfg.cache.Host.Must()},
+			InitAddress: []string{cfg.cache.Host},
 			SelectDB:    1,
 		},
 	})
@@ -76,6 +72,8 @@ func TestIntegrationExampleAddSQLRepository(t *testing.T) {
 	tests := []struct {
 		name       string
 		userId     string
+		id         string
+		taken      bool
 		message    string
 		errMessage string
 	}{
@@ -85,6 +83,21 @@ func TestIntegrationExampleAddSQLRepository(t *testing.T) {
 			message:    "string",
 			errMessage: "",
 		},
+		{
+			name:       "PassingCase-ClientId",
+			userId:     uuid.NewString(),
+			id:         uuid.NewString(),
+			message:    "string",
+			errMessage: "",
+		},
+		{
+			name:       "FailingCase-IdTaken",
+			userId:     uuid.NewString(),
+			id:         uuid.NewString(),
+			taken:      true,
+			message:    "string",
+			errMessage: "example with this id already exists",
+		},
 	}
 
 	cfg := buildConfig()
@@ -102,7 +115,13 @@ func TestIntegrationExampleAddSQLRepository(t *testing.T) {
 			pool.Exec(context.TODO(), "INSERT INTO users (id) VALUES ($1) ON CONFLICT (id) DO NOTHING", tc.userId)
 
 			item, _ := example.New(tc.userId, tc.message)
-			res, err := repository.Add(context.TODO(), item)
+			item.Id = tc.id
+
+			if tc.taken {
+				repository.Add(context.TODO(), item, users.NoQuota())
+			}
+
+			res, err := repository.Add(context.TODO(), item, users.NoQuota())
 
 			var errMessage string
 			if err != nil {
@@ -115,8 +134,8 @@ func TestIntegrationExampleAddSQLRepository(t *testing.T) {
 
 			// Only validate if we're not checking error cases
 			if tc.errMessage == "" {
-				if res.Id == "" {
-					t.Errorf("Exected example to have id")
+				if res.Id == "" || (tc.id != "" && res.Id != tc.id) {
+					t.Errorf("Exected example to have id %s, got %s", tc.id, res.Id)
 				}
 
 				if res.Message != tc.message {
@@ -134,6 +153,105 @@ func TestIntegrationExampleAddSQLRepository(t *testing.T) {
 	}
 }
 
+func TestIntegrationExampleQuotaSQLRepository(t *testing.T) {
+	if testType != "INTEGRATION" {
+		t.Skip()
+	}
+
+	tests := []struct {
+		name          string
+		quota         users.Quota
+		own           *users.Quota
+		trashed       bool
+		errMessage    string
+		expectedUsage users.Usage
+	}{
+		{
+			name:          "PassingCase",
+			quota:         users.Quota{Examples: 2, Bytes: 100},
+			expectedUsage: users.Usage{Examples: 2, Bytes: 12},
+		},
+		{
+			name:          "PassingCase-TrashDoesNotCount",
+			quota:         users.Quota{Examples: 1, Bytes: users.Unlimited},
+			trashed:       true,
+			expectedUsage: users.Usage{Examples: 1, Bytes: 6},
+		},
+		{
+			name:          "PassingCase-OwnQuota",
+			quota:         users.Quota{Examples: 1, Bytes: users.Unlimited},
+			own:           &users.Quota{Examples: 2, Bytes: users.Unlimited},
+			expectedUsage: users.Usage{Examples: 2, Bytes: 12},
+		},
+		{
+			name:          "FailingCase-TooManyExamples",
+			quota:         users.Quota{Examples: 1, Bytes: users.Unlimited},
+			errMessage:    "example does not fit in the user's quota",
+			expectedUsage: users.Usage{Examples: 1, Bytes: 6},
+		},
+		{
+			name:          "FailingCase-TooManyBytes",
+			quota:         users.Quota{Examples: users.Unlimited, Bytes: 10},
+			errMessage:    "example does not fit in the user's quota",
+			expectedUsage: users.Usage{Examples: 1, Bytes: 6},
+		},
+	}
+
+	cfg := buildConfig()
+	pool, cache, err := buildClients(cfg)
+	if err != nil {
+		t.Errorf("Unexpected error building clients %s", err.Error())
+	}
+	defer pool.Close()
+
+	repository := NewSQLRepository(pool, cache)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			userId := uuid.NewString()
+			pool.Exec(context.TODO(), "INSERT INTO users (id) VALUES ($1) ON CONFLICT (id) DO NOTHING", userId)
+
+			if tc.own != nil {
+				pool.Exec(context.TODO(), "INSERT INTO quotas (uid, examples, bytes) VALUES ($1, $2, $3)", userId, tc.own.Examples, tc.own.Bytes)
+			}
+
+			first, _ := example.New(userId, "first!")
+			first, _ = repository.Add(context.TODO(), first, tc.quota)
+
+			if tc.trashed {
+				repository.Delete(context.TODO(), first.Id, userId, 0)
+			}
+
+			// When
+			second, _ := example.New(userId, "second")
+			_, err := repository.Add(context.TODO(), second, tc.quota)
+
+			// Then
+			var errMessage string
+			if err != nil {
+				errMessage = err.Error()
+			}
+
+			if tc.errMessage != errMessage {
+				t.Errorf("Got unexpected error %s, expected %s", errMessage, tc.errMessage)
+			}
+
+			usage, _, err := repository.Usage(context.TODO(), userId, tc.quota)
+			if err != nil {
+				t.Errorf("Unexpected error %s", err.Error())
+			}
+
+			if usage != tc.expectedUsage {
+				t.Errorf("Expected usage %+v, got %+v", tc.expectedUsage, usage)
+			}
+
+			// Clean up by deleting the user, triggering a cascading delete
+			pool.Exec(context.TODO(), "DELETE FROM users where id=$1", userId)
+		})
+	}
+}
+
 func TestIntegrationExampleGetSQLRepository(t *testing.T) {
 	if testType != "INTEGRATION" {
 		t.Skip()
@@ -169,7 +287,7 @@ func TestIntegrationExampleGetSQLRepository(t *testing.T) {
 			pool.Exec(context.TODO(), "INSERT INTO users (id) VALUES ($1) ON CONFLICT (id) DO NOTHING", tc.userId)
 
 			item, _ := example.New(tc.userId, tc.message)
-			res, err := repository.Add(context.TODO(), item)
+			res, err := repository.Add(context.TODO(), item, users.NoQuota())
 			if err != nil {
 				t.Errorf("Unexpected error adding example %s", err.Error())
 			}
@@ -250,14 +368,14 @@ func TestIntegrationExampleListSQLRepository(t *testing.T) {
 
 			for range tc.numItems {
 				item, _ := example.New(tc.userId, tc.message)
-				_, err := repository.Add(context.TODO(), item)
+				_, err := repository.Add(context.TODO(), item, users.NoQuota())
 				if err != nil {
 					t.Errorf("Unexpected error adding example %s", err.Error())
 				}
 			}
 
 			// When
-			results, err := repository.List(context.TODO(), tc.userId, tc.limit, tc.page)
+			results, err := repository.List(context.TODO(), tc.userId, nil, store.Pagination{Limit: tc.limit, Page: tc.page})
 
 			// Then
 			var errMessage string
@@ -270,8 +388,8 @@ func TestIntegrationExampleListSQLRepository(t *testing.T) {
 			}
 
 			if tc.errMessage == "" {
-				if len(results) != tc.limit {
-					t.Errorf("Expected %d items to be returned, got %d", tc.limit, len(results))
+				if len(results.Items) != tc.limit {
+					t.Errorf("Expected %d items to be returned, got %d", tc.limit, len(results.Items))
 				}
 			}
 
@@ -281,6 +399,151 @@ func TestIntegrationExampleListSQLRepository(t *testing.T) {
 	}
 }
 
+func TestIntegrationExampleListCursorSQLRepository(t *testing.T) {
+	if testType != "INTEGRATION" {
+		t.Skip()
+	}
+
+	cfg := buildConfig()
+	pool, cache, err := buildClients(cfg)
+	if err != nil {
+		t.Errorf("Unexpected error building clients %s", err.Error())
+	}
+	defer pool.Close()
+
+	repository := NewSQLRepository(pool, cache)
+
+	// Given
+	userId := uuid.NewString()
+	pool.Exec(context.TODO(), "INSERT INTO users (id) VALUES ($1) ON CONFLICT (id) DO NOTHING", userId)
+	defer pool.Exec(context.TODO(), "DELETE FROM users where id=$1", userId)
+
+	for range 25 {
+		item, _ := example.New(userId, "string")
+		repository.Add(context.TODO(), item, users.NoQuota())
+	}
+
+	// When
+	seen := make(map[string]bool)
+	pagination := store.Pagination{Limit: 10, Page: 1}
+	var last store.Page[example.Example]
+
+	for range 5 {
+		page, err := repository.List(context.TODO(), userId, nil, pagination)
+		if err != nil {
+			t.Fatalf("Unexpected error listing examples %s", err.Error())
+		}
+
+		for _, item := range page.Items {
+			if seen[item.Id] {
+				t.Errorf("Example %s returned on more than one page", item.Id)
+			}
+			seen[item.Id] = true
+		}
+
+		last = page
+		if page.Next == nil {
+			break
+		}
+
+		pagination = store.Pagination{Limit: 10, Cursor: page.Next}
+	}
+
+	back, err := repository.List(context.TODO(), userId, nil, store.Pagination{Limit: 10, Cursor: last.Prev})
+
+	// Then
+	if len(seen) != 25 {
+		t.Errorf("Expected to page through 25 examples, got %d", len(seen))
+	}
+
+	if err != nil || len(back.Items) != 10 || back.Next == nil {
+		t.Errorf("Expected a full previous page with a next cursor, got %d items", len(back.Items))
+	}
+}
+
+func TestIntegrationExampleListSearchSQLRepository(t *testing.T) {
+	if testType != "INTEGRATION" {
+		t.Skip()
+	}
+
+	cfg := buildConfig()
+	pool, cache, err := buildClients(cfg)
+	if err != nil {
+		t.Errorf("Unexpected error building clients %s", err.Error())
+	}
+	defer pool.Close()
+
+	repository := NewSQLRepository(pool, cache)
+
+	// Given
+	userId := uuid.NewString()
+	pool.Exec(context.TODO(), "INSERT INTO users (id) VALUES ($1) ON CONFLICT (id) DO NOTHING", userId)
+	defer pool.Exec(context.TODO(), "DELETE FROM users where id=$1", userId)
+
+	start := time.Now().Add(-time.Minute)
+	for _, msg := range []string{"Running late", "Ran home", "100% done", "Nothing to see"} {
+		item, _ := example.New(userId, msg)
+		repository.Add(context.TODO(), item, users.NoQuota())
+	}
+
+	// Only the start of a message is in the sort index, the longest still fits
+	otherId := uuid.NewString()
+	pool.Exec(context.TODO(), "INSERT INTO users (id) VALUES ($1) ON CONFLICT (id) DO NOTHING", otherId)
+	defer pool.Exec(context.TODO(), "DELETE FROM users where id=$1", otherId)
+
+	longest, _ := example.New(otherId, strings.Repeat("語", example.MaxMessageLength))
+	if _, err := repository.Add(context.TODO(), longest, users.NoQuota()); err != nil {
+		t.Fatalf("Unexpected error adding the longest message %s", err.Error())
+	}
+
+	tests := []struct {
+		name     string
+		filters  []store.Filter
+		expected []string
+	}{
+		{
+			name:     "PassingCase-SearchIsStemmed",
+			filters:  []store.Filter{{Field: "message", Op: store.Search, Value: "run"}},
+			expected: []string{"Running late"},
+		},
+		{
+			name:     "PassingCase-ContainsEscapesWildcards",
+			filters:  []store.Filter{{Field: "message", Op: store.Contains, Value: "0%"}},
+			expected: []string{"100% done"},
+		},
+		{
+			name:     "PassingCase-CreatedAtRange",
+			filters:  []store.Filter{{Field: "created_at", Op: store.Gte, Time: start}},
+			expected: []string{"100% done", "Nothing to see", "Ran home", "Running late"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// When
+			page, err := repository.List(context.TODO(), userId, tc.filters, store.Pagination{Limit: 10, Page: 1, Sort: store.Sort{Field: "message"}, Count: store.ExactCount})
+
+			// Then
+			if err != nil {
+				t.Fatalf("Unexpected error listing examples %s", err.Error())
+			}
+
+			var messages []string
+			for _, item := range page.Items {
+				messages = append(messages, item.Message)
+			}
+
+			if !slices.Equal(messages, tc.expected) {
+				t.Errorf("Expected %v, got %v", tc.expected, messages)
+			}
+
+			if page.Total == nil || page.Total.Count != int64(len(tc.expected)) {
+				t.Errorf("Expected a total of %d, got %+v", len(tc.expected), page.Total)
+			}
+		})
+	}
+}
+
 func TestIntegrationExampleUpdateSQLRepository(t *testing.T) {
 	if testType != "INTEGRATION" {
 		t.Skip()
@@ -291,6 +554,7 @@ func TestIntegrationExampleUpdateSQLRepository(t *testing.T) {
 		userId          string
 		originalMessage string
 		updateMessage   string
+		staleVersion    bool
 		errMessage      string
 	}{
 		{
@@ -300,6 +564,14 @@ func TestIntegrationExampleUpdateSQLRepository(t *testing.T) {
 			updateMessage:   "nu-string",
 			errMessage:      "",
 		},
+		{
+			name:            "FailingCase-StaleVersion",
+			userId:          uuid.NewString(),
+			originalMessage: "string",
+			updateMessage:   "nu-string",
+			staleVersion:    true,
+			errMessage:      "example version does not match",
+		},
 	}
 
 	cfg := buildConfig()
@@ -318,14 +590,19 @@ func TestIntegrationExampleUpdateSQLRepository(t *testing.T) {
 			pool.Exec(context.TODO(), "INSERT INTO users (id) VALUES ($1) ON CONFLICT (id) DO NOTHING", tc.userId)
 
 			item, _ := example.New(tc.userId, tc.originalMessage)
-			res, err := repository.Add(context.TODO(), item)
+			res, err := repository.Add(context.TODO(), item, users.NoQuota())
 			if err != nil {
 				t.Errorf("Unexpected error adding example %s", err.Error())
 			}
 
+			// Someone else updates the example first
+			if tc.staleVersion {
+				repository.Update(context.TODO(), res, users.NoQuota())
+			}
+
 			// When
 			res.SetMessage(tc.updateMessage)
-			result, err := repository.Update(context.TODO(), res)
+			result, err := repository.Update(context.TODO(), res, users.NoQuota())
 
 			// Then
 			var errMessage string
@@ -349,6 +626,21 @@ func TestIntegrationExampleUpdateSQLRepository(t *testing.T) {
 				if result.UserId != tc.userId {
 					t.Errorf("Expected message %s, got %s", tc.userId, result.UserId)
 				}
+
+				if result.Version != res.Version+1 {
+					t.Errorf("Expected version %d, got %d", res.Version+1, result.Version)
+				}
+
+				// Both the original and the update are kept
+				original, err := repository.GetRevision(context.TODO(), res.Id, res.Version)
+				if err != nil || original.Message != tc.originalMessage {
+					t.Errorf("Expected revision %d to have message %s, got %s (%v)", res.Version, tc.originalMessage, original.Message, err)
+				}
+
+				page, err := repository.ListRevisions(context.TODO(), res.Id, store.Pagination{Limit: 10, Page: 1})
+				if err != nil || len(page.Items) != 2 {
+					t.Errorf("Expected 2 revisions, got %d (%v)", len(page.Items), err)
+				}
 			}
 
 			// Clean up by deleting the user, triggering a cascading delete
@@ -392,13 +684,13 @@ func TestIntegrationExampleDeleteSQLRepository(t *testing.T) {
 			pool.Exec(context.TODO(), "INSERT INTO users (id) VALUES ($1) ON CONFLICT (id) DO NOTHING", tc.userId)
 
 			item, _ := example.New(tc.userId, tc.message)
-			res, err := repository.Add(context.TODO(), item)
+			res, err := repository.Add(context.TODO(), item, users.NoQuota())
 			if err != nil {
 				t.Errorf("Unexpected error adding example %s", err.Error())
 			}
 
 			// When
-			err = repository.Delete(context.TODO(), res.Id)
+			err = repository.Delete(context.TODO(), res.Id, tc.userId, 0)
 
 			// Then
 			var errMessage string
@@ -415,8 +707,104 @@ func TestIntegrationExampleDeleteSQLRepository(t *testing.T) {
 				t.Errorf("Expected getting a deleted item to error, found no error")
 			}
 
+			// Only its owner can take it out of the trash
+			if _, err := repository.Restore(context.TODO(), uuid.NewString(), res.Id, users.NoQuota()); !errors.Is(err, notFoundError) {
+				t.Errorf("Expected another user's restore to be not found, got %v", err)
+			}
+
+			// The example is in the trash until it is restored
+			restored, err := repository.Restore(context.TODO(), tc.userId, res.Id, users.NoQuota())
+			if err != nil || restored.Deleted() || restored.Version != res.Version+2 {
+				t.Errorf("Expected to restore the example, got %+v and %v", restored, err)
+			}
+
+			if _, err = repository.Get(context.TODO(), res.Id); err != nil {
+				t.Errorf("Expected to get the restored example, got %s", err.Error())
+			}
+
 			// Clean up users and examples in the event the test fails
 			pool.Exec(context.TODO(), "DELETE FROM users WHERE id=$1", tc.userId)
 		})
 	}
 }
+
+func TestIntegrationExampleBatchSQLRepository(t *testing.T) {
+	if testType != "INTEGRATION" {
+		t.Skip()
+	}
+
+	tests := []struct {
+		name          string
+		userId        string
+		atomic        bool
+		deleteErr     string
+		expectedItems int
+	}{
+		{
+			name:          "FailingCase-AtomicRolledBack",
+			userId:        uuid.NewString(),
+			atomic:        true,
+			deleteErr:     "example version does not match",
+			expectedItems: 1,
+		},
+		{
+			name:          "PassingCase-BestEffort",
+			userId:        uuid.NewString(),
+			atomic:        false,
+			deleteErr:     "example version does not match",
+			expectedItems: 3,
+		},
+	}
+
+	cfg := buildConfig()
+	pool, cache, err := buildClients(cfg)
+	if err != nil {
+		t.Errorf("Unexpected error building clients %s", err.Error())
+	}
+	defer pool.Close()
+
+	repository := NewSQLRepository(pool, cache)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			// Insert the user so we don't get FK errors
+			pool.Exec(context.TODO(), "INSERT INTO users (id) VALUES ($1) ON CONFLICT (id) DO NOTHING", tc.userId)
+
+			item, _ := example.New(tc.userId, "existing")
+			existing, err := repository.Add(context.TODO(), item, users.NoQuota())
+			if err != nil {
+				t.Errorf("Unexpected error adding example %s", err.Error())
+			}
+
+			first, _ := example.New(tc.userId, "first")
+			second, _ := example.New(tc.userId, "second")
+			stale := existing
+			stale.Version += 5
+
+			// When
+			results, err := repository.Batch(context.TODO(), tc.userId, []Write{
+				{Kind: CreateWrite, Item: first},
+				{Kind: CreateWrite, Item: second},
+				{Kind: DeleteWrite, Item: stale, DeletedBy: tc.userId},
+			}, tc.atomic, users.NoQuota())
+
+			// Then
+			if err != nil {
+				t.Errorf("Unexpected error %s", err.Error())
+			}
+
+			if results[2].Err == nil || results[2].Err.Error() != tc.deleteErr {
+				t.Errorf("Expected the delete to fail with %s, got %v", tc.deleteErr, results[2].Err)
+			}
+
+			page, err := repository.List(context.TODO(), tc.userId, nil, store.Pagination{Limit: 10, Page: 1})
+			if err != nil || len(page.Items) != tc.expectedItems {
+				t.Errorf("Expected %d examples, got %d (%v)", tc.expectedItems, len(page.Items), err)
+			}
+
+			// Clean up by deleting the user, triggering a cascading delete
+			pool.Exec(context.TODO(), "DELETE FROM users where id=$1", tc.userId)
+		})
+	}
+}
@@ -1,68 +1,291 @@
 package exampleservice
 
 import (
-	"encoding/json"
+	"context"
 	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"strconv"
+	"strings"
 
+	"github.com/google/uuid"
 	"github.com/moonmoon1919/go-api-reference/internal/cache"
 	"github.com/moonmoon1919/go-api-reference/internal/middleware"
 	"github.com/moonmoon1919/go-api-reference/internal/requests"
 	"github.com/moonmoon1919/go-api-reference/internal/responses"
+	"github.com/moonmoon1919/go-api-reference/internal/store"
+	"github.com/moonmoon1919/go-api-reference/pkg/example"
 )
 
 const (
-	msgInternalServerError      = "INTERNAL_SERVER_ERROR"
-	msgJsonMarshallError        = "JSON_MARSHAL_ERROR"
-	msgServiceError             = "SERVICE_ERROR"
-	msgNotFound                 = "NOT_FOUND"
-	msgCacheError               = "CACHE_ERROR"
-	msgControllerError          = "CONTROLLER_ERROR"
-	cacheSetError               = "CACHE_SET_ERROR"
-	cacheCheckGet               = "CACHE_CHECK_GET"
-	cacheHit                    = "CACHE_HIT"
-	cacheMiss                   = "CACHE_MISS"
-	cacheCheckConditionalUpdate = "CACHE_CHECK_CONDITIONAL_UPDATE"
-	cacheMissConditionalUpdate  = "CACHE_MISS_CONDITIONAL_UPDATE"
-	cacheHitConditionalUpdate   = "CACHE_HIT_CONDITIONAL_UPDATE"
-	errMissingUser              = "USER_MISSING"
-	errInvalidLimit             = "LIMIT_MUST_BE_INTEGER"
-	errLimitOutOfRange          = "LIMIT_OUT_OF_RANGE"
-	errInvalidPage              = "PAGE_MUST_BE_INTEGER"
-	errPageOutOfRange           = "PAGE_OUT_OF_RANGE"
-	keyError                    = "ERROR"
-	etagLog                     = "ETAG"
-	pathValId                   = "id"
+	cacheSetError          = "CACHE_SET_ERROR"
+	cacheCheckGet          = "CACHE_CHECK_GET"
+	cacheHit               = "CACHE_HIT"
+	cacheMiss              = "CACHE_MISS"
+	checkConditionalUpdate = "CHECK_CONDITIONAL_UPDATE"
+	errInvalidLimit        = "LIMIT_MUST_BE_INTEGER"
+	errLimitOutOfRange     = "LIMIT_OUT_OF_RANGE"
+	errInvalidPage         = "PAGE_MUST_BE_INTEGER"
+	errPageOutOfRange      = "PAGE_OUT_OF_RANGE"
+	errInvalidCursor       = "CURSOR_INVALID"
+	errCursorWithPage      = "CURSOR_WITH_PAGE"
+	errInvalidTotal        = "TOTAL_MUST_BE_EXACT_OR_ESTIMATED"
+	errLimitTooLarge       = "LIMIT_TOO_LARGE"
+	errBatchTooLarge       = "TOO_MANY_OPERATIONS"
+	errUnknownFilter       = "FILTER_NOT_SUPPORTED"
+	errUnsupportedOperator = "FILTER_OPERATOR_NOT_SUPPORTED"
+	errInvalidFilterValue  = "FILTER_VALUE_INVALID"
+	errTooManyFilters      = "TOO_MANY_FILTERS"
+	errUnknownSort         = "SORT_NOT_SUPPORTED"
+	errCursorSortMismatch  = "CURSOR_SORT_MISMATCH"
+	errInvalidRevision     = "REVISION_MUST_BE_INTEGER"
+	errInvalidPatch        = "INVALID_PATCH"
+	errInvalidId           = "ID_MUST_BE_UUID"
+	keyError               = "ERROR"
+	etagLog                = "ETAG"
+	pathValId              = "id"
+	pathValRevision        = "rev"
 )
 
+var missingUserError = errors.New("requesting user is missing from the context")
+var invalidRevisionError = errors.New("revision must be a positive integer")
+var invalidIdError = errors.New("id must be a UUID")
+
 type Controller struct {
 	Service Service
 	Cache   cache.Cacher
+	Cursors requests.CursorCodec
+}
+
+// MARK: Errors
+/*
+Domain rule violations are reported per field with a 422
+*/
+func validationProblem(err error) (responses.Problem, bool) {
+	var invalid example.ValidationError
+	if !errors.As(err, &invalid) {
+		return responses.Problem{}, false
+	}
+
+	details := make([]responses.FieldError, 0, len(invalid.Violations))
+	for _, v := range invalid.Violations {
+		details = append(details, responses.FieldError{Field: v.Field, Code: v.Code, Message: v.Message})
+	}
+
+	return responses.NewProblem(http.StatusUnprocessableEntity, responses.VALIDATION_FAILED).WithErrors(details...), true
+}
+
+/*
+Media types responses can be sent in, JSON unless the client asks for another
+*/
+var encoders = responses.DefaultEncoders
+
+/*
+The errors a client can cause, from the service and from reading the request
+*/
+var problems = responses.NewRegistry().
+	Encoders(encoders...).
+	RegisterFunc(validationProblem).
+	RegisterFunc(requests.BodyProblem).
+	Register(preconditionFailedError, http.StatusPreconditionFailed, responses.PRECONDITION_FAILED).
+	Register(repositoryConflictError, http.StatusConflict, responses.CONFLICT).
+	Register(repositoryNotFoundError, http.StatusNotFound, responses.NOT_FOUND).
+	Register(revisionNotFoundServiceError, http.StatusNotFound, responses.NOT_FOUND).
+	Register(overQuotaError, http.StatusForbidden, responses.QUOTA_EXCEEDED).
+	Register(notAppliedError, http.StatusFailedDependency, responses.FAILED_DEPENDENCY).
+	Register(limitToLargeError, http.StatusBadRequest, errLimitTooLarge).
+	Register(batchTooLargeError, http.StatusBadRequest, errBatchTooLarge).
+	Register(invalidIdError, http.StatusBadRequest, errInvalidId).
+	Register(invalidRevisionError, http.StatusBadRequest, errInvalidRevision).
+	Register(store.CursorSortMismatchError, http.StatusBadRequest, errCursorSortMismatch).
+	Register(requests.UnsupportedMediaTypeError, http.StatusUnsupportedMediaType, responses.UNSUPPORTED_MEDIA).
+	Register(requests.InvalidPatchError, http.StatusBadRequest, errInvalidPatch).
+	Register(requests.PatchTestFailedError, http.StatusConflict, responses.PATCH_TEST_FAILED).
+	Register(requests.PatchNotApplicableError, http.StatusUnprocessableEntity, responses.PATCH_NOT_APPLICABLE).
+	Register(requests.InvalidLimitError, http.StatusBadRequest, errInvalidLimit).
+	Register(requests.LimitTooLowError, http.StatusBadRequest, errLimitOutOfRange).
+	Register(requests.InvalidPageError, http.StatusBadRequest, errInvalidPage).
+	Register(requests.PageTooLowError, http.StatusBadRequest, errPageOutOfRange).
+	Register(requests.InvalidTotalError, http.StatusBadRequest, errInvalidTotal).
+	Register(requests.CursorWithPageError, http.StatusBadRequest, errCursorWithPage).
+	Register(requests.InvalidCursorError, http.StatusBadRequest, errInvalidCursor).
+	Register(requests.UnknownFilterError, http.StatusBadRequest, errUnknownFilter).
+	Register(requests.UnsupportedOperatorError, http.StatusBadRequest, errUnsupportedOperator).
+	Register(requests.InvalidFilterValueError, http.StatusBadRequest, errInvalidFilterValue).
+	Register(requests.TooManyFiltersError, http.StatusBadRequest, errTooManyFilters).
+	Register(requests.UnknownSortError, http.StatusBadRequest, errUnknownSort)
+
+/*
+Adapts a controller method to an http.HandlerFunc, errors are written as problems
+*/
+func Handle(h responses.Handler) http.HandlerFunc {
+	return problems.Handle(h)
+}
+
+func requestingUser(r *http.Request) (middleware.RequestingUser, error) {
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		return middleware.RequestingUser{}, missingUserError
+	}
+
+	return user, nil
+}
+
+// MARK: Preconditions
+/*
+Strong ETag for an example, derived from its version
+
+Every media type is a different representation with its own ETag, JSON is
+"3" and the others name their subtype, e.g., "3-cbor"
+*/
+func versionEtag(version int, mediaType responses.HeaderValue) string {
+	etag := strconv.Itoa(version)
+
+	if mediaType != responses.ApplicationJson {
+		_, subtype, _ := strings.Cut(mediaType.Value(), "/")
+		etag += "-" + subtype
+	}
+
+	return strconv.Quote(etag)
+}
+
+/*
+The media type an example is sent in, negotiated from Accept like the body
+
+Falls back to JSON when nothing is acceptable, encoding the body answers that with a 406
+*/
+func exampleMediaType(r *http.Request) responses.HeaderValue {
+	enc, err := encoders.Negotiate(r, GetExampleResponse{})
+	if err != nil {
+		return responses.ApplicationJson
+	}
+
+	return enc.MediaType()
+}
+
+func validatorsFromExample(item example.Example, mediaType responses.HeaderValue) requests.Validators {
+	return requests.Validators{
+		ETag:         versionEtag(item.Version, mediaType),
+		LastModified: item.UpdatedAt,
+		Exists:       true,
+	}
+}
+
+func validatorHeaders(v requests.Validators) responses.Headers {
+	headers := responses.Headers{
+		responses.NoCachePrivate(),
+		responses.Etag(v.ETag),
+	}
+
+	if !v.LastModified.IsZero() {
+		headers = append(headers, responses.LastModified(v.LastModified))
+	}
+
+	return headers
+}
+
+/*
+Evaluates the conditional headers of a request
+
+Returns true when the handler is done, with the 304 to send or the error for a 412
+*/
+func preconditions(r *http.Request, v requests.Validators) (responses.Response, bool, error) {
+	switch requests.EvaluatePreconditions(r, v) {
+	case requests.NotModified:
+		return responses.NotModified(validatorHeaders(v)...), true, nil
+	case requests.PreconditionFailed:
+		return responses.Response{}, true, fmt.Errorf("%w: %s", preconditionFailedError, v.ETag)
+	default:
+		return responses.Response{}, false, nil
+	}
+}
+
+/*
+Evaluates the conditional headers of a write against the current example
+
+Returns the version the write must apply to, the store checks it again so
+nothing can change between here and the write. Returns a version of 0 when
+the request isn't conditional. Writes never get a 304, only a 412. The
+service treats another user's example as missing, so a 412 doesn't tell them
+it exists.
+*/
+func (c Controller) writePreconditions(r *http.Request, userId, id string) (int, error) {
+	if !requests.HasPreconditions(r) {
+		return 0, nil
+	}
+
+	slog.LogAttrs(
+		r.Context(),
+		slog.LevelInfo,
+		checkConditionalUpdate,
+		slog.String(logKeyId, id),
+	)
+
+	var validators requests.Validators
+
+	current, err := c.Service.owned(r.Context(), userId, id)
+	switch {
+	case err == nil:
+		validators = validatorsFromExample(current, exampleMediaType(r))
+	case errors.Is(err, repositoryNotFoundError):
+		// Preconditions are evaluated against a missing resource
+	default:
+		return 0, err
+	}
+
+	if _, done, err := preconditions(r, validators); done {
+		return 0, err
+	}
+
+	return current.Version, nil
+}
+
+/*
+Stores the version of an example in the cache, the ETag of every
+representation is derived from it
+
+The cache only lets GET answer If-None-Match without loading the example,
+it is best-effort. If the cache is unavailable we log and carry on.
+*/
+func (c Controller) cacheVersion(ctx context.Context, id string, version int) {
+	err := c.Cache.Set(ctx, id, strconv.Itoa(version))
+	if err != nil {
+		slog.LogAttrs(
+			ctx,
+			slog.LevelWarn,
+			cacheSetError,
+			slog.String(keyError, err.Error()),
+		)
+	}
 }
 
 // MARK: GET
-func (c Controller) Get(w http.ResponseWriter, r *http.Request) {
+func (c Controller) Get(r *http.Request) (responses.Response, error) {
 	id, err := requests.LoadPathValue(r, pathValId)
 	if err != nil {
-		responses.WriteInternalServerErrorResponse(w)
-		return
+		return responses.Response{}, err
 	}
 
-	// Cache check
+	// Cache check, If-None-Match can be answered with the cached ETag alone
+	// The other conditions need the example so they skip the cache
 	etag := r.Header.Get(requests.IfNoneMatch.Name())
-	if len(etag) != 0 {
-		condition := cache.NewNoneMatch(c.Cache)
+	cacheable := len(r.Header.Get(requests.IfMatch.Name())) == 0 && len(r.Header.Get(requests.IfUnmodifiedSince.Name())) == 0
 
+	if len(etag) != 0 && cacheable {
 		slog.LogAttrs(
 			r.Context(),
 			slog.LevelInfo,
 			cacheCheckGet, slog.String(etagLog, etag),
 		)
 
+		cached, ok := c.Cache.Get(r.Context(), id)
+		version, err := strconv.Atoi(cached)
+		ok = ok && err == nil
+		validators := requests.Validators{ETag: versionEtag(version, exampleMediaType(r)), Exists: true}
+
 		// Cache hit
-		if ok := condition.Met(r.Context(), id, etag); ok {
+		if ok && requests.EvaluatePreconditions(r, validators) == requests.NotModified {
 			slog.LogAttrs(
 				r.Context(),
 				slog.LevelInfo,
@@ -70,11 +293,7 @@ func (c Controller) Get(w http.ResponseWriter, r *http.Request) {
 				slog.String(etagLog, etag),
 			)
 
-			responses.WriteNotModifiedResponse(w, &responses.Headers{
-				responses.NoCachePrivate(),
-			})
-
-			return
+			return responses.NotModified(validatorHeaders(validators)...), nil
 		} else {
 			slog.LogAttrs(
 				r.Context(),
@@ -88,480 +307,502 @@ func (c Controller) Get(w http.ResponseWriter, r *http.Request) {
 	// Call the service
 	data, err := c.Service.Get(r.Context(), id)
 	if err != nil {
-		switch {
-		case errors.Is(err, repositoryNotFoundError):
-			// Not found is not necessarily an error from our perspective
-			// So we log it as info
-			slog.LogAttrs(
-				r.Context(),
-				slog.LevelInfo,
-				notFoundMsg,
-				slog.Any(logKeyId, id),
-			)
-			responses.WriteNotFoundResponse(w)
-			return
-		default:
-			slog.LogAttrs(
-				r.Context(),
-				slog.LevelError,
-				msgServiceError,
-				slog.Any(keyError, err),
-			)
-			responses.WriteInternalServerErrorResponse(w)
-			return
+		// e.g., If-Match: * fails on a missing example
+		if errors.Is(err, repositoryNotFoundError) {
+			if resp, done, err := preconditions(r, requests.Validators{}); done {
+				return resp, err
+			}
 		}
+
+		return responses.Response{}, err
 	}
 
-	// Marshal the response in the controller
-	// So we don't inadvertently call json.Marshall a bunch of times
-	resp := NewGetExampleResponseFromExample(data)
-	respBytes, err := json.Marshal(resp)
+	// Read thru cache
+	validators := validatorsFromExample(data, exampleMediaType(r))
+	c.cacheVersion(r.Context(), id, data.Version)
+
+	// The cache didn't have it (or is unavailable), compare with the stored validators
+	if resp, done, err := preconditions(r, validators); done {
+		return resp, err
+	}
 
+	return responses.OK(NewGetExampleResponseFromExample(data), validatorHeaders(validators)...), nil
+}
+
+// MARK: LIST
+func (c Controller) List(r *http.Request) (responses.Response, error) {
+	user, err := requestingUser(r)
 	if err != nil {
-		slog.LogAttrs(
-			r.Context(),
-			slog.LevelError,
-			msgJsonMarshallError,
-			slog.String(keyError, err.Error()),
-		)
+		return responses.Response{}, err
+	}
 
-		responses.WriteInternalServerErrorResponse(w)
-		return
+	limit, page, err := requests.GetPaginationParameters(r)
+	if err != nil {
+		return responses.Response{}, err
 	}
 
-	// Read thru cache
-	cacheKey, err := c.Cache.Set(r.Context(), id, &respBytes)
+	// Cursor for keyset pagination, replaces page
+	cursor, err := requests.GetCursor(r, c.Cursors)
 	if err != nil {
-		slog.LogAttrs(
-			r.Context(),
-			slog.LevelError,
-			msgCacheError,
-			slog.String(keyError, err.Error()),
-		)
+		return responses.Response{}, err
+	}
 
-		responses.WriteInternalServerErrorResponse(w)
-		return
+	// Counting is opt-in, exact counts get slower as the listing grows
+	count, err := requests.GetCountMode(r)
+	if err != nil {
+		return responses.Response{}, err
 	}
 
-	// Content-Digest
-	digest := responses.CalculateContentDigest(&respBytes)
+	// Filters and sort, only fields in the schema are accepted
+	query, err := requests.ParseQuery(r, listQuerySchema)
+	if err != nil {
+		return responses.Response{}, err
+	}
 
-	// Write the response
-	responses.WriteSuccessResponse(
-		w,
-		&respBytes,
-		&responses.Headers{
-			responses.NoCachePrivate(),
-			responses.Etag(cacheKey),
-			responses.ContentDigest(digest, responses.SHA256),
-		},
-	)
-}
+	// Call the service
+	pagination := store.Pagination{Limit: limit, Page: page, Cursor: cursor, Count: count, Sort: query.Sort}
+	data, err := c.Service.List(r.Context(), user.Id, query.Filters, pagination)
+	if err != nil {
+		return responses.Response{}, err
+	}
 
-// MARK: LIST
-func (c Controller) List(w http.ResponseWriter, r *http.Request) {
-	user, ok := middleware.UserFromContext(r.Context())
+	// Encoded here, a page has no version so its weak ETag comes from the representation that's sent
+	resp := NewListExampleResponseFromPage(data, pagination, c.Cursors)
+	encoded, err := encoders.Encode(r, resp)
+	if err != nil {
+		return responses.Response{}, err
+	}
 
-	if !ok {
-		slog.LogAttrs(
-			r.Context(),
-			slog.LevelError,
-			msgServiceError,
-			slog.String(keyError, errMissingUser),
-		)
-		responses.WriteInternalServerErrorResponse(w)
-		return
+	validators := requests.Validators{
+		ETag:   fmt.Sprintf(`W/"%s"`, responses.CalculateContentDigest(&encoded.Data)),
+		Exists: true,
 	}
 
-	// Limit for pagination
-	queryLimit := r.URL.Query().Get("limit")
+	if resp, done, err := preconditions(r, validators); done {
+		return resp, err
+	}
 
-	var limit int = 10 // Default limit is 10
-	if queryLimit != "" {
-		n, err := strconv.Atoi(queryLimit)
+	headers := validatorHeaders(validators)
+	if links, ok := responses.PageLinks(r.URL, resp.NextCursor, resp.PrevCursor); ok {
+		headers = append(headers, links)
+	}
 
-		if err != nil {
-			responses.WriteBadRequestResponse(w, errInvalidLimit)
-			return
-		}
+	return responses.OK(encoded, headers...), nil
+}
 
-		// Smallest limit we support is 1
-		// Sending a limit less than 1 results in an error
-		if n < 1 {
-			responses.WriteBadRequestResponse(w, errLimitOutOfRange)
-			return
-		}
+// MARK: CREATE (POST)
+func (c Controller) Create(r *http.Request) (responses.Response, error) {
+	user, err := requestingUser(r)
+	if err != nil {
+		return responses.Response{}, err
+	}
+
+	// The collection always exists and has no validators of its own
+	if resp, done, err := preconditions(r, requests.Validators{Exists: true}); done {
+		return resp, err
+	}
+
+	// Parse the request
+	var request CreateExampleRequest
+	if err := requests.DecodeBody(r, &request); err != nil {
+		return responses.Response{}, err
+	}
 
-		limit = n
+	// Call the service
+	data, err := c.Service.Add(r.Context(), user.Id, request.Content())
+	if err != nil {
+		return responses.Response{}, err
 	}
 
-	// Page for pagination
-	queryPage := r.URL.Query().Get("page")
+	// Write thru cache
+	validators := validatorsFromExample(data, exampleMediaType(r))
+	c.cacheVersion(r.Context(), data.Id, data.Version)
+
+	return responses.Created(NewCreateExampleResponseFromExample(data), validatorHeaders(validators)...), nil
+}
+
+// MARK: PATCH
+/*
+Media types PATCH accepts, plain JSON is a PatchExampleRequest
+*/
+func acceptPatch() responses.Header {
+	return responses.AcceptPatch(requests.MediaTypeJson, requests.MediaTypeMergePatch, requests.MediaTypeJsonPatch)
+}
 
-	var page int = 1
-	if queryPage != "" {
-		n, err := strconv.Atoi(queryPage)
+/*
+Reads the patch in the format named by Content-Type
+*/
+func loadPatch(r *http.Request) (Patch, error) {
+	// An unparseable Content-Type is unsupported too
+	mediaType, _ := requests.MediaType(r)
+
+	switch mediaType {
+	case requests.MediaTypeJson:
+		var request PatchExampleRequest
+		if err := requests.DecodeBody(r, &request); err != nil {
+			return nil, err
+		}
 
+		return request.Changes(), nil
+	case requests.MediaTypeMergePatch:
+		patch, err := requests.LoadMergePatch(r)
 		if err != nil {
-			responses.WriteBadRequestResponse(w, errInvalidPage)
-			return
+			return nil, fmt.Errorf("%w: %w", requests.InvalidPatchError, err)
 		}
 
-		// Pages are 1-based
-		// Sending in a page number less than 1 results in an error
-		if n < 1 {
-			responses.WriteBadRequestResponse(w, errPageOutOfRange)
-			return
+		return MergePatchRequest{Patch: patch}, nil
+	case requests.MediaTypeJsonPatch:
+		patch, err := requests.LoadJSONPatch(r)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", requests.InvalidPatchError, err)
 		}
 
-		page = n
+		return JSONPatchRequest{Patch: patch}, nil
+	default:
+		return nil, requests.UnsupportedMediaTypeError
 	}
+}
 
-	// Call the service
-	data, err := c.Service.List(r.Context(), user.Id, limit, page)
-	if err != nil {
-		switch {
-		// Client error - dont log as an error
-		case errors.Is(err, limitToLargeError):
-			responses.WriteBadRequestResponse(w, err.Error())
-			return
-		// Client error - dont log as an error
-		case errors.Is(err, RepositoryListError):
-			responses.WriteInternalServerErrorResponse(w)
-			return
-		default:
-			slog.LogAttrs(
-				r.Context(),
-				slog.LevelError,
-				msgServiceError,
-				slog.String(keyError, err.Error()),
-			)
+func (c Controller) Patch(r *http.Request) (responses.Response, error) {
+	id, err := requests.LoadPathValue(r, pathValId)
+	if err != nil {
+		return responses.Response{}, err
+	}
 
-			responses.WriteInternalServerErrorResponse(w)
-			return
-		}
+	user, err := requestingUser(r)
+	if err != nil {
+		return responses.Response{}, err
 	}
 
-	resp := NewListExampleResponseFromExamples(data)
-	respBytes, err := json.Marshal(resp)
+	// Conditional update, the version check happens in the database
+	// so it holds even when the cache is cold
+	version, err := c.writePreconditions(r, user.Id, id)
 	if err != nil {
-		slog.LogAttrs(
-			r.Context(),
-			slog.LevelError,
-			msgJsonMarshallError,
-			slog.String(keyError, err.Error()),
-		)
+		return responses.Response{}, err
+	}
 
-		responses.WriteInternalServerErrorResponse(w)
-		return
+	patch, err := loadPatch(r)
+	if err != nil {
+		// Tells the client which formats it can send instead
+		return responses.Response{Headers: responses.Headers{acceptPatch()}}, err
 	}
 
-	// Content-Digest
-	digest := responses.CalculateContentDigest(&respBytes)
+	data, err := c.Service.Update(r.Context(), user.Id, id, patch, version)
+	if err != nil {
+		return responses.Response{}, err
+	}
 
-	// Write the response
-	responses.WriteSuccessResponse(
-		w,
-		&respBytes,
-		&responses.Headers{
-			responses.NoCachePrivate(),
-			responses.ContentDigest(digest, responses.SHA256),
-		},
-	)
+	// Write thru cache
+	validators := validatorsFromExample(data, exampleMediaType(r))
+	c.cacheVersion(r.Context(), id, data.Version)
 
+	return responses.OK(NewPatchExampleResponseFromExample(data), validatorHeaders(validators)...), nil
 }
 
-// MARK: CREATE (POST)
-func (c Controller) Create(w http.ResponseWriter, r *http.Request) {
-	user, ok := middleware.UserFromContext(r.Context())
-	if !ok {
-		slog.LogAttrs(
-			r.Context(),
-			slog.LevelError,
-			msgServiceError,
-			slog.Any(keyError, errMissingUser),
-		)
-		responses.WriteInternalServerErrorResponse(w)
-		return
+// MARK: PUT
+/*
+If-None-Match: * only creates and any If-Match only replaces
+*/
+func putCondition(r *http.Request) PutCondition {
+	switch {
+	case r.Header.Get(requests.IfNoneMatch.Name()) == "*":
+		return CreateOnly
+	case r.Header.Get(requests.IfMatch.Name()) != "":
+		return ReplaceOnly
+	default:
+		return CreateOrReplace
 	}
+}
 
-	// Parse the request
-	var request CreateExampleRequest
-	if err := requests.LoadRequestBody(w, r, &request); err != nil {
-		return
+/*
+Creates or replaces an example at an id the client chose, so systems that
+own their ids can sync without keeping ours
+*/
+func (c Controller) Put(r *http.Request) (responses.Response, error) {
+	id, err := requests.LoadPathValue(r, pathValId)
+	if err != nil {
+		return responses.Response{}, err
 	}
 
-	// Call the service
-	data, err := c.Service.Add(r.Context(), user.Id, request.Message)
-	if err != nil {
-		slog.LogAttrs(
-			r.Context(),
-			slog.LevelError,
-			msgServiceError,
-			slog.String(keyError, err.Error()),
-		)
+	// Ids are stored as UUIDs
+	if _, err := uuid.Parse(id); err != nil {
+		return responses.Response{}, invalidIdError
+	}
 
-		switch {
-		case errors.Is(err, InvalidMessageError{}):
-			responses.WriteBadRequestResponse(w, err.Error())
-			return
-		case errors.Is(err, repositoryAddError):
-			responses.WriteInternalServerErrorResponse(w)
-			return
-		default:
-			responses.WriteInternalServerErrorResponse(w)
-			return
-		}
+	user, err := requestingUser(r)
+	if err != nil {
+		return responses.Response{}, err
 	}
 
-	// Create the response bytes
-	resp := NewCreateExampleResponseFromExample(data)
-	respBytes, err := json.Marshal(resp)
+	version, err := c.writePreconditions(r, user.Id, id)
 	if err != nil {
-		slog.LogAttrs(
-			r.Context(),
-			slog.LevelError,
-			msgJsonMarshallError,
-			slog.String(keyError, err.Error()),
-		)
+		return responses.Response{}, err
+	}
 
-		responses.WriteInternalServerErrorResponse(w)
-		return
+	var request PutExampleRequest
+	if err := requests.DecodeBody(r, &request); err != nil {
+		return responses.Response{}, err
+	}
+
+	data, created, err := c.Service.Put(r.Context(), user.Id, id, request.Replacement(), putCondition(r), version)
+	if err != nil {
+		return responses.Response{}, err
 	}
 
 	// Write thru cache
-	cacheKey, err := c.Cache.Set(r.Context(), data.Id, &respBytes)
+	validators := validatorsFromExample(data, exampleMediaType(r))
+	c.cacheVersion(r.Context(), id, data.Version)
+
+	resp := NewPutExampleResponseFromExample(data)
+	if created {
+		return responses.Created(resp, validatorHeaders(validators)...), nil
+	}
+
+	return responses.OK(resp, validatorHeaders(validators)...), nil
+}
+
+// MARK: DELETE
+func (c Controller) Delete(r *http.Request) (responses.Response, error) {
+	user, err := requestingUser(r)
 	if err != nil {
-		slog.LogAttrs(
-			r.Context(),
-			slog.LevelError,
-			cacheSetError,
-			slog.Any(keyError, err),
-		)
+		return responses.Response{}, err
+	}
 
-		responses.WriteInternalServerErrorResponse(w)
-		return
+	id, err := requests.LoadPathValue(r, pathValId)
+	if err != nil {
+		return responses.Response{}, err
 	}
 
-	// Content-Digest
-	digest := responses.CalculateContentDigest(&respBytes)
+	version, err := c.writePreconditions(r, user.Id, id)
+	if err != nil {
+		return responses.Response{}, err
+	}
 
-	// Write response
-	responses.WriteCreatedResponse(
-		w,
-		&respBytes,
-		&responses.Headers{
-			responses.NoCachePrivate(),
-			responses.Etag(cacheKey),
-			responses.ContentDigest(digest, responses.SHA256),
-		},
-	)
+	if err := c.Service.Delete(r.Context(), user.Id, id, version); err != nil {
+		return responses.Response{}, err
+	}
+
+	// Clear the cache
+	c.Cache.Delete(r.Context(), id)
+
+	return responses.NoContent(responses.NoCachePrivate()), nil
 }
 
-// MARK: PATCH
-func (c Controller) Patch(w http.ResponseWriter, r *http.Request) {
+// MARK: RESTORE
+func (c Controller) Restore(r *http.Request) (responses.Response, error) {
+	user, err := requestingUser(r)
+	if err != nil {
+		return responses.Response{}, err
+	}
+
 	id, err := requests.LoadPathValue(r, pathValId)
 	if err != nil {
-		responses.WriteInternalServerErrorResponse(w)
-		return
+		return responses.Response{}, err
 	}
 
-	user, ok := middleware.UserFromContext(r.Context())
-	if !ok {
-		slog.LogAttrs(
-			r.Context(),
-			slog.LevelError,
-			msgServiceError,
-			slog.Any(keyError, errMissingUser),
-		)
-		responses.WriteInternalServerErrorResponse(w)
-		return
+	data, err := c.Service.Restore(r.Context(), user.Id, id)
+	if err != nil {
+		return responses.Response{}, err
 	}
 
-	// Cache condition
-	etag := r.Header.Get(requests.IfMatch.Name())
+	// Write thru cache
+	validators := validatorsFromExample(data, exampleMediaType(r))
+	c.cacheVersion(r.Context(), id, data.Version)
 
-	if len(etag) != 0 {
-		condition := cache.NewMatch(c.Cache)
+	return responses.OK(NewGetExampleResponseFromExample(data), validatorHeaders(validators)...), nil
+}
 
-		slog.LogAttrs(
-			r.Context(),
-			slog.LevelInfo,
-			cacheCheckConditionalUpdate,
-			slog.String(etagLog, etag),
-		)
+// MARK: REVISIONS
+/*
+Reads the revision path value, it must be a revision number
+*/
+func revisionPathValue(r *http.Request) (int, error) {
+	value, err := requests.LoadPathValue(r, pathValRevision)
+	if err != nil {
+		return 0, err
+	}
 
-		// Cache miss - precondition failed
-		if ok := condition.Met(r.Context(), id, etag); !ok {
-			slog.LogAttrs(
-				r.Context(),
-				slog.LevelInfo,
-				cacheMissConditionalUpdate,
-				slog.String(etagLog, etag),
-			)
+	revision, err := strconv.Atoi(value)
+	if err != nil || revision < 1 {
+		return 0, invalidRevisionError
+	}
 
-			responses.WritePreflightConditionFailedResponse(w)
-			return
-		} else {
-			slog.LogAttrs(
-				r.Context(),
-				slog.LevelInfo,
-				cacheHitConditionalUpdate,
-				slog.String(etagLog, etag),
-			)
-		}
+	return revision, nil
+}
+
+func (c Controller) ListRevisions(r *http.Request) (responses.Response, error) {
+	user, err := requestingUser(r)
+	if err != nil {
+		return responses.Response{}, err
 	}
 
-	var request PatchExampleRequest
-	if err := requests.LoadRequestBody(w, r, &request); err != nil {
-		return
+	id, err := requests.LoadPathValue(r, pathValId)
+	if err != nil {
+		return responses.Response{}, err
 	}
 
-	data, err := c.Service.Update(r.Context(), user.Id, id, request.Message)
+	pagination, err := requests.GetCursorPaginationParameters(r, c.Cursors)
+	if err != nil {
+		return responses.Response{}, err
+	}
 
+	data, err := c.Service.ListRevisions(r.Context(), user.Id, id, pagination)
 	if err != nil {
+		return responses.Response{}, err
+	}
 
-		switch {
-		case errors.Is(err, repositoryNotFoundError):
-			// Log client errors as info
-			slog.LogAttrs(
-				r.Context(),
-				slog.LevelInfo,
-				msgNotFound,
-				slog.String(keyError, err.Error()),
-			)
+	resp := NewListRevisionResponseFromPage(data, pagination, c.Cursors)
+	headers := responses.Headers{responses.NoCachePrivate()}
 
-			responses.WriteNotFoundResponse(w)
-			return
-		case errors.Is(err, InvalidMessageError{}):
-			responses.WriteBadRequestResponse(w, err.Error())
-			return
-		case errors.Is(err, repositoryAddError):
-			slog.LogAttrs(
-				r.Context(),
-				slog.LevelError,
-				msgServiceError,
-				slog.String(keyError, err.Error()),
-			)
+	if links, ok := responses.PageLinks(r.URL, resp.NextCursor, resp.PrevCursor); ok {
+		headers = append(headers, links)
+	}
 
-			responses.WriteInternalServerErrorResponse(w)
-			return
-		default:
-			slog.LogAttrs(
-				r.Context(),
-				slog.LevelError,
-				msgServiceError,
-				slog.String(keyError, err.Error()),
-			)
+	return responses.OK(resp, headers...), nil
+}
 
-			responses.WriteInternalServerErrorResponse(w)
-			return
-		}
+func (c Controller) GetRevision(r *http.Request) (responses.Response, error) {
+	user, err := requestingUser(r)
+	if err != nil {
+		return responses.Response{}, err
 	}
 
-	// Create response
-	resp := NewPatchExampleResponseFromExample(data)
-	respBytes, err := json.Marshal(resp)
+	id, err := requests.LoadPathValue(r, pathValId)
+	if err != nil {
+		return responses.Response{}, err
+	}
 
+	revision, err := revisionPathValue(r)
 	if err != nil {
-		slog.LogAttrs(
-			r.Context(),
-			slog.LevelError,
-			msgInternalServerError,
-			slog.String(keyError, err.Error()),
-		)
+		return responses.Response{}, err
+	}
 
-		responses.WriteInternalServerErrorResponse(w)
-		return
+	data, err := c.Service.GetRevision(r.Context(), user.Id, id, revision)
+	if err != nil {
+		return responses.Response{}, err
 	}
 
-	// Write thru cache
-	cacheKey, err := c.Cache.Set(r.Context(), id, &respBytes)
+	// A revision never changes, its number is its ETag like a version
+	return responses.OK(NewRevisionResponseFromRevision(data), validatorHeaders(requests.Validators{
+		ETag:         versionEtag(data.Revision, exampleMediaType(r)),
+		LastModified: data.CreatedAt,
+	})...), nil
+}
 
+func (c Controller) Revert(r *http.Request) (responses.Response, error) {
+	user, err := requestingUser(r)
 	if err != nil {
-		slog.LogAttrs(
-			r.Context(),
-			slog.LevelError,
-			cacheSetError,
-			slog.Any(keyError, err),
-		)
+		return responses.Response{}, err
+	}
 
-		responses.WriteInternalServerErrorResponse(w)
-		return
+	id, err := requests.LoadPathValue(r, pathValId)
+	if err != nil {
+		return responses.Response{}, err
 	}
 
-	// Content-Digest
-	digest := responses.CalculateContentDigest(&respBytes)
+	revision, err := revisionPathValue(r)
+	if err != nil {
+		return responses.Response{}, err
+	}
 
-	responses.WriteSuccessResponse(
-		w,
-		&respBytes,
-		&responses.Headers{
-			responses.NoCachePrivate(),
-			responses.Etag(cacheKey),
-			responses.ContentDigest(digest, responses.SHA256),
-		},
-	)
+	// Reverting is an update, so it honours If-Match like a patch
+	version, err := c.writePreconditions(r, user.Id, id)
+	if err != nil {
+		return responses.Response{}, err
+	}
+
+	// A validation error means the revision was written under rules that have since changed
+	data, err := c.Service.Revert(r.Context(), user.Id, id, revision, version)
+	if err != nil {
+		return responses.Response{}, err
+	}
+
+	// Write thru cache
+	validators := validatorsFromExample(data, exampleMediaType(r))
+	c.cacheVersion(r.Context(), id, data.Version)
+
+	return responses.OK(NewGetExampleResponseFromExample(data), validatorHeaders(validators)...), nil
 }
 
-// MARK: DELETE
-func (c Controller) Delete(w http.ResponseWriter, r *http.Request) {
-	user, ok := middleware.UserFromContext(r.Context())
-	if !ok {
-		slog.LogAttrs(
-			r.Context(),
-			slog.LevelError,
-			msgServiceError,
-			slog.Any(keyError, errMissingUser),
-		)
-		responses.WriteInternalServerErrorResponse(w)
-		return
+// MARK: BATCH
+/*
+The status and body an operation would have had as a request of its own
+*/
+func newBatchResultResponse(op Operation, result OperationResult) BatchResultResponse {
+	switch {
+	case result.Err == nil && op.Kind == DeleteOperation:
+		return BatchResultResponse{Status: http.StatusNoContent}
+	case result.Err == nil:
+		resp := NewGetExampleResponseFromExample(result.Item)
+		status := http.StatusOK
+		if op.Kind == CreateOperation {
+			status = http.StatusCreated
+		}
+
+		return BatchResultResponse{Status: status, Example: &resp}
+	default:
+		problem, _ := problems.Classify(result.Err)
+		return BatchResultResponse{Status: problem.Status, Error: problem.Code, Details: problem.Errors}
 	}
+}
 
-	if id, err := requests.LoadPathValue(r, pathValId); err != nil {
-		slog.LogAttrs(
-			r.Context(),
-			slog.LevelError,
-			msgControllerError,
-			slog.String(errKey, err.Error()),
-		)
+/*
+Runs many creates, updates and deletes in one request
 
-		responses.WriteInternalServerErrorResponse(w)
-		return
-	} else {
-		err := c.Service.Delete(r.Context(), user.Id, id)
+Every operation gets the status it would have had on its own. The response is
+a 200 when they all succeeded and a 207 when any failed.
+*/
+func (c Controller) Batch(r *http.Request) (responses.Response, error) {
+	user, err := requestingUser(r)
+	if err != nil {
+		return responses.Response{}, err
+	}
 
-		if err != nil {
-			switch {
-			case errors.Is(err, repositoryNotFoundError):
-				// Log client errors as info
-				slog.LogAttrs(
-					r.Context(),
-					slog.LevelInfo,
-					msgNotFound,
-					slog.String(keyError, err.Error()),
-				)
-
-				responses.WriteNotFoundResponse(w)
-				return
-			default:
-				slog.LogAttrs(
-					r.Context(),
-					slog.LevelError,
-					msgServiceError,
-					slog.String(keyError, err.Error()),
-				)
-
-				responses.WriteInternalServerErrorResponse(w)
-				return
-			}
+	var request BatchRequest
+	if err := requests.DecodeBody(r, &request); err != nil {
+		return responses.Response{}, err
+	}
+
+	ops := request.Batch()
+	results, err := c.Service.Batch(r.Context(), user.Id, ops, request.Atomic())
+	if err != nil {
+		return responses.Response{}, err
+	}
+
+	resp := BatchResponse{Results: make([]BatchResultResponse, 0, len(results))}
+	failed := false
+
+	for i, result := range results {
+		resp.Results = append(resp.Results, newBatchResultResponse(ops[i], result))
+		failed = failed || result.Err != nil
+
+		// ETags of changed examples are dropped, GET caches them again
+		if result.Err == nil && ops[i].Kind != CreateOperation {
+			c.Cache.Delete(r.Context(), ops[i].Id)
 		}
+	}
 
-		// Clear the cache
-		c.Cache.Delete(r.Context(), id)
+	if failed {
+		return responses.MultiStatus(resp, responses.NoCachePrivate()), nil
 	}
 
-	responses.WriteNoContentResponse(w, &responses.Headers{
-		responses.NoCachePrivate(),
-	})
+	return responses.OK(resp, responses.NoCachePrivate()), nil
+}
+
+// MARK: USAGE
+/*
+What the requesting user is storing and their quota
+*/
+func (c Controller) Usage(r *http.Request) (responses.Response, error) {
+	user, err := requestingUser(r)
+	if err != nil {
+		return responses.Response{}, err
+	}
+
+	usage, quota, err := c.Service.Usage(r.Context(), user.Id)
+	if err != nil {
+		return responses.Response{}, err
+	}
+
+	return responses.OK(NewUsageResponse(usage, quota), responses.NoCachePrivate()), nil
 }
@@ -3,17 +3,26 @@ package exampleservice
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/moonmoon1919/go-api-reference/internal/bus"
 	"github.com/moonmoon1919/go-api-reference/internal/cache"
 	"github.com/moonmoon1919/go-api-reference/internal/middleware"
+	"github.com/moonmoon1919/go-api-reference/internal/requests"
+	"github.com/moonmoon1919/go-api-reference/internal/responses"
+	"github.com/moonmoon1919/go-api-reference/internal/store"
+	"github.com/moonmoon1919/go-api-reference/pkg/example"
+	"github.com/moonmoon1919/go-api-reference/pkg/users"
 )
 
 type path struct {
@@ -64,7 +73,7 @@ func TestControllerGet(t *testing.T) {
 	cache := cache.NewInMemoryCache()
 	repo := NewInMemoryExampleRepository()
 	b := bus.NewFake()
-	service := Service{Store: repo, Bus: b}
+	service := Service{Store: repo, Bus: b, Quota: users.NoQuota()}
 	controller := Controller{Service: service, Cache: cache}
 
 	for _, tc := range tests {
@@ -74,19 +83,30 @@ func TestControllerGet(t *testing.T) {
 
 			// If the expected status is not found, dont precreate the object
 			if tc.expectedStatus != http.StatusNotFound {
-				d, _ := service.Add(context.TODO(), tc.userId, tc.message)
+				d, _ := service.Add(context.TODO(), tc.userId, Content{Message: tc.message})
 				expected = NewGetExampleResponseFromExample(d)
 				id = d.Id
 			}
 
 			tc.request.SetPathValue("id", id)
 
-			controller.Get(tc.responseWriter, tc.request)
+			Handle(controller.Get)(tc.responseWriter, tc.request)
 
 			if tc.responseWriter.Code != tc.expectedStatus {
 				t.Errorf("expected status code to be %d, got %d", tc.expectedStatus, tc.responseWriter.Code)
 			}
 
+			if tc.expectedStatus == http.StatusNotFound {
+				var problem responses.Problem
+				json.Unmarshal(tc.responseWriter.Body.Bytes(), &problem)
+
+				if problem.Code != responses.NOT_FOUND || problem.Status != http.StatusNotFound {
+					t.Errorf("expected a not found problem, got %+v", problem)
+				}
+
+				return
+			}
+
 			var actual GetExampleResponse
 			json.Unmarshal(tc.responseWriter.Body.Bytes(), &actual)
 
@@ -102,7 +122,7 @@ func TestControllerGet(t *testing.T) {
 				nuRequest.Header.Set("If-None-Match", etag)
 				nuRequest.SetPathValue("id", id)
 
-				controller.Get(nuWriter, nuRequest)
+				Handle(controller.Get)(nuWriter, nuRequest)
 
 				if nuWriter.Code != http.StatusNotModified {
 					t.Errorf("expected not modified status code, got %d", nuWriter.Code)
@@ -143,7 +163,7 @@ func TestControllerCreate(t *testing.T) {
 	cache := cache.NewInMemoryCache()
 	repo := NewInMemoryExampleRepository()
 	b := bus.NewFake()
-	service := Service{Store: repo, Bus: b}
+	service := Service{Store: repo, Bus: b, Quota: users.NoQuota()}
 	controller := Controller{Service: service, Cache: cache}
 
 	for _, tc := range tests {
@@ -155,7 +175,7 @@ func TestControllerCreate(t *testing.T) {
 			})
 			r := tc.request.WithContext(ctx)
 
-			controller.Create(tc.responseWriter, r)
+			Handle(controller.Create)(tc.responseWriter, r)
 
 			if tc.responseWriter.Code != tc.expectedStatus {
 				t.Errorf("expected status code to be %d, got %d", tc.expectedStatus, tc.responseWriter.Code)
@@ -164,6 +184,271 @@ func TestControllerCreate(t *testing.T) {
 	}
 }
 
+func TestControllerQuota(t *testing.T) {
+	// Given
+	service := Service{Store: NewInMemoryExampleRepository(), Bus: bus.NewFake(), Quota: users.Quota{Examples: 1, Bytes: 100}}
+	controller := Controller{Service: service, Cache: cache.NewInMemoryCache()}
+	ctx := middleware.ContextWithUser(context.Background(), middleware.RequestingUser{Id: "123"})
+
+	for _, expectedStatus := range []int{http.StatusCreated, http.StatusForbidden} {
+		w := httptest.NewRecorder()
+
+		// When
+		Handle(controller.Create)(w, httptest.NewRequest(http.MethodPost, "/examples", strings.NewReader(`{"message": "yay"}`)).WithContext(ctx))
+
+		// Then
+		if w.Code != expectedStatus {
+			t.Errorf("expected status code to be %d, got %d", expectedStatus, w.Code)
+		}
+
+		if expectedStatus == http.StatusForbidden && !strings.Contains(w.Body.String(), responses.QUOTA_EXCEEDED) {
+			t.Errorf("expected %s, got %s", responses.QUOTA_EXCEEDED, w.Body.String())
+		}
+	}
+
+	// When
+	w := httptest.NewRecorder()
+	Handle(controller.Usage)(w, httptest.NewRequest(http.MethodGet, "/me/usage", nil).WithContext(ctx))
+
+	// Then
+	var resp UsageResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+
+	expected := UsageResponse{Examples: 1, Bytes: 3, Quota: QuotaResponse{Examples: 1, Bytes: 100}}
+	if w.Code != http.StatusOK || resp != expected {
+		t.Errorf("expected %+v, got %d %+v", expected, w.Code, resp)
+	}
+}
+
+func TestControllerQuotaOnWrite(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		handle func(c Controller) responses.Handler
+	}{
+		{
+			name:   "FailingCase-Patch",
+			method: http.MethodPatch,
+			path:   "/examples/%s",
+			body:   `{"message": "much longer than before"}`,
+			handle: func(c Controller) responses.Handler { return c.Patch },
+		},
+		{
+			name:   "FailingCase-Restore",
+			method: http.MethodPost,
+			path:   "/examples/%s/restore",
+			handle: func(c Controller) responses.Handler { return c.Restore },
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			service := Service{Store: NewInMemoryExampleRepository(), Bus: bus.NewFake(), Quota: users.Quota{Examples: 1, Bytes: 10}}
+			controller := Controller{Service: service, Cache: cache.NewInMemoryCache()}
+
+			item, _ := service.Add(context.TODO(), "123", Content{Message: "yay"})
+
+			// Trashing the example makes room for another, restoring it doesn't fit
+			if tc.method == http.MethodPost {
+				service.Delete(context.TODO(), "123", item.Id, 0)
+				service.Add(context.TODO(), "123", Content{Message: "other"})
+			}
+
+			request := httptest.NewRequest(tc.method, fmt.Sprintf(tc.path, item.Id), strings.NewReader(tc.body))
+			request.SetPathValue("id", item.Id)
+			ctx := middleware.ContextWithUser(request.Context(), middleware.RequestingUser{Id: "123"})
+			w := httptest.NewRecorder()
+
+			// When
+			Handle(tc.handle(controller))(w, request.WithContext(ctx))
+
+			// Then
+			if w.Code != http.StatusForbidden {
+				t.Errorf("expected status code to be %d, got %d", http.StatusForbidden, w.Code)
+			}
+
+			if !strings.Contains(w.Body.String(), responses.QUOTA_EXCEEDED) {
+				t.Errorf("expected %s, got %s", responses.QUOTA_EXCEEDED, w.Body.String())
+			}
+		})
+	}
+}
+
+/*
+A cache that is always down
+*/
+type unavailableCache struct{}
+
+func (u unavailableCache) Set(ctx context.Context, key, val string) error {
+	return cache.CircuitOpenError
+}
+
+func (u unavailableCache) Get(ctx context.Context, key string) (string, bool) {
+	return "", false
+}
+
+func (u unavailableCache) Delete(ctx context.Context, key string) error {
+	return cache.CircuitOpenError
+}
+
+func TestControllerCacheUnavailable(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		body           string
+		expectedStatus int
+	}{
+		{
+			name:           "PassingCase-Create",
+			method:         http.MethodPost,
+			body:           `{"message": "yay"}`,
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:           "PassingCase-Patch",
+			method:         http.MethodPatch,
+			body:           `{"message": "nay"}`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "PassingCase-Get",
+			method:         http.MethodGet,
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	repo := NewInMemoryExampleRepository()
+	b := bus.NewFake()
+	service := Service{Store: repo, Bus: b, Quota: users.NoQuota()}
+	controller := Controller{Service: service, Cache: unavailableCache{}}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			item, _ := service.Add(context.TODO(), "123", Content{Message: "initial"})
+
+			request := httptest.NewRequest(tc.method, fmt.Sprintf("/examples/%s", item.Id), strings.NewReader(tc.body))
+			request.SetPathValue("id", item.Id)
+			request.Header.Set("If-None-Match", "stale")
+
+			ctx := middleware.ContextWithUser(request.Context(), middleware.RequestingUser{
+				Id:          "123",
+				Role:        middleware.AdministratorRole,
+				Permissions: middleware.NewPermissionSet([]string{"example::read", "example::create", "example::delete"}),
+			})
+			r := request.WithContext(ctx)
+			w := httptest.NewRecorder()
+
+			// When
+			switch tc.method {
+			case http.MethodPost:
+				Handle(controller.Create)(w, r)
+			case http.MethodPatch:
+				Handle(controller.Patch)(w, r)
+			default:
+				Handle(controller.Get)(w, r)
+			}
+
+			// Then
+			if w.Code != tc.expectedStatus {
+				t.Errorf("expected status code to be %d, got %d", tc.expectedStatus, w.Code)
+			}
+
+			// ETags come from the version, they don't depend on the cache
+			if etag := w.Header().Get("Etag"); etag == "" {
+				t.Errorf("expected an etag when the cache is unavailable")
+			}
+		})
+	}
+}
+
+func TestControllerGetColdCache(t *testing.T) {
+	// Given
+	repo := NewInMemoryExampleRepository()
+	b := bus.NewFake()
+	service := Service{Store: repo, Bus: b, Quota: users.NoQuota()}
+	controller := Controller{Service: service, Cache: cache.NewInMemoryCache()}
+
+	item, _ := service.Add(context.TODO(), "123", Content{Message: "initial"})
+
+	request := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/examples/%s", item.Id), nil)
+	request.SetPathValue("id", item.Id)
+	request.Header.Set("If-None-Match", `"1"`)
+	w := httptest.NewRecorder()
+
+	// When
+	Handle(controller.Get)(w, request)
+
+	// Then
+	if w.Code != http.StatusNotModified {
+		t.Errorf("expected status code to be %d, got %d", http.StatusNotModified, w.Code)
+	}
+}
+
+func TestControllerGetConditional(t *testing.T) {
+	tests := []struct {
+		name           string
+		headers        map[string]string
+		expectedStatus int
+	}{
+		{
+			name:           "PassingCase-IfNoneMatchList",
+			headers:        map[string]string{"If-None-Match": `"7", W/"1"`},
+			expectedStatus: http.StatusNotModified,
+		},
+		{
+			name:           "PassingCase-IfNoneMatchWildcard",
+			headers:        map[string]string{"If-None-Match": "*"},
+			expectedStatus: http.StatusNotModified,
+		},
+		{
+			name:           "PassingCase-IfModifiedSince",
+			headers:        map[string]string{"If-Modified-Since": time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)},
+			expectedStatus: http.StatusNotModified,
+		},
+		{
+			name:           "PassingCase-Modified",
+			headers:        map[string]string{"If-Modified-Since": time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "FailingCase-IfMatchStale",
+			headers:        map[string]string{"If-Match": `"2"`},
+			expectedStatus: http.StatusPreconditionFailed,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			repo := NewInMemoryExampleRepository()
+			b := bus.NewFake()
+			service := Service{Store: repo, Bus: b, Quota: users.NoQuota()}
+			controller := Controller{Service: service, Cache: cache.NewInMemoryCache()}
+
+			item, _ := service.Add(context.TODO(), "123", Content{Message: "initial"})
+
+			request := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/examples/%s", item.Id), nil)
+			request.SetPathValue("id", item.Id)
+			for k, v := range tc.headers {
+				request.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+
+			// When
+			Handle(controller.Get)(w, request)
+
+			// Then
+			if w.Code != tc.expectedStatus {
+				t.Errorf("expected status code to be %d, got %d", tc.expectedStatus, w.Code)
+			}
+		})
+	}
+}
+
 // MARK: PATCH
 func TestControllerPatch(t *testing.T) {
 	tests := []struct {
@@ -193,12 +478,30 @@ func TestControllerPatch(t *testing.T) {
 			expectedStatus:  http.StatusPreconditionFailed,
 			validateThruGet: false,
 		},
+		{
+			name:            "Precondition failed - stale version",
+			responseWriter:  httptest.NewRecorder(),
+			initialValue:    "dude",
+			updatedValue:    "sweet",
+			etagVal:         `"2"`,
+			expectedStatus:  http.StatusPreconditionFailed,
+			validateThruGet: false,
+		},
+		{
+			name:            "Precondition failed - weak etag",
+			responseWriter:  httptest.NewRecorder(),
+			initialValue:    "dude",
+			updatedValue:    "sweet",
+			etagVal:         `W/"1"`,
+			expectedStatus:  http.StatusPreconditionFailed,
+			validateThruGet: false,
+		},
 	}
 
 	cache := cache.NewInMemoryCache()
 	repo := NewInMemoryExampleRepository()
 	b := bus.NewFake()
-	service := Service{Store: repo, Bus: b}
+	service := Service{Store: repo, Bus: b, Quota: users.NoQuota()}
 	controller := Controller{Service: service, Cache: cache}
 
 	for _, tc := range tests {
@@ -215,7 +518,7 @@ func TestControllerPatch(t *testing.T) {
 			})
 			ir := initialRequest.WithContext(ctx)
 
-			controller.Create(initialResponseWriter, ir)
+			Handle(controller.Create)(initialResponseWriter, ir)
 
 			if initialResponseWriter.Code != 201 {
 				t.Errorf("expected status 201, got %d when putting initial data", initialResponseWriter.Code)
@@ -246,7 +549,7 @@ func TestControllerPatch(t *testing.T) {
 				request.Header.Set("If-Match", etag)
 			}
 
-			controller.Patch(tc.responseWriter, patchRequest)
+			Handle(controller.Patch)(tc.responseWriter, patchRequest)
 
 			if tc.responseWriter.Code != tc.expectedStatus {
 				t.Errorf("expected status code to be %d, got %d", tc.expectedStatus, tc.responseWriter.Code)
@@ -264,99 +567,660 @@ func TestControllerPatch(t *testing.T) {
 	}
 }
 
-// MARK: DELETE
-func TestControllerDelete(t *testing.T) {
+func TestControllerCreateValidation(t *testing.T) {
+	// Given
+	service := Service{Store: NewInMemoryExampleRepository(), Bus: bus.NewFake(), Quota: users.NoQuota()}
+	controller := Controller{Service: service, Cache: cache.NewInMemoryCache()}
+
+	body := fmt.Sprintf(`{"message": "%s", "tags": ["Fine", "not fine"]}`, strings.Repeat("m", 4097))
+	request := httptest.NewRequest(http.MethodPost, "/examples", strings.NewReader(body))
+	ctx := middleware.ContextWithUser(request.Context(), middleware.RequestingUser{
+		Id:          "123",
+		Role:        middleware.AdministratorRole,
+		Permissions: middleware.NewPermissionSet([]string{"example::read", "example::create", "example::delete"}),
+	})
+	w := httptest.NewRecorder()
+
+	// When
+	Handle(controller.Create)(w, request.WithContext(ctx))
+
+	// Then
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected status code to be %d, got %d", http.StatusUnprocessableEntity, w.Code)
+	}
+
+	if contentType := w.Header().Get("Content-Type"); contentType != "application/problem+json" {
+		t.Errorf("expected a problem, got %s", contentType)
+	}
+
+	var actual responses.Problem
+	json.Unmarshal(w.Body.Bytes(), &actual)
+
+	expected := []responses.FieldError{
+		{Field: "message", Code: example.CodeTooLong, Message: "message length must be at most 4096"},
+		{Field: "tags", Code: example.CodeInvalidTag, Message: "tags must be 1-32 lowercase letters, digits or dashes"},
+	}
+
+	if actual.Code != responses.VALIDATION_FAILED || !reflect.DeepEqual(actual.Errors, expected) {
+		t.Errorf("expected %v, got %+v", expected, actual)
+	}
+}
+
+func TestControllerProblems(t *testing.T) {
 	tests := []struct {
 		name           string
-		body           string
-		responseWriter *httptest.ResponseRecorder
+		target         string
 		expectedStatus int
-		validate       bool
+		expectedCode   string
+		expectedDetail string
 	}{
 		{
-			name:           "PassingCase",
-			body:           `{"message": "dude, sweet"}`,
-			responseWriter: httptest.NewRecorder(),
-			expectedStatus: http.StatusNoContent,
-			validate:       true,
+			name:           "LimitTooLarge",
+			target:         "/examples?limit=51",
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   errLimitTooLarge,
+			expectedDetail: "maximum limit is 50",
+		},
+		{
+			name:           "InvalidLimit",
+			target:         "/examples?limit=ten",
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   errInvalidLimit,
+			expectedDetail: "limit must be an integer",
+		},
+		{
+			name:           "UnsupportedOperator",
+			target:         "/examples?message[gt]=a",
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   errUnsupportedOperator,
+			expectedDetail: "operator is not supported for filter",
+		},
+		{
+			name:           "InvalidCursor",
+			target:         "/examples?cursor=forged",
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   errInvalidCursor,
+			expectedDetail: "cursor is invalid",
 		},
 	}
 
-	cache := cache.NewInMemoryCache()
-	repo := NewInMemoryExampleRepository()
-	b := bus.NewFake()
-	service := Service{Store: repo, Bus: b}
-	controller := Controller{Service: service, Cache: cache}
+	controller := Controller{Service: Service{Store: NewInMemoryExampleRepository(), Bus: bus.NewFake(), Quota: users.NoQuota()}, Cache: cache.NewInMemoryCache()}
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
 			// Given
-			initialResponseWriter := httptest.NewRecorder()
-			initialRequest := httptest.NewRequest(http.MethodPost, "/examples", strings.NewReader(tc.body))
+			request := httptest.NewRequest(http.MethodGet, tc.target, nil)
+			ctx := middleware.ContextWithUser(request.Context(), middleware.RequestingUser{Id: uuid.NewString()})
+			w := httptest.NewRecorder()
 
-			ctx := middleware.ContextWithUser(initialRequest.Context(), middleware.RequestingUser{
-				Id:          "123",
-				Role:        middleware.AdministratorRole,
-				Permissions: middleware.NewPermissionSet([]string{"example::read", "example::create", "example::delete"}),
-			})
-			ir := initialRequest.WithContext(ctx)
+			// When
+			Handle(controller.List)(w, request.WithContext(ctx))
 
-			controller.Create(initialResponseWriter, ir)
+			// Then
+			var problem responses.Problem
+			json.Unmarshal(w.Body.Bytes(), &problem)
 
-			if initialResponseWriter.Code != http.StatusCreated {
-				t.Errorf("expected status 201, got %d when putting initial data", initialResponseWriter.Code)
+			if w.Code != tc.expectedStatus || problem.Status != tc.expectedStatus {
+				t.Errorf("expected status %d, got %d", tc.expectedStatus, w.Code)
 			}
 
-			var response CreateExampleResponse
-			if err := json.NewDecoder(initialResponseWriter.Body).Decode(&response); err != nil {
-				t.Errorf("error decoding create example response, %d", err)
+			if problem.Code != tc.expectedCode || problem.Detail != tc.expectedDetail {
+				t.Errorf("expected %s %q, got %s %q", tc.expectedCode, tc.expectedDetail, problem.Code, problem.Detail)
 			}
+		})
+	}
+}
+
+func TestProblems(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		expectedStatus int
+		expectedCode   string
+		expectedLevel  slog.Level
+	}{
+		{name: "Validation", err: example.ValidationError{Violations: example.Rules{example.NotEmpty()}.Check("message", "")}, expectedStatus: http.StatusUnprocessableEntity, expectedCode: responses.VALIDATION_FAILED, expectedLevel: slog.LevelInfo},
+		{name: "InvalidBody", err: requests.DecodeError{Err: requests.InvalidBodyError()}, expectedStatus: http.StatusBadRequest, expectedCode: "INVALID_REQUEST_BODY", expectedLevel: slog.LevelInfo},
+		{name: "PreconditionFailed", err: preconditionFailedError, expectedStatus: http.StatusPreconditionFailed, expectedCode: responses.PRECONDITION_FAILED, expectedLevel: slog.LevelInfo},
+		{name: "Conflict", err: repositoryConflictError, expectedStatus: http.StatusConflict, expectedCode: responses.CONFLICT, expectedLevel: slog.LevelInfo},
+		{name: "NotFound", err: repositoryNotFoundError, expectedStatus: http.StatusNotFound, expectedCode: responses.NOT_FOUND, expectedLevel: slog.LevelInfo},
+		{name: "RevisionNotFound", err: revisionNotFoundServiceError, expectedStatus: http.StatusNotFound, expectedCode: responses.NOT_FOUND, expectedLevel: slog.LevelInfo},
+		{name: "OverQuota", err: overQuotaError, expectedStatus: http.StatusForbidden, expectedCode: responses.QUOTA_EXCEEDED, expectedLevel: slog.LevelInfo},
+		{name: "NotApplied", err: notAppliedError, expectedStatus: http.StatusFailedDependency, expectedCode: responses.FAILED_DEPENDENCY, expectedLevel: slog.LevelInfo},
+		{name: "LimitTooLarge", err: limitToLargeError, expectedStatus: http.StatusBadRequest, expectedCode: errLimitTooLarge, expectedLevel: slog.LevelInfo},
+		{name: "BatchTooLarge", err: batchTooLargeError, expectedStatus: http.StatusBadRequest, expectedCode: errBatchTooLarge, expectedLevel: slog.LevelInfo},
+		{name: "InvalidId", err: invalidIdError, expectedStatus: http.StatusBadRequest, expectedCode: errInvalidId, expectedLevel: slog.LevelInfo},
+		{name: "InvalidRevision", err: invalidRevisionError, expectedStatus: http.StatusBadRequest, expectedCode: errInvalidRevision, expectedLevel: slog.LevelInfo},
+		{name: "CursorSortMismatch", err: store.CursorSortMismatchError, expectedStatus: http.StatusBadRequest, expectedCode: errCursorSortMismatch, expectedLevel: slog.LevelInfo},
+		{name: "UnsupportedMediaType", err: requests.UnsupportedMediaTypeError, expectedStatus: http.StatusUnsupportedMediaType, expectedCode: responses.UNSUPPORTED_MEDIA, expectedLevel: slog.LevelInfo},
+		{name: "InvalidPatch", err: requests.InvalidPatchError, expectedStatus: http.StatusBadRequest, expectedCode: errInvalidPatch, expectedLevel: slog.LevelInfo},
+		{name: "PatchTestFailed", err: requests.PatchTestFailedError, expectedStatus: http.StatusConflict, expectedCode: responses.PATCH_TEST_FAILED, expectedLevel: slog.LevelInfo},
+		{name: "PatchNotApplicable", err: requests.PatchNotApplicableError, expectedStatus: http.StatusUnprocessableEntity, expectedCode: responses.PATCH_NOT_APPLICABLE, expectedLevel: slog.LevelInfo},
+		{name: "InvalidLimit", err: requests.InvalidLimitError, expectedStatus: http.StatusBadRequest, expectedCode: errInvalidLimit, expectedLevel: slog.LevelInfo},
+		{name: "LimitTooLow", err: requests.LimitTooLowError, expectedStatus: http.StatusBadRequest, expectedCode: errLimitOutOfRange, expectedLevel: slog.LevelInfo},
+		{name: "InvalidPage", err: requests.InvalidPageError, expectedStatus: http.StatusBadRequest, expectedCode: errInvalidPage, expectedLevel: slog.LevelInfo},
+		{name: "PageTooLow", err: requests.PageTooLowError, expectedStatus: http.StatusBadRequest, expectedCode: errPageOutOfRange, expectedLevel: slog.LevelInfo},
+		{name: "InvalidTotal", err: requests.InvalidTotalError, expectedStatus: http.StatusBadRequest, expectedCode: errInvalidTotal, expectedLevel: slog.LevelInfo},
+		{name: "CursorWithPage", err: requests.CursorWithPageError, expectedStatus: http.StatusBadRequest, expectedCode: errCursorWithPage, expectedLevel: slog.LevelInfo},
+		{name: "InvalidCursor", err: requests.InvalidCursorError, expectedStatus: http.StatusBadRequest, expectedCode: errInvalidCursor, expectedLevel: slog.LevelInfo},
+		{name: "UnknownFilter", err: requests.UnknownFilterError, expectedStatus: http.StatusBadRequest, expectedCode: errUnknownFilter, expectedLevel: slog.LevelInfo},
+		{name: "UnsupportedOperator", err: requests.UnsupportedOperatorError, expectedStatus: http.StatusBadRequest, expectedCode: errUnsupportedOperator, expectedLevel: slog.LevelInfo},
+		{name: "InvalidFilterValue", err: requests.InvalidFilterValueError, expectedStatus: http.StatusBadRequest, expectedCode: errInvalidFilterValue, expectedLevel: slog.LevelInfo},
+		{name: "TooManyFilters", err: requests.TooManyFiltersError, expectedStatus: http.StatusBadRequest, expectedCode: errTooManyFilters, expectedLevel: slog.LevelInfo},
+		{name: "UnknownSort", err: requests.UnknownSortError, expectedStatus: http.StatusBadRequest, expectedCode: errUnknownSort, expectedLevel: slog.LevelInfo},
+		{name: "Wrapped", err: fmt.Errorf("%w: %s", preconditionFailedError, `"1"`), expectedStatus: http.StatusPreconditionFailed, expectedCode: responses.PRECONDITION_FAILED, expectedLevel: slog.LevelInfo},
+		{name: "MissingUser", err: missingUserError, expectedStatus: http.StatusInternalServerError, expectedCode: responses.INTERNAL_SERVER_ERROR, expectedLevel: slog.LevelError},
+		{name: "Unregistered", err: errors.New("connection refused"), expectedStatus: http.StatusInternalServerError, expectedCode: responses.INTERNAL_SERVER_ERROR, expectedLevel: slog.LevelError},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			err := tc.err
 
 			// When
-			request := httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/examples/%s", response.Id), nil)
-			request.SetPathValue("id", response.Id)
-			nuCtx := middleware.ContextWithUser(request.Context(), middleware.RequestingUser{
+			problem, level := problems.Classify(err)
+
+			// Then
+			if problem.Status != tc.expectedStatus || problem.Code != tc.expectedCode {
+				t.Errorf("expected %d %s, got %d %s", tc.expectedStatus, tc.expectedCode, problem.Status, problem.Code)
+			}
+
+			if level != tc.expectedLevel {
+				t.Errorf("expected level %s, got %s", tc.expectedLevel, level)
+			}
+		})
+	}
+}
+
+func TestControllerPatchStatus(t *testing.T) {
+	tests := []struct {
+		name              string
+		body              string
+		expectedStatus    int
+		expectedLifecycle string
+	}{
+		{
+			name:              "PassingCase-Archive",
+			body:              `{"status": "archived"}`,
+			expectedStatus:    http.StatusOK,
+			expectedLifecycle: "archived",
+		},
+		{
+			name:           "FailingCase-BackToDraft",
+			body:           `{"status": "draft"}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:           "FailingCase-InvalidTag",
+			body:           `{"tags": ["not valid"]}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+	}
+
+	service := Service{Store: NewInMemoryExampleRepository(), Bus: bus.NewFake(), Quota: users.NoQuota()}
+	controller := Controller{Service: service, Cache: cache.NewInMemoryCache()}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			item, _ := service.Add(context.TODO(), "123", Content{Message: "initial"})
+			service.Update(context.TODO(), "123", item.Id, Changes{Status: example.Published}, 0)
+
+			request := httptest.NewRequest(http.MethodPatch, fmt.Sprintf("/examples/%s", item.Id), strings.NewReader(tc.body))
+			request.SetPathValue("id", item.Id)
+			ctx := middleware.ContextWithUser(request.Context(), middleware.RequestingUser{
 				Id:          "123",
 				Role:        middleware.AdministratorRole,
 				Permissions: middleware.NewPermissionSet([]string{"example::read", "example::create", "example::delete"}),
 			})
-			deleteRequest := request.WithContext(nuCtx)
+			w := httptest.NewRecorder()
 
-			controller.Delete(tc.responseWriter, deleteRequest)
+			// When
+			Handle(controller.Patch)(w, request.WithContext(ctx))
 
 			// Then
-			if tc.responseWriter.Code != tc.expectedStatus {
-				t.Errorf("expected status code to be %d, got %d", tc.expectedStatus, tc.responseWriter.Code)
+			if w.Code != tc.expectedStatus {
+				t.Errorf("expected status code to be %d, got %d", tc.expectedStatus, w.Code)
 			}
 
-			if tc.validate {
-				// Value is no longer in repo
-				_, err := repo.Get(context.TODO(), response.Id)
-
-				if err == nil {
-					t.Errorf("expected repository to return that item was not found, item found")
-				}
-
-				// Value is no longer in cache
-				_, ok := cache.Get(context.TODO(), response.Id)
+			if tc.expectedLifecycle != "" {
+				var response PatchExampleResponse
+				json.Unmarshal(w.Body.Bytes(), &response)
 
-				if ok {
-					t.Errorf("expected item to no longer be in cache, item in cache")
+				if response.Status != tc.expectedLifecycle || response.Message != "initial" {
+					t.Errorf("expected a %s example with its message kept, got %+v", tc.expectedLifecycle, response)
 				}
 			}
 		})
 	}
 }
 
-// MARK: LIST
-func TestControllerList(t *testing.T) {
+func TestControllerPatchDocuments(t *testing.T) {
 	tests := []struct {
 		name           string
-		responseWriter *httptest.ResponseRecorder
-		request        *http.Request
+		contentType    string
+		body           string
+		expectedStatus int
+		expectedTitle  string
+		expectedTags   []string
+	}{
+		{
+			name:           "PassingCase-MergePatch",
+			contentType:    "application/merge-patch+json",
+			body:           `{"title": null, "tags": ["go"]}`,
+			expectedStatus: http.StatusOK,
+			expectedTitle:  "",
+			expectedTags:   []string{"go"},
+		},
+		{
+			name:           "PassingCase-JSONPatch",
+			contentType:    "application/json-patch+json",
+			body:           `[{"op": "test", "path": "/title", "value": "first"}, {"op": "replace", "path": "/title", "value": "second"}, {"op": "add", "path": "/tags/-", "value": "api"}]`,
+			expectedStatus: http.StatusOK,
+			expectedTitle:  "second",
+			expectedTags:   []string{"api", "go"},
+		},
+		{
+			name:           "FailingCase-TestFailed",
+			contentType:    "application/json-patch+json",
+			body:           `[{"op": "test", "path": "/title", "value": "other"}, {"op": "replace", "path": "/title", "value": "second"}]`,
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:           "FailingCase-UnknownField",
+			contentType:    "application/merge-patch+json",
+			body:           `{"owner": "someone-else"}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:           "FailingCase-PathNotFound",
+			contentType:    "application/json-patch+json",
+			body:           `[{"op": "remove", "path": "/tags/5"}]`,
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:           "FailingCase-DomainValidation",
+			contentType:    "application/merge-patch+json",
+			body:           `{"message": null}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:           "FailingCase-MalformedPatch",
+			contentType:    "application/json-patch+json",
+			body:           `[{"op": "merge", "path": "/title"}]`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "FailingCase-UnsupportedMediaType",
+			contentType:    "text/plain",
+			body:           `title=second`,
+			expectedStatus: http.StatusUnsupportedMediaType,
+		},
+	}
+
+	service := Service{Store: NewInMemoryExampleRepository(), Bus: bus.NewFake(), Quota: users.NoQuota()}
+	controller := Controller{Service: service, Cache: cache.NewInMemoryCache()}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			item, _ := service.Add(context.TODO(), "123", Content{Title: "first", Message: "initial", Tags: []string{"go"}})
+
+			request := httptest.NewRequest(http.MethodPatch, fmt.Sprintf("/examples/%s", item.Id), strings.NewReader(tc.body))
+			request.SetPathValue("id", item.Id)
+			request.Header.Set("Content-Type", tc.contentType)
+			ctx := middleware.ContextWithUser(request.Context(), middleware.RequestingUser{
+				Id:          "123",
+				Role:        middleware.AdministratorRole,
+				Permissions: middleware.NewPermissionSet([]string{"example::read", "example::create", "example::delete"}),
+			})
+			w := httptest.NewRecorder()
+
+			// When
+			Handle(controller.Patch)(w, request.WithContext(ctx))
+
+			// Then
+			if w.Code != tc.expectedStatus {
+				t.Errorf("expected status code to be %d, got %d", tc.expectedStatus, w.Code)
+			}
+
+			if tc.expectedStatus == http.StatusUnsupportedMediaType && !strings.Contains(w.Header().Get("Accept-Patch"), "application/merge-patch+json") {
+				t.Errorf("expected Accept-Patch to list merge patch, got %s", w.Header().Get("Accept-Patch"))
+			}
+
+			stored, _ := service.Get(context.TODO(), item.Id)
+			if tc.expectedStatus != http.StatusOK {
+				if stored.Version != item.Version {
+					t.Errorf("expected a rejected patch to leave version %d, got %d", item.Version, stored.Version)
+				}
+				return
+			}
+
+			if stored.Title != tc.expectedTitle || !slices.Equal(stored.Tags, tc.expectedTags) || stored.Message != "initial" {
+				t.Errorf("expected title %q and tags %v with the message kept, got %+v", tc.expectedTitle, tc.expectedTags, stored)
+			}
+		})
+	}
+}
+
+// MARK: PUT
+func TestControllerPut(t *testing.T) {
+	tests := []struct {
+		name           string
+		exists         bool
+		owner          string
+		id             string
+		headers        map[string]string
+		body           string
+		expectedStatus int
+	}{
+		{
+			name:           "PassingCase-Create",
+			body:           `{"title": "synced", "message": "hello"}`,
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:           "PassingCase-Replace",
+			exists:         true,
+			body:           `{"title": "synced", "message": "hello"}`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "PassingCase-ReplaceMatchingEtag",
+			exists:         true,
+			headers:        map[string]string{"If-Match": `"1"`},
+			body:           `{"message": "hello"}`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "FailingCase-CreateOnlyExists",
+			exists:         true,
+			headers:        map[string]string{"If-None-Match": "*"},
+			body:           `{"message": "hello"}`,
+			expectedStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:           "FailingCase-ReplaceOnlyMissing",
+			headers:        map[string]string{"If-Match": "*"},
+			body:           `{"message": "hello"}`,
+			expectedStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:           "FailingCase-OtherUsersExample",
+			exists:         true,
+			owner:          "456",
+			body:           `{"message": "hello"}`,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "FailingCase-OtherUsersExampleCreateOnly",
+			exists:         true,
+			owner:          "456",
+			headers:        map[string]string{"If-None-Match": "*"},
+			body:           `{"message": "hello"}`,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "FailingCase-MissingMessage",
+			body:           `{"title": "synced"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "FailingCase-IdNotUUID",
+			id:             "not-a-uuid",
+			body:           `{"message": "hello"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	service := Service{Store: NewInMemoryExampleRepository(), Bus: bus.NewFake(), Quota: users.NoQuota()}
+	controller := Controller{Service: service, Cache: cache.NewInMemoryCache()}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			id := tc.id
+			if id == "" {
+				id = uuid.NewString()
+			}
+
+			owner := tc.owner
+			if owner == "" {
+				owner = "123"
+			}
+
+			if tc.exists {
+				service.Put(context.TODO(), owner, id, Replacement{Content: Content{Message: "existing"}}, CreateOnly, 0)
+			}
+
+			request := httptest.NewRequest(http.MethodPut, fmt.Sprintf("/examples/%s", id), strings.NewReader(tc.body))
+			request.SetPathValue("id", id)
+			for k, v := range tc.headers {
+				request.Header.Set(k, v)
+			}
+
+			ctx := middleware.ContextWithUser(request.Context(), middleware.RequestingUser{
+				Id:          "123",
+				Role:        middleware.AdministratorRole,
+				Permissions: middleware.NewPermissionSet([]string{"example::read", "example::create", "example::delete"}),
+			})
+			w := httptest.NewRecorder()
+
+			// When
+			Handle(controller.Put)(w, request.WithContext(ctx))
+
+			// Then
+			if w.Code != tc.expectedStatus {
+				t.Errorf("expected status code to be %d, got %d", tc.expectedStatus, w.Code)
+			}
+
+			if w.Code == http.StatusOK || w.Code == http.StatusCreated {
+				var response PutExampleResponse
+				json.Unmarshal(w.Body.Bytes(), &response)
+
+				if response.Id != id || response.Message != "hello" {
+					t.Errorf("expected example %s to hold the request, got %+v", id, response)
+				}
+			}
+
+			// Nothing the caller sent reached another user's example
+			if stored, err := service.Get(context.TODO(), id); tc.owner != "" && (err != nil || stored.Message != "existing") {
+				t.Errorf("expected the other user's example to be left alone, got %+v", stored)
+			}
+		})
+	}
+}
+
+// MARK: DELETE
+func TestControllerDelete(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		ifMatch        string
+		responseWriter *httptest.ResponseRecorder
+		expectedStatus int
+		validate       bool
+	}{
+		{
+			name:           "PassingCase",
+			body:           `{"message": "dude, sweet"}`,
+			responseWriter: httptest.NewRecorder(),
+			expectedStatus: http.StatusNoContent,
+			validate:       true,
+		},
+		{
+			name:           "PassingCase-IfMatch",
+			body:           `{"message": "dude, sweet"}`,
+			ifMatch:        `"1"`,
+			responseWriter: httptest.NewRecorder(),
+			expectedStatus: http.StatusNoContent,
+			validate:       true,
+		},
+		{
+			name:           "FailingCase-IfMatchStale",
+			body:           `{"message": "dude, sweet"}`,
+			ifMatch:        `"2"`,
+			responseWriter: httptest.NewRecorder(),
+			expectedStatus: http.StatusPreconditionFailed,
+			validate:       false,
+		},
+	}
+
+	cache := cache.NewInMemoryCache()
+	repo := NewInMemoryExampleRepository()
+	b := bus.NewFake()
+	service := Service{Store: repo, Bus: b, Quota: users.NoQuota()}
+	controller := Controller{Service: service, Cache: cache}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			initialResponseWriter := httptest.NewRecorder()
+			initialRequest := httptest.NewRequest(http.MethodPost, "/examples", strings.NewReader(tc.body))
+
+			ctx := middleware.ContextWithUser(initialRequest.Context(), middleware.RequestingUser{
+				Id:          "123",
+				Role:        middleware.AdministratorRole,
+				Permissions: middleware.NewPermissionSet([]string{"example::read", "example::create", "example::delete"}),
+			})
+			ir := initialRequest.WithContext(ctx)
+
+			Handle(controller.Create)(initialResponseWriter, ir)
+
+			if initialResponseWriter.Code != http.StatusCreated {
+				t.Errorf("expected status 201, got %d when putting initial data", initialResponseWriter.Code)
+			}
+
+			var response CreateExampleResponse
+			if err := json.NewDecoder(initialResponseWriter.Body).Decode(&response); err != nil {
+				t.Errorf("error decoding create example response, %d", err)
+			}
+
+			// When
+			request := httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/examples/%s", response.Id), nil)
+			request.SetPathValue("id", response.Id)
+			nuCtx := middleware.ContextWithUser(request.Context(), middleware.RequestingUser{
+				Id:          "123",
+				Role:        middleware.AdministratorRole,
+				Permissions: middleware.NewPermissionSet([]string{"example::read", "example::create", "example::delete"}),
+			})
+			deleteRequest := request.WithContext(nuCtx)
+
+			if len(tc.ifMatch) != 0 {
+				deleteRequest.Header.Set("If-Match", tc.ifMatch)
+			}
+
+			Handle(controller.Delete)(tc.responseWriter, deleteRequest)
+
+			// Then
+			if tc.responseWriter.Code != tc.expectedStatus {
+				t.Errorf("expected status code to be %d, got %d", tc.expectedStatus, tc.responseWriter.Code)
+			}
+
+			if tc.validate {
+				// Value is no longer in repo
+				_, err := repo.Get(context.TODO(), response.Id)
+
+				if err == nil {
+					t.Errorf("expected repository to return that item was not found, item found")
+				}
+
+				// Value is no longer in cache
+				_, ok := cache.Get(context.TODO(), response.Id)
+
+				if ok {
+					t.Errorf("expected item to no longer be in cache, item in cache")
+				}
+			}
+		})
+	}
+}
+
+func TestControllerOtherUsersExample(t *testing.T) {
+	tests := []struct {
+		name    string
+		method  string
+		body    string
+		ifMatch string
+		handle  func(c Controller) responses.Handler
+		// What an id that doesn't exist gets
+		expectedStatus int
+	}{
+		{
+			name:           "FailingCase-Patch",
+			method:         http.MethodPatch,
+			body:           `{"message": "mine now"}`,
+			handle:         func(c Controller) responses.Handler { return c.Patch },
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "FailingCase-PatchIfMatch",
+			method:         http.MethodPatch,
+			body:           `{"message": "mine now"}`,
+			ifMatch:        `"1"`,
+			handle:         func(c Controller) responses.Handler { return c.Patch },
+			expectedStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:           "FailingCase-Delete",
+			method:         http.MethodDelete,
+			handle:         func(c Controller) responses.Handler { return c.Delete },
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "FailingCase-DeleteIfMatch",
+			method:         http.MethodDelete,
+			ifMatch:        `"1"`,
+			handle:         func(c Controller) responses.Handler { return c.Delete },
+			expectedStatus: http.StatusPreconditionFailed,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			service := Service{Store: NewInMemoryExampleRepository(), Bus: bus.NewFake(), Quota: users.NoQuota()}
+			controller := Controller{Service: service, Cache: cache.NewInMemoryCache()}
+
+			item, _ := service.Add(context.TODO(), "456", Content{Message: "theirs"})
+
+			request := httptest.NewRequest(tc.method, fmt.Sprintf("/examples/%s", item.Id), strings.NewReader(tc.body))
+			request.SetPathValue("id", item.Id)
+			if tc.ifMatch != "" {
+				request.Header.Set("If-Match", tc.ifMatch)
+			}
+			ctx := middleware.ContextWithUser(request.Context(), middleware.RequestingUser{Id: "123"})
+			w := httptest.NewRecorder()
+
+			// When
+			Handle(tc.handle(controller))(w, request.WithContext(ctx))
+
+			// Then
+			if w.Code != tc.expectedStatus {
+				t.Errorf("expected status code to be %d, got %d", tc.expectedStatus, w.Code)
+			}
+
+			if stored, err := service.Get(context.TODO(), item.Id); err != nil || stored.Message != "theirs" {
+				t.Errorf("expected the other user's example to be unchanged, got %+v %v", stored, err)
+			}
+		})
+	}
+}
+
+// MARK: LIST
+func TestControllerList(t *testing.T) {
+	tests := []struct {
+		name           string
+		responseWriter *httptest.ResponseRecorder
+		request        *http.Request
 		expectedStatus int
 		userId         string
 		numItems       int
+		expectedItems  int
+		expectedLink   string
+		expectedMore   bool
+		expectedTotal  *responses.Total
 	}{
 		{
 			name:           "PassingCase-50-items",
@@ -365,6 +1229,62 @@ func TestControllerList(t *testing.T) {
 			expectedStatus: http.StatusOK,
 			userId:         uuid.NewString(),
 			numItems:       50,
+			expectedItems:  50,
+		},
+		{
+			name:           "PassingCase-NextPageLink",
+			responseWriter: httptest.NewRecorder(),
+			request:        httptest.NewRequest(http.MethodGet, "/examples?limit=2&page=1", nil),
+			expectedStatus: http.StatusOK,
+			userId:         uuid.NewString(),
+			numItems:       3,
+			expectedItems:  2,
+			expectedLink:   `rel="next"`,
+			expectedMore:   true,
+		},
+		{
+			name:           "PassingCase-ExactTotal",
+			responseWriter: httptest.NewRecorder(),
+			request:        httptest.NewRequest(http.MethodGet, "/examples?limit=2&page=2&total=exact", nil),
+			expectedStatus: http.StatusOK,
+			userId:         uuid.NewString(),
+			numItems:       3,
+			expectedItems:  1,
+			expectedLink:   `rel="prev"`,
+			expectedTotal:  &responses.Total{Count: 3},
+		},
+		{
+			name:           "PassingCase-FilterAndSort",
+			responseWriter: httptest.NewRecorder(),
+			request:        httptest.NewRequest(http.MethodGet, "/examples?message[contains]=-1&sort=-message", nil),
+			expectedStatus: http.StatusOK,
+			userId:         uuid.NewString(),
+			numItems:       12,
+			expectedItems:  3,
+		},
+		{
+			name:           "FailingCase-UnknownFilter",
+			responseWriter: httptest.NewRecorder(),
+			request:        httptest.NewRequest(http.MethodGet, "/examples?uid[eq]=someone", nil),
+			expectedStatus: http.StatusBadRequest,
+			userId:         uuid.NewString(),
+			numItems:       0,
+		},
+		{
+			name:           "FailingCase-UnknownSort",
+			responseWriter: httptest.NewRecorder(),
+			request:        httptest.NewRequest(http.MethodGet, "/examples?sort=version", nil),
+			expectedStatus: http.StatusBadRequest,
+			userId:         uuid.NewString(),
+			numItems:       0,
+		},
+		{
+			name:           "FailingCase-InvalidTotal",
+			responseWriter: httptest.NewRecorder(),
+			request:        httptest.NewRequest(http.MethodGet, "/examples?limit=2&total=all", nil),
+			expectedStatus: http.StatusBadRequest,
+			userId:         uuid.NewString(),
+			numItems:       0,
 		},
 		{
 			name:           "PassingCase-0-items",
@@ -390,6 +1310,22 @@ func TestControllerList(t *testing.T) {
 			userId:         uuid.NewString(),
 			numItems:       0,
 		},
+		{
+			name:           "FailingCase-TamperedCursor",
+			responseWriter: httptest.NewRecorder(),
+			request:        httptest.NewRequest(http.MethodGet, "/examples?limit=50&cursor=eyJrIjoiYSIsImQiOjB9.forged", nil),
+			expectedStatus: http.StatusBadRequest,
+			userId:         uuid.NewString(),
+			numItems:       0,
+		},
+		{
+			name:           "FailingCase-CursorWithPage",
+			responseWriter: httptest.NewRecorder(),
+			request:        httptest.NewRequest(http.MethodGet, "/examples?limit=50&page=2&cursor=eyJrIjoiYSIsImQiOjB9.forged", nil),
+			expectedStatus: http.StatusBadRequest,
+			userId:         uuid.NewString(),
+			numItems:       0,
+		},
 	}
 
 	for _, tc := range tests {
@@ -398,8 +1334,8 @@ func TestControllerList(t *testing.T) {
 		cache := cache.NewInMemoryCache()
 		repo := NewInMemoryExampleRepository()
 		b := bus.NewFake()
-		service := Service{Store: repo, Bus: b}
-		controller := Controller{Service: service, Cache: cache}
+		service := Service{Store: repo, Bus: b, Quota: users.NoQuota()}
+		controller := Controller{Service: service, Cache: cache, Cursors: requests.NewCursorCodec("secret")}
 
 		t.Run(tc.name, func(t *testing.T) {
 			// Given
@@ -411,11 +1347,11 @@ func TestControllerList(t *testing.T) {
 			requestWithContext := tc.request.WithContext(ctx)
 
 			for i := range tc.numItems {
-				service.Add(context.TODO(), tc.userId, fmt.Sprintf("message-%d", i))
+				service.Add(context.TODO(), tc.userId, Content{Message: fmt.Sprintf("message-%d", i)})
 			}
 
 			// When
-			controller.List(tc.responseWriter, requestWithContext)
+			Handle(controller.List)(tc.responseWriter, requestWithContext)
 
 			// Then
 			if tc.responseWriter.Code != tc.expectedStatus {
@@ -426,8 +1362,471 @@ func TestControllerList(t *testing.T) {
 				var actual ListExampleResponse
 				json.Unmarshal(tc.responseWriter.Body.Bytes(), &actual)
 
-				if len(actual.Items) != tc.numItems {
-					t.Errorf("expected %d items, found %d", tc.numItems, len(actual.Items))
+				if len(actual.Items) != tc.expectedItems {
+					t.Errorf("expected %d items, found %d", tc.expectedItems, len(actual.Items))
+				}
+
+				if actual.HasMore != tc.expectedMore {
+					t.Errorf("expected has_more %t, got %t", tc.expectedMore, actual.HasMore)
+				}
+
+				if !reflect.DeepEqual(actual.Total, tc.expectedTotal) {
+					t.Errorf("expected total %+v, got %+v", tc.expectedTotal, actual.Total)
+				}
+
+				if link := tc.responseWriter.Header().Get("Link"); !strings.Contains(link, tc.expectedLink) || (tc.expectedLink == "" && link != "") {
+					t.Errorf("expected Link header with %s, got %s", tc.expectedLink, link)
+				}
+			}
+		})
+	}
+}
+
+func TestControllerListNegotiation(t *testing.T) {
+	tests := []struct {
+		name           string
+		accept         string
+		expectedStatus int
+		expectedType   string
+	}{
+		{
+			name:           "PassingCase-Default",
+			accept:         "",
+			expectedStatus: http.StatusOK,
+			expectedType:   "application/json",
+		},
+		{
+			name:           "PassingCase-Cbor",
+			accept:         "application/cbor",
+			expectedStatus: http.StatusOK,
+			expectedType:   "application/cbor",
+		},
+		{
+			name:           "PassingCase-MessagePack",
+			accept:         "application/msgpack, application/json;q=0.5",
+			expectedStatus: http.StatusOK,
+			expectedType:   "application/msgpack",
+		},
+		{
+			name:           "PassingCase-Ndjson",
+			accept:         "application/x-ndjson",
+			expectedStatus: http.StatusOK,
+			expectedType:   "application/x-ndjson",
+		},
+		{
+			name:           "FailingCase-NotAcceptable",
+			accept:         "text/html",
+			expectedStatus: http.StatusNotAcceptable,
+			expectedType:   "application/problem+json",
+		},
+	}
+
+	userId := uuid.NewString()
+	service := Service{Store: NewInMemoryExampleRepository(), Bus: bus.NewFake(), Quota: users.NoQuota()}
+	controller := Controller{Service: service, Cache: cache.NewInMemoryCache(), Cursors: requests.NewCursorCodec("secret")}
+
+	for i := range 3 {
+		service.Add(context.TODO(), userId, Content{Message: fmt.Sprintf("message-%d", i)})
+	}
+
+	etags := make(map[string]string)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			request := httptest.NewRequest(http.MethodGet, "/examples", nil)
+			request.Header.Set("Accept", tc.accept)
+			ctx := middleware.ContextWithUser(request.Context(), middleware.RequestingUser{Id: userId})
+			w := httptest.NewRecorder()
+
+			// When
+			Handle(controller.List)(w, request.WithContext(ctx))
+
+			// Then
+			if w.Code != tc.expectedStatus {
+				t.Fatalf("expected status code to be %d, got %d", tc.expectedStatus, w.Code)
+			}
+
+			if contentType := w.Header().Get("Content-Type"); contentType != tc.expectedType {
+				t.Errorf("expected Content-Type %s, got %s", tc.expectedType, contentType)
+			}
+
+			if tc.expectedStatus != http.StatusOK {
+				return
+			}
+
+			body := w.Body.Bytes()
+			if digest := w.Header().Get("Content-Digest"); digest != "sha-256="+responses.CalculateContentDigest(&body) {
+				t.Errorf("expected Content-Digest of the body that was sent, got %s", digest)
+			}
+
+			if vary := w.Header().Get("Vary"); vary != "Accept" {
+				t.Errorf("expected Vary: Accept, got %s", vary)
+			}
+
+			// Each representation has its own weak ETag
+			etag := w.Header().Get("Etag")
+			for other, otherEtag := range etags {
+				if otherEtag == etag {
+					t.Errorf("expected a different ETag from %s, got %s", other, etag)
+				}
+			}
+			etags[tc.expectedType] = etag
+
+			if tc.expectedType == "application/x-ndjson" && strings.Count(string(body), "\n") != 3 {
+				t.Errorf("expected a line for each of 3 items, got %q", body)
+			}
+
+			// The ETag is only current for the same representation
+			conditional := httptest.NewRequest(http.MethodGet, "/examples", nil)
+			conditional.Header.Set("Accept", tc.accept)
+			conditional.Header.Set("If-None-Match", etag)
+			cw := httptest.NewRecorder()
+
+			Handle(controller.List)(cw, conditional.WithContext(ctx))
+
+			if cw.Code != http.StatusNotModified {
+				t.Errorf("expected status code to be %d, got %d", http.StatusNotModified, cw.Code)
+			}
+		})
+	}
+}
+
+func TestControllerGetNegotiation(t *testing.T) {
+	tests := []struct {
+		name         string
+		accept       string
+		expectedEtag string
+	}{
+		{
+			name:         "PassingCase-Json",
+			accept:       "application/json",
+			expectedEtag: `"1"`,
+		},
+		{
+			name:         "PassingCase-Cbor",
+			accept:       "application/cbor",
+			expectedEtag: `"1-cbor"`,
+		},
+		{
+			name:         "PassingCase-MessagePack",
+			accept:       "application/msgpack",
+			expectedEtag: `"1-msgpack"`,
+		},
+	}
+
+	service := Service{Store: NewInMemoryExampleRepository(), Bus: bus.NewFake(), Quota: users.NoQuota()}
+	controller := Controller{Service: service, Cache: cache.NewInMemoryCache()}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			item, _ := service.Add(context.TODO(), "123", Content{Message: "Hi"})
+
+			get := func(accept, ifNoneMatch string) *httptest.ResponseRecorder {
+				request := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/examples/%s", item.Id), nil)
+				request.SetPathValue("id", item.Id)
+				request.Header.Set("Accept", accept)
+				if ifNoneMatch != "" {
+					request.Header.Set("If-None-Match", ifNoneMatch)
+				}
+
+				w := httptest.NewRecorder()
+				Handle(controller.Get)(w, request)
+				return w
+			}
+
+			// When
+			w := get(tc.accept, "")
+
+			// Then
+			if etag := w.Header().Get("Etag"); etag != tc.expectedEtag {
+				t.Errorf("expected ETag %s, got %s", tc.expectedEtag, etag)
+			}
+
+			// The version is cached now, the ETag only matches its own representation
+			for _, accept := range []string{"application/json", "application/cbor", "application/msgpack"} {
+				expectedStatus := http.StatusOK
+				if accept == tc.accept {
+					expectedStatus = http.StatusNotModified
+				}
+
+				if cw := get(accept, tc.expectedEtag); cw.Code != expectedStatus {
+					t.Errorf("expected status code to be %d for %s, got %d", expectedStatus, accept, cw.Code)
+				}
+			}
+
+			// If-Match is compared with the representation the write responds with
+			for _, accept := range []string{"application/json", "application/cbor"} {
+				expectedStatus := http.StatusPreconditionFailed
+				if accept == tc.accept {
+					expectedStatus = http.StatusOK
+				}
+
+				request := httptest.NewRequest(http.MethodPatch, fmt.Sprintf("/examples/%s", item.Id), strings.NewReader(`{"message": "Bye"}`))
+				request.SetPathValue("id", item.Id)
+				request.Header.Set("Accept", accept)
+				request.Header.Set("If-Match", tc.expectedEtag)
+				ctx := middleware.ContextWithUser(request.Context(), middleware.RequestingUser{Id: "123"})
+				pw := httptest.NewRecorder()
+
+				Handle(controller.Patch)(pw, request.WithContext(ctx))
+
+				if pw.Code != expectedStatus {
+					t.Errorf("expected status code to be %d for %s, got %d", expectedStatus, accept, pw.Code)
+				}
+			}
+		})
+	}
+}
+
+// MARK: RESTORE
+func TestControllerRestore(t *testing.T) {
+	tests := []struct {
+		name           string
+		delete         bool
+		userId         string
+		expectedStatus int
+	}{
+		{
+			name:           "PassingCase",
+			delete:         true,
+			userId:         "123",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "FailingCase-NotInTrash",
+			delete:         false,
+			userId:         "123",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "FailingCase-OtherUser",
+			delete:         true,
+			userId:         "456",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	service := Service{Store: NewInMemoryExampleRepository(), Bus: bus.NewFake(), Quota: users.NoQuota()}
+	controller := Controller{Service: service, Cache: cache.NewInMemoryCache()}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			item, _ := service.Add(context.TODO(), "123", Content{Message: "initial"})
+			if tc.delete {
+				service.Delete(context.TODO(), "123", item.Id, 0)
+			}
+
+			request := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/examples/%s/restore", item.Id), nil)
+			request.SetPathValue("id", item.Id)
+			ctx := middleware.ContextWithUser(request.Context(), middleware.RequestingUser{
+				Id:          tc.userId,
+				Role:        middleware.AdministratorRole,
+				Permissions: middleware.NewPermissionSet([]string{"example::read", "example::create", "example::delete"}),
+			})
+			w := httptest.NewRecorder()
+
+			// When
+			Handle(controller.Restore)(w, request.WithContext(ctx))
+
+			// Then
+			if w.Code != tc.expectedStatus {
+				t.Errorf("expected status code to be %d, got %d", tc.expectedStatus, w.Code)
+			}
+
+			// Deleting and restoring are both writes, so the version moved on twice
+			if tc.expectedStatus == http.StatusOK && w.Header().Get("Etag") != `"3"` {
+				t.Errorf("expected ETag \"3\", got %s", w.Header().Get("Etag"))
+			}
+		})
+	}
+}
+
+// MARK: REVISIONS
+func TestControllerRevisions(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		url            string
+		revision       string
+		handler        func(c Controller) responses.Handler
+		expectedStatus int
+	}{
+		{
+			name:           "PassingCase-List",
+			method:         http.MethodGet,
+			url:            "/examples/%s/revisions",
+			handler:        func(c Controller) responses.Handler { return c.ListRevisions },
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "PassingCase-Get",
+			method:         http.MethodGet,
+			url:            "/examples/%s/revisions/1",
+			revision:       "1",
+			handler:        func(c Controller) responses.Handler { return c.GetRevision },
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "PassingCase-Revert",
+			method:         http.MethodPost,
+			url:            "/examples/%s/revisions/1/revert",
+			revision:       "1",
+			handler:        func(c Controller) responses.Handler { return c.Revert },
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "FailingCase-RevisionNotFound",
+			method:         http.MethodGet,
+			url:            "/examples/%s/revisions/9",
+			revision:       "9",
+			handler:        func(c Controller) responses.Handler { return c.GetRevision },
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "FailingCase-RevisionNotInteger",
+			method:         http.MethodPost,
+			url:            "/examples/%s/revisions/first/revert",
+			revision:       "first",
+			handler:        func(c Controller) responses.Handler { return c.Revert },
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	service := Service{Store: NewInMemoryExampleRepository(), Bus: bus.NewFake(), Quota: users.NoQuota()}
+	controller := Controller{Service: service, Cache: cache.NewInMemoryCache(), Cursors: requests.NewCursorCodec("secret")}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			item, _ := service.Add(context.TODO(), "123", Content{Message: "initial"})
+			service.Update(context.TODO(), "123", item.Id, Changes{Message: "changed"}, 0)
+
+			request := httptest.NewRequest(tc.method, fmt.Sprintf(tc.url, item.Id), nil)
+			request.SetPathValue("id", item.Id)
+			if tc.revision != "" {
+				request.SetPathValue("rev", tc.revision)
+			}
+
+			ctx := middleware.ContextWithUser(request.Context(), middleware.RequestingUser{
+				Id:          "123",
+				Role:        middleware.AdministratorRole,
+				Permissions: middleware.NewPermissionSet([]string{"example::read", "example::create"}),
+			})
+			w := httptest.NewRecorder()
+
+			// When
+			Handle(tc.handler(controller))(w, request.WithContext(ctx))
+
+			// Then
+			if w.Code != tc.expectedStatus {
+				t.Errorf("expected status code to be %d, got %d", tc.expectedStatus, w.Code)
+			}
+		})
+	}
+
+	t.Run("Revert-Content", func(t *testing.T) {
+		// Given
+		item, _ := service.Add(context.TODO(), "123", Content{Message: "initial"})
+		service.Update(context.TODO(), "123", item.Id, Changes{Message: "changed"}, 0)
+
+		request := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/examples/%s/revisions/1/revert", item.Id), nil)
+		request.SetPathValue("id", item.Id)
+		request.SetPathValue("rev", "1")
+		request.Header.Set("If-Match", `"2"`)
+		ctx := middleware.ContextWithUser(request.Context(), middleware.RequestingUser{Id: "123"})
+		w := httptest.NewRecorder()
+
+		// When
+		Handle(controller.Revert)(w, request.WithContext(ctx))
+
+		// Then
+		var resp GetExampleResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+
+		if resp.Message != "initial" {
+			t.Errorf("expected message to be reverted to initial, got %s", resp.Message)
+		}
+
+		if w.Header().Get("Etag") != `"3"` {
+			t.Errorf("expected ETag \"3\", got %s", w.Header().Get("Etag"))
+		}
+	})
+}
+
+// MARK: BATCH
+func TestControllerBatch(t *testing.T) {
+	tests := []struct {
+		name             string
+		body             string
+		expectedStatus   int
+		expectedStatuses []int
+	}{
+		{
+			name:             "PassingCase",
+			body:             `{"operations": [{"op": "create", "message": "one"}, {"op": "update", "id": "{id}", "status": "published"}]}`,
+			expectedStatus:   http.StatusOK,
+			expectedStatuses: []int{http.StatusCreated, http.StatusOK},
+		},
+		{
+			name:             "FailingCase-Atomic",
+			body:             `{"operations": [{"op": "create", "message": "one"}, {"op": "delete", "id": "missing"}]}`,
+			expectedStatus:   http.StatusMultiStatus,
+			expectedStatuses: []int{http.StatusFailedDependency, http.StatusNotFound},
+		},
+		{
+			name:             "FailingCase-BestEffort",
+			body:             `{"mode": "best_effort", "operations": [{"op": "delete", "id": "{id}"}, {"op": "update", "id": "{id}", "message": "gone"}]}`,
+			expectedStatus:   http.StatusMultiStatus,
+			expectedStatuses: []int{http.StatusNoContent, http.StatusNotFound},
+		},
+		{
+			name:           "FailingCase-InvalidOperation",
+			body:           `{"operations": [{"op": "upsert"}]}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	service := Service{Store: NewInMemoryExampleRepository(), Bus: bus.NewFake(), Quota: users.NoQuota()}
+	controller := Controller{Service: service, Cache: cache.NewInMemoryCache()}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			item, _ := service.Add(context.TODO(), "123", Content{Message: "initial"})
+
+			request := httptest.NewRequest(http.MethodPost, "/examples/batch", strings.NewReader(strings.ReplaceAll(tc.body, "{id}", item.Id)))
+			ctx := middleware.ContextWithUser(request.Context(), middleware.RequestingUser{
+				Id:          "123",
+				Role:        middleware.AdministratorRole,
+				Permissions: middleware.NewPermissionSet([]string{"example::create", "example::delete"}),
+			})
+			w := httptest.NewRecorder()
+
+			// When
+			Handle(controller.Batch)(w, request.WithContext(ctx))
+
+			// Then
+			if w.Code != tc.expectedStatus {
+				t.Errorf("expected status code to be %d, got %d", tc.expectedStatus, w.Code)
+			}
+
+			if tc.expectedStatuses == nil {
+				return
+			}
+
+			var resp BatchResponse
+			json.Unmarshal(w.Body.Bytes(), &resp)
+
+			if len(resp.Results) != len(tc.expectedStatuses) {
+				t.Fatalf("expected %d results, got %d", len(tc.expectedStatuses), len(resp.Results))
+			}
+
+			for i, result := range resp.Results {
+				if result.Status != tc.expectedStatuses[i] {
+					t.Errorf("expected operation %d to have status %d, got %d", i, tc.expectedStatuses[i], result.Status)
 				}
 			}
 		})
@@ -9,22 +9,56 @@ import (
 	"github.com/moonmoon1919/go-api-reference/internal/responses"
 )
 
+const (
+	statusOk       = "ok"
+	statusDegraded = "degraded"
+)
+
+/*
+A dependency that reports its status through the health check endpoint
+
+Status returns a human readable state and whether the dependency is healthy
+*/
+type Check interface {
+	Name() string
+	Status() (string, bool)
+}
+
 /*
 Standard health check endpoint
+
+An unhealthy check marks the service as degraded, it does not fail the health check.
+Checks are for dependencies we can run without (e.g., the cache)
 */
 type HealthCheckResponse struct {
-	Status string `json:"status"`
-	Build  string `json:"build"`
+	Status string            `json:"status"`
+	Build  string            `json:"build"`
+	Checks map[string]string `json:"checks,omitempty"`
 }
 
-type HealthController struct{}
+type HealthController struct {
+	Checks []Check
+}
 
 func (h HealthController) Get(w http.ResponseWriter, r *http.Request) {
 	resp := HealthCheckResponse{
-		Status: "ok",
+		Status: statusOk,
 		Build:  build.VERSION,
 	}
 
+	if len(h.Checks) > 0 {
+		resp.Checks = make(map[string]string, len(h.Checks))
+	}
+
+	for _, check := range h.Checks {
+		status, healthy := check.Status()
+		resp.Checks[check.Name()] = status
+
+		if !healthy {
+			resp.Status = statusDegraded
+		}
+	}
+
 	respBytes, err := json.Marshal(resp)
 
 	if err != nil {
@@ -36,6 +70,7 @@ func (h HealthController) Get(w http.ResponseWriter, r *http.Request) {
 		)
 
 		responses.WriteInternalServerErrorResponse(w)
+		return
 	}
 
 	responses.WriteSuccessResponse(w, &respBytes, &responses.Headers{})
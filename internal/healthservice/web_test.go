@@ -36,3 +36,65 @@ func TestHealthController_Get(t *testing.T) {
 		t.Fatalf("expected build to be set, got empty string")
 	}
 }
+
+type fakeCheck struct {
+	status  string
+	healthy bool
+}
+
+func (f fakeCheck) Name() string {
+	return "cache"
+}
+
+func (f fakeCheck) Status() (string, bool) {
+	return f.status, f.healthy
+}
+
+func TestHealthController_GetChecks(t *testing.T) {
+	tests := []struct {
+		name           string
+		check          fakeCheck
+		expectedStatus string
+	}{
+		{
+			name:           "PassingCase-Healthy",
+			check:          fakeCheck{status: "closed", healthy: true},
+			expectedStatus: "ok",
+		},
+		{
+			name:           "PassingCase-Degraded",
+			check:          fakeCheck{status: "open", healthy: false},
+			expectedStatus: "degraded",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// GIVEN
+			wr := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/health", nil)
+			controller := HealthController{Checks: []Check{tc.check}}
+
+			// WHEN
+			controller.Get(wr, req)
+
+			// THEN
+			if wr.Code != http.StatusOK {
+				t.Fatalf("expected status code to be %d, got %d", http.StatusOK, wr.Code)
+			}
+
+			var response HealthCheckResponse
+			if err := json.Unmarshal(wr.Body.Bytes(), &response); err != nil {
+				t.Fatalf("failed to unmarshal response body: %v", err)
+			}
+
+			if response.Status != tc.expectedStatus {
+				t.Errorf("expected status to be %s, got %s", tc.expectedStatus, response.Status)
+			}
+
+			if response.Checks["cache"] != tc.check.status {
+				t.Errorf("expected cache check to be %s, got %s", tc.check.status, response.Checks["cache"])
+			}
+		})
+	}
+}
@@ -0,0 +1,491 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"hash"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/moonmoon1919/go-api-reference/internal/requests"
+	"github.com/moonmoon1919/go-api-reference/internal/responses"
+)
+
+const (
+	AcceptEncodingHeader  = "Accept-Encoding"
+	ContentEncodingHeader = "Content-Encoding"
+	contentLengthHeader   = "Content-Length"
+	trailerHeader         = "Trailer"
+	encodingGzip          = "gzip"
+	encodingDeflate       = "deflate"
+)
+
+// MARK: Compressor
+/*
+A compressed stream, Flush sends what was written so far
+*/
+type CompressWriter interface {
+	io.WriteCloser
+	Flush() error
+}
+
+/*
+A content coding responses can be compressed with, e.g., a Brotli
+implementation can be passed to CompressionMiddleware alongside the defaults
+*/
+type Compressor interface {
+	// The Content-Encoding token, e.g., gzip
+	Encoding() string
+	NewWriter(w io.Writer) CompressWriter
+}
+
+type resetWriter interface {
+	CompressWriter
+	Reset(w io.Writer)
+}
+
+/*
+Compression writers are expensive to allocate so they are reused
+*/
+type pooledCompressor struct {
+	encoding string
+	pool     *sync.Pool
+}
+
+func (c pooledCompressor) Encoding() string {
+	return c.encoding
+}
+
+func (c pooledCompressor) NewWriter(w io.Writer) CompressWriter {
+	cw := c.pool.Get().(resetWriter)
+	cw.Reset(w)
+
+	return &pooledWriter{resetWriter: cw, pool: c.pool}
+}
+
+type pooledWriter struct {
+	resetWriter
+	pool *sync.Pool
+}
+
+func (w *pooledWriter) Close() error {
+	err := w.resetWriter.Close()
+	w.pool.Put(w.resetWriter)
+
+	return err
+}
+
+func NewGzipCompressor() Compressor {
+	return pooledCompressor{
+		encoding: encodingGzip,
+		pool:     &sync.Pool{New: func() any { return gzip.NewWriter(io.Discard) }},
+	}
+}
+
+/*
+HTTP's deflate is the zlib format from RFC 1950, not a raw deflate stream
+*/
+func NewDeflateCompressor() Compressor {
+	return pooledCompressor{
+		encoding: encodingDeflate,
+		pool:     &sync.Pool{New: func() any { return zlib.NewWriter(io.Discard) }},
+	}
+}
+
+/*
+The compressor the client prefers from Accept-Encoding, ties go to the server's order
+
+Returns false when the client accepts none of them, identity is always acceptable
+*/
+func negotiateEncoding(header string, compressors []Compressor) (Compressor, bool) {
+	qualities := make(map[string]float64)
+
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding == "" {
+			continue
+		}
+
+		quality := 1.0
+		if name, value, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(name) == "q" {
+			q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil {
+				continue
+			}
+
+			quality = q
+		}
+
+		qualities[coding] = quality
+	}
+
+	var chosen Compressor
+	best := 0.0
+
+	for _, c := range compressors {
+		q, ok := qualities[c.Encoding()]
+		if !ok {
+			// A wildcard only covers codings that weren't named
+			q = qualities["*"]
+		}
+
+		if q > best {
+			chosen, best = c, q
+		}
+	}
+
+	return chosen, chosen != nil
+}
+
+/*
+Formats that are already compressed, compressing them again only costs CPU
+*/
+func alreadyCompressed(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	switch mediaType {
+	case "application/gzip", "application/zip", "application/zstd", "application/x-bzip2", "application/pdf":
+		return true
+	}
+
+	major, _, _ := strings.Cut(mediaType, "/")
+
+	return major == "image" || major == "video" || major == "audio" || major == "font"
+}
+
+// MARK: compressWriter
+/*
+Where compressed bytes go, they're buffered until the response is complete so
+the digests can be sent as headers, or sent straight away once the handler
+flushes. Everything is hashed on the way through.
+*/
+type digestSink struct {
+	w         io.Writer
+	buf       bytes.Buffer
+	hashes    map[responses.DigestAlgorithm]hash.Hash
+	streaming bool
+}
+
+func newDigestSink(w io.Writer, algs ...responses.DigestAlgorithm) *digestSink {
+	s := &digestSink{w: w, hashes: make(map[responses.DigestAlgorithm]hash.Hash)}
+
+	for _, alg := range algs {
+		if h, ok := responses.NewDigestHash(alg); ok {
+			s.hashes[alg] = h
+		}
+	}
+
+	return s
+}
+
+func (s *digestSink) Write(b []byte) (int, error) {
+	for _, h := range s.hashes {
+		h.Write(b)
+	}
+
+	if s.streaming {
+		return s.w.Write(b)
+	}
+
+	return s.buf.Write(b)
+}
+
+/*
+Sends what was buffered, everything after is written straight through
+*/
+func (s *digestSink) stream() error {
+	s.streaming = true
+	_, err := s.w.Write(s.buf.Bytes())
+	s.buf.Reset()
+
+	return err
+}
+
+func (s *digestSink) digest(alg responses.DigestAlgorithm) string {
+	return responses.EncodeDigest(s.hashes[alg])
+}
+
+/*
+A wrapper around the http.ResponseWriter that compresses the body
+
+Bodies are held until minSize bytes were written, smaller bodies are sent as
+they are. A Flush before that starts compressing straight away.
+*/
+type compressWriter struct {
+	http.ResponseWriter
+	compressor Compressor
+	minSize    int
+	status     int
+	// The digest algorithms the client asked for
+	contentAlg responses.DigestAlgorithm
+	reprAlg    responses.DigestAlgorithm
+	// Identity bytes written before deciding whether to compress
+	pending     bytes.Buffer
+	decided     bool
+	wroteHeader bool
+	cw          CompressWriter
+	sink        *digestSink
+	// The client's If-None-Match, a 304 keeps the coded ETag it has
+	ifNoneMatch string
+}
+
+func (w *compressWriter) WriteHeader(status int) {
+	// Informational responses come before the real one, e.g., 103 Early Hints,
+	// they're passed on without deciding anything
+	if status >= 100 && status < http.StatusOK && status != http.StatusSwitchingProtocols {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+
+	if w.status != 0 {
+		return
+	}
+
+	w.status = status
+
+	// A 304 names the representation the client has, compressed or not
+	if status == http.StatusNotModified && w.compressor != nil {
+		etag := w.Header().Get(responses.EtagKey.Name())
+		if coded := requests.CodedEtag(etag, w.compressor.Encoding()); coded != etag && strings.Contains(w.ifNoneMatch, coded) {
+			w.Header().Set(responses.EtagKey.Name(), coded)
+		}
+	}
+
+	// Switching protocols and bodiless responses go out as they are
+	if status < http.StatusOK || status == http.StatusNoContent || status == http.StatusNotModified {
+		w.decided = true
+	}
+
+	if w.decided {
+		w.sendHeader()
+	}
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	if w.decided {
+		if w.cw != nil {
+			return w.cw.Write(b)
+		}
+
+		w.sendHeader()
+		return w.ResponseWriter.Write(b)
+	}
+
+	n, _ := w.pending.Write(b)
+	if w.pending.Len() >= w.minSize {
+		if err := w.decide(); err != nil {
+			return 0, err
+		}
+	}
+
+	return n, nil
+}
+
+/*
+Sends what was written so far, compressed when it can be
+*/
+func (w *compressWriter) Flush() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	if !w.decided {
+		w.decide()
+	}
+
+	if w.cw != nil {
+		if !w.sink.streaming {
+			w.startStreaming()
+		}
+
+		w.cw.Flush()
+	}
+
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+/*
+Handlers set Vary themselves, so Accept-Encoding is only added as the headers are sent
+*/
+func (w *compressWriter) sendHeader() {
+	if w.wroteHeader {
+		return
+	}
+
+	w.wroteHeader = true
+
+	// Caches must keep a copy per coding, even of responses that weren't compressed
+	w.Header().Add(responses.VaryKey.Name(), AcceptEncodingHeader)
+	w.ResponseWriter.WriteHeader(w.status)
+}
+
+func (w *compressWriter) compressible() bool {
+	header := w.Header()
+
+	return w.compressor != nil &&
+		header.Get(ContentEncodingHeader) == "" &&
+		!alreadyCompressed(header.Get(responses.ContentType.Name()))
+}
+
+/*
+Starts compressing what is pending, or sends it as it is
+*/
+func (w *compressWriter) decide() error {
+	w.decided = true
+
+	if !w.compressible() {
+		w.sendHeader()
+		_, err := w.ResponseWriter.Write(w.pending.Bytes())
+		return err
+	}
+
+	w.sink = newDigestSink(w.ResponseWriter, w.contentAlg, w.reprAlg)
+	w.cw = w.compressor.NewWriter(w.sink)
+
+	_, err := w.cw.Write(w.pending.Bytes())
+	return err
+}
+
+/*
+The headers for the compressed representation, the length and digests of the
+identity representation no longer apply and a strong ETag names the coding
+*/
+func (w *compressWriter) setEncodingHeaders() {
+	header := w.Header()
+	header.Set(ContentEncodingHeader, w.compressor.Encoding())
+	if etag := header.Get(responses.EtagKey.Name()); etag != "" {
+		header.Set(responses.EtagKey.Name(), requests.CodedEtag(etag, w.compressor.Encoding()))
+	}
+	header.Del(contentLengthHeader)
+	header.Del(responses.ContentDigestKey.Name())
+	header.Del(responses.ReprDigestKey.Name())
+}
+
+/*
+The digests can't be known before the body is sent so they're sent as trailers
+*/
+func (w *compressWriter) startStreaming() {
+	w.setEncodingHeaders()
+	w.Header().Add(trailerHeader, responses.ContentDigestKey.Name())
+	w.Header().Add(trailerHeader, responses.ReprDigestKey.Name())
+
+	w.sendHeader()
+	w.sink.stream()
+}
+
+/*
+Finishes the response, called once the handler returns
+*/
+func (w *compressWriter) Close() error {
+	if !w.decided {
+		// Smaller than minSize, not worth compressing
+		w.decided = true
+
+		if w.status == 0 && w.pending.Len() == 0 {
+			return nil
+		}
+
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+
+		w.sendHeader()
+		_, err := w.ResponseWriter.Write(w.pending.Bytes())
+		return err
+	}
+
+	if w.cw == nil {
+		// Nothing was written, the response is still sent with Vary
+		if !w.wroteHeader && w.status != 0 {
+			w.sendHeader()
+		}
+
+		return nil
+	}
+
+	if err := w.cw.Close(); err != nil {
+		return err
+	}
+
+	// Content codings are part of the representation, so for a complete
+	// response the content and representation digests are the same
+	digest := responses.ContentDigest(w.sink.digest(w.contentAlg), w.contentAlg)
+	repr := responses.ReprDigest(w.sink.digest(w.reprAlg), w.reprAlg)
+
+	if w.sink.streaming {
+		w.Header().Set(http.TrailerPrefix+digest.Key(), digest.Value())
+		w.Header().Set(http.TrailerPrefix+repr.Key(), repr.Value())
+		return nil
+	}
+
+	w.setEncodingHeaders()
+	w.Header().Set(digest.Key(), digest.Value())
+	w.Header().Set(repr.Key(), repr.Value())
+	w.Header().Set(contentLengthHeader, strconv.Itoa(w.sink.buf.Len()))
+
+	w.sendHeader()
+	_, err := w.ResponseWriter.Write(w.sink.buf.Bytes())
+	return err
+}
+
+// MARK: CompressionMiddleware
+type compressionMiddleware struct {
+	next        http.Handler
+	minSize     int
+	compressors []Compressor
+}
+
+func (m *compressionMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	cw := &compressWriter{
+		ResponseWriter: w,
+		minSize:        m.minSize,
+		contentAlg:     responses.WantedDigest(r.Header.Get(responses.WantContentDigestKey.Name())),
+		reprAlg:        responses.WantedDigest(r.Header.Get(responses.WantReprDigestKey.Name())),
+		ifNoneMatch:    strings.Join(r.Header.Values(requests.IfNoneMatch.Name()), ","),
+	}
+
+	// Without a coding to use the body goes straight through
+	if compressor, ok := negotiateEncoding(r.Header.Get(AcceptEncodingHeader), m.compressors); ok && r.Method != http.MethodHead {
+		cw.compressor = compressor
+	} else {
+		cw.decided = true
+	}
+
+	defer cw.Close()
+
+	m.next.ServeHTTP(cw, r)
+}
+
+/*
+Compresses responses with the coding the client prefers from Accept-Encoding
+
+Bodies smaller than minSize and formats that are already compressed are sent
+as they are. The Content-Digest and Repr-Digest are of the compressed bytes,
+compressors are tried in order, gzip and deflate when none are given.
+*/
+func CompressionMiddleware(minSize int, compressors ...Compressor) func(http.Handler) http.Handler {
+	if len(compressors) == 0 {
+		compressors = []Compressor{NewGzipCompressor(), NewDeflateCompressor()}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return &compressionMiddleware{next: next, minSize: minSize, compressors: compressors}
+	}
+}
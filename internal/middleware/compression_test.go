@@ -0,0 +1,436 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"net/textproto"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/moonmoon1919/go-api-reference/internal/requests"
+	"github.com/moonmoon1919/go-api-reference/internal/responses"
+)
+
+func sha256Digest(b []byte) string {
+	hash := sha256.Sum256(b)
+	return "sha-256=" + base64.StdEncoding.EncodeToString(hash[:])
+}
+
+func decompress(t *testing.T, encoding string, body []byte) []byte {
+	t.Helper()
+
+	var r io.Reader
+	var err error
+
+	switch encoding {
+	// The pluggable compressor in these tests is gzip under another name
+	case "gzip", "br":
+		r, err = gzip.NewReader(bytes.NewReader(body))
+	case "deflate":
+		r, err = zlib.NewReader(bytes.NewReader(body))
+	default:
+		return body
+	}
+
+	if err != nil {
+		t.Fatalf("expected a %s body, got error %s", encoding, err.Error())
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("expected a %s body, got error %s", encoding, err.Error())
+	}
+
+	return data
+}
+
+/*
+A compressor under another name, stands in for one a server plugs in
+*/
+type renamedCompressor struct {
+	Compressor
+	encoding string
+}
+
+func (c renamedCompressor) Encoding() string {
+	return c.encoding
+}
+
+func TestCompressionMiddleware(t *testing.T) {
+	large := strings.Repeat(`{"message":"hello"},`, 100)
+
+	tests := []struct {
+		name             string
+		acceptEncoding   string
+		body             string
+		contentType      string
+		status           int
+		compressors      []Compressor
+		expectedEncoding string
+	}{
+		{
+			name:             "Gzip",
+			acceptEncoding:   "gzip, deflate",
+			body:             large,
+			expectedEncoding: "gzip",
+		},
+		{
+			name:             "DeflatePreferred",
+			acceptEncoding:   "gzip;q=0.5, deflate",
+			body:             large,
+			expectedEncoding: "deflate",
+		},
+		{
+			name:             "Wildcard",
+			acceptEncoding:   "*",
+			body:             large,
+			expectedEncoding: "gzip",
+		},
+		{
+			name:             "Refused",
+			acceptEncoding:   "gzip;q=0, deflate;q=0",
+			body:             large,
+			expectedEncoding: "",
+		},
+		{
+			name:             "NoAcceptEncoding",
+			acceptEncoding:   "",
+			body:             large,
+			expectedEncoding: "",
+		},
+		{
+			name:             "SmallBody",
+			acceptEncoding:   "gzip",
+			body:             `{"message":"hello"}`,
+			expectedEncoding: "",
+		},
+		{
+			name:             "AlreadyCompressed",
+			acceptEncoding:   "gzip",
+			body:             large,
+			contentType:      "image/png",
+			expectedEncoding: "",
+		},
+		{
+			name:             "NotModified",
+			acceptEncoding:   "gzip",
+			status:           http.StatusNotModified,
+			expectedEncoding: "",
+		},
+		{
+			name:             "Pluggable",
+			acceptEncoding:   "br, gzip;q=0.5",
+			body:             large,
+			compressors:      []Compressor{NewGzipCompressor(), renamedCompressor{Compressor: NewGzipCompressor(), encoding: "br"}},
+			expectedEncoding: "br",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			handler := responses.NewRegistry().Handle(func(r *http.Request) (responses.Response, error) {
+				if tc.status == http.StatusNotModified {
+					return responses.NotModified(responses.Etag(`"1"`)), nil
+				}
+
+				return responses.OK(responses.Encoded{MediaType: responses.ApplicationJson, Data: []byte(tc.body)}), nil
+			})
+
+			var next http.Handler = handler
+			if tc.contentType != "" {
+				next = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set("Content-Type", tc.contentType)
+					w.Write([]byte(tc.body))
+				})
+			}
+
+			middleware := CompressionMiddleware(1024, tc.compressors...)(LoggingMiddleware(next))
+
+			r := httptest.NewRequest(http.MethodGet, "/examples", nil)
+			if tc.acceptEncoding != "" {
+				r.Header.Set(AcceptEncodingHeader, tc.acceptEncoding)
+			}
+			w := httptest.NewRecorder()
+
+			// When
+			middleware.ServeHTTP(w, r)
+
+			// Then
+			if encoding := w.Header().Get(ContentEncodingHeader); encoding != tc.expectedEncoding {
+				t.Fatalf("expected Content-Encoding %q, got %q", tc.expectedEncoding, encoding)
+			}
+
+			if !slices.Contains(w.Header().Values("Vary"), AcceptEncodingHeader) {
+				t.Errorf("expected Vary to include Accept-Encoding, got %v", w.Header().Values("Vary"))
+			}
+
+			if tc.status == http.StatusNotModified {
+				if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+					t.Errorf("expected an empty 304, got %d with %d bytes", w.Code, w.Body.Len())
+				}
+
+				return
+			}
+
+			body := w.Body.Bytes()
+			if content := decompress(t, tc.expectedEncoding, body); string(content) != tc.body {
+				t.Errorf("expected the body to round trip, got %q", content)
+			}
+
+			if tc.contentType != "" {
+				return
+			}
+
+			// The digests are of the bytes that were sent, compressed or not
+			if digest := w.Header().Get("Content-Digest"); digest != sha256Digest(body) {
+				t.Errorf("expected Content-Digest %s, got %s", sha256Digest(body), digest)
+			}
+
+			if digest := w.Header().Get("Repr-Digest"); digest != sha256Digest(body) {
+				t.Errorf("expected Repr-Digest %s, got %s", sha256Digest(body), digest)
+			}
+		})
+	}
+}
+
+func TestCompressionMiddlewareStreaming(t *testing.T) {
+	// Given
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+
+		for _, line := range []string{`{"id":1}`, `{"id":2}`} {
+			w.Write([]byte(line + "\n"))
+
+			// Through the logging middleware's wrapper
+			if err := http.NewResponseController(w).Flush(); err != nil {
+				t.Errorf("expected flush to succeed, got %s", err.Error())
+			}
+		}
+	})
+
+	middleware := CompressionMiddleware(1024)(LoggingMiddleware(handler))
+
+	r := httptest.NewRequest(http.MethodGet, "/examples", nil)
+	r.Header.Set(AcceptEncodingHeader, "gzip")
+	w := httptest.NewRecorder()
+
+	// When
+	middleware.ServeHTTP(w, r)
+
+	// Then
+	if !w.Flushed {
+		t.Error("expected the response to be flushed")
+	}
+
+	resp := w.Result()
+	if encoding := resp.Header.Get(ContentEncodingHeader); encoding != "gzip" {
+		t.Fatalf("expected a gzip stream, got %q", encoding)
+	}
+
+	body := w.Body.Bytes()
+	if content := decompress(t, "gzip", body); string(content) != "{\"id\":1}\n{\"id\":2}\n" {
+		t.Errorf("expected both lines, got %q", content)
+	}
+
+	// Headers went out before the body was complete, the digest is a trailer
+	if digest := resp.Trailer.Get("Content-Digest"); digest != sha256Digest(body) {
+		t.Errorf("expected a Content-Digest trailer %s, got %s", sha256Digest(body), digest)
+	}
+}
+
+func TestCompressionMiddlewareWantDigest(t *testing.T) {
+	tests := []struct {
+		name           string
+		acceptEncoding string
+	}{
+		{name: "Compressed", acceptEncoding: "gzip"},
+		{name: "Identity", acceptEncoding: ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			body := strings.Repeat(`{"message":"hello"},`, 100)
+			handler := responses.NewRegistry().Handle(func(r *http.Request) (responses.Response, error) {
+				return responses.OK(responses.Encoded{MediaType: responses.ApplicationJson, Data: []byte(body)}), nil
+			})
+
+			middleware := CompressionMiddleware(1024)(LoggingMiddleware(handler))
+
+			r := httptest.NewRequest(http.MethodGet, "/examples", nil)
+			r.Header.Set("Want-Content-Digest", "sha-512=10, sha-256=1")
+			if tc.acceptEncoding != "" {
+				r.Header.Set(AcceptEncodingHeader, tc.acceptEncoding)
+			}
+			w := httptest.NewRecorder()
+
+			// When
+			middleware.ServeHTTP(w, r)
+
+			// Then
+			sent := w.Body.Bytes()
+			hash := sha512.Sum512(sent)
+			expected := "sha-512=" + base64.StdEncoding.EncodeToString(hash[:])
+
+			if digest := w.Header().Get("Content-Digest"); digest != expected {
+				t.Errorf("expected Content-Digest %s, got %s", expected, digest)
+			}
+
+			// Only the content digest was asked for
+			if digest := w.Header().Get("Repr-Digest"); digest != sha256Digest(sent) {
+				t.Errorf("expected Repr-Digest %s, got %s", sha256Digest(sent), digest)
+			}
+		})
+	}
+}
+
+func TestCompressionMiddlewareEarlyHints(t *testing.T) {
+	// Given
+	body := strings.Repeat(`{"message":"hello"},`, 100)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Link", "</style.css>; rel=preload; as=style")
+		w.WriteHeader(http.StatusEarlyHints)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(body))
+	})
+
+	// The recorder keeps the first status it's given, a server sends 1xx on their own
+	server := httptest.NewServer(CompressionMiddleware(1024)(LoggingMiddleware(handler)))
+	defer server.Close()
+
+	var informational []int
+	trace := &httptrace.ClientTrace{
+		Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			informational = append(informational, code)
+			return nil
+		},
+	}
+
+	r, _ := http.NewRequestWithContext(httptrace.WithClientTrace(context.Background(), trace), http.MethodPost, server.URL+"/examples", nil)
+	r.Header.Set(AcceptEncodingHeader, "gzip")
+
+	// When
+	resp, err := server.Client().Do(r)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err.Error())
+	}
+	defer resp.Body.Close()
+
+	// Then
+	if !slices.Equal(informational, []int{http.StatusEarlyHints}) {
+		t.Errorf("expected a 103 before the response, got %v", informational)
+	}
+
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("expected status code to be %d, got %d", http.StatusCreated, resp.StatusCode)
+	}
+
+	if encoding := resp.Header.Get(ContentEncodingHeader); encoding != "gzip" {
+		t.Fatalf("expected Content-Encoding gzip, got %q", encoding)
+	}
+
+	sent, _ := io.ReadAll(resp.Body)
+	if content := decompress(t, "gzip", sent); string(content) != body {
+		t.Errorf("expected the body to round trip, got %q", content)
+	}
+}
+
+func TestCompressionMiddlewareEtag(t *testing.T) {
+	large := strings.Repeat(`{"message":"hello"},`, 100)
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		ifNoneMatch    string
+		body           string
+		expectedStatus int
+		expectedEtag   string
+	}{
+		{
+			name:           "PassingCase-Compressed",
+			acceptEncoding: "gzip",
+			body:           large,
+			expectedStatus: http.StatusOK,
+			expectedEtag:   `"1-gzip"`,
+		},
+		{
+			name:           "PassingCase-Identity",
+			body:           large,
+			expectedStatus: http.StatusOK,
+			expectedEtag:   `"1"`,
+		},
+		{
+			name:           "PassingCase-SmallBody",
+			acceptEncoding: "gzip",
+			body:           `{"message":"hello"}`,
+			expectedStatus: http.StatusOK,
+			expectedEtag:   `"1"`,
+		},
+		{
+			name:           "PassingCase-NotModifiedCoded",
+			acceptEncoding: "gzip",
+			ifNoneMatch:    `"1-gzip"`,
+			body:           large,
+			expectedStatus: http.StatusNotModified,
+			expectedEtag:   `"1-gzip"`,
+		},
+		{
+			name:           "PassingCase-NotModifiedIdentity",
+			acceptEncoding: "gzip",
+			ifNoneMatch:    `"1"`,
+			body:           large,
+			expectedStatus: http.StatusNotModified,
+			expectedEtag:   `"1"`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			handler := responses.NewRegistry().Handle(func(r *http.Request) (responses.Response, error) {
+				validators := requests.Validators{ETag: `"1"`, Exists: true}
+				if requests.EvaluatePreconditions(r, validators) == requests.NotModified {
+					return responses.NotModified(responses.Etag(validators.ETag)), nil
+				}
+
+				return responses.OK(responses.Encoded{MediaType: responses.ApplicationJson, Data: []byte(tc.body)}, responses.Etag(validators.ETag)), nil
+			})
+
+			middleware := CompressionMiddleware(1024)(LoggingMiddleware(handler))
+
+			r := httptest.NewRequest(http.MethodGet, "/examples/1", nil)
+			if tc.acceptEncoding != "" {
+				r.Header.Set(AcceptEncodingHeader, tc.acceptEncoding)
+			}
+			if tc.ifNoneMatch != "" {
+				r.Header.Set("If-None-Match", tc.ifNoneMatch)
+			}
+			w := httptest.NewRecorder()
+
+			// When
+			middleware.ServeHTTP(w, r)
+
+			// Then
+			if w.Code != tc.expectedStatus {
+				t.Errorf("expected status code to be %d, got %d", tc.expectedStatus, w.Code)
+			}
+
+			if etag := w.Header().Get("Etag"); etag != tc.expectedEtag {
+				t.Errorf("expected ETag %s, got %s", tc.expectedEtag, etag)
+			}
+		})
+	}
+}
@@ -0,0 +1,271 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/moonmoon1919/go-api-reference/internal/cache"
+	"github.com/moonmoon1919/go-api-reference/internal/responses"
+)
+
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+	idempotencyKeyPrefix     = "idempotency"
+	msgIdempotencyStoreError = "IDEMPOTENCY_STORE_ERROR"
+	msgIdempotentReplay      = "IDEMPOTENT_REPLAY"
+	msgIdempotencyKeyInUse   = "IDEMPOTENCY_KEY_IN_USE"
+	msgIdempotencyKeyReused  = "IDEMPOTENCY_KEY_REUSED"
+	errIdempotencyKeyTooLong = "IDEMPOTENCY_KEY_TOO_LONG"
+	errInvalidRequestBody    = "INVALID_REQUEST_BODY"
+	keyIdempotencyKey        = "idempotency_key"
+)
+
+/*
+What is stored for an Idempotency-Key, Status is 0 while the first request
+with the key is still being handled
+*/
+type idempotencyRecord struct {
+	Fingerprint string      `json:"fingerprint"`
+	Status      int         `json:"status,omitempty"`
+	Header      http.Header `json:"header,omitempty"`
+	Body        []byte      `json:"body,omitempty"`
+}
+
+/*
+Only unsafe methods change anything, everything else is already idempotent
+*/
+func unsafeMethod(method string) bool {
+	return method == http.MethodPost || method == http.MethodPatch || method == http.MethodDelete
+}
+
+/*
+Identifies a request, a key reused with a different fingerprint is a client bug
+*/
+func fingerprint(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(r.Method + " " + r.URL.RequestURI() + "\n"))
+	hash.Write(body)
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+/*
+Keys are scoped to the requesting user, so clients can't see each other's responses
+*/
+func idempotencyStoreKey(r *http.Request, key string) string {
+	user, _ := UserFromContext(r.Context())
+
+	return idempotencyKeyPrefix + ":" + user.Id + ":" + key
+}
+
+/*
+A wrapper around the http.ResponseWriter that keeps a copy of the response so it can be replayed
+*/
+type recordingWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *recordingWriter) WriteHeader(status int) {
+	// Informational responses aren't the one that is replayed, e.g., 103 Early Hints
+	if w.status == 0 && (status >= http.StatusOK || status == http.StatusSwitchingProtocols) {
+		w.status = status
+	}
+
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	w.body.Write(b)
+
+	return w.ResponseWriter.Write(b)
+}
+
+/*
+Streaming handlers flush through the wrapper, e.g., an NDJSON listing
+*/
+func (w *recordingWriter) Flush() {
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *recordingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func replay(w http.ResponseWriter, r *http.Request, record idempotencyRecord) {
+	slog.LogAttrs(
+		r.Context(),
+		slog.LevelInfo,
+		msgIdempotentReplay,
+		slog.Int(keyStatus, record.Status),
+	)
+
+	for key, values := range record.Header {
+		w.Header()[key] = values
+	}
+
+	w.Header().Set(IdempotentReplayedHeader, "true")
+	w.WriteHeader(record.Status)
+	w.Write(record.Body)
+}
+
+// MARK: IdempotencyMiddleware
+/*
+Makes POST, PATCH and DELETE safe to retry with an Idempotency-Key header
+
+The first request with a key reserves it and its response is stored for ttl,
+a retry gets the stored response back without running the handler again.
+A retry while the first request is running gets a 409 and a key reused for a
+different request a 422. Server errors aren't stored so they can be retried.
+
+The key is only reserved for reservation while the first request runs, so a
+process that dies before storing the response doesn't hold the key for long.
+It should be as long as a request can take, e.g., the server's write timeout,
+0 reserves keys for ttl.
+
+Requests are handled without idempotency when the store is unavailable.
+*/
+func IdempotencyMiddleware(store cache.Reserver, ttl, reservation time.Duration) func(http.HandlerFunc) http.HandlerFunc {
+	if reservation <= 0 || reservation > ttl {
+		reservation = ttl
+	}
+
+	return func(h http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if key == "" || !unsafeMethod(r.Method) {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			if len(key) > maxIdempotencyKeyLength {
+				responses.WriteBadRequestResponse(w, errIdempotencyKeyTooLong)
+				return
+			}
+
+			// The body is read here for the fingerprint, the handler gets a copy
+			var body []byte
+			if r.Body != nil {
+				b, err := io.ReadAll(r.Body)
+				if err != nil {
+					responses.WriteBadRequestResponse(w, errInvalidRequestBody)
+					return
+				}
+
+				body = b
+				r.Body = io.NopCloser(bytes.NewReader(body))
+			}
+
+			storeKey := idempotencyStoreKey(r, key)
+			pending := idempotencyRecord{Fingerprint: fingerprint(r, body)}
+			pendingBytes, _ := json.Marshal(pending)
+
+			existing, reserved, err := store.Reserve(r.Context(), storeKey, string(pendingBytes), reservation)
+			if err != nil {
+				slog.LogAttrs(
+					r.Context(),
+					slog.LevelWarn,
+					msgIdempotencyStoreError,
+					slog.String(keyError, err.Error()),
+				)
+
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			if !reserved {
+				var record idempotencyRecord
+				if err := json.Unmarshal([]byte(existing), &record); err != nil {
+					slog.LogAttrs(
+						r.Context(),
+						slog.LevelError,
+						msgIdempotencyStoreError,
+						slog.String(keyError, err.Error()),
+					)
+
+					responses.WriteInternalServerErrorResponse(w)
+					return
+				}
+
+				switch {
+				case record.Fingerprint != pending.Fingerprint:
+					slog.LogAttrs(r.Context(), slog.LevelInfo, msgIdempotencyKeyReused, slog.String(keyIdempotencyKey, key))
+					responses.WriteIdempotencyKeyReusedResponse(w)
+				case record.Status == 0:
+					slog.LogAttrs(r.Context(), slog.LevelInfo, msgIdempotencyKeyInUse, slog.String(keyIdempotencyKey, key))
+					responses.WriteIdempotencyKeyInUseResponse(w)
+				default:
+					replay(w, r, record)
+				}
+
+				return
+			}
+
+			rw := &recordingWriter{ResponseWriter: w}
+			stored := false
+
+			// Release the key when the handler panics or fails so the request can be retried
+			defer func() {
+				if stored {
+					return
+				}
+
+				if err := store.Delete(r.Context(), storeKey); err != nil {
+					slog.LogAttrs(
+						r.Context(),
+						slog.LevelWarn,
+						msgIdempotencyStoreError,
+						slog.String(keyError, err.Error()),
+					)
+				}
+			}()
+
+			h.ServeHTTP(rw, r)
+
+			if rw.status == 0 {
+				rw.status = http.StatusOK
+			}
+
+			if rw.status >= http.StatusInternalServerError {
+				return
+			}
+
+			pending.Status = rw.status
+			pending.Header = rw.Header().Clone()
+			pending.Body = rw.body.Bytes()
+
+			// A replay is a request of its own, it keeps its own id
+			pending.Header.Del(responses.RequestIdKey.Name())
+
+			recordBytes, err := json.Marshal(pending)
+			if err == nil {
+				err = store.Set(r.Context(), storeKey, string(recordBytes), ttl)
+			}
+
+			if err != nil {
+				slog.LogAttrs(
+					r.Context(),
+					slog.LevelWarn,
+					msgIdempotencyStoreError,
+					slog.String(keyError, err.Error()),
+				)
+				return
+			}
+
+			stored = true
+		}
+	}
+}
@@ -0,0 +1,301 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/moonmoon1919/go-api-reference/internal/cache"
+)
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		firstKey       string
+		firstBody      string
+		secondKey      string
+		secondBody     string
+		firstStatus    int
+		inFlight       bool
+		expectedStatus int
+		expectedCalls  int
+		expectReplay   bool
+	}{
+		{
+			name:           "PassingCase-Replayed",
+			method:         http.MethodPost,
+			firstKey:       "abc",
+			firstBody:      `{"message": "hi"}`,
+			secondKey:      "abc",
+			secondBody:     `{"message": "hi"}`,
+			firstStatus:    http.StatusCreated,
+			expectedStatus: http.StatusCreated,
+			expectedCalls:  1,
+			expectReplay:   true,
+		},
+		{
+			name:           "PassingCase-DifferentKeys",
+			method:         http.MethodPost,
+			firstKey:       "abc",
+			firstBody:      `{"message": "hi"}`,
+			secondKey:      "def",
+			secondBody:     `{"message": "hi"}`,
+			firstStatus:    http.StatusCreated,
+			expectedStatus: http.StatusCreated,
+			expectedCalls:  2,
+		},
+		{
+			name:           "PassingCase-NoKey",
+			method:         http.MethodPost,
+			firstBody:      `{"message": "hi"}`,
+			secondBody:     `{"message": "hi"}`,
+			firstStatus:    http.StatusCreated,
+			expectedStatus: http.StatusCreated,
+			expectedCalls:  2,
+		},
+		{
+			name:           "PassingCase-SafeMethodIgnored",
+			method:         http.MethodGet,
+			firstKey:       "abc",
+			secondKey:      "abc",
+			firstStatus:    http.StatusOK,
+			expectedStatus: http.StatusOK,
+			expectedCalls:  2,
+		},
+		{
+			name:           "PassingCase-ServerErrorRetried",
+			method:         http.MethodPatch,
+			firstKey:       "abc",
+			firstBody:      `{"message": "hi"}`,
+			secondKey:      "abc",
+			secondBody:     `{"message": "hi"}`,
+			firstStatus:    http.StatusInternalServerError,
+			expectedStatus: http.StatusInternalServerError,
+			expectedCalls:  2,
+		},
+		{
+			name:           "FailingCase-DifferentBody",
+			method:         http.MethodPost,
+			firstKey:       "abc",
+			firstBody:      `{"message": "hi"}`,
+			secondKey:      "abc",
+			secondBody:     `{"message": "bye"}`,
+			firstStatus:    http.StatusCreated,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCalls:  1,
+		},
+		{
+			name:           "FailingCase-InFlight",
+			method:         http.MethodDelete,
+			firstKey:       "abc",
+			secondKey:      "abc",
+			firstStatus:    http.StatusNoContent,
+			inFlight:       true,
+			expectedStatus: http.StatusConflict,
+			expectedCalls:  1,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			calls := 0
+			var second func()
+
+			middleware := IdempotencyMiddleware(cache.NewInMemoryReserver(), time.Hour, time.Minute)
+			handler := middleware(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+
+				// The retry arrives while the first request is still running
+				if tc.inFlight && second != nil {
+					retry := second
+					second = nil
+					retry()
+				}
+
+				w.Header().Set("Etag", `"1"`)
+				w.WriteHeader(tc.firstStatus)
+				w.Write([]byte(`{"id": "1"}`))
+			})
+
+			request := func(key, body string) *http.Request {
+				r := httptest.NewRequest(tc.method, "/examples", strings.NewReader(body))
+				if key != "" {
+					r.Header.Set(IdempotencyKeyHeader, key)
+				}
+
+				return r.WithContext(ContextWithUser(r.Context(), RequestingUser{Id: "123"}))
+			}
+
+			w := httptest.NewRecorder()
+			if tc.inFlight {
+				second = func() { handler(w, request(tc.secondKey, tc.secondBody)) }
+				handler(httptest.NewRecorder(), request(tc.firstKey, tc.firstBody))
+			} else {
+				handler(httptest.NewRecorder(), request(tc.firstKey, tc.firstBody))
+
+				// When
+				handler(w, request(tc.secondKey, tc.secondBody))
+			}
+
+			// Then
+			if w.Code != tc.expectedStatus {
+				t.Errorf("expected status %d, got %d", tc.expectedStatus, w.Code)
+			}
+
+			if calls != tc.expectedCalls {
+				t.Errorf("expected the handler to run %d times, got %d", tc.expectedCalls, calls)
+			}
+
+			replayed := w.Header().Get(IdempotentReplayedHeader) == "true"
+			if replayed != tc.expectReplay {
+				t.Errorf("expected replayed to be %t, got %t", tc.expectReplay, replayed)
+			}
+
+			if tc.expectReplay && (w.Body.String() != `{"id": "1"}` || w.Header().Get("Etag") != `"1"`) {
+				t.Errorf("expected the stored response, got %s with headers %v", w.Body.String(), w.Header())
+			}
+		})
+	}
+}
+
+func TestIdempotencyKeysScopedToUser(t *testing.T) {
+	// Given
+	calls := 0
+	handler := IdempotencyMiddleware(cache.NewInMemoryReserver(), time.Hour, time.Minute)(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	for _, userId := range []string{"123", "456"} {
+		r := httptest.NewRequest(http.MethodPost, "/examples", strings.NewReader(`{}`))
+		r.Header.Set(IdempotencyKeyHeader, "abc")
+
+		// When
+		handler(httptest.NewRecorder(), r.WithContext(ContextWithUser(context.Background(), RequestingUser{Id: userId})))
+	}
+
+	// Then
+	if calls != 2 {
+		t.Errorf("expected each user to get their own key, handler ran %d times", calls)
+	}
+}
+
+/*
+Records the TTL of every write, a crashed request is stood in for by one that
+never stores its response
+*/
+type ttlRecordingReserver struct {
+	*cache.InMemoryReserver
+	reserved []time.Duration
+	set      []time.Duration
+}
+
+func (s *ttlRecordingReserver) Reserve(ctx context.Context, key, val string, ttl time.Duration) (string, bool, error) {
+	s.reserved = append(s.reserved, ttl)
+	return s.InMemoryReserver.Reserve(ctx, key, val, ttl)
+}
+
+func (s *ttlRecordingReserver) Set(ctx context.Context, key, val string, ttl time.Duration) error {
+	s.set = append(s.set, ttl)
+	return s.InMemoryReserver.Set(ctx, key, val, ttl)
+}
+
+func TestIdempotencyReservationTTL(t *testing.T) {
+	// Given
+	store := &ttlRecordingReserver{InMemoryReserver: cache.NewInMemoryReserver()}
+	handler := IdempotencyMiddleware(store, time.Hour, 10*time.Second)(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	r := httptest.NewRequest(http.MethodPost, "/examples", strings.NewReader(`{}`))
+	r.Header.Set(IdempotencyKeyHeader, "abc")
+
+	// When
+	handler(httptest.NewRecorder(), r.WithContext(ContextWithUser(context.Background(), RequestingUser{Id: "123"})))
+
+	// Then
+	if len(store.reserved) != 1 || store.reserved[0] != 10*time.Second {
+		t.Errorf("expected the key to be reserved for 10s, got %v", store.reserved)
+	}
+
+	if len(store.set) != 1 || store.set[0] != time.Hour {
+		t.Errorf("expected the response to be kept for 1h, got %v", store.set)
+	}
+}
+
+func TestIdempotencyReplayKeepsRequestId(t *testing.T) {
+	// Given
+	handler := IdempotencyMiddleware(cache.NewInMemoryReserver(), time.Hour, time.Minute)(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	send := func(requestId string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/examples", strings.NewReader(`{}`))
+		r.Header.Set(IdempotencyKeyHeader, "abc")
+
+		// Set by the request id middleware before the handler runs
+		w := httptest.NewRecorder()
+		w.Header().Set("X-Request-Id", requestId)
+
+		handler(w, r.WithContext(ContextWithUser(context.Background(), RequestingUser{Id: "123"})))
+		return w
+	}
+
+	send("first")
+
+	// When
+	w := send("second")
+
+	// Then
+	if w.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Fatalf("expected the response to be replayed")
+	}
+
+	if id := w.Header().Get("X-Request-Id"); id != "second" {
+		t.Errorf("expected the replay to keep its own request id, got %s", id)
+	}
+}
+
+func TestIdempotencyStreaming(t *testing.T) {
+	// Given
+	handler := IdempotencyMiddleware(cache.NewInMemoryReserver(), time.Hour, time.Minute)(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusEarlyHints)
+		w.WriteHeader(http.StatusCreated)
+
+		for _, line := range []string{`{"id":1}`, `{"id":2}`} {
+			w.Write([]byte(line + "\n"))
+
+			if err := http.NewResponseController(w).Flush(); err != nil {
+				t.Errorf("expected flush to succeed, got %s", err.Error())
+			}
+		}
+	})
+
+	send := func() *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/examples", strings.NewReader(`{}`))
+		r.Header.Set(IdempotencyKeyHeader, "abc")
+		w := httptest.NewRecorder()
+
+		handler(w, r.WithContext(ContextWithUser(context.Background(), RequestingUser{Id: "123"})))
+		return w
+	}
+
+	// When
+	w := send()
+	replayed := send()
+
+	// Then
+	if !w.Flushed {
+		t.Error("expected the response to be flushed")
+	}
+
+	// The 103 went out first, the replay has the final status
+	if replayed.Code != http.StatusCreated || replayed.Body.String() != "{\"id\":1}\n{\"id\":2}\n" {
+		t.Errorf("expected the stored 201 with both lines, got %d %q", replayed.Code, replayed.Body.String())
+	}
+}
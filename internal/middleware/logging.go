@@ -13,6 +13,7 @@ const (
 	keyRemoteAddr       = "remote_addr"
 	keyUrl              = "url"
 	keyDurationMs       = "duration_ms"
+	keyRequestId        = "request_id"
 )
 
 /*
@@ -28,6 +29,17 @@ func (w *responseWriter) WriteHeader(status int) {
 	w.ResponseWriter.WriteHeader(status)
 }
 
+/*
+Streaming handlers flush through the wrapper, e.g., to the compression middleware
+*/
+func (w *responseWriter) Flush() {
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
 type loggingMiddleware struct {
 	next http.Handler
 }
@@ -42,7 +54,10 @@ func (m *loggingMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	m.next.ServeHTTP(rw, r)
 
+	requestId, _ := RequestIdFromContext(r.Context())
+
 	slog.LogAttrs(r.Context(), slog.LevelInfo, msgRequestCompleted,
+		slog.String(keyRequestId, requestId),
 		slog.String(keyMethod, r.Method),
 		slog.Int(keyStatus, rw.statusCode),
 		slog.String(keyRemoteAddr, r.RemoteAddr),
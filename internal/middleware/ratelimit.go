@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"net"
+	"net/http"
+
+	"github.com/moonmoon1919/go-api-reference/internal/ratelimit"
+	"github.com/moonmoon1919/go-api-reference/internal/responses"
+)
+
+const (
+	ApiKeyHeader          = "X-Api-Key"
+	rateLimitKeyPrefix    = "ratelimit"
+	msgRateLimitError     = "RATE_LIMIT_ERROR"
+	msgRateLimitExceeded  = "RATE_LIMIT_EXCEEDED"
+	keyRateLimitPattern   = "pattern"
+	keyRateLimitClient    = "client"
+	keyRateLimitRetrySecs = "retry_after_s"
+)
+
+/*
+Who a request is counted against, the requesting user when there is one,
+then the API key and finally the client IP
+
+API keys are hashed so they never end up in the limiter's store or logs
+*/
+func rateLimitClient(r *http.Request) string {
+	if user, ok := UserFromContext(r.Context()); ok && user.Id != "" {
+		return "user:" + user.Id
+	}
+
+	if key := r.Header.Get(ApiKeyHeader); key != "" {
+		hash := sha256.Sum256([]byte(key))
+		return "key:" + hex.EncodeToString(hash[:])
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	return "ip:" + host
+}
+
+// MARK: RateLimitMiddleware
+/*
+Limits how often a client can call a route, each route pattern has its own
+limit and its own count
+
+Every response carries the RateLimit headers, a client over the limit gets a
+429 with Retry-After. Requests are let through when the limiter is unavailable.
+*/
+func RateLimitMiddleware(limiter ratelimit.Limiter, policy ratelimit.Policy) func(http.HandlerFunc) http.HandlerFunc {
+	return func(h http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			limit := policy.For(r.Pattern)
+			client := rateLimitClient(r)
+			key := rateLimitKeyPrefix + ":" + r.Pattern + ":" + client
+
+			decision, err := limiter.Allow(r.Context(), key, policy.Algorithm, limit)
+			if err != nil {
+				slog.LogAttrs(
+					r.Context(),
+					slog.LevelWarn,
+					msgRateLimitError,
+					slog.String(keyError, err.Error()),
+				)
+
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			headers := append(
+				responses.RateLimit(decision.Limit, decision.Remaining, decision.Reset),
+				responses.RateLimitPolicy(limit.Requests, limit.Window),
+			)
+
+			if !decision.Allowed {
+				slog.LogAttrs(
+					r.Context(),
+					slog.LevelInfo,
+					msgRateLimitExceeded,
+					slog.String(keyRateLimitPattern, r.Pattern),
+					slog.String(keyRateLimitClient, client),
+					slog.Float64(keyRateLimitRetrySecs, decision.RetryAfter.Seconds()),
+				)
+
+				headers = append(headers, responses.RetryAfter(decision.RetryAfter))
+				responses.WriteTooManyRequestsResponse(w, &headers)
+				return
+			}
+
+			for _, header := range headers {
+				w.Header().Set(header.Key(), header.Value())
+			}
+
+			h.ServeHTTP(w, r)
+		}
+	}
+}
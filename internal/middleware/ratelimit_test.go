@@ -0,0 +1,167 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/moonmoon1919/go-api-reference/internal/ratelimit"
+)
+
+type failingLimiter struct{}
+
+func (f failingLimiter) Allow(ctx context.Context, key string, algorithm ratelimit.Algorithm, limit ratelimit.Limit) (ratelimit.Decision, error) {
+	return ratelimit.Decision{}, errors.New("unavailable")
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	policy := ratelimit.Policy{
+		Algorithm: ratelimit.TokenBucket,
+		Default:   ratelimit.Limit{Requests: 2, Window: time.Minute},
+		Routes:    map[string]ratelimit.Limit{"POST /examples": {Requests: 1, Window: time.Minute}},
+	}
+
+	tests := []struct {
+		name           string
+		limiter        ratelimit.Limiter
+		pattern        string
+		requests       int
+		expectedStatus int
+		expectedLimit  string
+		expectedLeft   string
+		expectRetry    bool
+	}{
+		{
+			name:           "PassingCase-Default",
+			limiter:        ratelimit.NewInMemoryLimiter(),
+			pattern:        "GET /examples",
+			requests:       2,
+			expectedStatus: http.StatusOK,
+			expectedLimit:  "2",
+			expectedLeft:   "0",
+		},
+		{
+			name:           "PassingCase-LimiterUnavailable",
+			limiter:        failingLimiter{},
+			pattern:        "POST /examples",
+			requests:       3,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "FailingCase-RouteLimit",
+			limiter:        ratelimit.NewInMemoryLimiter(),
+			pattern:        "POST /examples",
+			requests:       2,
+			expectedStatus: http.StatusTooManyRequests,
+			expectedLimit:  "1",
+			expectedLeft:   "0",
+			expectRetry:    true,
+		},
+		{
+			name:           "FailingCase-DefaultLimit",
+			limiter:        ratelimit.NewInMemoryLimiter(),
+			pattern:        "GET /examples",
+			requests:       3,
+			expectedStatus: http.StatusTooManyRequests,
+			expectedLimit:  "2",
+			expectedLeft:   "0",
+			expectRetry:    true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			handler := RateLimitMiddleware(tc.limiter, policy)(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+
+			var w *httptest.ResponseRecorder
+			for range tc.requests {
+				r := httptest.NewRequest(http.MethodGet, "/examples", nil)
+				r.Pattern = tc.pattern
+
+				// When
+				w = httptest.NewRecorder()
+				handler(w, r.WithContext(ContextWithUser(r.Context(), RequestingUser{Id: "123"})))
+			}
+
+			// Then
+			if w.Code != tc.expectedStatus {
+				t.Errorf("expected status %d, got %d", tc.expectedStatus, w.Code)
+			}
+
+			if got := w.Header().Get("RateLimit-Limit"); got != tc.expectedLimit {
+				t.Errorf("expected limit %q, got %q", tc.expectedLimit, got)
+			}
+
+			if got := w.Header().Get("RateLimit-Remaining"); got != tc.expectedLeft {
+				t.Errorf("expected remaining %q, got %q", tc.expectedLeft, got)
+			}
+
+			if retry := w.Header().Get("Retry-After") != ""; retry != tc.expectRetry {
+				t.Errorf("expected Retry-After to be set %t, got %t", tc.expectRetry, retry)
+			}
+		})
+	}
+}
+
+func TestRateLimitClients(t *testing.T) {
+	tests := []struct {
+		name     string
+		user     string
+		apiKey   string
+		expected string
+	}{
+		{name: "User", user: "123", apiKey: "secret", expected: "user:123"},
+		{name: "ApiKey", apiKey: "secret", expected: "key:2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b"},
+		{name: "IP", expected: "ip:192.0.2.1"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			r := httptest.NewRequest(http.MethodGet, "/examples", nil)
+			if tc.apiKey != "" {
+				r.Header.Set(ApiKeyHeader, tc.apiKey)
+			}
+
+			if tc.user != "" {
+				r = r.WithContext(ContextWithUser(r.Context(), RequestingUser{Id: tc.user}))
+			}
+
+			// When
+			client := rateLimitClient(r)
+
+			// Then
+			if client != tc.expected {
+				t.Errorf("expected %s, got %s", tc.expected, client)
+			}
+		})
+	}
+}
+
+func TestRateLimitPerUser(t *testing.T) {
+	// Given
+	policy := ratelimit.Policy{Algorithm: ratelimit.SlidingWindow, Default: ratelimit.Limit{Requests: 1, Window: time.Minute}}
+	handler := RateLimitMiddleware(ratelimit.NewInMemoryLimiter(), policy)(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	for _, userId := range []string{"123", "456"} {
+		r := httptest.NewRequest(http.MethodGet, "/examples", nil)
+		r.Pattern = "GET /examples"
+		w := httptest.NewRecorder()
+
+		// When
+		handler(w, r.WithContext(ContextWithUser(r.Context(), RequestingUser{Id: userId})))
+
+		// Then
+		if w.Code != http.StatusOK {
+			t.Errorf("expected each user to have their own limit, got %d for %s", w.Code, userId)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/moonmoon1919/go-api-reference/internal/responses"
+)
+
+/*
+Key for storing the request id in the context
+*/
+type requestIdKey struct{}
+
+func ContextWithRequestId(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIdKey{}, id)
+}
+
+func RequestIdFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIdKey{}).(string)
+	return id, ok
+}
+
+type requestIdMiddleware struct {
+	next http.Handler
+}
+
+/*
+Keeps the id a proxy assigned when it's a UUID, anything else could be used
+to forge log lines so a new one is generated
+*/
+func (m *requestIdMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id := r.Header.Get(responses.RequestIdKey.Name())
+	if _, err := uuid.Parse(id); err != nil {
+		id = uuid.NewString()
+	}
+
+	// Set before the handler runs so error responses can include it
+	w.Header().Set(responses.RequestIdKey.Name(), id)
+
+	m.next.ServeHTTP(w, r.WithContext(ContextWithRequestId(r.Context(), id)))
+}
+
+/*
+Gives every request an id, it's returned in X-Request-Id and in problems
+*/
+func RequestIdMiddleware(next http.Handler) http.Handler {
+	return &requestIdMiddleware{next: next}
+}
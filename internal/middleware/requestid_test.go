@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/moonmoon1919/go-api-reference/internal/responses"
+)
+
+func TestRequestIdMiddleware(t *testing.T) {
+	tests := []struct {
+		name          string
+		incoming      string
+		keepsIncoming bool
+	}{
+		{name: "Generated"},
+		{name: "KeepsUUID", incoming: "0b6f2c1e-8f3a-4d2b-9c55-1d7c3a9e4b21", keepsIncoming: true},
+		{name: "ReplacesOther", incoming: "abc\nforged=1"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			var seen string
+			handler := RequestIdMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen, _ = RequestIdFromContext(r.Context())
+				responses.WriteNotFoundResponse(w)
+			}))
+
+			r := httptest.NewRequest(http.MethodGet, "/examples/123", nil)
+			if tc.incoming != "" {
+				r.Header.Set("X-Request-Id", tc.incoming)
+			}
+			w := httptest.NewRecorder()
+
+			// When
+			handler.ServeHTTP(w, r)
+
+			// Then
+			id := w.Header().Get("X-Request-Id")
+			if _, err := uuid.Parse(id); err != nil {
+				t.Fatalf("expected a UUID request id, got %q", id)
+			}
+
+			if (id == tc.incoming) != tc.keepsIncoming {
+				t.Errorf("expected the incoming id to be kept %t, got %s", tc.keepsIncoming, id)
+			}
+
+			if seen != id {
+				t.Errorf("expected the context to have %s, got %s", id, seen)
+			}
+
+			var problem responses.Problem
+			json.Unmarshal(w.Body.Bytes(), &problem)
+
+			if problem.RequestId != id || problem.Instance != "urn:uuid:"+id {
+				t.Errorf("expected the problem to name request %s, got %+v", id, problem)
+			}
+		})
+	}
+}
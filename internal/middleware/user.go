@@ -61,9 +61,11 @@ func getUserFromRequest(_ *http.Request) (RequestingUser, error) {
 			"example::create",
 			"example::delete",
 			"admin::example::read",
+			"admin::example::update",
 			"admin::example::delete",
 			"admin::user::read",
 			"admin::user::create",
+			"admin::user::update",
 			"admin::user::delete",
 			"admin::auditlog::read",
 		}),
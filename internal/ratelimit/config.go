@@ -0,0 +1,116 @@
+package ratelimit
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/moonmoon1919/go-api-reference/internal/config"
+)
+
+type Backend string
+
+const (
+	// Each replica counts on its own
+	MemoryBackend Backend = "memory"
+	// Replicas share counts in Valkey
+	ValkeyBackend Backend = "valkey"
+)
+
+type Config struct {
+	Backend Backend
+	Policy  Policy
+}
+
+/*
+Resolves the rate limit config, problems are collected by the loader
+
+Route limits are a comma separated list of pattern=limit, e.g.,
+"POST /examples=20/1m, POST /examples/batch=5/1m"
+*/
+func LoadConfig(l *config.Loader, lookup config.Lookup) Config {
+	var c Config
+
+	config.Bind(l, "RATE_LIMIT_BACKEND", backend(lookup("RATE_LIMIT_BACKEND", config.NewDefaultValueSource(string(MemoryBackend)))), &c.Backend)
+	config.Bind(l, "RATE_LIMIT_ALGORITHM", algorithm(lookup("RATE_LIMIT_ALGORITHM", config.NewDefaultValueSource(string(TokenBucket)))), &c.Policy.Algorithm)
+	config.Bind(l, "RATE_LIMIT_DEFAULT", limit(lookup("RATE_LIMIT_DEFAULT", config.NewDefaultValueSource("100/1m"))), &c.Policy.Default)
+	config.Bind(l, "RATE_LIMIT_ROUTES", routes(lookup("RATE_LIMIT_ROUTES", config.NewDefaultValueSource(""))), &c.Policy.Routes)
+
+	return c
+}
+
+func backend(src config.Configurator) config.CustomSource[Backend] {
+	return config.NewCustomSource(func() (Backend, error) {
+		v, err := src.Get()
+		if err != nil {
+			return "", err
+		}
+
+		switch b := Backend(strings.TrimSpace(v)); b {
+		case MemoryBackend, ValkeyBackend:
+			return b, nil
+		default:
+			return "", fmt.Errorf("%w: %q is not memory or valkey", config.InvalidValueError, v)
+		}
+	})
+}
+
+func algorithm(src config.Configurator) config.CustomSource[Algorithm] {
+	return config.NewCustomSource(func() (Algorithm, error) {
+		v, err := src.Get()
+		if err != nil {
+			return "", err
+		}
+
+		a, err := ParseAlgorithm(v)
+		if err != nil {
+			return "", fmt.Errorf("%w: %q is not token_bucket or sliding_window", config.InvalidValueError, v)
+		}
+
+		return a, nil
+	})
+}
+
+func limit(src config.Configurator) config.CustomSource[Limit] {
+	return config.NewCustomSource(func() (Limit, error) {
+		v, err := src.Get()
+		if err != nil {
+			return Limit{}, err
+		}
+
+		l, err := ParseLimit(v)
+		if err != nil {
+			return Limit{}, fmt.Errorf("%w: %s", config.InvalidValueError, err)
+		}
+
+		return l, nil
+	})
+}
+
+func routes(src config.Configurator) config.CustomSource[map[string]Limit] {
+	list := config.StringList(src)
+
+	return config.NewCustomSource(func() (map[string]Limit, error) {
+		items, err := list.Get()
+		if err != nil {
+			return nil, err
+		}
+
+		routes := make(map[string]Limit, len(items))
+		for _, item := range items {
+			// Patterns can't contain =, the last one separates the limit
+			i := strings.LastIndex(item, "=")
+			if i < 1 {
+				return nil, fmt.Errorf("%w: %q is not pattern=limit", config.InvalidValueError, item)
+			}
+
+			l, err := ParseLimit(item[i+1:])
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s", config.InvalidValueError, err)
+			}
+
+			routes[strings.TrimSpace(item[:i])] = l
+		}
+
+		return routes, nil
+	})
+}
@@ -0,0 +1,73 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// How often idle keys are dropped
+const sweepInterval = time.Minute
+
+type entry struct {
+	bucket  bucket
+	window  window
+	expires time.Time
+}
+
+/*
+Counts requests in this process, each replica enforces its own limits
+*/
+type InMemoryLimiter struct {
+	mu        sync.Mutex
+	entries   map[string]entry
+	now       func() time.Time
+	lastSweep time.Time
+}
+
+func (m *InMemoryLimiter) Allow(ctx context.Context, key string, algorithm Algorithm, limit Limit) (Decision, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	m.sweep(now)
+
+	e := m.entries[key]
+
+	var decision Decision
+	switch algorithm {
+	case TokenBucket:
+		e.bucket, decision = takeToken(e.bucket, limit, now)
+	case SlidingWindow:
+		e.window, decision = countRequest(e.window, limit, now)
+	default:
+		return Decision{}, UnknownAlgorithmError
+	}
+
+	// Both algorithms have forgotten a key after two windows
+	e.expires = now.Add(2 * limit.Window)
+	m.entries[key] = e
+
+	return decision, nil
+}
+
+func (m *InMemoryLimiter) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < sweepInterval {
+		return
+	}
+
+	for key, e := range m.entries {
+		if now.After(e.expires) {
+			delete(m.entries, key)
+		}
+	}
+
+	m.lastSweep = now
+}
+
+func NewInMemoryLimiter() *InMemoryLimiter {
+	return &InMemoryLimiter{
+		entries: make(map[string]entry),
+		now:     time.Now,
+	}
+}
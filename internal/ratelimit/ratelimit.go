@@ -0,0 +1,199 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var InvalidLimitError = errors.New("invalid rate limit")
+var UnknownAlgorithmError = errors.New("unknown rate limit algorithm")
+
+// MARK: Algorithms
+type Algorithm string
+
+const (
+	// Allows bursts of up to Requests, refilled evenly over Window
+	TokenBucket Algorithm = "token_bucket"
+	// Allows Requests in any Window, weighing the previous window by how much of it overlaps
+	SlidingWindow Algorithm = "sliding_window"
+)
+
+func ParseAlgorithm(s string) (Algorithm, error) {
+	switch a := Algorithm(strings.TrimSpace(s)); a {
+	case TokenBucket, SlidingWindow:
+		return a, nil
+	default:
+		return "", fmt.Errorf("%w: %q", UnknownAlgorithmError, s)
+	}
+}
+
+// MARK: Limits
+/*
+Requests allowed per Window, e.g., 100/1m
+*/
+type Limit struct {
+	Requests int
+	Window   time.Duration
+}
+
+func (l Limit) String() string {
+	return strconv.Itoa(l.Requests) + "/" + l.Window.String()
+}
+
+/*
+Parses a limit written as requests/window, e.g., "100/1m"
+*/
+func ParseLimit(s string) (Limit, error) {
+	requests, window, ok := strings.Cut(strings.TrimSpace(s), "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("%w: %q is not requests/window", InvalidLimitError, s)
+	}
+
+	n, err := strconv.Atoi(requests)
+	if err != nil || n < 1 {
+		return Limit{}, fmt.Errorf("%w: %q needs a positive number of requests", InvalidLimitError, s)
+	}
+
+	d, err := time.ParseDuration(window)
+	if err != nil || d <= 0 {
+		return Limit{}, fmt.Errorf("%w: %q needs a positive window", InvalidLimitError, s)
+	}
+
+	return Limit{Requests: n, Window: d}, nil
+}
+
+/*
+The limits for an API, Routes are keyed by the pattern a route is registered
+with, e.g., "POST /examples". Routes without their own limit get Default.
+*/
+type Policy struct {
+	Algorithm Algorithm
+	Default   Limit
+	Routes    map[string]Limit
+}
+
+func (p Policy) For(pattern string) Limit {
+	if limit, ok := p.Routes[pattern]; ok {
+		return limit
+	}
+
+	return p.Default
+}
+
+// MARK: Decisions
+/*
+The outcome of a request against a limit
+
+Reset is how long until the limit is fully available again, RetryAfter how
+long a denied client has to wait for its next request to be allowed
+*/
+type Decision struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+/*
+The Limiter interface counts requests for a key against a limit
+*/
+type Limiter interface {
+	Allow(ctx context.Context, key string, algorithm Algorithm, limit Limit) (Decision, error)
+}
+
+// MARK: Token bucket
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+/*
+Refills the bucket for the time since it was last used and takes a token from it
+*/
+func takeToken(b bucket, limit Limit, now time.Time) (bucket, Decision) {
+	capacity := float64(limit.Requests)
+	perSecond := capacity / limit.Window.Seconds()
+
+	if b.updated.IsZero() {
+		b.tokens = capacity
+	} else {
+		b.tokens = math.Min(capacity, b.tokens+now.Sub(b.updated).Seconds()*perSecond)
+	}
+	b.updated = now
+
+	decision := Decision{Limit: limit.Requests}
+
+	if b.tokens >= 1 {
+		b.tokens--
+		decision.Allowed = true
+	} else {
+		decision.RetryAfter = seconds((1 - b.tokens) / perSecond)
+	}
+
+	decision.Remaining = int(b.tokens)
+	decision.Reset = seconds((capacity - b.tokens) / perSecond)
+
+	return b, decision
+}
+
+// MARK: Sliding window
+type window struct {
+	start    time.Time
+	current  int
+	previous int
+}
+
+/*
+Counts a request in the current fixed window, estimating the sliding window
+from the previous one weighted by how much of it still overlaps
+*/
+func countRequest(w window, limit Limit, now time.Time) (window, Decision) {
+	start := now.Truncate(limit.Window)
+
+	switch {
+	case w.start.Equal(start):
+	case w.start.Add(limit.Window).Equal(start):
+		w = window{start: start, previous: w.current}
+	default:
+		w = window{start: start}
+	}
+
+	elapsed := now.Sub(start)
+	overlap := 1 - elapsed.Seconds()/limit.Window.Seconds()
+	estimate := float64(w.previous)*overlap + float64(w.current)
+
+	decision := Decision{Limit: limit.Requests, Reset: limit.Window - elapsed}
+	threshold := float64(limit.Requests) - 1
+
+	if estimate <= threshold {
+		w.current++
+		decision.Allowed = true
+		decision.Remaining = max(0, int(math.Floor(threshold-estimate)))
+
+		return w, decision
+	}
+
+	// When the previous window has faded enough in this window, or else how far into the next
+	if w.previous > 0 && float64(w.current) <= threshold {
+		fade := (1 - (threshold-float64(w.current))/float64(w.previous)) * limit.Window.Seconds()
+		decision.RetryAfter = seconds(fade - elapsed.Seconds())
+	} else {
+		fade := (1 - threshold/float64(w.current)) * limit.Window.Seconds()
+		decision.RetryAfter = decision.Reset + seconds(fade)
+	}
+
+	return w, decision
+}
+
+/*
+Headers carry whole seconds, round up so clients never retry too early
+*/
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Ceil(math.Max(s, 0))) * time.Second
+}
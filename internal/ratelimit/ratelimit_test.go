@@ -0,0 +1,172 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected Limit
+		err      error
+	}{
+		{name: "PassingCase", value: "100/1m", expected: Limit{Requests: 100, Window: time.Minute}},
+		{name: "PassingCase-Whitespace", value: " 5/10s ", expected: Limit{Requests: 5, Window: 10 * time.Second}},
+		{name: "FailingCase-NoWindow", value: "100", err: InvalidLimitError},
+		{name: "FailingCase-ZeroRequests", value: "0/1m", err: InvalidLimitError},
+		{name: "FailingCase-BadWindow", value: "10/minute", err: InvalidLimitError},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// When
+			limit, err := ParseLimit(tc.value)
+
+			// Then
+			if !errors.Is(err, tc.err) {
+				t.Errorf("expected error %v, got %v", tc.err, err)
+			}
+
+			if limit != tc.expected {
+				t.Errorf("expected %s, got %s", tc.expected, limit)
+			}
+		})
+	}
+}
+
+func TestPolicyFor(t *testing.T) {
+	policy := Policy{
+		Default: Limit{Requests: 100, Window: time.Minute},
+		Routes:  map[string]Limit{"POST /examples": {Requests: 5, Window: time.Minute}},
+	}
+
+	if limit := policy.For("POST /examples"); limit.Requests != 5 {
+		t.Errorf("expected the route limit, got %s", limit)
+	}
+
+	if limit := policy.For("GET /examples"); limit.Requests != 100 {
+		t.Errorf("expected the default limit, got %s", limit)
+	}
+}
+
+/*
+Each step advances the clock by after and then makes a request
+*/
+type step struct {
+	after      time.Duration
+	allowed    bool
+	remaining  int
+	retryAfter time.Duration
+}
+
+func TestInMemoryLimiter(t *testing.T) {
+	limit := Limit{Requests: 2, Window: 10 * time.Second}
+
+	tests := []struct {
+		name      string
+		algorithm Algorithm
+		steps     []step
+	}{
+		{
+			name:      "TokenBucket-Burst",
+			algorithm: TokenBucket,
+			steps: []step{
+				{allowed: true, remaining: 1},
+				{allowed: true, remaining: 0},
+				{allowed: false, retryAfter: 5 * time.Second},
+			},
+		},
+		{
+			name:      "TokenBucket-Refills",
+			algorithm: TokenBucket,
+			steps: []step{
+				{allowed: true, remaining: 1},
+				{allowed: true, remaining: 0},
+				{after: 5 * time.Second, allowed: true, remaining: 0},
+				{after: 2 * time.Second, allowed: false, retryAfter: 3 * time.Second},
+			},
+		},
+		{
+			name:      "SlidingWindow-Limit",
+			algorithm: SlidingWindow,
+			steps: []step{
+				{allowed: true, remaining: 1},
+				{allowed: true, remaining: 0},
+				{allowed: false, retryAfter: 15 * time.Second},
+			},
+		},
+		{
+			name:      "SlidingWindow-PreviousWindowFades",
+			algorithm: SlidingWindow,
+			steps: []step{
+				{allowed: true, remaining: 1},
+				{allowed: true, remaining: 0},
+				// Halfway into the next window both previous requests still weigh 0.5
+				{after: 15 * time.Second, allowed: true, remaining: 0},
+				{allowed: false, retryAfter: 5 * time.Second},
+			},
+		},
+		{
+			name:      "SlidingWindow-Forgets",
+			algorithm: SlidingWindow,
+			steps: []step{
+				{allowed: true, remaining: 1},
+				{allowed: true, remaining: 0},
+				{after: 30 * time.Second, allowed: true, remaining: 1},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			now := time.Date(2025, time.March, 10, 12, 0, 0, 0, time.UTC)
+			limiter := NewInMemoryLimiter()
+			limiter.now = func() time.Time { return now }
+
+			for i, s := range tc.steps {
+				now = now.Add(s.after)
+
+				// When
+				decision, err := limiter.Allow(context.TODO(), "user:123", tc.algorithm, limit)
+
+				// Then
+				if err != nil {
+					t.Fatalf("step %d: unexpected error %v", i, err)
+				}
+
+				if decision.Allowed != s.allowed {
+					t.Errorf("step %d: expected allowed %t, got %t", i, s.allowed, decision.Allowed)
+				}
+
+				if decision.Allowed && decision.Remaining != s.remaining {
+					t.Errorf("step %d: expected %d remaining, got %d", i, s.remaining, decision.Remaining)
+				}
+
+				if decision.RetryAfter != s.retryAfter {
+					t.Errorf("step %d: expected retry after %s, got %s", i, s.retryAfter, decision.RetryAfter)
+				}
+			}
+		})
+	}
+}
+
+func TestInMemoryLimiterKeys(t *testing.T) {
+	// Given
+	limiter := NewInMemoryLimiter()
+	limit := Limit{Requests: 1, Window: time.Minute}
+
+	limiter.Allow(context.TODO(), "user:123", TokenBucket, limit)
+
+	// When
+	decision, _ := limiter.Allow(context.TODO(), "user:456", TokenBucket, limit)
+
+	// Then
+	if !decision.Allowed {
+		t.Errorf("expected every key to have its own limit")
+	}
+}
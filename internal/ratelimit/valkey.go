@@ -0,0 +1,124 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/valkey-io/valkey-go"
+)
+
+var unexpectedReplyError = errors.New("unexpected reply from rate limit script")
+
+/*
+Same refill as takeToken, times are in milliseconds from the server clock so
+replicas with drifting clocks still agree
+*/
+var tokenBucketScript = valkey.NewLuaScript(`
+local capacity = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+local rate = capacity / window
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'updated')
+local tokens = tonumber(state[1])
+if tokens == nil then
+  tokens = capacity
+else
+  tokens = math.min(capacity, tokens + (now - tonumber(state[2])) * rate)
+end
+
+local allowed = 0
+local retry = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+else
+  retry = math.ceil((1 - tokens) / rate)
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'updated', now)
+redis.call('PEXPIRE', KEYS[1], window * 2)
+
+return {allowed, math.floor(tokens), math.ceil((capacity - tokens) / rate), retry}
+`)
+
+/*
+Same estimate as countRequest, each fixed window is its own counter that
+expires once it can no longer overlap
+*/
+var slidingWindowScript = valkey.NewLuaScript(`
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+local start = now - (now % window)
+local elapsed = now - start
+
+local current_key = KEYS[1] .. ':' .. start
+local current = tonumber(redis.call('GET', current_key) or '0')
+local previous = tonumber(redis.call('GET', KEYS[1] .. ':' .. (start - window)) or '0')
+
+local estimate = previous * (1 - elapsed / window) + current
+local threshold = limit - 1
+local reset = window - elapsed
+
+if estimate <= threshold then
+  redis.call('INCR', current_key)
+  redis.call('PEXPIRE', current_key, window * 2)
+  return {1, math.max(0, math.floor(threshold - estimate)), reset, 0}
+end
+
+local retry
+if previous > 0 and current <= threshold then
+  retry = (1 - (threshold - current) / previous) * window - elapsed
+else
+  retry = reset + (1 - threshold / current) * window
+end
+
+return {0, 0, reset, math.ceil(retry)}
+`)
+
+/*
+Counts requests in Valkey so every replica shares the same limits
+*/
+type ValkeyLimiter struct {
+	client valkey.Client
+}
+
+func (v *ValkeyLimiter) Allow(ctx context.Context, key string, algorithm Algorithm, limit Limit) (Decision, error) {
+	var script *valkey.Lua
+	switch algorithm {
+	case TokenBucket:
+		script = tokenBucketScript
+	case SlidingWindow:
+		script = slidingWindowScript
+	default:
+		return Decision{}, UnknownAlgorithmError
+	}
+
+	args := []string{strconv.Itoa(limit.Requests), strconv.FormatInt(limit.Window.Milliseconds(), 10)}
+
+	// allowed, remaining, reset and retry after in milliseconds
+	result, err := script.Exec(ctx, v.client, []string{key}, args).AsIntSlice()
+	if err != nil {
+		return Decision{}, err
+	}
+
+	if len(result) != 4 {
+		return Decision{}, unexpectedReplyError
+	}
+
+	return Decision{
+		Allowed:    result[0] == 1,
+		Limit:      limit.Requests,
+		Remaining:  int(result[1]),
+		Reset:      seconds(float64(result[2]) / 1000),
+		RetryAfter: seconds(float64(result[3]) / 1000),
+	}, nil
+}
+
+func NewValkeyLimiter(client valkey.Client) *ValkeyLimiter {
+	return &ValkeyLimiter{client: client}
+}
@@ -0,0 +1,280 @@
+package requests
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// MARK: Validators
+/*
+The validators of the current representation of the target resource
+
+ETag is the quoted entity tag (e.g., `"3"` or `W/"abc"`), empty when the
+resource has none. LastModified is ignored when zero. Exists is false when
+there is no current representation, which matters for If-Match: * and
+If-None-Match: *
+*/
+type Validators struct {
+	ETag         string
+	LastModified time.Time
+	Exists       bool
+}
+
+type Outcome int
+
+const (
+	// Carry on with the request
+	Proceed Outcome = iota
+	// Respond with 304, only for GET and HEAD
+	NotModified
+	// Respond with 412
+	PreconditionFailed
+)
+
+func (o Outcome) String() string {
+	switch o {
+	case Proceed:
+		return "proceed"
+	case NotModified:
+		return "not-modified"
+	case PreconditionFailed:
+		return "precondition-failed"
+	default:
+		return "unknown"
+	}
+}
+
+/*
+Reports whether the request carries any conditional headers
+*/
+func HasPreconditions(r *http.Request) bool {
+	for _, h := range []HeaderKey{IfMatch, IfNoneMatch, IfModifiedSince, IfUnmodifiedSince} {
+		if len(r.Header.Values(h.Name())) != 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// MARK: Evaluate
+/*
+Evaluates the conditional headers of a request against the validators of the
+target resource, following the precedence in RFC 9110 section 13.2.2
+
+ 1. If-Match, when false the request fails with 412
+ 2. If-Unmodified-Since, only when If-Match is absent
+ 3. If-None-Match, when false GET and HEAD get a 304, everything else a 412
+ 4. If-Modified-Since, only for GET and HEAD when If-None-Match is absent
+
+Dates that don't parse are ignored, as required by the RFC
+*/
+func EvaluatePreconditions(r *http.Request, v Validators) Outcome {
+	safe := r.Method == http.MethodGet || r.Method == http.MethodHead
+
+	if ifMatch, ok := headerList(r, IfMatch); ok {
+		if !matchesAny(ifMatch, v, strongCompare) {
+			return PreconditionFailed
+		}
+	} else if since, ok := headerTime(r, IfUnmodifiedSince); ok {
+		if v.Exists && !v.LastModified.IsZero() && modifiedSince(v.LastModified, since) {
+			return PreconditionFailed
+		}
+	}
+
+	if ifNoneMatch, ok := headerList(r, IfNoneMatch); ok {
+		if matchesAny(ifNoneMatch, v, weakCompare) {
+			if safe {
+				return NotModified
+			}
+
+			return PreconditionFailed
+		}
+	} else if since, ok := headerTime(r, IfModifiedSince); ok && safe {
+		if v.Exists && !v.LastModified.IsZero() && !modifiedSince(v.LastModified, since) {
+			return NotModified
+		}
+	}
+
+	return Proceed
+}
+
+/*
+HTTP dates have a resolution of one second, compare at the same resolution
+*/
+func modifiedSince(lastModified, since time.Time) bool {
+	return lastModified.Truncate(time.Second).After(since)
+}
+
+// MARK: Entity tags
+type entityTag struct {
+	weak   bool
+	opaque string
+}
+
+func strongCompare(a, b entityTag) bool {
+	return !a.weak && !b.weak && a.opaque == b.opaque
+}
+
+func weakCompare(a, b entityTag) bool {
+	return a.opaque == b.opaque
+}
+
+/*
+Reports whether any of the tags in a header list match the current ETag
+
+A wildcard matches any current representation
+*/
+func matchesAny(tags []string, v Validators, compare func(a, b entityTag) bool) bool {
+	if !v.Exists {
+		return false
+	}
+
+	current, ok := parseEntityTag(v.ETag)
+
+	for _, tag := range tags {
+		if tag == "*" {
+			return true
+		}
+
+		if !ok {
+			continue
+		}
+
+		if candidate, valid := parseEntityTag(tag); valid && (compare(candidate, current) || compare(candidate.decoded(), current)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// MARK: Content codings
+/*
+Content codings a compressed representation's strong ETag can be suffixed with
+*/
+var etagCodings = []string{"gzip", "deflate", "br", "zstd", "compress"}
+
+/*
+The ETag of a representation compressed with coding
+
+A strong ETag names the coding, e.g., "3" is "3-gzip", the compressed bytes are
+a different representation. A weak ETag is unchanged, the content is the same.
+*/
+func CodedEtag(etag, coding string) string {
+	tag, ok := parseEntityTag(etag)
+	if !ok || tag.weak {
+		return etag
+	}
+
+	return `"` + tag.opaque + "-" + coding + `"`
+}
+
+/*
+The tag without its content coding, "3-gzip" compares like "3"
+
+Conditions are about the state of the resource, which a coding doesn't change
+*/
+func (t entityTag) decoded() entityTag {
+	for _, coding := range etagCodings {
+		if opaque, ok := strings.CutSuffix(t.opaque, "-"+coding); ok {
+			return entityTag{weak: t.weak, opaque: opaque}
+		}
+	}
+
+	return t
+}
+
+func parseEntityTag(s string) (entityTag, bool) {
+	var tag entityTag
+
+	if strings.HasPrefix(s, "W/") {
+		tag.weak = true
+		s = s[2:]
+	}
+
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return entityTag{}, false
+	}
+
+	tag.opaque = s[1 : len(s)-1]
+
+	if strings.ContainsRune(tag.opaque, '"') {
+		return entityTag{}, false
+	}
+
+	return tag, true
+}
+
+// MARK: Header parsing
+/*
+Splits a list of entity tags, handles repeated headers and commas inside tags
+
+Returns false when the header is absent
+*/
+func headerList(r *http.Request, key HeaderKey) ([]string, bool) {
+	values := r.Header.Values(key.Name())
+
+	if len(values) == 0 {
+		return nil, false
+	}
+
+	var tags []string
+	for _, value := range values {
+		tags = append(tags, splitEntityTags(value)...)
+	}
+
+	return tags, true
+}
+
+func splitEntityTags(s string) []string {
+	var tags []string
+
+	for {
+		s = strings.TrimLeft(s, " \t,")
+		if len(s) == 0 {
+			return tags
+		}
+
+		if s[0] == '*' {
+			tags = append(tags, "*")
+			s = s[1:]
+			continue
+		}
+
+		start := 0
+		if strings.HasPrefix(s, "W/") {
+			start = 2
+		}
+
+		// Malformed, keep what we have so far and give up on the rest
+		if len(s) <= start || s[start] != '"' {
+			return tags
+		}
+
+		end := strings.IndexByte(s[start+1:], '"')
+		if end < 0 {
+			return tags
+		}
+
+		end += start + 2
+		tags = append(tags, s[:end])
+		s = s[end:]
+	}
+}
+
+func headerTime(r *http.Request, key HeaderKey) (time.Time, bool) {
+	value := r.Header.Get(key.Name())
+
+	if len(value) == 0 {
+		return time.Time{}, false
+	}
+
+	t, err := http.ParseTime(value)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return t, true
+}
@@ -0,0 +1,241 @@
+package requests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestEvaluatePreconditions(t *testing.T) {
+	lastModified := time.Date(2025, time.March, 10, 12, 0, 0, 0, time.UTC)
+	before := lastModified.Add(-time.Hour).Format(http.TimeFormat)
+	after := lastModified.Add(time.Hour).Format(http.TimeFormat)
+	current := Validators{ETag: `"2"`, LastModified: lastModified, Exists: true}
+
+	tests := []struct {
+		name       string
+		method     string
+		headers    map[string]string
+		validators Validators
+		expected   Outcome
+	}{
+		{
+			name:       "PassingCase-NoConditions",
+			method:     http.MethodGet,
+			validators: current,
+			expected:   Proceed,
+		},
+		{
+			name:       "PassingCase-IfNoneMatchHit",
+			method:     http.MethodGet,
+			headers:    map[string]string{"If-None-Match": `"2"`},
+			validators: current,
+			expected:   NotModified,
+		},
+		{
+			name:       "PassingCase-IfNoneMatchWeakComparison",
+			method:     http.MethodGet,
+			headers:    map[string]string{"If-None-Match": `W/"2"`},
+			validators: current,
+			expected:   NotModified,
+		},
+		{
+			name:       "PassingCase-IfNoneMatchList",
+			method:     http.MethodGet,
+			headers:    map[string]string{"If-None-Match": `"1", "a,b", W/"2"`},
+			validators: current,
+			expected:   NotModified,
+		},
+		{
+			name:       "PassingCase-IfNoneMatchCoded",
+			method:     http.MethodGet,
+			headers:    map[string]string{"If-None-Match": `"2-gzip"`},
+			validators: current,
+			expected:   NotModified,
+		},
+		{
+			name:       "PassingCase-IfNoneMatchOtherMediaType",
+			method:     http.MethodGet,
+			headers:    map[string]string{"If-None-Match": `"2-cbor"`},
+			validators: current,
+			expected:   Proceed,
+		},
+		{
+			name:       "PassingCase-IfMatchCoded",
+			method:     http.MethodPatch,
+			headers:    map[string]string{"If-Match": `"2-deflate"`},
+			validators: current,
+			expected:   Proceed,
+		},
+		{
+			name:       "PassingCase-IfNoneMatchMiss",
+			method:     http.MethodGet,
+			headers:    map[string]string{"If-None-Match": `"1"`},
+			validators: current,
+			expected:   Proceed,
+		},
+		{
+			name:       "PassingCase-IfNoneMatchUnquotedNeverMatches",
+			method:     http.MethodGet,
+			headers:    map[string]string{"If-None-Match": `2`},
+			validators: current,
+			expected:   Proceed,
+		},
+		{
+			name:       "PassingCase-IfNoneMatchWildcard",
+			method:     http.MethodGet,
+			headers:    map[string]string{"If-None-Match": `*`},
+			validators: current,
+			expected:   NotModified,
+		},
+		{
+			name:       "PassingCase-IfNoneMatchWildcardMissingResource",
+			method:     http.MethodPut,
+			headers:    map[string]string{"If-None-Match": `*`},
+			validators: Validators{},
+			expected:   Proceed,
+		},
+		{
+			name:       "FailingCase-IfNoneMatchOnUnsafeMethod",
+			method:     http.MethodDelete,
+			headers:    map[string]string{"If-None-Match": `"2"`},
+			validators: current,
+			expected:   PreconditionFailed,
+		},
+		{
+			name:       "PassingCase-IfMatchHit",
+			method:     http.MethodPatch,
+			headers:    map[string]string{"If-Match": `"1", "2"`},
+			validators: current,
+			expected:   Proceed,
+		},
+		{
+			name:       "FailingCase-IfMatchWeakNeverMatches",
+			method:     http.MethodPatch,
+			headers:    map[string]string{"If-Match": `W/"2"`},
+			validators: current,
+			expected:   PreconditionFailed,
+		},
+		{
+			name:       "FailingCase-IfMatchMiss",
+			method:     http.MethodPatch,
+			headers:    map[string]string{"If-Match": `"1"`},
+			validators: current,
+			expected:   PreconditionFailed,
+		},
+		{
+			name:       "PassingCase-IfMatchWildcard",
+			method:     http.MethodDelete,
+			headers:    map[string]string{"If-Match": `*`},
+			validators: current,
+			expected:   Proceed,
+		},
+		{
+			name:       "FailingCase-IfMatchWildcardMissingResource",
+			method:     http.MethodDelete,
+			headers:    map[string]string{"If-Match": `*`},
+			validators: Validators{},
+			expected:   PreconditionFailed,
+		},
+		{
+			name:       "PassingCase-IfMatchTakesPrecedenceOverIfUnmodifiedSince",
+			method:     http.MethodPatch,
+			headers:    map[string]string{"If-Match": `"2"`, "If-Unmodified-Since": before},
+			validators: current,
+			expected:   Proceed,
+		},
+		{
+			name:       "FailingCase-IfUnmodifiedSince",
+			method:     http.MethodPatch,
+			headers:    map[string]string{"If-Unmodified-Since": before},
+			validators: current,
+			expected:   PreconditionFailed,
+		},
+		{
+			name:       "PassingCase-IfUnmodifiedSince",
+			method:     http.MethodPatch,
+			headers:    map[string]string{"If-Unmodified-Since": after},
+			validators: current,
+			expected:   Proceed,
+		},
+		{
+			name:       "PassingCase-IfUnmodifiedSinceInvalidDateIgnored",
+			method:     http.MethodPatch,
+			headers:    map[string]string{"If-Unmodified-Since": "yesterday"},
+			validators: current,
+			expected:   Proceed,
+		},
+		{
+			name:       "PassingCase-IfModifiedSinceNotModified",
+			method:     http.MethodGet,
+			headers:    map[string]string{"If-Modified-Since": lastModified.Format(http.TimeFormat)},
+			validators: Validators{ETag: `"2"`, LastModified: lastModified.Add(500 * time.Millisecond), Exists: true},
+			expected:   NotModified,
+		},
+		{
+			name:       "PassingCase-IfModifiedSinceModified",
+			method:     http.MethodGet,
+			headers:    map[string]string{"If-Modified-Since": before},
+			validators: current,
+			expected:   Proceed,
+		},
+		{
+			name:       "PassingCase-IfNoneMatchTakesPrecedenceOverIfModifiedSince",
+			method:     http.MethodGet,
+			headers:    map[string]string{"If-None-Match": `"1"`, "If-Modified-Since": after},
+			validators: current,
+			expected:   Proceed,
+		},
+		{
+			name:       "PassingCase-IfModifiedSinceIgnoredOnUnsafeMethod",
+			method:     http.MethodPatch,
+			headers:    map[string]string{"If-Modified-Since": after},
+			validators: current,
+			expected:   Proceed,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			r := httptest.NewRequest(tc.method, "/examples/123", nil)
+			for k, v := range tc.headers {
+				r.Header.Set(k, v)
+			}
+
+			// When
+			outcome := EvaluatePreconditions(r, tc.validators)
+
+			// Then
+			if outcome != tc.expected {
+				t.Errorf("expected %s, got %s", tc.expected, outcome)
+			}
+		})
+	}
+}
+
+func TestCodedEtag(t *testing.T) {
+	tests := []struct {
+		name     string
+		etag     string
+		expected string
+	}{
+		{name: "PassingCase-Strong", etag: `"3"`, expected: `"3-gzip"`},
+		{name: "PassingCase-MediaType", etag: `"3-cbor"`, expected: `"3-cbor-gzip"`},
+		{name: "PassingCase-WeakUnchanged", etag: `W/"abc"`, expected: `W/"abc"`},
+		{name: "PassingCase-InvalidUnchanged", etag: `3`, expected: `3`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// When
+			coded := CodedEtag(tc.etag, "gzip")
+
+			// Then
+			if coded != tc.expected {
+				t.Errorf("expected %s, got %s", tc.expected, coded)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package requests
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/moonmoon1919/go-api-reference/internal/store"
+)
+
+const cursorParam = "cursor"
+
+var InvalidCursorError = errors.New("cursor is invalid")
+var CursorWithPageError = errors.New("cursor cannot be combined with page")
+
+// MARK: Codec
+/*
+Turns cursors into opaque tokens and back
+
+Tokens are signed so clients can't forge a position in someone else's
+listing or depend on what is inside them. Every instance must share the
+same secret for tokens to work across instances.
+*/
+type CursorCodec struct {
+	secret []byte
+}
+
+func NewCursorCodec(secret string) CursorCodec {
+	return CursorCodec{secret: []byte(secret)}
+}
+
+func (c CursorCodec) sign(payload string) string {
+	mac := hmac.New(sha256.New, c.secret)
+	mac.Write([]byte(payload))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (c CursorCodec) Encode(cursor store.Cursor) string {
+	// Marshalling a struct of a string and an int can't fail
+	data, _ := json.Marshal(cursor)
+	payload := base64.RawURLEncoding.EncodeToString(data)
+
+	return payload + "." + c.sign(payload)
+}
+
+/*
+Encodes an optional cursor, nil becomes an empty token
+*/
+func (c CursorCodec) Token(cursor *store.Cursor) string {
+	if cursor == nil {
+		return ""
+	}
+
+	return c.Encode(*cursor)
+}
+
+func (c CursorCodec) Decode(token string) (store.Cursor, error) {
+	payload, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return store.Cursor{}, InvalidCursorError
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(c.sign(payload))) {
+		return store.Cursor{}, InvalidCursorError
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return store.Cursor{}, InvalidCursorError
+	}
+
+	var cursor store.Cursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return store.Cursor{}, InvalidCursorError
+	}
+
+	return cursor, nil
+}
+
+// MARK: Parameters
+/*
+Reads the cursor query parameter, nil when the request has none
+
+A cursor already fixes the position so it can't be sent with page
+*/
+func GetCursor(r *http.Request, codec CursorCodec) (*store.Cursor, error) {
+	query := r.URL.Query()
+
+	token := query.Get(cursorParam)
+	if token == "" {
+		return nil, nil
+	}
+
+	if query.Has("page") {
+		return nil, CursorWithPageError
+	}
+
+	cursor, err := codec.Decode(token)
+	if err != nil {
+		return nil, err
+	}
+
+	return &cursor, nil
+}
+
+/*
+Reads limit and total with either cursor or page
+*/
+func GetCursorPaginationParameters(r *http.Request, codec CursorCodec) (store.Pagination, error) {
+	limit, page, err := GetPaginationParameters(r)
+	if err != nil {
+		return store.Pagination{}, err
+	}
+
+	count, err := GetCountMode(r)
+	if err != nil {
+		return store.Pagination{}, err
+	}
+
+	cursor, err := GetCursor(r, codec)
+	if err != nil {
+		return store.Pagination{}, err
+	}
+
+	return store.Pagination{Limit: limit, Page: page, Cursor: cursor, Count: count}, nil
+}
@@ -0,0 +1,126 @@
+package requests
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/moonmoon1919/go-api-reference/internal/store"
+)
+
+func TestCursorCodec(t *testing.T) {
+	codec := NewCursorCodec("secret")
+	valid := codec.Encode(store.Cursor{Key: "b7c2", Direction: store.Backward})
+	payload, _, _ := strings.Cut(valid, ".")
+
+	tests := []struct {
+		name       string
+		token      string
+		expected   store.Cursor
+		errMessage string
+	}{
+		{
+			name:     "PassingCase",
+			token:    valid,
+			expected: store.Cursor{Key: "b7c2", Direction: store.Backward},
+		},
+		{
+			name:       "FailingCase-TamperedSignature",
+			token:      payload + ".AAAA",
+			errMessage: "cursor is invalid",
+		},
+		{
+			name:       "FailingCase-OtherSecret",
+			token:      NewCursorCodec("other").Encode(store.Cursor{Key: "b7c2"}),
+			errMessage: "cursor is invalid",
+		},
+		{
+			name:       "FailingCase-Unsigned",
+			token:      payload,
+			errMessage: "cursor is invalid",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			// When
+			cursor, err := codec.Decode(tc.token)
+
+			// Then
+			var errMessage string
+			if err != nil {
+				errMessage = err.Error()
+			}
+
+			if errMessage != tc.errMessage {
+				t.Errorf("expected error message %s, got %s", tc.errMessage, errMessage)
+			}
+
+			if cursor != tc.expected {
+				t.Errorf("expected %+v, got %+v", tc.expected, cursor)
+			}
+		})
+	}
+}
+
+func TestGetCursorPaginationParameters(t *testing.T) {
+	codec := NewCursorCodec("secret")
+	token := codec.Encode(store.Cursor{Key: "b7c2"})
+
+	tests := []struct {
+		name           string
+		query          string
+		expectedCursor bool
+		errMessage     string
+	}{
+		{
+			name:  "PassingCase-Page",
+			query: "limit=10&page=2",
+		},
+		{
+			name:           "PassingCase-Cursor",
+			query:          "limit=10&cursor=" + token,
+			expectedCursor: true,
+		},
+		{
+			name:       "FailingCase-CursorWithPage",
+			query:      "limit=10&page=2&cursor=" + token,
+			errMessage: "cursor cannot be combined with page",
+		},
+		{
+			name:       "FailingCase-InvalidTotal",
+			query:      "limit=10&total=some",
+			errMessage: "total must be exact or estimated",
+		},
+		{
+			name:       "FailingCase-InvalidLimit",
+			query:      "limit=nan&cursor=" + token,
+			errMessage: "limit must be an integer",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			r, _ := http.NewRequest(http.MethodGet, "/examples?"+tc.query, nil)
+
+			// When
+			p, err := GetCursorPaginationParameters(r, codec)
+
+			// Then
+			var errMessage string
+			if err != nil {
+				errMessage = err.Error()
+			}
+
+			if errMessage != tc.errMessage {
+				t.Errorf("expected error message %s, got %s", tc.errMessage, errMessage)
+			}
+
+			if (p.Cursor != nil) != tc.expectedCursor {
+				t.Errorf("expected cursor %t, got %+v", tc.expectedCursor, p.Cursor)
+			}
+		})
+	}
+}
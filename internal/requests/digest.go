@@ -0,0 +1,172 @@
+package requests
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/moonmoon1919/go-api-reference/internal/responses"
+)
+
+const (
+	ContentDigest      HeaderKey = "Content-Digest"
+	ReprDigest         HeaderKey = "Repr-Digest"
+	codeDigestMismatch           = "DIGEST_MISMATCH"
+	codeInvalidDigest            = "INVALID_DIGEST"
+)
+
+var DigestMismatchError = errors.New("request body does not match its digest")
+var InvalidDigestError = errors.New("digest is not a dictionary of base64 digests")
+
+// MARK: Digests
+/*
+One digest a client sent, header is where it came from
+*/
+type digest struct {
+	header HeaderKey
+	alg    responses.DigestAlgorithm
+	value  []byte
+}
+
+/*
+Parses an RFC 9530 digest dictionary, e.g., sha-256=:X48E9q...=:
+
+Algorithms we don't support are ignored as the RFC asks. The colons around
+the value are optional, our own responses send it without them.
+*/
+func parseDigests(key HeaderKey, header string) ([]digest, error) {
+	digests := make([]digest, 0)
+
+	for _, member := range strings.Split(header, ",") {
+		member = strings.TrimSpace(member)
+		if member == "" {
+			continue
+		}
+
+		name, value, ok := strings.Cut(member, "=")
+		if !ok {
+			return nil, InvalidDigestError
+		}
+
+		alg := responses.DigestAlgorithm(strings.ToLower(strings.TrimSpace(name)))
+		h, ok := responses.NewDigestHash(alg)
+		if !ok {
+			continue
+		}
+
+		value = strings.TrimSpace(value)
+		if strings.HasPrefix(value, ":") {
+			if len(value) < 2 || !strings.HasSuffix(value, ":") {
+				return nil, InvalidDigestError
+			}
+
+			value = value[1 : len(value)-1]
+		}
+
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil || len(decoded) != h.Size() {
+			return nil, InvalidDigestError
+		}
+
+		digests = append(digests, digest{header: key, alg: alg, value: decoded})
+	}
+
+	return digests, nil
+}
+
+/*
+Hashes the body as it is read and checks it against the digests at the end
+
+Requests aren't content coded or partial, so the Content-Digest and the
+Repr-Digest are both of the body as it was sent.
+*/
+type digestReader struct {
+	r       io.Reader
+	hashes  map[responses.DigestAlgorithm]hash.Hash
+	digests []digest
+}
+
+func (d *digestReader) Read(p []byte) (int, error) {
+	n, err := d.r.Read(p)
+
+	for _, h := range d.hashes {
+		h.Write(p[:n])
+	}
+
+	if errors.Is(err, io.EOF) {
+		for _, expected := range d.digests {
+			if !bytes.Equal(d.hashes[expected.alg].Sum(nil), expected.value) {
+				return n, fmt.Errorf("%w: %s %s", DigestMismatchError, expected.header.Name(), expected.alg)
+			}
+		}
+	}
+
+	return n, err
+}
+
+/*
+The body of r, checked against its digests when the client sent any
+*/
+func verifiedBody(r *http.Request) (io.Reader, bool, error) {
+	digests := make([]digest, 0)
+
+	for _, key := range []HeaderKey{ContentDigest, ReprDigest} {
+		header := r.Header.Get(key.Name())
+		if header == "" {
+			continue
+		}
+
+		parsed, err := parseDigests(key, header)
+		if err != nil {
+			return nil, false, err
+		}
+
+		digests = append(digests, parsed...)
+	}
+
+	if len(digests) == 0 {
+		return r.Body, false, nil
+	}
+
+	reader := &digestReader{r: r.Body, hashes: make(map[responses.DigestAlgorithm]hash.Hash), digests: digests}
+	for _, d := range digests {
+		reader.hashes[d.alg], _ = responses.NewDigestHash(d.alg)
+	}
+
+	return reader, true, nil
+}
+
+func isDigestError(err error) bool {
+	return errors.Is(err, DigestMismatchError) || errors.Is(err, InvalidDigestError)
+}
+
+/*
+Decodes the JSON body of r into v, verifying its digests while it's read
+
+The rest of the body is read after the JSON value so the digest covers all
+of it. A digest failure is reported over a decode error, the body that
+didn't decode may not be the one the client sent.
+*/
+func decodeVerified(r *http.Request, v any) error {
+	body, verified, err := verifiedBody(r)
+	if err != nil {
+		return err
+	}
+
+	decodeErr := json.NewDecoder(body).Decode(v)
+	if !verified || isDigestError(decodeErr) {
+		return decodeErr
+	}
+
+	if _, err := io.Copy(io.Discard, body); isDigestError(err) {
+		return err
+	}
+
+	return decodeErr
+}
@@ -0,0 +1,166 @@
+package requests
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func sha256Header(body string) string {
+	hash := sha256.Sum256([]byte(body))
+	return "sha-256=:" + base64.StdEncoding.EncodeToString(hash[:]) + ":"
+}
+
+func sha512Header(body string) string {
+	hash := sha512.Sum512([]byte(body))
+	return "sha-512=:" + base64.StdEncoding.EncodeToString(hash[:]) + ":"
+}
+
+func TestDecodeBodyDigests(t *testing.T) {
+	body := `{"title": "hello", "count": 1}`
+	bareSha256 := strings.Trim(strings.TrimPrefix(sha256Header(body), "sha-256="), ":")
+
+	tests := []struct {
+		name          string
+		body          string
+		contentDigest string
+		reprDigest    string
+		expectedCode  string
+	}{
+		{
+			name: "PassingCase-NoDigest",
+			body: body,
+		},
+		{
+			name:          "PassingCase-Sha256",
+			body:          body,
+			contentDigest: sha256Header(body),
+		},
+		{
+			name:          "PassingCase-Sha512",
+			body:          body,
+			contentDigest: sha512Header(body),
+		},
+		{
+			name:          "PassingCase-Both",
+			body:          body,
+			contentDigest: sha256Header(body) + ", " + sha512Header(body),
+			reprDigest:    sha512Header(body),
+		},
+		{
+			name:          "PassingCase-WithoutColons",
+			body:          body,
+			contentDigest: "sha-256=" + bareSha256,
+		},
+		{
+			name:          "PassingCase-UnsupportedIgnored",
+			body:          body,
+			contentDigest: "md5=:XrY7u+Ae7tCTyyK7j1rNww==:",
+		},
+		{
+			name:          "FailingCase-Mismatch",
+			body:          body,
+			contentDigest: sha256Header(`{"title": "other", "count": 1}`),
+			expectedCode:  codeDigestMismatch,
+		},
+		{
+			name:         "FailingCase-ReprMismatch",
+			body:         body,
+			reprDigest:   sha512Header("tampered"),
+			expectedCode: codeDigestMismatch,
+		},
+		{
+			name:          "FailingCase-TrailingBytes",
+			body:          body + "   extra",
+			contentDigest: sha256Header(body),
+			expectedCode:  codeDigestMismatch,
+		},
+		{
+			name:          "FailingCase-InvalidJsonMismatch",
+			body:          `{"title": `,
+			contentDigest: sha256Header(body),
+			expectedCode:  codeDigestMismatch,
+		},
+		{
+			name:          "FailingCase-NotBase64",
+			body:          body,
+			contentDigest: "sha-256=:not base64:",
+			expectedCode:  codeInvalidDigest,
+		},
+		{
+			name:          "FailingCase-WrongLength",
+			body:          body,
+			contentDigest: "sha-512=:" + bareSha256 + ":",
+			expectedCode:  codeInvalidDigest,
+		},
+		{
+			name:          "FailingCase-NotADictionary",
+			body:          body,
+			contentDigest: "sha-256",
+			expectedCode:  codeInvalidDigest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			r := httptest.NewRequest(http.MethodPost, "/examples", strings.NewReader(tc.body))
+			if tc.contentDigest != "" {
+				r.Header.Set("Content-Digest", tc.contentDigest)
+			}
+			if tc.reprDigest != "" {
+				r.Header.Set("Repr-Digest", tc.reprDigest)
+			}
+
+			// When
+			var decoded titleBody
+			err := DecodeBody(r, &decoded)
+
+			// Then
+			if tc.expectedCode == "" {
+				if err != nil {
+					t.Fatalf("expected no error, got %s", err.Error())
+				}
+
+				if decoded.Title != "hello" {
+					t.Errorf("expected the body to be decoded, got %+v", decoded)
+				}
+
+				return
+			}
+
+			problem, ok := BodyProblem(err)
+			if !ok {
+				t.Fatalf("expected a body problem, got %v", err)
+			}
+
+			if problem.Status != http.StatusBadRequest || problem.Code != tc.expectedCode {
+				t.Errorf("expected 400 %s, got %d %s", tc.expectedCode, problem.Status, problem.Code)
+			}
+		})
+	}
+}
+
+func TestLoadJSONPatchDigest(t *testing.T) {
+	// Given
+	body := `[{"op": "replace", "path": "/title", "value": "hello"}]`
+	r := httptest.NewRequest(http.MethodPatch, "/examples/1", strings.NewReader(body))
+	r.Header.Set("Content-Digest", sha256Header("something else"))
+
+	// When
+	_, err := LoadJSONPatch(r)
+
+	// Then
+	if !errors.Is(err, DigestMismatchError) {
+		t.Fatalf("expected a digest mismatch, got %v", err)
+	}
+
+	if errors.Is(err, InvalidPatchError) {
+		t.Errorf("expected the patch itself not to be blamed, got %v", err)
+	}
+}
@@ -0,0 +1,443 @@
+package requests
+
+import (
+	"encoding/json"
+	"errors"
+	"maps"
+	"mime"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	ContentType HeaderKey = "Content-Type"
+
+	MediaTypeJson       = "application/json"
+	MediaTypeMergePatch = "application/merge-patch+json"
+	MediaTypeJsonPatch  = "application/json-patch+json"
+)
+
+var UnsupportedMediaTypeError = errors.New("media type is not supported")
+var InvalidPatchError = errors.New("patch is invalid")
+var PatchNotApplicableError = errors.New("patch cannot be applied")
+var PatchTestFailedError = errors.New("patch test failed")
+
+/*
+The media type of the request body without its parameters
+
+A body without a Content-Type is taken to be JSON
+*/
+func MediaType(r *http.Request) (string, error) {
+	header := r.Header.Get(ContentType.Name())
+	if header == "" {
+		return MediaTypeJson, nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(header)
+	if err != nil {
+		return "", UnsupportedMediaTypeError
+	}
+
+	return mediaType, nil
+}
+
+/*
+A patch whose digest doesn't match is reported as such, anything else that
+can't be decoded is an invalid patch
+*/
+func patchBodyError(err error) error {
+	if isDigestError(err) {
+		return DecodeError{Err: err}
+	}
+
+	return InvalidPatchError
+}
+
+// MARK: Merge Patch
+/*
+Reads an RFC 7396 merge patch, any JSON value is a valid merge patch
+*/
+func LoadMergePatch(r *http.Request) (any, error) {
+	var patch any
+	if r.Body == nil {
+		return nil, InvalidPatchError
+	}
+
+	if err := decodeVerified(r, &patch); err != nil {
+		return nil, patchBodyError(err)
+	}
+
+	return patch, nil
+}
+
+/*
+Applies an RFC 7396 merge patch to target without changing it
+
+Objects are merged member by member, a null member removes it, anything
+else replaces the target outright
+*/
+func MergePatch(target, patch any) any {
+	p, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	t, ok := target.(map[string]any)
+	if ok {
+		t = maps.Clone(t)
+	} else {
+		t = map[string]any{}
+	}
+
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+			continue
+		}
+
+		t[k] = MergePatch(t[k], v)
+	}
+
+	return t
+}
+
+// MARK: JSON Patch
+/*
+One RFC 6902 operation, From is only used by move and copy
+*/
+type PatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+/*
+An RFC 6902 patch, operations are applied in order and all or nothing
+*/
+type JSONPatch []PatchOperation
+
+/*
+Reads an RFC 6902 patch, operations and pointers are checked here so a
+malformed patch is rejected before anything is loaded
+*/
+func LoadJSONPatch(r *http.Request) (JSONPatch, error) {
+	var patch JSONPatch
+	if r.Body == nil {
+		return nil, InvalidPatchError
+	}
+
+	if err := decodeVerified(r, &patch); err != nil {
+		return nil, patchBodyError(err)
+	}
+
+	for _, op := range patch {
+		if _, err := pointer(op.Path); err != nil {
+			return nil, err
+		}
+
+		switch op.Op {
+		case "add", "replace", "test":
+			if len(op.Value) == 0 {
+				return nil, InvalidPatchError
+			}
+		case "move", "copy":
+			if _, err := pointer(op.From); err != nil {
+				return nil, err
+			}
+		case "remove":
+		default:
+			return nil, InvalidPatchError
+		}
+	}
+
+	return patch, nil
+}
+
+/*
+Applies the patch to a copy of doc, doc is unchanged when any operation fails
+*/
+func (p JSONPatch) Apply(doc any) (any, error) {
+	doc, err := clone(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, op := range p {
+		path, err := pointer(op.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		var value any
+		if len(op.Value) > 0 {
+			if err := json.Unmarshal(op.Value, &value); err != nil {
+				return nil, InvalidPatchError
+			}
+		}
+
+		switch op.Op {
+		case "add":
+			doc, err = set(doc, path, add(value))
+		case "remove":
+			doc, err = set(doc, path, remove)
+		case "replace":
+			doc, err = set(doc, path, replace(value))
+		case "move", "copy":
+			doc, err = transfer(doc, op.Op, op.From, path)
+		case "test":
+			var current any
+			current, err = get(doc, path)
+			if err == nil && !reflect.DeepEqual(current, value) {
+				err = PatchTestFailedError
+			}
+		default:
+			err = InvalidPatchError
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return doc, nil
+}
+
+/*
+Moves or copies the value at from to path
+*/
+func transfer(doc any, op, from string, path []string) (any, error) {
+	source, err := pointer(from)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := get(doc, source)
+	if err != nil {
+		return nil, err
+	}
+
+	if op == "copy" {
+		if value, err = clone(value); err != nil {
+			return nil, err
+		}
+
+		return set(doc, path, add(value))
+	}
+
+	// A value can't be moved into itself
+	if len(path) > len(source) && slices.Equal(path[:len(source)], source) {
+		return nil, PatchNotApplicableError
+	}
+
+	if doc, err = set(doc, source, remove); err != nil {
+		return nil, err
+	}
+
+	return set(doc, path, add(value))
+}
+
+// MARK: Pointers
+/*
+Splits an RFC 6901 JSON pointer into its unescaped tokens, "" is the whole document
+*/
+func pointer(s string) ([]string, error) {
+	if s == "" {
+		return []string{}, nil
+	}
+
+	if !strings.HasPrefix(s, "/") {
+		return nil, InvalidPatchError
+	}
+
+	tokens := strings.Split(s[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+	}
+
+	return tokens, nil
+}
+
+/*
+An array index, end allows the index one past the last element
+*/
+func index(token string, length int, end bool) (int, error) {
+	if end && token == "-" {
+		return length, nil
+	}
+
+	// No signs or leading zeros
+	if token == "" || (len(token) > 1 && token[0] == '0') || strings.ContainsAny(token, "+-") {
+		return 0, PatchNotApplicableError
+	}
+
+	i, err := strconv.Atoi(token)
+	if err != nil || i > length || (!end && i == length) {
+		return 0, PatchNotApplicableError
+	}
+
+	return i, nil
+}
+
+func get(doc any, path []string) (any, error) {
+	for _, token := range path {
+		switch c := doc.(type) {
+		case map[string]any:
+			v, ok := c[token]
+			if !ok {
+				return nil, PatchNotApplicableError
+			}
+			doc = v
+		case []any:
+			i, err := index(token, len(c), false)
+			if err != nil {
+				return nil, err
+			}
+			doc = c[i]
+		default:
+			return nil, PatchNotApplicableError
+		}
+	}
+
+	return doc, nil
+}
+
+/*
+Changes the container holding the last token of path, returning the new container
+*/
+type change func(container any, token string) (any, error)
+
+/*
+Applies a change at path and writes every container on the way back, arrays
+are values so one that grows or shrinks has to be put back in its parent
+*/
+func set(doc any, path []string, fn change) (any, error) {
+	if len(path) == 0 {
+		return fn(nil, "")
+	}
+
+	if len(path) == 1 {
+		return fn(doc, path[0])
+	}
+
+	switch c := doc.(type) {
+	case map[string]any:
+		child, ok := c[path[0]]
+		if !ok {
+			return nil, PatchNotApplicableError
+		}
+
+		v, err := set(child, path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		c[path[0]] = v
+
+		return c, nil
+	case []any:
+		i, err := index(path[0], len(c), false)
+		if err != nil {
+			return nil, err
+		}
+
+		v, err := set(c[i], path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		c[i] = v
+
+		return c, nil
+	default:
+		return nil, PatchNotApplicableError
+	}
+}
+
+func add(value any) change {
+	return func(container any, token string) (any, error) {
+		switch c := container.(type) {
+		case nil:
+			// The whole document
+			return value, nil
+		case map[string]any:
+			c[token] = value
+			return c, nil
+		case []any:
+			i, err := index(token, len(c), true)
+			if err != nil {
+				return nil, err
+			}
+
+			return append(c[:i], append([]any{value}, c[i:]...)...), nil
+		default:
+			return nil, PatchNotApplicableError
+		}
+	}
+}
+
+func remove(container any, token string) (any, error) {
+	switch c := container.(type) {
+	case map[string]any:
+		if _, ok := c[token]; !ok {
+			return nil, PatchNotApplicableError
+		}
+
+		delete(c, token)
+		return c, nil
+	case []any:
+		i, err := index(token, len(c), false)
+		if err != nil {
+			return nil, err
+		}
+
+		return append(c[:i], c[i+1:]...), nil
+	default:
+		// Including the whole document, which can't be removed
+		return nil, PatchNotApplicableError
+	}
+}
+
+func replace(value any) change {
+	return func(container any, token string) (any, error) {
+		switch c := container.(type) {
+		case nil:
+			return value, nil
+		case map[string]any:
+			if _, ok := c[token]; !ok {
+				return nil, PatchNotApplicableError
+			}
+
+			c[token] = value
+			return c, nil
+		case []any:
+			i, err := index(token, len(c), false)
+			if err != nil {
+				return nil, err
+			}
+
+			c[i] = value
+			return c, nil
+		default:
+			return nil, PatchNotApplicableError
+		}
+	}
+}
+
+/*
+A deep copy through JSON, documents only hold JSON values
+*/
+func clone(doc any) (any, error) {
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return nil, PatchNotApplicableError
+	}
+
+	var v any
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, PatchNotApplicableError
+	}
+
+	return v, nil
+}
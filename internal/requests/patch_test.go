@@ -0,0 +1,257 @@
+package requests
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func decode(t *testing.T, s string) any {
+	var v any
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatalf("invalid test document %s: %v", s, err)
+	}
+
+	return v
+}
+
+func TestMediaType(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		expected string
+		err      error
+	}{
+		{
+			name:     "PassingCase-NoHeaderIsJson",
+			expected: MediaTypeJson,
+		},
+		{
+			name:     "PassingCase-ParametersDropped",
+			header:   "application/merge-patch+json; charset=utf-8",
+			expected: MediaTypeMergePatch,
+		},
+		{
+			name:   "FailingCase-Unparseable",
+			header: "application/",
+			err:    UnsupportedMediaTypeError,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			r := httptest.NewRequest(http.MethodPatch, "/", nil)
+			if tc.header != "" {
+				r.Header.Set("Content-Type", tc.header)
+			}
+
+			// When
+			mediaType, err := MediaType(r)
+
+			// Then
+			if err != tc.err {
+				t.Errorf("expected error %v, got %v", tc.err, err)
+			}
+
+			if mediaType != tc.expected {
+				t.Errorf("expected %s, got %s", tc.expected, mediaType)
+			}
+		})
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	// Examples from RFC 7396 Appendix A
+	tests := []struct {
+		name     string
+		target   string
+		patch    string
+		expected string
+	}{
+		{name: "PassingCase-Replace", target: `{"a":"b"}`, patch: `{"a":"c"}`, expected: `{"a":"c"}`},
+		{name: "PassingCase-Add", target: `{"a":"b"}`, patch: `{"b":"c"}`, expected: `{"a":"b","b":"c"}`},
+		{name: "PassingCase-Remove", target: `{"a":"b"}`, patch: `{"a":null}`, expected: `{}`},
+		{name: "PassingCase-ArraysReplaced", target: `{"a":["b"]}`, patch: `{"a":["c"]}`, expected: `{"a":["c"]}`},
+		{name: "PassingCase-Nested", target: `{"a":{"b":"c"}}`, patch: `{"a":{"b":"d","c":null}}`, expected: `{"a":{"b":"d"}}`},
+		{name: "PassingCase-NonObjectTarget", target: `["c"]`, patch: `{"a":"b"}`, expected: `{"a":"b"}`},
+		{name: "PassingCase-NonObjectPatch", target: `{"a":"foo"}`, patch: `"bar"`, expected: `"bar"`},
+		{name: "PassingCase-NullsNotAdded", target: `{}`, patch: `{"a":{"bb":{"ccc":null}}}`, expected: `{"a":{"bb":{}}}`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			target := decode(t, tc.target)
+
+			// When
+			result := MergePatch(target, decode(t, tc.patch))
+
+			// Then
+			if !reflect.DeepEqual(result, decode(t, tc.expected)) {
+				t.Errorf("expected %s, got %v", tc.expected, result)
+			}
+
+			if !reflect.DeepEqual(target, decode(t, tc.target)) {
+				t.Errorf("expected target to be unchanged, got %v", target)
+			}
+		})
+	}
+}
+
+func TestLoadJSONPatch(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		err  error
+	}{
+		{name: "PassingCase", body: `[{"op":"replace","path":"/a","value":1},{"op":"move","from":"/a","path":"/b"}]`},
+		{name: "FailingCase-NotAnArray", body: `{"op":"remove","path":"/a"}`, err: InvalidPatchError},
+		{name: "FailingCase-UnknownOp", body: `[{"op":"merge","path":"/a"}]`, err: InvalidPatchError},
+		{name: "FailingCase-MissingValue", body: `[{"op":"add","path":"/a"}]`, err: InvalidPatchError},
+		{name: "FailingCase-RelativePointer", body: `[{"op":"remove","path":"a"}]`, err: InvalidPatchError},
+		{name: "FailingCase-MissingFrom", body: `[{"op":"copy","path":"/a","from":"b"}]`, err: InvalidPatchError},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			r := httptest.NewRequest(http.MethodPatch, "/", strings.NewReader(tc.body))
+
+			// When
+			_, err := LoadJSONPatch(r)
+
+			// Then
+			if err != tc.err {
+				t.Errorf("expected error %v, got %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestJSONPatchApply(t *testing.T) {
+	tests := []struct {
+		name     string
+		doc      string
+		patch    string
+		expected string
+		err      error
+	}{
+		{
+			name:     "PassingCase-AddMember",
+			doc:      `{"foo":"bar"}`,
+			patch:    `[{"op":"add","path":"/baz","value":"qux"}]`,
+			expected: `{"foo":"bar","baz":"qux"}`,
+		},
+		{
+			name:     "PassingCase-AddArrayElement",
+			doc:      `{"foo":["bar","baz"]}`,
+			patch:    `[{"op":"add","path":"/foo/1","value":"qux"}]`,
+			expected: `{"foo":["bar","qux","baz"]}`,
+		},
+		{
+			name:     "PassingCase-AppendArrayElement",
+			doc:      `{"foo":["bar"]}`,
+			patch:    `[{"op":"add","path":"/foo/-","value":["abc"]}]`,
+			expected: `{"foo":["bar",["abc"]]}`,
+		},
+		{
+			name:     "PassingCase-RemoveArrayElement",
+			doc:      `{"foo":["bar","qux","baz"]}`,
+			patch:    `[{"op":"remove","path":"/foo/1"}]`,
+			expected: `{"foo":["bar","baz"]}`,
+		},
+		{
+			name:     "PassingCase-Replace",
+			doc:      `{"baz":"qux","foo":"bar"}`,
+			patch:    `[{"op":"replace","path":"/baz","value":"boo"}]`,
+			expected: `{"baz":"boo","foo":"bar"}`,
+		},
+		{
+			name:     "PassingCase-MoveArrayElement",
+			doc:      `{"foo":["all","grass","cows","eat"]}`,
+			patch:    `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`,
+			expected: `{"foo":["all","cows","eat","grass"]}`,
+		},
+		{
+			name:     "PassingCase-Copy",
+			doc:      `{"foo":{"bar":1}}`,
+			patch:    `[{"op":"copy","from":"/foo","path":"/baz"},{"op":"replace","path":"/baz/bar","value":2}]`,
+			expected: `{"foo":{"bar":1},"baz":{"bar":2}}`,
+		},
+		{
+			name:     "PassingCase-EscapedPointer",
+			doc:      `{"a/b":1,"m~n":2}`,
+			patch:    `[{"op":"remove","path":"/a~1b"},{"op":"test","path":"/m~0n","value":2}]`,
+			expected: `{"m~n":2}`,
+		},
+		{
+			name:     "PassingCase-ReplaceWholeDocument",
+			doc:      `{"foo":"bar"}`,
+			patch:    `[{"op":"replace","path":"","value":{"baz":"qux"}}]`,
+			expected: `{"baz":"qux"}`,
+		},
+		{
+			name:  "FailingCase-TestFailed",
+			doc:   `{"baz":"qux","foo":["a",2,"c"]}`,
+			patch: `[{"op":"replace","path":"/baz","value":"x"},{"op":"test","path":"/foo/1","value":"2"}]`,
+			err:   PatchTestFailedError,
+		},
+		{
+			name:  "FailingCase-RemoveMissing",
+			doc:   `{"foo":"bar"}`,
+			patch: `[{"op":"remove","path":"/baz"}]`,
+			err:   PatchNotApplicableError,
+		},
+		{
+			name:  "FailingCase-AddOutOfBounds",
+			doc:   `{"foo":["bar"]}`,
+			patch: `[{"op":"add","path":"/foo/2","value":"x"}]`,
+			err:   PatchNotApplicableError,
+		},
+		{
+			name:  "FailingCase-LeadingZeroIndex",
+			doc:   `{"foo":["bar","baz"]}`,
+			patch: `[{"op":"remove","path":"/foo/01"}]`,
+			err:   PatchNotApplicableError,
+		},
+		{
+			name:  "FailingCase-MoveIntoChild",
+			doc:   `{"foo":{"bar":1}}`,
+			patch: `[{"op":"move","from":"/foo","path":"/foo/bar"}]`,
+			err:   PatchNotApplicableError,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			doc := decode(t, tc.doc)
+
+			var patch JSONPatch
+			if err := json.Unmarshal([]byte(tc.patch), &patch); err != nil {
+				t.Fatalf("invalid test patch: %v", err)
+			}
+
+			// When
+			result, err := patch.Apply(doc)
+
+			// Then
+			if !errors.Is(err, tc.err) {
+				t.Errorf("expected error %v, got %v", tc.err, err)
+			}
+
+			if tc.err == nil && !reflect.DeepEqual(result, decode(t, tc.expected)) {
+				t.Errorf("expected %s, got %v", tc.expected, result)
+			}
+
+			if !reflect.DeepEqual(doc, decode(t, tc.doc)) {
+				t.Errorf("expected document to be unchanged, got %v", doc)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package requests
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/moonmoon1919/go-api-reference/internal/store"
+)
+
+const (
+	sortParam = "sort"
+	// Long enough for any sensible search, short enough to keep queries cheap
+	maxFilterValueLength = 256
+	maxFilters           = 10
+)
+
+// Pagination parameters are read by GetCursorPaginationParameters
+var reservedParams = []string{"limit", "page", "total", cursorParam, sortParam}
+
+var UnknownFilterError = errors.New("filter is not supported")
+var UnsupportedOperatorError = errors.New("operator is not supported for filter")
+var InvalidFilterValueError = errors.New("filter value is invalid")
+var TooManyFiltersError = errors.New("too many filters")
+var UnknownSortError = errors.New("sort is not supported")
+
+// MARK: Schema
+type FieldType int
+
+const (
+	TextField FieldType = iota
+	// Values must be RFC 3339 timestamps
+	TimeField
+)
+
+type FilterField struct {
+	Type      FieldType
+	Operators []store.Operator
+}
+
+/*
+The filters and sorts a listing accepts
+
+Anything not in the schema is rejected, so only fields the store knows
+how to query ever reach it
+*/
+type QuerySchema struct {
+	Filters map[string]FilterField
+	Sorts   []string
+}
+
+type Query struct {
+	Filters []store.Filter
+	Sort    store.Sort
+}
+
+// MARK: Parse
+/*
+Splits a parameter like created_at[gte] into its field and operator
+
+A parameter without an operator compares for equality
+*/
+func parseFilterParam(param string) (string, store.Operator, bool) {
+	field, rest, found := strings.Cut(param, "[")
+	if !found {
+		return param, store.Eq, true
+	}
+
+	op, ok := strings.CutSuffix(rest, "]")
+	if !ok || op == "" {
+		return "", "", false
+	}
+
+	return field, store.Operator(op), true
+}
+
+func parseFilter(field string, op store.Operator, spec FilterField, value string) (store.Filter, error) {
+	if value == "" || len(value) > maxFilterValueLength {
+		return store.Filter{}, InvalidFilterValueError
+	}
+
+	filter := store.Filter{Field: field, Op: op}
+
+	switch spec.Type {
+	case TimeField:
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return store.Filter{}, InvalidFilterValueError
+		}
+
+		filter.Time = t
+	default:
+		filter.Value = value
+	}
+
+	return filter, nil
+}
+
+/*
+Reads filters written as field[operator]=value and sort=field or sort=-field
+
+Filters are sorted by parameter so the same query always
+builds the same SQL
+*/
+func ParseQuery(r *http.Request, schema QuerySchema) (Query, error) {
+	query := r.URL.Query()
+
+	var result Query
+
+	if s := query.Get(sortParam); s != "" {
+		sort := store.ParseSort(s)
+		if !slices.Contains(schema.Sorts, sort.Field) {
+			return Query{}, UnknownSortError
+		}
+
+		result.Sort = sort
+	}
+
+	params := make([]string, 0, len(query))
+	for param := range query {
+		if !slices.Contains(reservedParams, param) {
+			params = append(params, param)
+		}
+	}
+	slices.Sort(params)
+
+	for _, param := range params {
+		field, op, ok := parseFilterParam(param)
+		if !ok {
+			return Query{}, UnknownFilterError
+		}
+
+		spec, ok := schema.Filters[field]
+		if !ok {
+			return Query{}, UnknownFilterError
+		}
+
+		if !slices.Contains(spec.Operators, op) {
+			return Query{}, UnsupportedOperatorError
+		}
+
+		for _, value := range query[param] {
+			filter, err := parseFilter(field, op, spec, value)
+			if err != nil {
+				return Query{}, err
+			}
+
+			result.Filters = append(result.Filters, filter)
+		}
+
+		if len(result.Filters) > maxFilters {
+			return Query{}, TooManyFiltersError
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,112 @@
+package requests
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/moonmoon1919/go-api-reference/internal/store"
+)
+
+func TestParseQuery(t *testing.T) {
+	schema := QuerySchema{
+		Filters: map[string]FilterField{
+			"message":    {Type: TextField, Operators: []store.Operator{store.Contains, store.Search}},
+			"created_at": {Type: TimeField, Operators: []store.Operator{store.Gte, store.Lt}},
+		},
+		Sorts: []string{"created_at", "message"},
+	}
+
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		query      string
+		expected   Query
+		errMessage string
+	}{
+		{
+			name:  "PassingCase-Empty",
+			query: "limit=10&page=1&total=exact",
+		},
+		{
+			name:  "PassingCase-SearchAndRange",
+			query: "message[search]=hello+world&created_at[lt]=2025-02-01T00:00:00Z&created_at[gte]=2025-01-01T00:00:00Z&sort=-created_at",
+			expected: Query{
+				Filters: []store.Filter{
+					{Field: "created_at", Op: store.Gte, Time: start},
+					{Field: "created_at", Op: store.Lt, Time: end},
+					{Field: "message", Op: store.Search, Value: "hello world"},
+				},
+				Sort: store.Sort{Field: "created_at", Desc: true},
+			},
+		},
+		{
+			name:       "FailingCase-UnknownField",
+			query:      "uid[eq]=someone",
+			errMessage: "filter is not supported",
+		},
+		{
+			name:       "FailingCase-UnsupportedOperator",
+			query:      "message[gt]=a",
+			errMessage: "operator is not supported for filter",
+		},
+		{
+			name:       "FailingCase-MissingOperator",
+			query:      "message=hello",
+			errMessage: "operator is not supported for filter",
+		},
+		{
+			name:       "FailingCase-MalformedOperator",
+			query:      "message[contains=hello",
+			errMessage: "filter is not supported",
+		},
+		{
+			name:       "FailingCase-InvalidTime",
+			query:      "created_at[gte]=yesterday",
+			errMessage: "filter value is invalid",
+		},
+		{
+			name:       "FailingCase-ValueTooLong",
+			query:      "message[contains]=" + strings.Repeat("a", 257),
+			errMessage: "filter value is invalid",
+		},
+		{
+			name:       "FailingCase-TooManyFilters",
+			query:      "message[contains]=a" + strings.Repeat("&message[contains]=a", 10),
+			errMessage: "too many filters",
+		},
+		{
+			name:       "FailingCase-UnknownSort",
+			query:      "sort=-uid",
+			errMessage: "sort is not supported",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			r, _ := http.NewRequest(http.MethodGet, "/examples?"+tc.query, nil)
+
+			// When
+			query, err := ParseQuery(r, schema)
+
+			// Then
+			var errMessage string
+			if err != nil {
+				errMessage = err.Error()
+			}
+
+			if errMessage != tc.errMessage {
+				t.Errorf("expected error message %s, got %s", tc.errMessage, errMessage)
+			}
+
+			if !reflect.DeepEqual(query, tc.expected) {
+				t.Errorf("expected %+v, got %+v", tc.expected, query)
+			}
+		})
+	}
+}
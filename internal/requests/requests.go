@@ -3,11 +3,15 @@ package requests
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/moonmoon1919/go-api-reference/internal/responses"
+	"github.com/moonmoon1919/go-api-reference/internal/store"
 )
 
 type HeaderKey string
@@ -19,9 +23,14 @@ func (h HeaderKey) Name() string {
 const (
 	IfNoneMatch                 HeaderKey = "If-None-Match"
 	IfMatch                     HeaderKey = "If-Match"
+	IfModifiedSince             HeaderKey = "If-Modified-Since"
+	IfUnmodifiedSince           HeaderKey = "If-Unmodified-Since"
 	msgMissingRequestBody                 = "MISSING_REQUEST_BODY"
 	msgInvalidRequestBody                 = "INVALID_REQUEST_BODY"
 	msgMissingExpectedPathParam           = "MISSING_EXPECTED_PATH_PARAM"
+	msgMissingRequiredFields              = "MISSING_REQUIRED_FIELDS"
+	codeRequired                          = "REQUIRED"
+	codeInvalidType                       = "INVALID_TYPE"
 	keyError                              = "ERROR"
 	keyPath                               = "PATH"
 	keyParam                              = "PARAM"
@@ -31,30 +40,128 @@ var LimitTooLowError = errors.New("limit must be greater than 0")
 var InvalidLimitError = errors.New("limit must be an integer")
 var PageTooLowError = errors.New("page must be greater than 0")
 var InvalidPageError = errors.New("page must be an integer")
+var InvalidTotalError = errors.New("total must be exact or estimated")
+
+// MARK: Request Body
+/*
+A body that was read but can't be accepted, returned by UnmarshalJSON
+
+Code is stable for clients to match on, Fields are the fields that caused it
+*/
+type BodyError struct {
+	Code   string
+	Detail string
+	Fields []string
+}
 
-func LoadRequestBody(w http.ResponseWriter, r *http.Request, v any) error {
-	if r.Body == nil {
-		responses.WriteBadRequestResponse(w, msgMissingRequestBody)
-		return errors.New(msgMissingRequestBody)
+func (e BodyError) Error() string {
+	if e.Detail == "" {
+		return e.Code
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
-		slog.Error(msgInvalidRequestBody, keyError, err)
+	return e.Code + ": " + e.Detail
+}
 
-		if err.Error() == "EOF" {
-			responses.WriteBadRequestResponse(w, msgMissingRequestBody)
-		} else if err.Error() == "unexpected EOF" {
-			responses.WriteBadRequestResponse(w, msgInvalidRequestBody)
-		} else {
-			responses.WriteBadRequestResponse(w, err.Error())
+func InvalidBodyError() BodyError {
+	return BodyError{Code: msgInvalidRequestBody}
+}
+
+func MissingFieldsError(fields ...string) BodyError {
+	return BodyError{Code: msgMissingRequiredFields, Detail: strings.Join(fields, ", "), Fields: fields}
+}
+
+/*
+The problem for a body that couldn't be decoded
+
+Decoder errors describe our types, only what the client sent is reported
+*/
+func bodyProblem(err error) responses.Problem {
+	var body BodyError
+	var syntax *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+
+	switch {
+	case errors.Is(err, DigestMismatchError):
+		return responses.NewProblem(http.StatusBadRequest, codeDigestMismatch).WithDetail(err.Error())
+	case errors.Is(err, InvalidDigestError):
+		return responses.NewProblem(http.StatusBadRequest, codeInvalidDigest).WithDetail(InvalidDigestError.Error())
+	case errors.Is(err, io.EOF):
+		return responses.NewProblem(http.StatusBadRequest, msgMissingRequestBody)
+	case errors.As(err, &body):
+		problem := responses.NewProblem(http.StatusBadRequest, body.Code).WithDetail(body.Detail)
+		for _, field := range body.Fields {
+			problem.Errors = append(problem.Errors, responses.FieldError{Field: field, Code: codeRequired, Message: field + " is required"})
 		}
 
-		return err
+		return problem
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		return responses.NewProblem(http.StatusBadRequest, msgInvalidRequestBody).WithErrors(responses.FieldError{
+			Field:   typeErr.Field,
+			Code:    codeInvalidType,
+			Message: fmt.Sprintf("%s must not be a %s", typeErr.Field, typeErr.Value),
+		})
+	case errors.As(err, &syntax), errors.Is(err, io.ErrUnexpectedEOF):
+		return responses.NewProblem(http.StatusBadRequest, msgInvalidRequestBody).WithDetail("request body is not valid JSON")
+	default:
+		return responses.NewProblem(http.StatusBadRequest, msgInvalidRequestBody)
+	}
+}
+
+/*
+The body couldn't be decoded, Err is the decoder's error
+*/
+type DecodeError struct {
+	Err error
+}
+
+func (e DecodeError) Error() string {
+	return e.Err.Error()
+}
+
+func (e DecodeError) Unwrap() error {
+	return e.Err
+}
+
+/*
+The problem for a DecodeError, registered with a responses.Registry
+*/
+func BodyProblem(err error) (responses.Problem, bool) {
+	var decodeErr DecodeError
+	if !errors.As(err, &decodeErr) {
+		return responses.Problem{}, false
+	}
+
+	return bodyProblem(decodeErr.Err), true
+}
+
+/*
+Decodes the JSON body of r into v, failures are a DecodeError
+
+Bodies sent with a Content-Digest or Repr-Digest must match it
+*/
+func DecodeBody(r *http.Request, v any) error {
+	if r.Body == nil {
+		return DecodeError{Err: io.EOF}
+	}
+
+	if err := decodeVerified(r, v); err != nil {
+		slog.LogAttrs(r.Context(), slog.LevelInfo, msgInvalidRequestBody, slog.String(keyError, err.Error()))
+		return DecodeError{Err: err}
 	}
 
 	return nil
 }
 
+func LoadRequestBody(w http.ResponseWriter, r *http.Request, v any) error {
+	err := DecodeBody(r, v)
+	if err != nil {
+		problem, _ := BodyProblem(err)
+		responses.WriteProblem(w, problem, &responses.Headers{})
+	}
+
+	return err
+}
+
 func LoadPathValue(r *http.Request, key string) (string, error) {
 	value := r.PathValue(key)
 
@@ -102,3 +209,19 @@ func GetPaginationParameters(r *http.Request) (int, int, error) {
 
 	return limit, page, nil
 }
+
+/*
+Reads the total query parameter, listings are only counted when a client asks
+*/
+func GetCountMode(r *http.Request) (store.CountMode, error) {
+	switch r.URL.Query().Get("total") {
+	case "":
+		return store.NoCount, nil
+	case "exact":
+		return store.ExactCount, nil
+	case "estimated":
+		return store.EstimatedCount, nil
+	default:
+		return store.NoCount, InvalidTotalError
+	}
+}
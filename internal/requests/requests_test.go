@@ -1,24 +1,107 @@
 package requests
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/http/httptest"
+	"reflect"
 	"strconv"
+	"strings"
 	"testing"
+
+	"github.com/moonmoon1919/go-api-reference/internal/responses"
 )
 
+type titleBody struct {
+	Title string `json:"title"`
+	Count int    `json:"count"`
+}
+
+func (b *titleBody) UnmarshalJSON(data []byte) error {
+	type Aux titleBody
+	if err := json.Unmarshal(data, (*Aux)(b)); err != nil {
+		return err
+	}
+
+	if b.Title == "" {
+		return MissingFieldsError("title")
+	}
+
+	return nil
+}
+
 func TestLoadRequestBody(t *testing.T) {
 	tests := []struct {
-		name string
-	}{}
+		name           string
+		body           string
+		expectedCode   string
+		expectedDetail string
+		expectedErrors []responses.FieldError
+	}{
+		{
+			name: "PassingCase",
+			body: `{"title": "hello", "count": 1}`,
+		},
+		{
+			name:         "FailingCase-MissingBody",
+			body:         "",
+			expectedCode: "MISSING_REQUEST_BODY",
+		},
+		{
+			name:           "FailingCase-InvalidJson",
+			body:           `{"title": `,
+			expectedCode:   "INVALID_REQUEST_BODY",
+			expectedDetail: "request body is not valid JSON",
+		},
+		{
+			name:           "FailingCase-WrongType",
+			body:           `{"title": "hello", "count": "one"}`,
+			expectedCode:   "INVALID_REQUEST_BODY",
+			expectedErrors: []responses.FieldError{{Field: "count", Code: "INVALID_TYPE", Message: "count must not be a string"}},
+		},
+		{
+			name:           "FailingCase-MissingFields",
+			body:           `{"count": 1}`,
+			expectedCode:   "MISSING_REQUIRED_FIELDS",
+			expectedDetail: "title",
+			expectedErrors: []responses.FieldError{{Field: "title", Code: "REQUIRED", Message: "title is required"}},
+		},
+	}
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
 			// Given
+			r := httptest.NewRequest(http.MethodPost, "/examples", strings.NewReader(tc.body))
+			w := httptest.NewRecorder()
 
 			// When
+			var body titleBody
+			err := LoadRequestBody(w, r, &body)
 
 			// Then
+			if (err != nil) != (tc.expectedCode != "") {
+				t.Fatalf("expected an error %t, got %v", tc.expectedCode != "", err)
+			}
+
+			if tc.expectedCode == "" {
+				return
+			}
+
+			var problem responses.Problem
+			json.Unmarshal(w.Body.Bytes(), &problem)
+
+			if w.Code != http.StatusBadRequest || problem.Status != http.StatusBadRequest {
+				t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+			}
+
+			if problem.Code != tc.expectedCode || problem.Detail != tc.expectedDetail {
+				t.Errorf("expected %s %q, got %s %q", tc.expectedCode, tc.expectedDetail, problem.Code, problem.Detail)
+			}
+
+			if !reflect.DeepEqual(problem.Errors, tc.expectedErrors) {
+				t.Errorf("expected errors %v, got %v", tc.expectedErrors, problem.Errors)
+			}
 		})
 	}
 }
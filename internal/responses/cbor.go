@@ -0,0 +1,127 @@
+package responses
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// CBOR major types, RFC 8949 section 3.1
+const (
+	cborUnsigned byte = 0
+	cborNegative byte = 1
+	cborText     byte = 3
+	cborArray    byte = 4
+	cborMap      byte = 5
+	cborFalse    byte = 0xf4
+	cborTrue     byte = 0xf5
+	cborNull     byte = 0xf6
+	cborFloat64  byte = 0xfb
+)
+
+/*
+RFC 8949 CBOR, the body has the same fields as its JSON
+*/
+type CborEncoder struct{}
+
+func (CborEncoder) MediaType() HeaderValue {
+	return ApplicationCbor
+}
+
+func (CborEncoder) CanEncode(v any) bool {
+	return true
+}
+
+func (CborEncoder) Encode(v any) ([]byte, error) {
+	value, err := jsonValue(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return appendCbor(nil, value)
+}
+
+/*
+The initial byte and argument of a data item, in as few bytes as possible
+*/
+func appendCborHead(buf []byte, major byte, n uint64) []byte {
+	major <<= 5
+
+	switch {
+	case n < 24:
+		return append(buf, major|byte(n))
+	case n <= math.MaxUint8:
+		return append(buf, major|24, byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(buf, major|25), uint16(n))
+	case n <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(buf, major|26), uint32(n))
+	default:
+		return binary.BigEndian.AppendUint64(append(buf, major|27), n)
+	}
+}
+
+func appendCbor(buf []byte, v any) ([]byte, error) {
+	switch t := v.(type) {
+	case nil:
+		return append(buf, cborNull), nil
+	case bool:
+		if t {
+			return append(buf, cborTrue), nil
+		}
+
+		return append(buf, cborFalse), nil
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			if i < 0 {
+				// Negative integers are encoded as -1 - n
+				return appendCborHead(buf, cborNegative, uint64(-(i + 1))), nil
+			}
+
+			return appendCborHead(buf, cborUnsigned, uint64(i)), nil
+		}
+
+		if u, err := strconv.ParseUint(t.String(), 10, 64); err == nil {
+			return appendCborHead(buf, cborUnsigned, u), nil
+		}
+
+		f, err := t.Float64()
+		if err != nil {
+			return nil, err
+		}
+
+		return binary.BigEndian.AppendUint64(append(buf, cborFloat64), math.Float64bits(f)), nil
+	case string:
+		buf = appendCborHead(buf, cborText, uint64(len(t)))
+		return append(buf, t...), nil
+	case []any:
+		buf = appendCborHead(buf, cborArray, uint64(len(t)))
+
+		var err error
+		for _, item := range t {
+			if buf, err = appendCbor(buf, item); err != nil {
+				return nil, err
+			}
+		}
+
+		return buf, nil
+	case map[string]any:
+		buf = appendCborHead(buf, cborMap, uint64(len(t)))
+
+		var err error
+		for _, k := range sortedKeys(t) {
+			buf = appendCborHead(buf, cborText, uint64(len(k)))
+			buf = append(buf, k...)
+
+			if buf, err = appendCbor(buf, t[k]); err != nil {
+				return nil, err
+			}
+		}
+
+		return buf, nil
+	default:
+		return nil, fmt.Errorf("cbor: unsupported type %T", v)
+	}
+}
@@ -0,0 +1,75 @@
+package responses
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"hash"
+	"strconv"
+	"strings"
+)
+
+const (
+	WantContentDigestKey HeaderKey = "Want-Content-Digest"
+	WantReprDigestKey    HeaderKey = "Want-Repr-Digest"
+)
+
+/*
+A hash for alg, returns false for algorithms that aren't supported
+*/
+func NewDigestHash(alg DigestAlgorithm) (hash.Hash, bool) {
+	switch alg {
+	case SHA256:
+		return sha256.New(), true
+	case SHA512:
+		return sha512.New(), true
+	default:
+		return nil, false
+	}
+}
+
+func EncodeDigest(h hash.Hash) string {
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func CalculateDigest(alg DigestAlgorithm, val *[]byte) string {
+	h, ok := NewDigestHash(alg)
+	if !ok {
+		h = sha256.New()
+	}
+
+	h.Write(*val)
+
+	return EncodeDigest(h)
+}
+
+/*
+The algorithm a client prefers from Want-Content-Digest or Want-Repr-Digest,
+e.g., sha-512=10, sha-256=3
+
+Preferences are 0 to 10, 0 means not acceptable. Falls back to SHA-256 when
+the header is missing or names nothing we support.
+*/
+func WantedDigest(header string) DigestAlgorithm {
+	wanted, best := SHA256, 0
+
+	for _, member := range strings.Split(header, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(member), "=")
+		alg := DigestAlgorithm(strings.ToLower(strings.TrimSpace(name)))
+
+		if _, ok := NewDigestHash(alg); !ok {
+			continue
+		}
+
+		preference, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || preference < 1 || preference > 10 {
+			continue
+		}
+
+		if preference > best {
+			wanted, best = alg, preference
+		}
+	}
+
+	return wanted
+}
@@ -0,0 +1,33 @@
+package responses
+
+import "testing"
+
+func TestWantedDigest(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		expected DigestAlgorithm
+	}{
+		{name: "Missing", header: "", expected: SHA256},
+		{name: "Sha512", header: "sha-512=3", expected: SHA512},
+		{name: "HighestPreference", header: "sha-256=3, sha-512=10", expected: SHA512},
+		{name: "ZeroIsNotAcceptable", header: "sha-512=0, sha-256=1", expected: SHA256},
+		{name: "Unsupported", header: "md5=10", expected: SHA256},
+		{name: "OutOfRange", header: "sha-512=11", expected: SHA256},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			header := tc.header
+
+			// When
+			alg := WantedDigest(header)
+
+			// Then
+			if alg != tc.expected {
+				t.Errorf("expected %s, got %s", tc.expected, alg)
+			}
+		})
+	}
+}
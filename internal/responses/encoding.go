@@ -0,0 +1,270 @@
+package responses
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	AcceptKey           HeaderKey   = "Accept"
+	VaryKey             HeaderKey   = "Vary"
+	ApplicationCbor     HeaderValue = "application/cbor"
+	ApplicationMsgPack  HeaderValue = "application/msgpack"
+	ApplicationNdjson   HeaderValue = "application/x-ndjson"
+	NOT_ACCEPTABLE                  = "NOT_ACCEPTABLE"
+	notAcceptableDetail             = "none of the accepted media types can represent this resource"
+)
+
+var NotAcceptableError = errors.New(notAcceptableDetail)
+
+func Vary(keys ...HeaderKey) Header {
+	names := make([]string, len(keys))
+	for idx, k := range keys {
+		names[idx] = k.Name()
+	}
+
+	return Header{key: VaryKey, value: strings.Join(names, ", ")}
+}
+
+// MARK: Encoder
+/*
+Writes a response body in one media type
+*/
+type Encoder interface {
+	MediaType() HeaderValue
+	// False when v has no representation in this media type, e.g., NDJSON for a single item
+	CanEncode(v any) bool
+	Encode(v any) ([]byte, error)
+}
+
+/*
+A body that was already encoded, sent as is
+*/
+type Encoded struct {
+	MediaType HeaderValue
+	Data      []byte
+}
+
+/*
+A list response, each record is a line of NDJSON
+*/
+type Lister interface {
+	Records() []any
+}
+
+type JsonEncoder struct{}
+
+func (JsonEncoder) MediaType() HeaderValue {
+	return ApplicationJson
+}
+
+func (JsonEncoder) CanEncode(v any) bool {
+	return true
+}
+
+func (JsonEncoder) Encode(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+/*
+Newline delimited JSON, only lists can be encoded
+
+Only the records are sent, the next and previous pages are in the Link header
+*/
+type NdjsonEncoder struct{}
+
+func (NdjsonEncoder) MediaType() HeaderValue {
+	return ApplicationNdjson
+}
+
+func (NdjsonEncoder) CanEncode(v any) bool {
+	_, ok := v.(Lister)
+	return ok
+}
+
+func (NdjsonEncoder) Encode(v any) ([]byte, error) {
+	list, ok := v.(Lister)
+	if !ok {
+		return nil, NotAcceptableError
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+
+	for _, record := range list.Records() {
+		// Encode ends every record with a newline
+		if err := enc.Encode(record); err != nil {
+			return nil, err
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+/*
+The value as decoded from its JSON, so every encoding has the same field names
+and formats, e.g., timestamps are RFC 3339 strings everywhere
+*/
+func jsonValue(v any) (any, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
+
+/*
+Map keys shortest first then bytewise, the deterministic order from RFC 8949
+so the same content always has the same bytes and digest
+*/
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if len(keys[i]) != len(keys[j]) {
+			return len(keys[i]) < len(keys[j])
+		}
+
+		return keys[i] < keys[j]
+	})
+
+	return keys
+}
+
+// MARK: Negotiation
+/*
+The encoders a server offers, in order of preference
+*/
+type Encoders []Encoder
+
+var DefaultEncoders = Encoders{JsonEncoder{}, CborEncoder{}, MessagePackEncoder{}, NdjsonEncoder{}}
+
+type mediaRange struct {
+	mediaType string
+	quality   float64
+}
+
+/*
+The media ranges of an Accept header, ranges that don't parse are ignored
+*/
+func parseAccept(header string) []mediaRange {
+	ranges := make([]mediaRange, 0)
+
+	for _, part := range strings.Split(header, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			if quality, err = strconv.ParseFloat(q, 64); err != nil || quality < 0 || quality > 1 {
+				continue
+			}
+		}
+
+		ranges = append(ranges, mediaRange{mediaType: mediaType, quality: quality})
+	}
+
+	return ranges
+}
+
+/*
+The quality the client gave mediaType, from the most specific range that matches it
+*/
+func quality(ranges []mediaRange, mediaType string) float64 {
+	major, _, _ := strings.Cut(mediaType, "/")
+	best, specificity := 0.0, -1
+
+	for _, r := range ranges {
+		s := -1
+		switch {
+		case r.mediaType == mediaType:
+			s = 2
+		case r.mediaType == major+"/*":
+			s = 1
+		case r.mediaType == "*/*":
+			s = 0
+		}
+
+		if s > specificity {
+			best, specificity = r.quality, s
+		}
+	}
+
+	return best
+}
+
+/*
+The encoder for v the client prefers, ties go to the server's order
+
+Without an Accept header, or one that doesn't parse, the first encoder that
+can encode v is used.
+Returns NotAcceptableError when nothing the client accepts can encode v.
+*/
+func (e Encoders) Negotiate(r *http.Request, v any) (Encoder, error) {
+	header := strings.Join(r.Header.Values(AcceptKey.Name()), ",")
+	ranges := parseAccept(header)
+
+	var chosen Encoder
+	best := 0.0
+
+	for _, enc := range e {
+		if !enc.CanEncode(v) {
+			continue
+		}
+
+		if len(ranges) == 0 {
+			return enc, nil
+		}
+
+		if q := quality(ranges, enc.MediaType().Value()); q > best {
+			chosen, best = enc, q
+		}
+	}
+
+	if chosen == nil {
+		return nil, NotAcceptableError
+	}
+
+	return chosen, nil
+}
+
+/*
+Encodes v in the media type the client asked for
+
+Controllers that derive a validator from the body, e.g., a weak ETag, encode
+it themselves so the validator matches the representation that is sent
+*/
+func (e Encoders) Encode(r *http.Request, v any) (Encoded, error) {
+	enc, err := e.Negotiate(r, v)
+	if err != nil {
+		return Encoded{}, err
+	}
+
+	data, err := enc.Encode(v)
+	if err != nil {
+		return Encoded{}, err
+	}
+
+	return Encoded{MediaType: enc.MediaType(), Data: data}, nil
+}
@@ -0,0 +1,180 @@
+package responses
+
+import (
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// MARK: CBOR
+func TestCborEncoder(t *testing.T) {
+	// Vectors from RFC 8949 Appendix A
+	tests := []struct {
+		name     string
+		value    any
+		expected string
+	}{
+		{name: "Zero", value: 0, expected: "00"},
+		{name: "TwentyThree", value: 23, expected: "17"},
+		{name: "TwentyFour", value: 24, expected: "1818"},
+		{name: "Thousand", value: 1000, expected: "1903e8"},
+		{name: "Million", value: 1000000, expected: "1a000f4240"},
+		{name: "Trillion", value: 1000000000000, expected: "1b000000e8d4a51000"},
+		{name: "MaxUint64", value: uint64(18446744073709551615), expected: "1bffffffffffffffff"},
+		{name: "MinusOne", value: -1, expected: "20"},
+		{name: "MinusHundred", value: -100, expected: "3863"},
+		{name: "MinusThousand", value: -1000, expected: "3903e7"},
+		{name: "Float", value: 1.1, expected: "fb3ff199999999999a"},
+		{name: "False", value: false, expected: "f4"},
+		{name: "True", value: true, expected: "f5"},
+		{name: "Null", value: nil, expected: "f6"},
+		{name: "EmptyString", value: "", expected: "60"},
+		{name: "String", value: "IETF", expected: "6449455446"},
+		{name: "EmptyArray", value: []any{}, expected: "80"},
+		{name: "NestedArray", value: []any{1, []any{2, 3}, []any{4, 5}}, expected: "8301820203820405"},
+		{name: "Map", value: map[string]any{"b": []any{2, 3}, "a": 1}, expected: "a26161016162820203"},
+		{name: "MapKeysShortestFirst", value: map[string]any{"aa": 1, "b": 2}, expected: "a261620262616101"},
+		{name: "Struct", value: struct {
+			Title string `json:"title"`
+		}{Title: "a"}, expected: "a1657469746c656161"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			enc := CborEncoder{}
+
+			// When
+			data, err := enc.Encode(tc.value)
+
+			// Then
+			if err != nil {
+				t.Fatalf("expected no error, got %s", err.Error())
+			}
+
+			if got := hex.EncodeToString(data); got != tc.expected {
+				t.Errorf("expected %s, got %s", tc.expected, got)
+			}
+		})
+	}
+}
+
+// MARK: MessagePack
+func TestMessagePackEncoder(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    any
+		expected string
+	}{
+		{name: "Zero", value: 0, expected: "00"},
+		{name: "PositiveFixInt", value: 127, expected: "7f"},
+		{name: "Uint8", value: 128, expected: "cc80"},
+		{name: "Uint16", value: 256, expected: "cd0100"},
+		{name: "Uint32", value: 65536, expected: "ce00010000"},
+		{name: "Uint64", value: 4294967296, expected: "cf0000000100000000"},
+		{name: "NegativeFixInt", value: -1, expected: "ff"},
+		{name: "NegativeFixIntMin", value: -32, expected: "e0"},
+		{name: "Int8", value: -33, expected: "d0df"},
+		{name: "Int16", value: -129, expected: "d1ff7f"},
+		{name: "Int32", value: -32769, expected: "d2ffff7fff"},
+		{name: "Float", value: 1.5, expected: "cb3ff8000000000000"},
+		{name: "Nil", value: nil, expected: "c0"},
+		{name: "True", value: true, expected: "c3"},
+		{name: "False", value: false, expected: "c2"},
+		{name: "EmptyString", value: "", expected: "a0"},
+		{name: "FixStr", value: "a", expected: "a161"},
+		{name: "Str8", value: strings.Repeat("a", 32), expected: "d920" + strings.Repeat("61", 32)},
+		{name: "FixArray", value: []any{1, 2}, expected: "920102"},
+		{name: "Array16", value: make([]any, 16), expected: "dc0010" + strings.Repeat("c0", 16)},
+		{name: "FixMap", value: map[string]any{"a": 1}, expected: "81a16101"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			enc := MessagePackEncoder{}
+
+			// When
+			data, err := enc.Encode(tc.value)
+
+			// Then
+			if err != nil {
+				t.Fatalf("expected no error, got %s", err.Error())
+			}
+
+			if got := hex.EncodeToString(data); got != tc.expected {
+				t.Errorf("expected %s, got %s", tc.expected, got)
+			}
+		})
+	}
+}
+
+// MARK: NDJSON
+func TestNdjsonEncoder(t *testing.T) {
+	// Given
+	page := Paged[string]{Items: []string{"a", "<b>"}, Limit: 2, NextCursor: "abc"}
+
+	// When
+	data, err := NdjsonEncoder{}.Encode(page)
+
+	// Then
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+
+	if expected := "\"a\"\n\"<b>\"\n"; string(data) != expected {
+		t.Errorf("expected %q, got %q", expected, string(data))
+	}
+
+	if _, err := (NdjsonEncoder{}).Encode("a"); !errors.Is(err, NotAcceptableError) {
+		t.Errorf("expected a single item to be not acceptable, got %v", err)
+	}
+}
+
+// MARK: Negotiation
+func TestEncodersNegotiate(t *testing.T) {
+	tests := []struct {
+		name          string
+		accept        string
+		value         any
+		expectedType  HeaderValue
+		expectedError error
+	}{
+		{name: "NoAccept", accept: "", value: "a", expectedType: ApplicationJson},
+		{name: "Exact", accept: "application/cbor", value: "a", expectedType: ApplicationCbor},
+		{name: "Quality", accept: "application/json;q=0.5, application/msgpack", value: "a", expectedType: ApplicationMsgPack},
+		{name: "Wildcard", accept: "*/*", value: "a", expectedType: ApplicationJson},
+		{name: "MostSpecificWins", accept: "application/*;q=0.9, application/json;q=0.1", value: "a", expectedType: ApplicationCbor},
+		{name: "Refused", accept: "application/json;q=0, */*", value: "a", expectedType: ApplicationCbor},
+		{name: "Parameters", accept: "application/json; charset=utf-8", value: "a", expectedType: ApplicationJson},
+		{name: "Unparseable", accept: ";;;", value: "a", expectedType: ApplicationJson},
+		{name: "NdjsonList", accept: "application/x-ndjson", value: Paged[string]{}, expectedType: ApplicationNdjson},
+		{name: "NdjsonSingle", accept: "application/x-ndjson", value: "a", expectedError: NotAcceptableError},
+		{name: "NotAcceptable", accept: "text/html", value: "a", expectedError: NotAcceptableError},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.accept != "" {
+				r.Header.Set(AcceptKey.Name(), tc.accept)
+			}
+
+			// When
+			enc, err := DefaultEncoders.Negotiate(r, tc.value)
+
+			// Then
+			if !errors.Is(err, tc.expectedError) {
+				t.Fatalf("expected error %v, got %v", tc.expectedError, err)
+			}
+
+			if err == nil && enc.MediaType() != tc.expectedType {
+				t.Errorf("expected %s, got %s", tc.expectedType, enc.MediaType())
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package responses
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+)
+
+const (
+	msgRequestFailed = "REQUEST_FAILED"
+	msgEncodeError   = "ENCODE_ERROR"
+	keyError         = "ERROR"
+	keyCode          = "CODE"
+	keyStatus        = "STATUS"
+)
+
+// MARK: Response
+/*
+What a handler sends when it succeeds
+
+Body is encoded in the media type the client accepts, an Encoded body is sent
+as is. Responses with a body get a Content-Digest and Repr-Digest.
+*/
+type Response struct {
+	Status  int
+	Body    any
+	Headers Headers
+}
+
+func OK(body any, headers ...Header) Response {
+	return Response{Status: http.StatusOK, Body: body, Headers: headers}
+}
+
+func Created(body any, headers ...Header) Response {
+	return Response{Status: http.StatusCreated, Body: body, Headers: headers}
+}
+
+/*
+The body reports a status for each part of the request, some of which failed
+*/
+func MultiStatus(body any, headers ...Header) Response {
+	return Response{Status: http.StatusMultiStatus, Body: body, Headers: headers}
+}
+
+func NoContent(headers ...Header) Response {
+	return Response{Status: http.StatusNoContent, Headers: headers}
+}
+
+func NotModified(headers ...Header) Response {
+	return Response{Status: http.StatusNotModified, Headers: headers}
+}
+
+// MARK: Handler
+/*
+A handler that returns its response instead of writing it
+
+Headers returned with an error are sent with the problem, e.g., Accept-Patch
+with a 415
+*/
+type Handler func(r *http.Request) (Response, error)
+
+/*
+The problem for err and the level to log it at
+
+Client errors are logged as info, server errors and anything that wasn't
+registered as an error
+*/
+func (reg *Registry) Classify(err error) (Problem, slog.Level) {
+	problem, _ := reg.Problem(err)
+
+	if problem.Status >= http.StatusInternalServerError {
+		return problem, slog.LevelError
+	}
+
+	return problem, slog.LevelInfo
+}
+
+/*
+Logs err and writes the problem registered for it
+*/
+func (reg *Registry) WriteError(ctx context.Context, w http.ResponseWriter, err error, headers Headers) {
+	problem, level := reg.Classify(err)
+
+	slog.LogAttrs(
+		ctx,
+		level,
+		msgRequestFailed,
+		slog.String(keyError, err.Error()),
+		slog.String(keyCode, problem.Code),
+		slog.Int(keyStatus, problem.Status),
+	)
+
+	WriteProblem(w, problem, &headers)
+}
+
+/*
+Adapts h to an http.HandlerFunc, errors are written as the problems registered for them
+
+Bodies are encoded in the media type negotiated from the Accept header, the
+digests are of the bytes that are sent
+*/
+func (reg *Registry) Handle(h Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp, err := h(r)
+		if err != nil {
+			reg.WriteError(r.Context(), w, err, resp.Headers)
+			return
+		}
+
+		// The representation depends on Accept, even when there is no body, e.g., a 304
+		headers := append(resp.Headers, Vary(AcceptKey))
+
+		if resp.Body == nil {
+			writeResponse(w, nil, resp.Status, &headers)
+			return
+		}
+
+		encoded, ok := resp.Body.(Encoded)
+		if !ok {
+			encoded, err = reg.encoders.Encode(r, resp.Body)
+			if errors.Is(err, NotAcceptableError) {
+				reg.WriteError(r.Context(), w, err, Headers{Vary(AcceptKey)})
+				return
+			}
+
+			if err != nil {
+				slog.LogAttrs(
+					r.Context(),
+					slog.LevelError,
+					msgEncodeError,
+					slog.String(keyError, err.Error()),
+				)
+
+				WriteInternalServerErrorResponse(w)
+				return
+			}
+		}
+
+		// Clients can ask for the algorithms with Want-Content-Digest and Want-Repr-Digest
+		contentAlg := WantedDigest(r.Header.Get(WantContentDigestKey.Name()))
+		reprAlg := WantedDigest(r.Header.Get(WantReprDigestKey.Name()))
+		headers = append(
+			headers,
+			ContentDigest(CalculateDigest(contentAlg, &encoded.Data), contentAlg),
+			ReprDigest(CalculateDigest(reprAlg, &encoded.Data), reprAlg),
+		)
+
+		writeResponseAs(w, &encoded.Data, resp.Status, encoded.MediaType, &headers)
+	}
+}
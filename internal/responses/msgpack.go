@@ -0,0 +1,173 @@
+package responses
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// MessagePack formats, from the spec at msgpack.org
+const (
+	msgPackNil      byte = 0xc0
+	msgPackFalse    byte = 0xc2
+	msgPackTrue     byte = 0xc3
+	msgPackFloat64  byte = 0xcb
+	msgPackUint8    byte = 0xcc
+	msgPackUint16   byte = 0xcd
+	msgPackUint32   byte = 0xce
+	msgPackUint64   byte = 0xcf
+	msgPackInt8     byte = 0xd0
+	msgPackInt16    byte = 0xd1
+	msgPackInt32    byte = 0xd2
+	msgPackInt64    byte = 0xd3
+	msgPackFixStr   byte = 0xa0
+	msgPackStr8     byte = 0xd9
+	msgPackStr16    byte = 0xda
+	msgPackStr32    byte = 0xdb
+	msgPackFixArray byte = 0x90
+	msgPackArray16  byte = 0xdc
+	msgPackArray32  byte = 0xdd
+	msgPackFixMap   byte = 0x80
+	msgPackMap16    byte = 0xde
+	msgPackMap32    byte = 0xdf
+)
+
+/*
+MessagePack, the body has the same fields as its JSON
+*/
+type MessagePackEncoder struct{}
+
+func (MessagePackEncoder) MediaType() HeaderValue {
+	return ApplicationMsgPack
+}
+
+func (MessagePackEncoder) CanEncode(v any) bool {
+	return true
+}
+
+func (MessagePackEncoder) Encode(v any) ([]byte, error) {
+	value, err := jsonValue(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return appendMsgPack(nil, value)
+}
+
+/*
+The smallest format for an integer
+*/
+func appendMsgPackInt(buf []byte, i int64) []byte {
+	switch {
+	case i >= 0:
+		return appendMsgPackUint(buf, uint64(i))
+	case i >= -32:
+		// Negative fixint
+		return append(buf, byte(i))
+	case i >= math.MinInt8:
+		return append(buf, msgPackInt8, byte(i))
+	case i >= math.MinInt16:
+		return binary.BigEndian.AppendUint16(append(buf, msgPackInt16), uint16(i))
+	case i >= math.MinInt32:
+		return binary.BigEndian.AppendUint32(append(buf, msgPackInt32), uint32(i))
+	default:
+		return binary.BigEndian.AppendUint64(append(buf, msgPackInt64), uint64(i))
+	}
+}
+
+func appendMsgPackUint(buf []byte, u uint64) []byte {
+	switch {
+	case u < 128:
+		// Positive fixint
+		return append(buf, byte(u))
+	case u <= math.MaxUint8:
+		return append(buf, msgPackUint8, byte(u))
+	case u <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(buf, msgPackUint16), uint16(u))
+	case u <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(buf, msgPackUint32), uint32(u))
+	default:
+		return binary.BigEndian.AppendUint64(append(buf, msgPackUint64), u)
+	}
+}
+
+/*
+The header of a string, array or map, fix is the format for small lengths
+*/
+func appendMsgPackLength(buf []byte, n int, fix byte, fixMax int, f8, f16, f32 byte) []byte {
+	switch {
+	case n <= fixMax:
+		return append(buf, fix|byte(n))
+	case f8 != 0 && n <= math.MaxUint8:
+		return append(buf, f8, byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(buf, f16), uint16(n))
+	default:
+		return binary.BigEndian.AppendUint32(append(buf, f32), uint32(n))
+	}
+}
+
+func appendMsgPackString(buf []byte, s string) []byte {
+	buf = appendMsgPackLength(buf, len(s), msgPackFixStr, 31, msgPackStr8, msgPackStr16, msgPackStr32)
+	return append(buf, s...)
+}
+
+func appendMsgPack(buf []byte, v any) ([]byte, error) {
+	switch t := v.(type) {
+	case nil:
+		return append(buf, msgPackNil), nil
+	case bool:
+		if t {
+			return append(buf, msgPackTrue), nil
+		}
+
+		return append(buf, msgPackFalse), nil
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return appendMsgPackInt(buf, i), nil
+		}
+
+		if u, err := strconv.ParseUint(t.String(), 10, 64); err == nil {
+			return appendMsgPackUint(buf, u), nil
+		}
+
+		f, err := t.Float64()
+		if err != nil {
+			return nil, err
+		}
+
+		return binary.BigEndian.AppendUint64(append(buf, msgPackFloat64), math.Float64bits(f)), nil
+	case string:
+		return appendMsgPackString(buf, t), nil
+	case []any:
+		// Arrays have no 8 bit format
+		buf = appendMsgPackLength(buf, len(t), msgPackFixArray, 15, 0, msgPackArray16, msgPackArray32)
+
+		var err error
+		for _, item := range t {
+			if buf, err = appendMsgPack(buf, item); err != nil {
+				return nil, err
+			}
+		}
+
+		return buf, nil
+	case map[string]any:
+		// Nor do maps
+		buf = appendMsgPackLength(buf, len(t), msgPackFixMap, 15, 0, msgPackMap16, msgPackMap32)
+
+		var err error
+		for _, k := range sortedKeys(t) {
+			buf = appendMsgPackString(buf, k)
+
+			if buf, err = appendMsgPack(buf, t[k]); err != nil {
+				return nil, err
+			}
+		}
+
+		return buf, nil
+	default:
+		return nil, fmt.Errorf("msgpack: unsupported type %T", v)
+	}
+}
@@ -0,0 +1,76 @@
+package responses
+
+import "github.com/moonmoon1919/go-api-reference/internal/store"
+
+// MARK: Paged
+type Total struct {
+	Count int64 `json:"count"`
+	// True when the count is the database's estimate
+	Estimated bool `json:"estimated"`
+}
+
+/*
+Envelope for every list response
+*/
+type Paged[T any] struct {
+	Items []T `json:"items"`
+	Limit int `json:"limit"`
+	// Only set when paging with page, cursors replace it
+	Page    int  `json:"page,omitempty"`
+	HasMore bool `json:"has_more"`
+	// Only set when the client asked for it with total=exact or total=estimated
+	Total *Total `json:"total,omitempty"`
+	// Opaque tokens for the cursor query parameter, empty when there is no such page
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+}
+
+/*
+Builds the envelope for a page of S, convert turns every item into its response
+*/
+func NewPaged[S, T any](page store.Page[S], p store.Pagination, convert func(S) T) Paged[T] {
+	items := make([]T, len(page.Items))
+
+	for idx, i := range page.Items {
+		items[idx] = convert(i)
+	}
+
+	paged := Paged[T]{
+		Items:   items,
+		Limit:   p.Limit,
+		HasMore: page.HasMore(),
+	}
+
+	if p.Cursor == nil {
+		paged.Page = p.Page
+	}
+
+	if page.Total != nil {
+		paged.Total = &Total{Count: page.Total.Count, Estimated: page.Total.Estimated}
+	}
+
+	return paged
+}
+
+/*
+Adds the tokens for the next and previous page
+*/
+func (p Paged[T]) WithCursors(next, prev string) Paged[T] {
+	p.NextCursor = next
+	p.PrevCursor = prev
+
+	return p
+}
+
+/*
+The items on their own, for encodings that stream them, e.g., NDJSON
+*/
+func (p Paged[T]) Records() []any {
+	records := make([]any, len(p.Items))
+
+	for idx, i := range p.Items {
+		records[idx] = i
+	}
+
+	return records
+}
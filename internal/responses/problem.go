@@ -0,0 +1,159 @@
+package responses
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const (
+	ApplicationProblemJson HeaderValue = "application/problem+json"
+	RequestIdKey           HeaderKey   = "X-Request-Id"
+	problemTypePrefix                  = "urn:go-api-reference:problem:"
+)
+
+// MARK: Problem
+/*
+RFC 9457 problem details, every error response has this shape
+
+Code is stable for clients to match on, Title and Detail are for people and
+may change. Errors lists the fields that were rejected, if any.
+*/
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      string       `json:"code"`
+	RequestId string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+/*
+A problem whose type is derived from its code, e.g., NOT_FOUND is
+urn:go-api-reference:problem:not-found
+*/
+func NewProblem(status int, code string) Problem {
+	return Problem{
+		Type:   problemTypePrefix + strings.ToLower(strings.ReplaceAll(code, "_", "-")),
+		Title:  http.StatusText(status),
+		Status: status,
+		Code:   code,
+	}
+}
+
+func (p Problem) WithDetail(detail string) Problem {
+	p.Detail = detail
+	return p
+}
+
+func (p Problem) WithErrors(errs ...FieldError) Problem {
+	p.Errors = errs
+	return p
+}
+
+/*
+Problems are never worth caching, the request id also makes every body unique
+*/
+func WriteProblem(w http.ResponseWriter, p Problem, headers *Headers) {
+	// Set by the request id middleware before any handler runs
+	if id := w.Header().Get(RequestIdKey.Name()); id != "" {
+		p.RequestId = id
+
+		// The request id names this occurrence of the problem
+		if _, err := uuid.Parse(id); err == nil {
+			p.Instance = "urn:uuid:" + id
+		}
+	}
+
+	respBytes, err := json.Marshal(p)
+	if err != nil {
+		http.Error(w, HTTP_ERROR_DEFAULT, http.StatusInternalServerError)
+		return
+	}
+
+	writeResponseAs(w, &respBytes, p.Status, ApplicationProblemJson, headers)
+}
+
+// MARK: Registry
+type registration struct {
+	target  error
+	problem Problem
+	match   func(error) (Problem, bool)
+}
+
+/*
+Maps the errors a service returns to the problems a client sees
+
+Errors are matched in the order they were registered, with errors.Is for
+sentinels and the match func for typed errors. The detail of a sentinel is
+its own message, never the wrapped chain, so internals don't leak.
+*/
+type Registry struct {
+	registrations []registration
+	encoders      Encoders
+}
+
+/*
+A registry that offers the default encoders, a 406 is registered for when
+none of them are acceptable
+*/
+func NewRegistry() *Registry {
+	r := &Registry{encoders: DefaultEncoders}
+	return r.Register(NotAcceptableError, http.StatusNotAcceptable, NOT_ACCEPTABLE)
+}
+
+/*
+Replaces the encoders responses are negotiated from, in order of preference
+*/
+func (r *Registry) Encoders(encoders ...Encoder) *Registry {
+	r.encoders = encoders
+	return r
+}
+
+/*
+Registers the problem for target and any error that wraps it
+*/
+func (r *Registry) Register(target error, status int, code string) *Registry {
+	r.registrations = append(r.registrations, registration{
+		target:  target,
+		problem: NewProblem(status, code).WithDetail(target.Error()),
+	})
+
+	return r
+}
+
+/*
+Registers a func for typed errors, e.g., to report validation errors per field
+*/
+func (r *Registry) RegisterFunc(match func(error) (Problem, bool)) *Registry {
+	r.registrations = append(r.registrations, registration{match: match})
+	return r
+}
+
+/*
+The problem registered for err
+
+Returns an internal server error and false when nothing was registered for it
+*/
+func (r *Registry) Problem(err error) (Problem, bool) {
+	for _, reg := range r.registrations {
+		if reg.match != nil {
+			if p, ok := reg.match(err); ok {
+				return p, true
+			}
+
+			continue
+		}
+
+		if errors.Is(err, reg.target) {
+			return reg.problem, true
+		}
+	}
+
+	return NewProblem(http.StatusInternalServerError, INTERNAL_SERVER_ERROR), false
+}
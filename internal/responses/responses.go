@@ -3,15 +3,20 @@ package responses
 import (
 	"crypto/sha256"
 	"encoding/base64"
-	"encoding/json"
 	"fmt"
+	"math"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type DigestAlgorithm string
 
 const (
 	SHA256 DigestAlgorithm = "sha-256"
+	SHA512 DigestAlgorithm = "sha-512"
 )
 
 type HeaderKey string
@@ -29,8 +34,17 @@ func (h HeaderValue) Value() string {
 const (
 	ContentType         HeaderKey   = "Content-Type"
 	ContentDigestKey    HeaderKey   = "Content-Digest"
+	ReprDigestKey       HeaderKey   = "Repr-Digest"
 	CacheControlKey     HeaderKey   = "Cache-Control"
 	EtagKey             HeaderKey   = "Etag"
+	LastModifiedKey     HeaderKey   = "Last-Modified"
+	LinkKey             HeaderKey   = "Link"
+	AcceptPatchKey      HeaderKey   = "Accept-Patch"
+	RetryAfterKey       HeaderKey   = "Retry-After"
+	RateLimitKey        HeaderKey   = "RateLimit-Limit"
+	RateLimitLeftKey    HeaderKey   = "RateLimit-Remaining"
+	RateLimitResetKey   HeaderKey   = "RateLimit-Reset"
+	RateLimitPolicyKey  HeaderKey   = "RateLimit-Policy"
 	NoCacheValue        HeaderValue = "no-cache"
 	NoCachePrivateValue HeaderValue = "no-cache, private"
 	ApplicationJson     HeaderValue = "application/json"
@@ -42,10 +56,19 @@ const (
 	NOT_FOUND             = "NOT_FOUND"
 	CONFLICT              = "CONFLICT"
 	PRECONDITION_FAILED   = "PRECONDITION_FAILED"
+	VALIDATION_FAILED     = "VALIDATION_FAILED"
+	FAILED_DEPENDENCY     = "FAILED_DEPENDENCY"
+	UNSUPPORTED_MEDIA     = "UNSUPPORTED_MEDIA_TYPE"
+	PATCH_TEST_FAILED     = "PATCH_TEST_FAILED"
+	PATCH_NOT_APPLICABLE  = "PATCH_NOT_APPLICABLE"
+	IDEMPOTENCY_KEY_BUSY  = "IDEMPOTENCY_KEY_IN_USE"
+	IDEMPOTENCY_KEY_REUSE = "IDEMPOTENCY_KEY_REUSED"
+	TOO_MANY_REQUESTS     = "TOO_MANY_REQUESTS"
+	QUOTA_EXCEEDED        = "QUOTA_EXCEEDED"
 	INTERNAL_SERVER_ERROR = "INTERNAL_SERVER_ERROR"
 )
 
-const HTTP_ERROR_DEFAULT = `{"error": "INTERNAL_SERVER_ERROR"}`
+const HTTP_ERROR_DEFAULT = `{"type": "urn:go-api-reference:problem:internal-server-error", "title": "Internal Server Error", "status": 500, "code": "INTERNAL_SERVER_ERROR"}`
 
 type Header struct {
 	key   HeaderKey
@@ -83,17 +106,129 @@ func ContentDigest(data string, alg DigestAlgorithm) Header {
 	return Header{key: ContentDigestKey, value: fmt.Sprintf("%s=%s", alg, data)}
 }
 
+/*
+RFC 9530 digest of the representation, the same as the Content-Digest unless
+only part of it is sent
+*/
+func ReprDigest(data string, alg DigestAlgorithm) Header {
+	return Header{key: ReprDigestKey, value: fmt.Sprintf("%s=%s", alg, data)}
+}
+
 func Etag(value string) Header {
 	return Header{key: EtagKey, value: value}
 }
 
+func LastModified(t time.Time) Header {
+	return Header{key: LastModifiedKey, value: t.UTC().Format(http.TimeFormat)}
+}
+
+// MARK: Rate Limits
+/*
+Whole seconds, rounded up so clients never come back too early
+*/
+func wholeSeconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
+
+func RetryAfter(d time.Duration) Header {
+	return Header{key: RetryAfterKey, value: wholeSeconds(d)}
+}
+
+/*
+The RateLimit headers from the IETF draft, remaining requests and the
+seconds until the limit is fully available again
+*/
+func RateLimit(limit, remaining int, reset time.Duration) Headers {
+	return Headers{
+		{key: RateLimitKey, value: strconv.Itoa(limit)},
+		{key: RateLimitLeftKey, value: strconv.Itoa(remaining)},
+		{key: RateLimitResetKey, value: wholeSeconds(reset)},
+	}
+}
+
+/*
+The limit that applies, e.g., 100;w=60 for 100 requests a minute
+*/
+func RateLimitPolicy(limit int, window time.Duration) Header {
+	return Header{key: RateLimitPolicyKey, value: fmt.Sprintf("%d;w=%s", limit, wholeSeconds(window))}
+}
+
+/*
+Media types a resource accepts for PATCH
+*/
+func AcceptPatch(mediaTypes ...string) Header {
+	return Header{key: AcceptPatchKey, value: strings.Join(mediaTypes, ", ")}
+}
+
+// MARK: Links
+const (
+	RelNext = "next"
+	RelPrev = "prev"
+)
+
+type Link struct {
+	Target string
+	Rel    string
+}
+
+/*
+RFC 8288 Link header, e.g., <https://example.com/items?cursor=abc>; rel="next"
+*/
+func Links(links ...Link) Header {
+	values := make([]string, len(links))
+
+	for idx, l := range links {
+		values[idx] = fmt.Sprintf("<%s>; rel=%q", l.Target, l.Rel)
+	}
+
+	return Header{key: LinkKey, value: strings.Join(values, ", ")}
+}
+
+/*
+Links to the next and previous page of the listing at u
+
+Empty tokens are left out, returns false when there is nothing to link to.
+The page parameter is dropped since a cursor replaces it.
+*/
+func PageLinks(u *url.URL, next, prev string) (Header, bool) {
+	links := make([]Link, 0, 2)
+
+	for _, l := range []Link{{Target: next, Rel: RelNext}, {Target: prev, Rel: RelPrev}} {
+		if l.Target == "" {
+			continue
+		}
+
+		query := u.Query()
+		query.Del("page")
+		query.Set("cursor", l.Target)
+
+		target := url.URL{Path: u.Path, RawQuery: query.Encode()}
+		links = append(links, Link{Target: target.String(), Rel: l.Rel})
+	}
+
+	if len(links) == 0 {
+		return Header{}, false
+	}
+
+	return Links(links...), true
+}
+
 // MARK: Responses
-type errorResponse struct {
-	Error string `json:"error"`
+/*
+Why a single field was rejected, Code is stable for clients to match on
+*/
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
 }
 
 func writeResponse(w http.ResponseWriter, data *[]byte, code int, headers *Headers) {
-	w.Header().Set(ContentType.Name(), ApplicationJson.Value())
+	writeResponseAs(w, data, code, ApplicationJson, headers)
+}
+
+func writeResponseAs(w http.ResponseWriter, data *[]byte, code int, contentType HeaderValue, headers *Headers) {
+	w.Header().Set(ContentType.Name(), contentType.Value())
 	for _, header := range *headers {
 		w.Header().Set(header.Key(), header.Value())
 	}
@@ -113,24 +248,20 @@ func writeResponse(w http.ResponseWriter, data *[]byte, code int, headers *Heade
 	w.Write(*data)
 }
 
-func writeErrorResponse(w http.ResponseWriter, error string, code int) {
-	e := errorResponse{
-		Error: error,
-	}
-
-	respBytes, err := json.Marshal(e)
-
-	if err != nil {
-		http.Error(w, HTTP_ERROR_DEFAULT, http.StatusInternalServerError)
-		return
-	}
+func writeErrorResponse(w http.ResponseWriter, code string, status int) {
+	WriteProblem(w, NewProblem(status, code), &Headers{})
+}
 
-	writeResponse(w, &respBytes, code, &Headers{})
+func writeErrorResponseWithHeaders(w http.ResponseWriter, code string, status int, headers *Headers) {
+	WriteProblem(w, NewProblem(status, code), headers)
 }
 
 // MARK: Error Responses
-func WriteBadRequestResponse(w http.ResponseWriter, error string) {
-	writeErrorResponse(w, error, http.StatusBadRequest)
+/*
+Code should be a stable code clients can match on, never an error message
+*/
+func WriteBadRequestResponse(w http.ResponseWriter, code string) {
+	writeErrorResponse(w, code, http.StatusBadRequest)
 }
 
 func WriteUnauthorizedResponse(w http.ResponseWriter) {
@@ -149,6 +280,62 @@ func WritePreflightConditionFailedResponse(w http.ResponseWriter) {
 	writeErrorResponse(w, PRECONDITION_FAILED, http.StatusPreconditionFailed)
 }
 
+/*
+Headers should tell the client which media types are accepted, e.g., Accept-Patch
+*/
+func WriteUnsupportedMediaTypeResponse(w http.ResponseWriter, headers *Headers) {
+	writeErrorResponseWithHeaders(w, UNSUPPORTED_MEDIA, http.StatusUnsupportedMediaType, headers)
+}
+
+/*
+A JSON Patch test operation didn't match the current resource
+*/
+func WritePatchTestFailedResponse(w http.ResponseWriter) {
+	writeErrorResponse(w, PATCH_TEST_FAILED, http.StatusConflict)
+}
+
+/*
+The patch was well formed but can't be applied to the resource, e.g., its path doesn't exist
+*/
+func WritePatchNotApplicableResponse(w http.ResponseWriter) {
+	writeErrorResponse(w, PATCH_NOT_APPLICABLE, http.StatusUnprocessableEntity)
+}
+
+/*
+The request was well formed but its content breaks the domain's rules
+*/
+func WriteUnprocessableEntityResponse(w http.ResponseWriter, details []FieldError) {
+	WriteProblem(w, NewProblem(http.StatusUnprocessableEntity, VALIDATION_FAILED).WithErrors(details...), &Headers{})
+}
+
+/*
+A request with the same Idempotency-Key is still being handled
+*/
+func WriteIdempotencyKeyInUseResponse(w http.ResponseWriter) {
+	writeErrorResponse(w, IDEMPOTENCY_KEY_BUSY, http.StatusConflict)
+}
+
+/*
+The Idempotency-Key was already used for a different request
+*/
+func WriteIdempotencyKeyReusedResponse(w http.ResponseWriter) {
+	writeErrorResponse(w, IDEMPOTENCY_KEY_REUSE, http.StatusUnprocessableEntity)
+}
+
+/*
+Headers should include Retry-After and the RateLimit headers
+*/
+func WriteTooManyRequestsResponse(w http.ResponseWriter, headers *Headers) {
+	writeErrorResponseWithHeaders(w, TOO_MANY_REQUESTS, http.StatusTooManyRequests, headers)
+}
+
+/*
+The user is storing as much as their quota allows, retrying won't help until they remove something
+*/
+func WriteQuotaExceededResponse(w http.ResponseWriter) {
+	writeErrorResponse(w, QUOTA_EXCEEDED, http.StatusForbidden)
+}
+
 func WriteInternalServerErrorResponse(w http.ResponseWriter) {
 	writeErrorResponse(w, INTERNAL_SERVER_ERROR, http.StatusInternalServerError)
 }
@@ -166,6 +353,13 @@ func WriteCreatedResponse(w http.ResponseWriter, data *[]byte, headers *Headers)
 	writeResponse(w, data, http.StatusCreated, headers)
 }
 
+/*
+The body reports a status for each part of the request, some of which failed
+*/
+func WriteMultiStatusResponse(w http.ResponseWriter, data *[]byte, headers *Headers) {
+	writeResponse(w, data, http.StatusMultiStatus, headers)
+}
+
 func WriteNoContentResponse(w http.ResponseWriter, headers *Headers) {
 	writeResponse(w, nil, http.StatusNoContent, headers)
 }
@@ -9,6 +9,9 @@ const (
 	QueueShutdownSignalMsg    = "QUEUE_SHUTDOWN_SIGNAL_RECEIVED"
 	QueueShutdownCompleteMsg  = "QUEUE_SHUTDOWN_COMPLETE"
 	ProcessShutdownMsg        = "PROCESS_SHUTDOWN_COMPLETE"
+	InvalidConfigMsg          = "INVALID_CONFIG"
+	EffectiveConfigMsg        = "EFFECTIVE_CONFIG"
+	ConfigPrefetchFailedMsg   = "CONFIG_PREFETCH_FAILED"
 	LogKeyError               = "error"
 	LogKeyAddr                = "addr"
 )
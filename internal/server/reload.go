@@ -0,0 +1,37 @@
+package server
+
+import (
+	"log/slog"
+	"strings"
+
+	"github.com/moonmoon1919/go-api-reference/internal/config"
+)
+
+/*
+Keeps level in sync with a reloadable config key, e.g., LOG_LEVEL=DEBUG
+
+Values are slog level names (DEBUG, INFO, WARN, ERROR) with an optional offset
+(e.g., INFO+2), an invalid value rejects the reload
+*/
+func WatchLogLevel(r *config.Reloader, key string, level *slog.LevelVar) {
+	r.Validate(key, func(v string) error {
+		_, err := parseLevel(v)
+		return err
+	})
+
+	r.Subscribe(key, func(v string) {
+		l, err := parseLevel(v)
+		if err != nil {
+			return
+		}
+
+		level.Set(l)
+	})
+}
+
+func parseLevel(v string) (slog.Level, error) {
+	var l slog.Level
+	err := l.UnmarshalText([]byte(strings.TrimSpace(v)))
+
+	return l, err
+}
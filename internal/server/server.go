@@ -1,9 +1,11 @@
 package server
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/moonmoon1919/go-api-reference/internal/config"
+	"github.com/moonmoon1919/go-api-reference/pkg/users"
 )
 
 const ProcessChannelsBufferSize = 1
@@ -15,7 +17,83 @@ type Timeouts struct {
 }
 
 type Config struct {
-	Port      config.Configurator
-	Profiling config.Configurator
+	Port      string
+	Profiling bool
 	Timeouts  Timeouts
+	// How often reloadable config is re-read, 0 only reloads on SIGHUP
+	ReloadInterval time.Duration
+	// Signs pagination cursors, must be the same on every instance
+	CursorSecret string
+	// How long responses to requests with an Idempotency-Key are kept
+	IdempotencyTTL time.Duration
+	// For users without a quota of their own, -1 is unlimited and 0 allows nothing
+	Quota users.Quota
+	// Responses smaller than this are sent uncompressed
+	CompressionMinSize int
+}
+
+/*
+Resolves the server config, problems are collected by the loader
+*/
+func LoadConfig(l *config.Loader, lookup config.Lookup, defaultPort string) Config {
+	c := Config{
+		Timeouts: Timeouts{
+			Read:  10 * time.Second,
+			Write: 10 * time.Second,
+			Idle:  30 * time.Second,
+		},
+	}
+
+	config.Bind(l, "PORT", lookup("PORT", config.NewDefaultValueSource(defaultPort)), &c.Port)
+	config.Bind(l, "PROFILING_ENABLED", config.Bool(lookup("PROFILING_ENABLED", config.NewDefaultValueSource(""))), &c.Profiling)
+	config.Bind(l, "SERVER_READ_TIMEOUT", config.Duration(lookup("SERVER_READ_TIMEOUT", config.NewDefaultValueSource(c.Timeouts.Read.String()))), &c.Timeouts.Read)
+	config.Bind(l, "SERVER_WRITE_TIMEOUT", config.Duration(lookup("SERVER_WRITE_TIMEOUT", config.NewDefaultValueSource(c.Timeouts.Write.String()))), &c.Timeouts.Write)
+	config.Bind(l, "SERVER_IDLE_TIMEOUT", config.Duration(lookup("SERVER_IDLE_TIMEOUT", config.NewDefaultValueSource(c.Timeouts.Idle.String()))), &c.Timeouts.Idle)
+
+	config.Bind(l, "CONFIG_RELOAD_INTERVAL", config.Duration(lookup("CONFIG_RELOAD_INTERVAL", config.NewDefaultValueSource("0s"))), &c.ReloadInterval)
+
+	config.Bind(l, "CURSOR_SECRET", lookup("CURSOR_SECRET"), &c.CursorSecret, config.Secret())
+
+	config.Bind(l, "IDEMPOTENCY_TTL", config.Duration(lookup("IDEMPOTENCY_TTL", config.NewDefaultValueSource("24h"))), &c.IdempotencyTTL)
+
+	config.Bind(l, "QUOTA_EXAMPLES", quotaLimit(lookup("QUOTA_EXAMPLES", config.NewDefaultValueSource("1000"))), &c.Quota.Examples)
+	config.Bind(l, "QUOTA_BYTES", quotaLimit(lookup("QUOTA_BYTES", config.NewDefaultValueSource("10485760"))), &c.Quota.Bytes)
+
+	config.Bind(l, "COMPRESSION_MIN_BYTES", nonNegative(lookup("COMPRESSION_MIN_BYTES", config.NewDefaultValueSource("1024"))), &c.CompressionMinSize)
+
+	return c
+}
+
+func nonNegative(src config.Configurator) config.CustomSource[int] {
+	i := config.Int(src)
+
+	return config.NewCustomSource(func() (int, error) {
+		v, err := i.Get()
+		if err != nil {
+			return 0, err
+		}
+
+		if v < 0 {
+			return 0, fmt.Errorf("%w: %d is negative", config.InvalidValueError, v)
+		}
+
+		return v, nil
+	})
+}
+
+func quotaLimit(src config.Configurator) config.CustomSource[int] {
+	i := config.Int(src)
+
+	return config.NewCustomSource(func() (int, error) {
+		v, err := i.Get()
+		if err != nil {
+			return 0, err
+		}
+
+		if v < users.Unlimited {
+			return 0, fmt.Errorf("%w: %d is below %d", config.InvalidValueError, v, users.Unlimited)
+		}
+
+		return v, nil
+	})
 }
@@ -7,20 +7,35 @@ import (
 )
 
 type Config struct {
-	Host     config.Configurator
-	User     config.Configurator
-	Password config.Configurator
-	Database config.Configurator
-	Schema   config.Configurator
+	Host     string
+	User     string
+	Password string
+	Database string
+	Schema   string
+}
+
+/*
+Resolves the database config, problems are collected by the loader
+*/
+func LoadConfig(l *config.Loader, lookup config.Lookup) Config {
+	var c Config
+
+	config.Bind(l, "DB_HOST", lookup("DB_HOST"), &c.Host)
+	config.Bind(l, "DB_USER", lookup("DB_USER"), &c.User)
+	config.Bind(l, "DB_PASS", lookup("DB_PASS"), &c.Password, config.Secret())
+	config.Bind(l, "DB_NAME", lookup("DB_NAME"), &c.Database)
+	config.Bind(l, "DB_SCHEMA", lookup("DB_SCHEMA", config.NewDefaultValueSource("schemas")), &c.Schema)
+
+	return c
 }
 
 func (d Config) ConnectionString() string {
 	return fmt.Sprintf(
 		"postgres://%s:%s@%s/%s?search_path=%s",
-		d.User.Must(),
-		d.Password.Must(),
-		d.Host.Must(),
-		d.Database.Must(),
-		d.Schema.Must(),
+		d.User,
+		d.Password,
+		d.Host,
+		d.Database,
+		d.Schema,
 	)
 }
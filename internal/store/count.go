@@ -0,0 +1,113 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+/*
+Below this many rows an exact count is cheap enough to use instead of an estimate
+*/
+const exactCountThreshold = 1000
+
+var unexpectedPlanError = errors.New("cannot read row estimate from query plan")
+
+// MARK: Count
+type CountMode int
+
+const (
+	NoCount CountMode = iota
+	ExactCount
+	EstimatedCount
+)
+
+/*
+The number of items in a listing, Estimated is true when it came from the planner
+*/
+type Total struct {
+	Count     int64
+	Estimated bool
+}
+
+type Querier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+/*
+Counts the rows of table that match where
+
+Exact counts scan every matching row. Estimates come from pg_class.reltuples
+when there is no condition and from the query plan otherwise, they cost about
+the same on any table size but can be off until the table is analyzed. Small
+estimates are replaced with an exact count.
+
+Returns nil for NoCount. table and where are never user input.
+*/
+func CountRows(ctx context.Context, q Querier, mode CountMode, table, where string, args ...any) (*Total, error) {
+	switch mode {
+	case ExactCount:
+		return exactCount(ctx, q, table, where, args...)
+	case EstimatedCount:
+		var estimate int64
+		var err error
+
+		if where == "" {
+			err = q.QueryRow(ctx, "SELECT reltuples::bigint FROM pg_class WHERE oid = to_regclass($1)", table).Scan(&estimate)
+		} else {
+			estimate, err = planEstimate(ctx, q, fmt.Sprintf("SELECT 1 FROM %s WHERE %s", table, where), args...)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		// reltuples is -1 until the table is first analyzed
+		if estimate < exactCountThreshold {
+			return exactCount(ctx, q, table, where, args...)
+		}
+
+		return &Total{Count: estimate, Estimated: true}, nil
+	default:
+		return nil, nil
+	}
+}
+
+func exactCount(ctx context.Context, q Querier, table, where string, args ...any) (*Total, error) {
+	query := "SELECT count(*) FROM " + table
+	if where != "" {
+		query += " WHERE " + where
+	}
+
+	var count int64
+	if err := q.QueryRow(ctx, query, args...).Scan(&count); err != nil {
+		return nil, err
+	}
+
+	return &Total{Count: count}, nil
+}
+
+/*
+Reads the planner's row estimate for query
+*/
+func planEstimate(ctx context.Context, q Querier, query string, args ...any) (int64, error) {
+	var plan string
+	if err := q.QueryRow(ctx, "EXPLAIN (FORMAT JSON) "+query, args...).Scan(&plan); err != nil {
+		return 0, err
+	}
+
+	var parsed []struct {
+		Plan struct {
+			Rows float64 `json:"Plan Rows"`
+		} `json:"Plan"`
+	}
+
+	if err := json.Unmarshal([]byte(plan), &parsed); err != nil || len(parsed) == 0 {
+		return 0, unexpectedPlanError
+	}
+
+	return int64(parsed[0].Plan.Rows), nil
+}
@@ -0,0 +1,121 @@
+package store
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type fakeRow struct {
+	value any
+}
+
+func (f fakeRow) Scan(dest ...any) error {
+	reflect.ValueOf(dest[0]).Elem().Set(reflect.ValueOf(f.value))
+	return nil
+}
+
+/*
+Answers count queries with count and everything else with estimate
+*/
+type fakeQuerier struct {
+	count    int64
+	estimate any
+	queries  []string
+}
+
+func (f *fakeQuerier) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	f.queries = append(f.queries, sql)
+
+	if strings.HasPrefix(sql, "SELECT count(*)") {
+		return fakeRow{value: f.count}
+	}
+
+	return fakeRow{value: f.estimate}
+}
+
+func TestCountRows(t *testing.T) {
+	tests := []struct {
+		name            string
+		mode            CountMode
+		where           string
+		estimate        any
+		expected        *Total
+		expectedQueries int
+		errMessage      string
+	}{
+		{
+			name:            "PassingCase-NoCount",
+			mode:            NoCount,
+			expectedQueries: 0,
+		},
+		{
+			name:            "PassingCase-Exact",
+			mode:            ExactCount,
+			where:           "uid=$1",
+			expected:        &Total{Count: 12},
+			expectedQueries: 1,
+		},
+		{
+			name:            "PassingCase-EstimateFromTable",
+			mode:            EstimatedCount,
+			estimate:        int64(250000),
+			expected:        &Total{Count: 250000, Estimated: true},
+			expectedQueries: 1,
+		},
+		{
+			name:            "PassingCase-EstimateFromPlan",
+			mode:            EstimatedCount,
+			where:           "uid=$1",
+			estimate:        `[{"Plan": {"Node Type": "Seq Scan", "Plan Rows": 48000}}]`,
+			expected:        &Total{Count: 48000, Estimated: true},
+			expectedQueries: 1,
+		},
+		{
+			name:            "PassingCase-SmallEstimateIsCountedExactly",
+			mode:            EstimatedCount,
+			estimate:        int64(-1),
+			expected:        &Total{Count: 12},
+			expectedQueries: 2,
+		},
+		{
+			name:            "FailingCase-UnexpectedPlan",
+			mode:            EstimatedCount,
+			where:           "uid=$1",
+			estimate:        `{}`,
+			expectedQueries: 1,
+			errMessage:      "cannot read row estimate from query plan",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			q := &fakeQuerier{count: 12, estimate: tc.estimate}
+
+			// When
+			total, err := CountRows(context.TODO(), q, tc.mode, "examples", tc.where, "uid")
+
+			// Then
+			var errMessage string
+			if err != nil {
+				errMessage = err.Error()
+			}
+
+			if errMessage != tc.errMessage {
+				t.Errorf("expected error message %s, got %s", tc.errMessage, errMessage)
+			}
+
+			if !reflect.DeepEqual(total, tc.expected) {
+				t.Errorf("expected %+v, got %+v", tc.expected, total)
+			}
+
+			if len(q.queries) != tc.expectedQueries {
+				t.Errorf("expected %d queries, got %v", tc.expectedQueries, q.queries)
+			}
+		})
+	}
+}
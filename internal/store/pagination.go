@@ -0,0 +1,283 @@
+package store
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// MARK: Cursor
+type Direction int
+
+const (
+	Forward Direction = iota
+	Backward
+)
+
+/*
+A position in a keyset listing
+
+Forward pages start after Key, backward pages end before it. Rows are
+ordered by their key so a cursor stays valid while rows are added or
+removed, unlike an offset. Sorted listings also keep the row's sort value,
+the key breaks ties between rows with the same value.
+*/
+type Cursor struct {
+	Key       string    `json:"k"`
+	Direction Direction `json:"d"`
+	// The sort the cursor was made for, empty in key order
+	Sort  string `json:"s,omitempty"`
+	Value string `json:"v,omitempty"`
+}
+
+// MARK: Pagination
+/*
+How to page through a listing
+
+A Cursor takes precedence over Page, Page is kept for clients that still
+use page/limit
+*/
+type Pagination struct {
+	Limit  int
+	Page   int
+	Cursor *Cursor
+	// Whether and how to count every item in the listing
+	Count CountMode
+	// The zero value orders by key
+	Sort Sort
+}
+
+/*
+A cursor is a position in one ordering, it means nothing in another
+*/
+func (p Pagination) Validate() error {
+	if p.Cursor != nil && p.Cursor.Sort != p.Sort.String() {
+		return CursorSortMismatchError
+	}
+
+	return nil
+}
+
+func (p Pagination) backward() bool {
+	return p.Cursor != nil && p.Cursor.Direction == Backward
+}
+
+/*
+One row more than the limit is fetched to know whether there is another page
+*/
+func (p Pagination) fetchLimit() int {
+	return p.Limit + 1
+}
+
+func (p Pagination) offset() int {
+	return (p.Page - 1) * p.Limit
+}
+
+/*
+Builds the keyset condition, ORDER BY and LIMIT for a query ordered by column
+
+The condition starts with AND so it can follow the WHERE clause of the
+query, placeholders are numbered from next. Pages without a cursor fall
+back to LIMIT/OFFSET. column is never user input.
+*/
+func (p Pagination) Clause(column string, next int) (string, []any) {
+	switch {
+	case p.Cursor == nil:
+		return fmt.Sprintf(" ORDER BY %s LIMIT $%d OFFSET $%d", column, next, next+1), []any{p.fetchLimit(), p.offset()}
+	case p.backward():
+		// Walk backwards from the cursor, NewPage puts the rows back in order
+		return fmt.Sprintf(" AND %s < $%d ORDER BY %s DESC LIMIT $%d", column, next, column, next+1), []any{p.Cursor.Key, p.fetchLimit()}
+	default:
+		return fmt.Sprintf(" AND %s > $%d ORDER BY %s LIMIT $%d", column, next, column, next+1), []any{p.Cursor.Key, p.fetchLimit()}
+	}
+}
+
+func direction(desc bool) string {
+	if desc {
+		return " DESC"
+	}
+
+	return ""
+}
+
+/*
+Builds the keyset condition, ORDER BY and LIMIT for a listing ordered by
+p.Sort with id breaking ties
+
+sort is the column p.Sort.Field maps to, listings without a sort are
+ordered by id alone as in Clause. Neither column is ever user input.
+*/
+func (p Pagination) SortedClause(sort Column, id Column, next int) (string, []any) {
+	if p.Sort.Field == "" {
+		return p.Clause(id.Name, next)
+	}
+
+	desc := p.Sort.Desc
+	if p.backward() {
+		// Walk backwards from the cursor, NewPage puts the rows back in order
+		desc = !desc
+	}
+
+	order := fmt.Sprintf(" ORDER BY %s%s, %s%s", sort.Name, direction(desc), id.Name, direction(desc))
+
+	if p.Cursor == nil {
+		return fmt.Sprintf("%s LIMIT $%d OFFSET $%d", order, next, next+1), []any{p.fetchLimit(), p.offset()}
+	}
+
+	op := ">"
+	if desc {
+		op = "<"
+	}
+
+	return fmt.Sprintf(
+		" AND (%s, %s) %s ($%d::%s, $%d::%s)%s LIMIT $%d",
+		sort.Name, id.Name, op, next, sort.Type, next+1, id.Type, order, next+2,
+	), []any{p.Cursor.Value, p.Cursor.Key, p.fetchLimit()}
+}
+
+// MARK: Page
+type Page[T any] struct {
+	Items []T
+	// nil when there is nothing after this page
+	Next *Cursor
+	// nil when there is nothing before this page
+	Prev *Cursor
+	// nil unless the listing was counted
+	Total *Total
+}
+
+func (p Page[T]) HasMore() bool {
+	return p.Next != nil
+}
+
+/*
+Builds a page from the rows fetched for p, in the order they were fetched
+
+rows holds up to one more row than the limit, as fetched with Clause
+*/
+func NewPage[T any](rows []T, key func(T) string, p Pagination) Page[T] {
+	return NewSortedPage(rows, key, key, p)
+}
+
+/*
+Builds a page from rows fetched with SortedClause
+
+value returns an item's value for p.Sort, it's kept in the page's cursors
+*/
+func NewSortedPage[T any](rows []T, key func(T) string, value func(T) string, p Pagination) Page[T] {
+	more := len(rows) > p.Limit
+	if more {
+		rows = rows[:p.Limit]
+	}
+
+	if p.backward() {
+		slices.Reverse(rows)
+	}
+
+	page := Page[T]{Items: rows}
+	if page.Items == nil {
+		page.Items = make([]T, 0)
+	}
+
+	if len(rows) == 0 {
+		return page
+	}
+
+	var hasNext, hasPrev bool
+	switch {
+	case p.Cursor == nil:
+		hasNext, hasPrev = more, p.Page > 1
+	case p.backward():
+		// We came from the page after this one
+		hasNext, hasPrev = true, more
+	default:
+		// We came from the page before this one
+		hasNext, hasPrev = more, true
+	}
+
+	cursor := func(item T, d Direction) *Cursor {
+		c := &Cursor{Key: key(item), Direction: d, Sort: p.Sort.String()}
+		if p.Sort.Field != "" {
+			c.Value = value(item)
+		}
+
+		return c
+	}
+
+	if hasNext {
+		page.Next = cursor(rows[len(rows)-1], Forward)
+	}
+
+	if hasPrev {
+		page.Prev = cursor(rows[0], Backward)
+	}
+
+	return page
+}
+
+/*
+Pages through items held in memory the same way Clause does in SQL
+*/
+func PageSlice[T any](items []T, key func(T) string, p Pagination) Page[T] {
+	return PageSortedSlice(items, key, key, p)
+}
+
+/*
+Pages through items held in memory the same way SortedClause does in SQL
+*/
+func PageSortedSlice[T any](items []T, key func(T) string, value func(T) string, p Pagination) Page[T] {
+	compare := func(aValue, aKey, bValue, bKey string) int {
+		var c int
+		if p.Sort.Field != "" {
+			c = strings.Compare(aValue, bValue)
+		}
+
+		if c == 0 {
+			c = strings.Compare(aKey, bKey)
+		}
+
+		if p.Sort.Desc {
+			return -c
+		}
+
+		return c
+	}
+
+	sorted := slices.Clone(items)
+	slices.SortFunc(sorted, func(a, b T) int {
+		return compare(value(a), key(a), value(b), key(b))
+	})
+
+	var rows []T
+
+	switch {
+	case p.Cursor == nil:
+		start := min(p.offset(), len(sorted))
+		rows = sorted[start:min(start+p.fetchLimit(), len(sorted))]
+	default:
+		idx, found := slices.BinarySearchFunc(sorted, *p.Cursor, func(item T, c Cursor) int {
+			return compare(value(item), key(item), c.Value, c.Key)
+		})
+
+		if p.backward() {
+			rows = slices.Clone(sorted[max(0, idx-p.fetchLimit()):idx])
+			slices.Reverse(rows)
+			break
+		}
+
+		if found {
+			idx++
+		}
+
+		rows = sorted[idx:min(idx+p.fetchLimit(), len(sorted))]
+	}
+
+	page := NewSortedPage(rows, key, value, p)
+
+	// Counting in memory is free, so it's always exact
+	if p.Count != NoCount {
+		page.Total = &Total{Count: int64(len(items))}
+	}
+
+	return page
+}
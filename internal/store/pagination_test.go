@@ -0,0 +1,259 @@
+package store
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func identity(s string) string {
+	return s
+}
+
+func TestPageSlice(t *testing.T) {
+	items := []string{"e", "a", "d", "b", "c"}
+
+	tests := []struct {
+		name         string
+		pagination   Pagination
+		expected     []string
+		expectedNext string
+		expectedPrev string
+	}{
+		{
+			name:         "PassingCase-FirstPage",
+			pagination:   Pagination{Limit: 2, Page: 1},
+			expected:     []string{"a", "b"},
+			expectedNext: "b",
+		},
+		{
+			name:         "PassingCase-OffsetPage",
+			pagination:   Pagination{Limit: 2, Page: 2},
+			expected:     []string{"c", "d"},
+			expectedNext: "d",
+			expectedPrev: "c",
+		},
+		{
+			name:         "PassingCase-Forward",
+			pagination:   Pagination{Limit: 2, Cursor: &Cursor{Key: "b"}},
+			expected:     []string{"c", "d"},
+			expectedNext: "d",
+			expectedPrev: "c",
+		},
+		{
+			name:         "PassingCase-ForwardToLastPage",
+			pagination:   Pagination{Limit: 2, Cursor: &Cursor{Key: "d"}},
+			expected:     []string{"e"},
+			expectedPrev: "e",
+		},
+		{
+			name:         "PassingCase-BackwardToFirstPage",
+			pagination:   Pagination{Limit: 2, Cursor: &Cursor{Key: "c", Direction: Backward}},
+			expected:     []string{"a", "b"},
+			expectedNext: "b",
+		},
+		{
+			name:         "PassingCase-CursorKeyWasDeleted",
+			pagination:   Pagination{Limit: 2, Cursor: &Cursor{Key: "bb"}},
+			expected:     []string{"c", "d"},
+			expectedNext: "d",
+			expectedPrev: "c",
+		},
+		{
+			name:       "PassingCase-PastTheEnd",
+			pagination: Pagination{Limit: 2, Page: 4},
+			expected:   []string{},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			// When
+			page := PageSlice(items, identity, tc.pagination)
+
+			// Then
+			if !slices.Equal(page.Items, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, page.Items)
+			}
+
+			var next, prev string
+			if page.Next != nil {
+				next = page.Next.Key
+			}
+			if page.Prev != nil {
+				prev = page.Prev.Key
+			}
+
+			if next != tc.expectedNext || prev != tc.expectedPrev {
+				t.Errorf("expected cursors %q and %q, got %q and %q", tc.expectedNext, tc.expectedPrev, next, prev)
+			}
+		})
+	}
+}
+
+func TestPaginationClause(t *testing.T) {
+	tests := []struct {
+		name       string
+		pagination Pagination
+		expected   string
+	}{
+		{
+			name:       "PassingCase-Offset",
+			pagination: Pagination{Limit: 10, Page: 3},
+			expected:   " ORDER BY id LIMIT $2 OFFSET $3",
+		},
+		{
+			name:       "PassingCase-Forward",
+			pagination: Pagination{Limit: 10, Cursor: &Cursor{Key: "a"}},
+			expected:   " AND id > $2 ORDER BY id LIMIT $3",
+		},
+		{
+			name:       "PassingCase-Backward",
+			pagination: Pagination{Limit: 10, Cursor: &Cursor{Key: "a", Direction: Backward}},
+			expected:   " AND id < $2 ORDER BY id DESC LIMIT $3",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			// When
+			clause, args := tc.pagination.Clause("id", 2)
+
+			// Then
+			if strings.TrimSpace(clause) != strings.TrimSpace(tc.expected) {
+				t.Errorf("expected %q, got %q", tc.expected, clause)
+			}
+
+			// One more row than the limit is always fetched
+			if !slices.Contains(args, any(11)) {
+				t.Errorf("expected a limit of 11 in %v", args)
+			}
+		})
+	}
+}
+
+type sortItem struct {
+	id    string
+	value string
+}
+
+func TestPageSortedSlice(t *testing.T) {
+	items := []sortItem{{"a", "2"}, {"b", "1"}, {"c", "2"}, {"d", "3"}, {"e", "1"}}
+	key := func(i sortItem) string { return i.id }
+	value := func(i sortItem) string { return i.value }
+	desc := Sort{Field: "value", Desc: true}
+
+	tests := []struct {
+		name         string
+		pagination   Pagination
+		expected     []string
+		expectedNext *Cursor
+	}{
+		{
+			name:         "PassingCase-TiesAreOrderedByKey",
+			pagination:   Pagination{Limit: 3, Page: 1, Sort: Sort{Field: "value"}},
+			expected:     []string{"b", "e", "a"},
+			expectedNext: &Cursor{Key: "a", Value: "2", Sort: "value"},
+		},
+		{
+			name:         "PassingCase-Descending",
+			pagination:   Pagination{Limit: 2, Page: 1, Sort: desc},
+			expected:     []string{"d", "c"},
+			expectedNext: &Cursor{Key: "c", Value: "2", Sort: "-value"},
+		},
+		{
+			name:         "PassingCase-DescendingForwardWithinATie",
+			pagination:   Pagination{Limit: 2, Sort: desc, Cursor: &Cursor{Key: "c", Value: "2", Sort: "-value"}},
+			expected:     []string{"a", "e"},
+			expectedNext: &Cursor{Key: "e", Value: "1", Sort: "-value"},
+		},
+		{
+			name:         "PassingCase-DescendingBackward",
+			pagination:   Pagination{Limit: 2, Sort: desc, Cursor: &Cursor{Key: "a", Value: "2", Direction: Backward, Sort: "-value"}},
+			expected:     []string{"d", "c"},
+			expectedNext: &Cursor{Key: "c", Value: "2", Sort: "-value"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			// When
+			page := PageSortedSlice(items, key, value, tc.pagination)
+
+			// Then
+			var ids []string
+			for _, item := range page.Items {
+				ids = append(ids, item.id)
+			}
+
+			if !slices.Equal(ids, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, ids)
+			}
+
+			if (page.Next == nil) != (tc.expectedNext == nil) || (page.Next != nil && *page.Next != *tc.expectedNext) {
+				t.Errorf("expected next cursor %+v, got %+v", tc.expectedNext, page.Next)
+			}
+		})
+	}
+}
+
+func TestPaginationSortedClause(t *testing.T) {
+	sort := Column{Name: "created_at", Type: "timestamptz"}
+	id := Column{Name: "id", Type: "uuid"}
+
+	tests := []struct {
+		name       string
+		pagination Pagination
+		expected   string
+	}{
+		{
+			name:       "PassingCase-NoSort",
+			pagination: Pagination{Limit: 10, Page: 1},
+			expected:   " ORDER BY id LIMIT $2 OFFSET $3",
+		},
+		{
+			name:       "PassingCase-Offset",
+			pagination: Pagination{Limit: 10, Page: 1, Sort: Sort{Field: "created_at", Desc: true}},
+			expected:   " ORDER BY created_at DESC, id DESC LIMIT $2 OFFSET $3",
+		},
+		{
+			name:       "PassingCase-Forward",
+			pagination: Pagination{Limit: 10, Sort: Sort{Field: "created_at"}, Cursor: &Cursor{Key: "a", Value: "b"}},
+			expected:   " AND (created_at, id) > ($2::timestamptz, $3::uuid) ORDER BY created_at, id LIMIT $4",
+		},
+		{
+			name:       "PassingCase-DescendingBackward",
+			pagination: Pagination{Limit: 10, Sort: Sort{Field: "created_at", Desc: true}, Cursor: &Cursor{Key: "a", Value: "b", Direction: Backward}},
+			expected:   " AND (created_at, id) > ($2::timestamptz, $3::uuid) ORDER BY created_at, id LIMIT $4",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Given
+			// When
+			clause, _ := tc.pagination.SortedClause(sort, id, 2)
+
+			// Then
+			if clause != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, clause)
+			}
+		})
+	}
+}
+
+func TestPaginationValidate(t *testing.T) {
+	// Given
+	p := Pagination{Limit: 10, Sort: Sort{Field: "message"}, Cursor: &Cursor{Key: "a", Sort: "-created_at"}}
+
+	// When
+	err := p.Validate()
+
+	// Then
+	if err != CursorSortMismatchError {
+		t.Errorf("expected %v, got %v", CursorSortMismatchError, err)
+	}
+}
@@ -0,0 +1,85 @@
+package store
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+var CursorSortMismatchError = errors.New("cursor was created for a different sort")
+
+// MARK: Filters
+type Operator string
+
+const (
+	Eq       Operator = "eq"
+	Contains Operator = "contains"
+	Search   Operator = "search"
+	Gt       Operator = "gt"
+	Gte      Operator = "gte"
+	Lt       Operator = "lt"
+	Lte      Operator = "lte"
+)
+
+/*
+A condition on a listing, e.g., created_at gte 2025-01-01T00:00:00Z
+
+Time is set instead of Value for time fields. Filters are only built from a
+whitelist, stores still reject fields and operators they don't know.
+*/
+type Filter struct {
+	Field string
+	Op    Operator
+	Value string
+	Time  time.Time
+}
+
+// MARK: Sort
+/*
+The field a listing is sorted by, the zero value sorts by id
+*/
+type Sort struct {
+	Field string
+	Desc  bool
+}
+
+/*
+The sort as it is written in a query, e.g., -created_at
+*/
+func (s Sort) String() string {
+	if s.Desc {
+		return "-" + s.Field
+	}
+
+	return s.Field
+}
+
+func ParseSort(s string) Sort {
+	if field, ok := strings.CutPrefix(s, "-"); ok {
+		return Sort{Field: field, Desc: true}
+	}
+
+	return Sort{Field: s}
+}
+
+/*
+Formats a time so that comparing the strings compares the times
+
+Postgres stores microseconds, so nothing is lost between the two
+*/
+func TimeValue(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000000Z07:00")
+}
+
+// MARK: Column
+/*
+A column to sort or seek on, Name can be an expression on one that's
+indexed, e.g., left(message, 256)
+
+Type is the Postgres type cursor values are cast to, so a value that was
+read back from a cursor compares like the column does
+*/
+type Column struct {
+	Name string
+	Type string
+}
@@ -3,9 +3,11 @@ package example
 type ExampleEvent string
 
 const (
-	ExampleCreatedEvent ExampleEvent = "ExampleCreated"
-	ExampleUpdatedEvent ExampleEvent = "ExampleUpdated"
-	ExampleDeletedEvent ExampleEvent = "ExampleDeleted"
+	ExampleCreatedEvent  ExampleEvent = "ExampleCreated"
+	ExampleUpdatedEvent  ExampleEvent = "ExampleUpdated"
+	ExampleDeletedEvent  ExampleEvent = "ExampleDeleted"
+	ExampleRestoredEvent ExampleEvent = "ExampleRestored"
+	ExamplePurgedEvent   ExampleEvent = "ExamplePurged"
 )
 
 type ExampleCreated struct {
@@ -43,3 +45,30 @@ func (e ExampleDeleted) Name() ExampleEvent {
 func (e ExampleDeleted) EntityId() string {
 	return e.Id
 }
+
+type ExampleRestored struct {
+	Id string
+}
+
+func (e ExampleRestored) Name() ExampleEvent {
+	return ExampleRestoredEvent
+}
+
+func (e ExampleRestored) EntityId() string {
+	return e.Id
+}
+
+/*
+The example was removed from the trash for good
+*/
+type ExamplePurged struct {
+	Id string
+}
+
+func (e ExamplePurged) Name() ExampleEvent {
+	return ExamplePurgedEvent
+}
+
+func (e ExamplePurged) EntityId() string {
+	return e.Id
+}
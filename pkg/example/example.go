@@ -1,27 +1,53 @@
 package example
 
 import (
-	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	MaxTitleLength   = 128
+	MaxMessageLength = 4096
+	MaxTags          = 10
+	MaxTagLength     = 32
 )
 
 type Example struct {
-	UserId  string
-	Id      string
+	UserId string
+	Id     string
+	// Optional
+	Title   string
 	Message string
+	// Sorted without duplicates, never nil
+	Tags   []string
+	Status Status
+	// Incremented by the store on every update, used for optimistic concurrency
+	Version int
+	// Set by the store when the example is added
+	CreatedAt time.Time
+	// Set by the store on every write
+	UpdatedAt time.Time
+	// Set while the example is in the trash, nil otherwise
+	DeletedAt *time.Time
+	// Who moved the example to the trash, a user or an administrator
+	DeletedBy string
 }
 
-var EmptyMessageError = errors.New("message length must be greater than 0")
-
+/*
+Creates a draft example without a title or tags
+*/
 func New(userId, msg string) (Example, error) {
-	ok := CannotBeEmpty(msg)
-
-	if !ok {
-		return Example{}, EmptyMessageError
+	if violations := MessageRules.Check("message", msg); len(violations) > 0 {
+		return Example{}, violated(violations...)
 	}
 
 	return Example{
 		UserId:  userId,
 		Message: msg,
+		Tags:    []string{},
+		Status:  Draft,
 	}, nil
 }
 
@@ -29,14 +55,87 @@ func Nil() Example {
 	return Example{}
 }
 
-func (e *Example) SetMessage(msg string) error {
-	ok := CannotBeEmpty(msg)
+func (e Example) Deleted() bool {
+	return e.DeletedAt != nil
+}
 
-	if !ok {
-		return EmptyMessageError
+/*
+How many bytes the example counts against its user's quota, the title,
+message and tags as UTF-8
+*/
+func (e Example) Size() int {
+	size := len(e.Title) + len(e.Message)
+	for _, tag := range e.Tags {
+		size += len(tag)
+	}
+
+	return size
+}
+
+func (e *Example) SetMessage(msg string) error {
+	if violations := MessageRules.Check("message", msg); len(violations) > 0 {
+		return violated(violations...)
 	}
 
 	e.Message = msg
 
 	return nil
 }
+
+/*
+An empty title removes it
+*/
+func (e *Example) SetTitle(title string) error {
+	if violations := TitleRules.Check("title", title); len(violations) > 0 {
+		return violated(violations...)
+	}
+
+	e.Title = title
+
+	return nil
+}
+
+/*
+Replaces the tags, they are lowercased and duplicates are dropped
+*/
+func (e *Example) SetTags(tags []string) error {
+	normalized := make([]string, 0, len(tags))
+
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+
+		if !IsValidTag(tag) {
+			return violated(Violation{Field: "tags", Code: CodeInvalidTag, Message: "tags must be 1-32 lowercase letters, digits or dashes"})
+		}
+
+		normalized = append(normalized, tag)
+	}
+
+	slices.Sort(normalized)
+	normalized = slices.Compact(normalized)
+
+	if len(normalized) > MaxTags {
+		return violated(Violation{Field: "tags", Code: CodeTooManyTags, Message: "an example can have at most 10 tags"})
+	}
+
+	e.Tags = normalized
+
+	return nil
+}
+
+/*
+Moves the example through its lifecycle, draft -> published -> archived
+*/
+func (e *Example) SetStatus(status Status) error {
+	if !status.Valid() {
+		return violated(Violation{Field: "status", Code: CodeInvalidStatus, Message: "status must be draft, published or archived"})
+	}
+
+	if !CanTransition(e.Status, status) {
+		return violated(Violation{Field: "status", Code: CodeInvalidTransition, Message: fmt.Sprintf("status cannot change from %s to %s", e.Status, status)})
+	}
+
+	e.Status = status
+
+	return nil
+}
@@ -0,0 +1,49 @@
+package example
+
+import (
+	"slices"
+	"time"
+)
+
+/*
+An example as it was written at one version
+*/
+type Revision struct {
+	ExampleId string
+	// The example's version when it was written
+	Revision int
+	Title    string
+	Message  string
+	Tags     []string
+	Status   Status
+	// When the version was written
+	CreatedAt time.Time
+}
+
+func NewRevision(e Example) Revision {
+	return Revision{
+		ExampleId: e.Id,
+		Revision:  e.Version,
+		Title:     e.Title,
+		Message:   e.Message,
+		Tags:      slices.Clone(e.Tags),
+		Status:    e.Status,
+		CreatedAt: e.UpdatedAt,
+	}
+}
+
+/*
+Puts back the title, message and tags of r
+
+The status is left alone, reverting can't move an example backwards through
+its lifecycle. The content is checked against today's rules, which may be
+stricter than when r was written.
+*/
+func (e *Example) Revert(r Revision) error {
+	var invalid ValidationError
+	invalid.Collect(e.SetTitle(r.Title))
+	invalid.Collect(e.SetMessage(r.Message))
+	invalid.Collect(e.SetTags(r.Tags))
+
+	return invalid.Err()
+}
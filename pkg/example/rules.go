@@ -1,5 +1,228 @@
 package example
 
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Violation codes, clients match on these rather than on messages
+const (
+	CodeRequired          = "REQUIRED"
+	CodeBlank             = "BLANK"
+	CodeTooLong           = "TOO_LONG"
+	CodeInvalidUTF8       = "INVALID_UTF8"
+	CodeControlCharacter  = "CONTROL_CHARACTER"
+	CodeForbiddenWord     = "FORBIDDEN_WORD"
+	CodeInvalidTag        = "INVALID_TAG"
+	CodeTooManyTags       = "TOO_MANY_TAGS"
+	CodeInvalidStatus     = "INVALID_STATUS"
+	CodeInvalidTransition = "INVALID_TRANSITION"
+)
+
 func CannotBeEmpty(val string) bool {
 	return len(val) > 0
 }
+
+/*
+Lengths are counted in characters, not bytes
+*/
+func CannotBeLongerThan(val string, max int) bool {
+	return utf8.RuneCountInString(val) <= max
+}
+
+/*
+Tags are lowercase letters, digits and dashes
+*/
+func IsValidTag(tag string) bool {
+	if !CannotBeEmpty(tag) || !CannotBeLongerThan(tag, MaxTagLength) {
+		return false
+	}
+
+	for _, r := range tag {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-') {
+			return false
+		}
+	}
+
+	return true
+}
+
+/*
+Staying in the same status is always allowed
+*/
+func CanTransition(from, to Status) bool {
+	return from == to || slices.Contains(transitions[from], to)
+}
+
+// MARK: Rules
+/*
+A single check on a text field
+
+Message finishes a sentence that starts with the field's name, e.g.,
+"length must be greater than 0"
+*/
+type Rule struct {
+	Code    string
+	Message string
+	Valid   func(val string) bool
+}
+
+/*
+Rules are all checked, so every problem with a value is reported at once
+*/
+type Rules []Rule
+
+func (rs Rules) Check(field, val string) []Violation {
+	var violations []Violation
+
+	for _, r := range rs {
+		if !r.Valid(val) {
+			violations = append(violations, Violation{Field: field, Code: r.Code, Message: field + " " + r.Message})
+		}
+	}
+
+	return violations
+}
+
+func NotEmpty() Rule {
+	return Rule{Code: CodeRequired, Message: "length must be greater than 0", Valid: CannotBeEmpty}
+}
+
+/*
+Rejects values that are nothing but whitespace, an empty value is left to NotEmpty
+*/
+func NotBlank() Rule {
+	return Rule{
+		Code:    CodeBlank,
+		Message: "cannot be only whitespace",
+		Valid: func(val string) bool {
+			return val == "" || strings.TrimSpace(val) != ""
+		},
+	}
+}
+
+func MaxLength(max int) Rule {
+	return Rule{
+		Code:    CodeTooLong,
+		Message: fmt.Sprintf("length must be at most %d", max),
+		Valid: func(val string) bool {
+			return CannotBeLongerThan(val, max)
+		},
+	}
+}
+
+func ValidUTF8() Rule {
+	return Rule{Code: CodeInvalidUTF8, Message: "must be valid UTF-8", Valid: utf8.ValidString}
+}
+
+/*
+Newlines and tabs are text, every other control character is rejected
+*/
+func NoControlCharacters() Rule {
+	return Rule{
+		Code:    CodeControlCharacter,
+		Message: "cannot contain control characters",
+		Valid: func(val string) bool {
+			return !strings.ContainsFunc(val, func(r rune) bool {
+				return unicode.IsControl(r) && r != '\n' && r != '\r' && r != '\t'
+			})
+		},
+	}
+}
+
+/*
+Rejects values containing any of words as a whole word, ignoring case
+*/
+func ForbiddenWords(words ...string) Rule {
+	quoted := make([]string, 0, len(words))
+	for _, w := range words {
+		if w = strings.TrimSpace(w); w != "" {
+			quoted = append(quoted, regexp.QuoteMeta(w))
+		}
+	}
+
+	if len(quoted) == 0 {
+		return Rule{Code: CodeForbiddenWord, Message: "contains a forbidden word", Valid: func(string) bool { return true }}
+	}
+
+	pattern := regexp.MustCompile(`(?i)\b(` + strings.Join(quoted, "|") + `)\b`)
+
+	return Rule{
+		Code:    CodeForbiddenWord,
+		Message: "contains a forbidden word",
+		Valid: func(val string) bool {
+			return !pattern.MatchString(val)
+		},
+	}
+}
+
+/*
+The rules every message is checked against
+
+Applications add to them at startup, e.g., with ForbiddenWords
+*/
+var MessageRules = Rules{
+	NotEmpty(),
+	NotBlank(),
+	MaxLength(MaxMessageLength),
+	ValidUTF8(),
+	NoControlCharacters(),
+}
+
+var TitleRules = Rules{
+	MaxLength(MaxTitleLength),
+	ValidUTF8(),
+	NoControlCharacters(),
+}
+
+// MARK: Violations
+type Violation struct {
+	Field   string
+	Code    string
+	Message string
+}
+
+/*
+Every rule an example broke
+*/
+type ValidationError struct {
+	Violations []Violation
+}
+
+func (v ValidationError) Error() string {
+	messages := make([]string, 0, len(v.Violations))
+	for _, violation := range v.Violations {
+		messages = append(messages, violation.Message)
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+/*
+Adds the violations of err when it is a ValidationError, so violations from
+several setters are reported together
+*/
+func (v *ValidationError) Collect(err error) {
+	if other, ok := err.(ValidationError); ok {
+		v.Violations = append(v.Violations, other.Violations...)
+	}
+}
+
+/*
+nil when nothing was violated
+*/
+func (v ValidationError) Err() error {
+	if len(v.Violations) == 0 {
+		return nil
+	}
+
+	return v
+}
+
+func violated(violations ...Violation) error {
+	return ValidationError{Violations: violations}.Err()
+}
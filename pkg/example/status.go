@@ -0,0 +1,25 @@
+package example
+
+type Status string
+
+const (
+	Draft     Status = "draft"
+	Published Status = "published"
+	Archived  Status = "archived"
+)
+
+/*
+The statuses an example can move to from each status
+
+Archived is final, an archived example is kept for reference only
+*/
+var transitions = map[Status][]Status{
+	Draft:     {Published, Archived},
+	Published: {Archived},
+	Archived:  {},
+}
+
+func (s Status) Valid() bool {
+	_, ok := transitions[s]
+	return ok
+}
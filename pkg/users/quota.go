@@ -0,0 +1,64 @@
+package users
+
+import "errors"
+
+var NegativeQuotaError = errors.New("quota limits must be 0 or greater, or -1 for unlimited")
+
+// A limit that is never reached, 0 is a real limit that allows nothing
+const Unlimited = -1
+
+/*
+Limits how much a user can store, each limit is Unlimited or 0 and greater
+*/
+type Quota struct {
+	Examples int
+	Bytes    int
+}
+
+/*
+A quota without limits
+*/
+func NoQuota() Quota {
+	return Quota{Examples: Unlimited, Bytes: Unlimited}
+}
+
+func (q Quota) Validate() error {
+	if q.Examples < Unlimited || q.Bytes < Unlimited {
+		return NegativeQuotaError
+	}
+
+	return nil
+}
+
+/*
+Whether usage is within the quota
+*/
+func (q Quota) Allows(u Usage) bool {
+	return within(u.Examples, q.Examples) && within(u.Bytes, q.Bytes)
+}
+
+func within(used, limit int) bool {
+	return limit == Unlimited || used <= limit
+}
+
+/*
+What a user is storing, examples in the trash don't count
+*/
+type Usage struct {
+	Examples int
+	Bytes    int
+}
+
+/*
+The usage after adding one more example of size bytes
+*/
+func (u Usage) Add(size int) Usage {
+	return Usage{Examples: u.Examples + 1, Bytes: u.Bytes + size}
+}
+
+/*
+The usage after one of the examples changes size by bytes
+*/
+func (u Usage) Grow(bytes int) Usage {
+	return Usage{Examples: u.Examples, Bytes: u.Bytes + bytes}
+}
@@ -0,0 +1,112 @@
+/*
+A stand-in for a remote config server, for local development
+
+Serves config values from a flat JSON file, e.g., {"LOG_LEVEL": "DEBUG"}.
+The file is re-read on every request so values can be changed while it runs.
+
+	GET /{name}           {"Value": "..."}
+	GET /?key=A&key=B     {"Values": {"A": "...", "B": "..."}}
+*/
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const (
+	startingMsg = "STARTING_CONFIG_SERVER"
+	readError   = "READ_CONFIG_ERROR"
+	keyError    = "error"
+	keyAddr     = "addr"
+)
+
+type valueResponse struct {
+	Value string `json:"Value"`
+}
+
+type batchResponse struct {
+	Values map[string]string `json:"Values"`
+}
+
+type configServer struct {
+	file  string
+	token string
+}
+
+func (c configServer) load() (map[string]string, error) {
+	data, err := os.ReadFile(c.file)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string)
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
+
+func (c configServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if len(c.token) != 0 && r.Header.Get("Authorization") != "Bearer "+c.token {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	values, err := c.load()
+	if err != nil {
+		slog.Error(readError, keyError, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	// Batch
+	if keys := r.URL.Query()["key"]; len(keys) > 0 {
+		resp := batchResponse{Values: make(map[string]string)}
+
+		for _, key := range keys {
+			if v, ok := values[key]; ok {
+				resp.Values[key] = v
+			}
+		}
+
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+
+	// Single key
+	v, ok := values[strings.TrimPrefix(r.URL.Path, "/")]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	json.NewEncoder(w).Encode(valueResponse{Value: v})
+}
+
+func main() {
+	var addr, file, token string
+	flag.StringVar(&addr, "addr", ":8090", "address to listen on")
+	flag.StringVar(&file, "file", "config.json", "JSON file with the config values")
+	flag.StringVar(&token, "token", "", "bearer token clients must send, none when empty")
+	flag.Parse()
+
+	slog.Info(startingMsg, keyAddr, addr)
+
+	if err := http.ListenAndServe(addr, configServer{file: file, token: token}); err != nil {
+		slog.Error(startingMsg, keyError, err)
+		os.Exit(1)
+	}
+}
@@ -1,6 +1,11 @@
 DROP TABLE IF EXISTS schemas.users CASCADE;
 DROP TABLE IF EXISTS schemas.examples CASCADE;
+DROP TABLE IF EXISTS schemas.example_revisions;
 DROP TABLE IF EXISTS schemas.auditlog;
+DROP TABLE IF EXISTS schemas.example_usage;
+DROP TABLE IF EXISTS schemas.quotas;
+DROP FUNCTION IF EXISTS schemas.track_example_usage;
+DROP FUNCTION IF EXISTS schemas.example_size;
 DROP SCHEMA IF EXISTS schemas;
 
 CREATE SCHEMA schemas;
@@ -11,8 +16,93 @@ CREATE TABLE schemas.users (
 
 CREATE TABLE schemas.examples (
     id uuid PRIMARY KEY DEFAULT gen_random_uuid() NOT NULL,
-    message VARCHAR(128) NOT NULL,
-    uid uuid NOT NULL REFERENCES schemas.users (id) ON DELETE CASCADE
+    title VARCHAR(128) NOT NULL DEFAULT '',
+    message VARCHAR(4096) NOT NULL,
+    tags TEXT[] NOT NULL DEFAULT '{}',
+    status VARCHAR(16) NOT NULL DEFAULT 'draft' CHECK (status IN ('draft', 'published', 'archived')),
+    uid uuid NOT NULL REFERENCES schemas.users (id) ON DELETE CASCADE,
+    version INTEGER NOT NULL DEFAULT 1,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    -- Set while the example is in the trash, reads skip these rows
+    deleted_at TIMESTAMPTZ,
+    deleted_by uuid,
+    search tsvector GENERATED ALWAYS AS (to_tsvector('english', message)) STORED
+);
+
+CREATE INDEX examples_search_idx ON schemas.examples USING GIN (search);
+CREATE INDEX examples_uid_created_at_idx ON schemas.examples (uid, created_at, id) WHERE deleted_at IS NULL;
+CREATE INDEX examples_uid_updated_at_idx ON schemas.examples (uid, updated_at, id) WHERE deleted_at IS NULL;
+-- Only the start of a message is indexed, an index entry can't hold the longest messages
+CREATE INDEX examples_uid_message_idx ON schemas.examples (uid, left(message, 256), id) WHERE deleted_at IS NULL;
+CREATE INDEX examples_trash_idx ON schemas.examples (id) WHERE deleted_at IS NOT NULL;
+
+-- Every version of an example, written in the same statement as the example
+CREATE TABLE schemas.example_revisions (
+    example_id uuid NOT NULL REFERENCES schemas.examples (id) ON DELETE CASCADE,
+    revision INTEGER NOT NULL,
+    title VARCHAR(128) NOT NULL DEFAULT '',
+    message VARCHAR(4096) NOT NULL,
+    tags TEXT[] NOT NULL DEFAULT '{}',
+    status VARCHAR(16) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (example_id, revision)
+);
+
+-- What each user is storing, examples in the trash don't count
+CREATE TABLE schemas.example_usage (
+    uid uuid PRIMARY KEY REFERENCES schemas.users (id) ON DELETE CASCADE,
+    examples INTEGER NOT NULL DEFAULT 0,
+    bytes BIGINT NOT NULL DEFAULT 0
+);
+
+-- Bytes an example counts against its user's quota, matches example.Size
+CREATE FUNCTION schemas.example_size(title TEXT, message TEXT, tags TEXT[]) RETURNS BIGINT AS $$
+    SELECT octet_length(title) + octet_length(message) + COALESCE((SELECT sum(octet_length(t)) FROM unnest(tags) AS t), 0)
+$$ LANGUAGE SQL IMMUTABLE;
+
+-- Keeps example_usage in step with every write to examples, in the same transaction
+CREATE FUNCTION schemas.track_example_usage() RETURNS trigger AS $$
+DECLARE
+    delta_examples INTEGER := 0;
+    delta_bytes BIGINT := 0;
+BEGIN
+    IF TG_OP IN ('UPDATE', 'DELETE') AND OLD.deleted_at IS NULL THEN
+        delta_examples := delta_examples - 1;
+        delta_bytes := delta_bytes - schemas.example_size(OLD.title, OLD.message, OLD.tags);
+    END IF;
+
+    IF TG_OP IN ('INSERT', 'UPDATE') AND NEW.deleted_at IS NULL THEN
+        delta_examples := delta_examples + 1;
+        delta_bytes := delta_bytes + schemas.example_size(NEW.title, NEW.message, NEW.tags);
+    END IF;
+
+    IF delta_examples = 0 AND delta_bytes = 0 THEN
+        RETURN NULL;
+    END IF;
+
+    -- Deletes only ever follow a write that created the row, and may be part of deleting the user
+    IF TG_OP <> 'DELETE' THEN
+        INSERT INTO schemas.example_usage (uid) VALUES (NEW.uid) ON CONFLICT (uid) DO NOTHING;
+    END IF;
+
+    UPDATE schemas.example_usage
+    SET examples = examples + delta_examples, bytes = bytes + delta_bytes
+    WHERE uid = COALESCE(NEW.uid, OLD.uid);
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER examples_usage AFTER INSERT OR UPDATE OR DELETE ON schemas.examples
+    FOR EACH ROW EXECUTE FUNCTION schemas.track_example_usage();
+
+-- Quotas set by an administrator, everyone else has the configured default
+-- A limit of -1 is unlimited, 0 allows nothing
+CREATE TABLE schemas.quotas (
+    uid uuid PRIMARY KEY REFERENCES schemas.users (id) ON DELETE CASCADE,
+    examples INTEGER NOT NULL CHECK (examples >= -1),
+    bytes BIGINT NOT NULL CHECK (bytes >= -1)
 );
 
 CREATE TABLE schemas.auditlog (